@@ -4,8 +4,12 @@ go 1.25.0
 
 require (
 	github.com/blang/semver v3.5.1+incompatible
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/joho/godotenv v1.5.1
+	github.com/makiuchi-d/gozxing v0.1.1
 	github.com/rhysd/go-github-selfupdate v1.2.3
+	github.com/yuin/gopher-lua v1.1.2
+	golang.org/x/sys v0.13.0
 	gopkg.in/gographics/imagick.v3 v3.7.2
 )
 
@@ -19,5 +23,7 @@ require (
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad // indirect
 	golang.org/x/net v0.0.0-20190404232315-eb5bcb51f2a3 // indirect
 	golang.org/x/oauth2 v0.0.0-20181106182150-f42d05182288 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	google.golang.org/appengine v1.3.0 // indirect
 )
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+)
+
+// RunApply implements `termagick apply <input> --out <file> [--recipe file] [--magick "options"]`.
+// It opens the input, applies the recipe (if any) and then the ImageMagick
+// style options (if any), and saves the result. This is the one-shot,
+// scriptable counterpart of the interactive editor.
+func RunApply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	outPath := fs.String("out", "", "file the result is written to (required)")
+	recipePath := fs.String("recipe", "", "recipe file to apply")
+	magickArgs := fs.String("magick", "", `ImageMagick style options to apply, e.g. "-resize 50% -sharpen 0x1"`)
+	verbose := fs.Bool("v", false, "print the termagick command each magick option was translated to")
+	addTraceFlag(fs)
+	addNoExecFlag(fs)
+	addQuietFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return withExitCode(exitUsage, fmt.Errorf(`usage: termagick apply <input> --out <file> [--recipe file] [--magick "options"]`))
+	}
+	if *outPath == "" {
+		return withExitCode(exitUsage, fmt.Errorf("apply requires --out <file>"))
+	}
+	if *recipePath == "" && *magickArgs == "" {
+		return withExitCode(exitUsage, fmt.Errorf("nothing to apply: pass --recipe and/or --magick"))
+	}
+
+	// Parse everything up front so mistakes are reported before the image is read.
+	var steps []RecipeStep
+	if *recipePath != "" {
+		steps, err = LoadRecipe(*recipePath)
+		if err != nil {
+			return err
+		}
+	}
+	opts, err := ParseMagickArgs(*magickArgs)
+	if err != nil {
+		return withExitCode(exitValidation, err)
+	}
+
+	store := NewMetaStore(Commands)
+	wand, err := LoadImage(positional[0])
+	if err != nil {
+		return withExitCode(exitRead, fmt.Errorf("read %s: %w", positional[0], err))
+	}
+	defer wand.Destroy()
+
+	if err := ApplyRecipe(store, wand, steps); err != nil {
+		return err
+	}
+	applied, err := ApplyMagickArgs(store, wand, opts)
+	if *verbose {
+		for _, step := range applied {
+			fmt.Println(step.String())
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := WriteWand(wand, *outPath); err != nil {
+		return withExitCode(exitWrite, fmt.Errorf("write %s: %w", *outPath, err))
+	}
+	fmt.Printf("Saved to %s\n", *outPath)
+	return nil
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// aspectRatios are the values of the cropAspect command's ratio parameter,
+// in EnumOptions order. A4 stands for every ISO 216 paper size (1:√2).
+var aspectRatios = []string{"1:1", "4:3", "3:2", "16:9", "A4"}
+
+// aspectOrientations are the values of the cropAspect command's orientation
+// parameter, in EnumOptions order.
+var aspectOrientations = []string{"AUTO", "LANDSCAPE", "PORTRAIT"}
+
+// cropGravities are the values of the cropAspect command's gravity
+// parameter, in EnumOptions order.
+var cropGravities = []string{"CENTER", "NORTH", "SOUTH", "EAST", "WEST", "NORTHWEST", "NORTHEAST", "SOUTHWEST", "SOUTHEAST"}
+
+// aspectRatioValue returns the ratio of the long side to the short side of
+// a preset.
+func aspectRatioValue(name string) float64 {
+	if name == "A4" {
+		return 297.0 / 210.0
+	}
+	var a, b float64
+	fmt.Sscanf(name, "%g:%g", &a, &b)
+	return a / b
+}
+
+// aspectCropRect returns the largest rectangle of the given ratio (long side
+// over short side) that fits in a width x height image, and its offset when
+// placed according to gravity. The rectangle is landscape or portrait as
+// orientation says; AUTO follows the image.
+func aspectCropRect(width, height uint, ratio float64, orientation, gravity string) (uint, uint, int, int) {
+	landscape := width >= height
+	switch orientation {
+	case "LANDSCAPE":
+		landscape = true
+	case "PORTRAIT":
+		landscape = false
+	}
+	if !landscape {
+		ratio = 1 / ratio
+	}
+	w, h := float64(width), float64(height)
+	cw, ch := w, math.Round(w/ratio)
+	if ch > h {
+		cw, ch = math.Round(h*ratio), h
+	}
+	cropW, cropH := max(1, uint(cw)), max(1, uint(ch))
+	x, y := gravityOffset(int(width), int(height), int(cropW), int(cropH), gravity)
+	return cropW, cropH, x, y
+}
+
+// gravityOffset returns where an inner x innerH rectangle goes inside an
+// outer one when placed according to gravity (one of cropGravities). The
+// offset is negative where the inner rectangle is the larger one.
+func gravityOffset(outerW, outerH, innerW, innerH int, gravity string) (int, int) {
+	x, y := (outerW-innerW)/2, (outerH-innerH)/2
+	if strings.Contains(gravity, "WEST") {
+		x = 0
+	} else if strings.Contains(gravity, "EAST") {
+		x = outerW - innerW
+	}
+	if strings.HasPrefix(gravity, "NORTH") {
+		y = 0
+	} else if strings.HasPrefix(gravity, "SOUTH") {
+		y = outerH - innerH
+	}
+	return x, y
+}
+
+// CropToAspect crops the current image of wand to the largest rectangle of
+// the named aspect ratio preset, anchored by gravity.
+func CropToAspect(wand *imagick.MagickWand, ratio, orientation, gravity string) error {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if w == 0 || h == 0 {
+		return fmt.Errorf("image has zero dimensions")
+	}
+	cw, ch, x, y := aspectCropRect(w, h, aspectRatioValue(ratio), orientation, gravity)
+	if err := wand.CropImage(cw, ch, x, y); err != nil {
+		return fmt.Errorf("failed to crop: %w", err)
+	}
+	return wand.SetImagePage(cw, ch, 0, 0)
+}
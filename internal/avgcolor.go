@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// avgSampleSize bounds the longer side of the copy averaged; the mean of a
+// point sample this size differs from the full image's by well under one
+// 8-bit level.
+const avgSampleSize = 256
+
+// rgbMean accumulates an alpha-weighted mean color.
+type rgbMean struct {
+	r, g, b, weight float64
+}
+
+func (m *rgbMean) add(r, g, b, a byte) {
+	w := float64(a) / 255
+	m.r += float64(r) * w
+	m.g += float64(g) * w
+	m.b += float64(b) * w
+	m.weight += w
+}
+
+// hex returns the mean as #rrggbb; fully transparent areas give black.
+func (m rgbMean) hex() string {
+	if m.weight == 0 {
+		return "#000000"
+	}
+	c := func(v float64) int { return int(math.Round(v / m.weight)) }
+	return fmt.Sprintf("#%02x%02x%02x", c(m.r), c(m.g), c(m.b))
+}
+
+// distance is how far apart two means are, in 8-bit RGB units.
+func (m rgbMean) distance(o rgbMean) float64 {
+	if m.weight == 0 || o.weight == 0 {
+		return 0
+	}
+	dr := m.r/m.weight - o.r/o.weight
+	dg := m.g/m.weight - o.g/o.weight
+	db := m.b/m.weight - o.b/o.weight
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// AverageColor returns the mean color of the current image of wand and CSS
+// for a placeholder: the mean as background-color and a two-stop gradient
+// between the halves of the image, along whichever axis differs more.
+// Transparent pixels count in proportion to their alpha.
+func AverageColor(wand *imagick.MagickWand) (string, string, error) {
+	img := wand.GetImage()
+	defer img.Destroy()
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	if w == 0 || h == 0 {
+		return "", "", fmt.Errorf("image has zero dimensions")
+	}
+	if long := max(w, h); long > avgSampleSize {
+		scale := float64(avgSampleSize) / float64(long)
+		w, h = max(1, uint(float64(w)*scale)), max(1, uint(float64(h)*scale))
+		if err := img.SampleImage(w, h); err != nil {
+			return "", "", fmt.Errorf("failed to sample image: %w", err)
+		}
+	}
+	px, err := img.ExportImagePixels(0, 0, w, h, "RGBA", imagick.PIXEL_CHAR)
+	if err != nil {
+		return "", "", fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	rgba, ok := px.([]byte)
+	if !ok {
+		return "", "", fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+
+	var all, top, bottom, left, right rgbMean
+	for y := uint(0); y < h; y++ {
+		for x := uint(0); x < w; x++ {
+			i := (y*w + x) * 4
+			r, g, b, a := rgba[i], rgba[i+1], rgba[i+2], rgba[i+3]
+			all.add(r, g, b, a)
+			if y < h/2 {
+				top.add(r, g, b, a)
+			} else {
+				bottom.add(r, g, b, a)
+			}
+			if x < w/2 {
+				left.add(r, g, b, a)
+			} else {
+				right.add(r, g, b, a)
+			}
+		}
+	}
+
+	gradient := fmt.Sprintf("linear-gradient(to bottom, %s, %s)", top.hex(), bottom.hex())
+	if left.distance(right) > top.distance(bottom) {
+		gradient = fmt.Sprintf("linear-gradient(to right, %s, %s)", left.hex(), right.hex())
+	}
+	css := strings.Join([]string{
+		"background-color: " + all.hex() + ";",
+		"background-image: " + gradient + ";",
+	}, "\n")
+	return all.hex(), css, nil
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/aztec"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/oned/rss"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// scannedCode is one symbol found by ScanCodes.
+type scannedCode struct {
+	Type    string
+	Payload string
+}
+
+// codeReaders return the decoders ScanCodes tries after the QR codes. Each
+// finds at most one symbol.
+var codeReaders = []func() gozxing.Reader{
+	func() gozxing.Reader { return oned.NewMultiFormatUPCEANReader(nil) },
+	oned.NewCode128Reader,
+	oned.NewCode39Reader,
+	oned.NewCode93Reader,
+	oned.NewITFReader,
+	oned.NewCodaBarReader,
+	rss.NewRSS14Reader,
+	func() gozxing.Reader { return datamatrix.NewDataMatrixReader() },
+	func() gozxing.Reader { return aztec.NewAztecReader() },
+}
+
+// ScanCodes decodes the QR codes and barcodes in the current image of wand:
+// any number of QR codes, and one each of EAN/UPC, Code 128/39/93, ITF,
+// Codabar, DataBar, Data Matrix and Aztec symbols.
+func ScanCodes(wand *imagick.MagickWand) ([]scannedCode, error) {
+	img := wand.GetImage()
+	defer img.Destroy()
+	// Flatten transparency onto white: codes are dark on light.
+	if img.GetImageAlphaChannel() {
+		if err := img.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE); err != nil {
+			return nil, fmt.Errorf("failed to remove alpha: %w", err)
+		}
+	}
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	px, err := img.ExportImagePixels(0, 0, w, h, "I", imagick.PIXEL_CHAR)
+	if err != nil {
+		return nil, fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	gray, ok := px.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(&image.Gray{Pix: gray, Stride: int(w), Rect: image.Rect(0, 0, int(w), int(h))})
+	if err != nil {
+		return nil, fmt.Errorf("failed to binarize image: %w", err)
+	}
+
+	// A reader that finds nothing returns an error, which only means there is
+	// no symbol of its kind.
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	var codes []scannedCode
+	seen := map[scannedCode]bool{}
+	add := func(r *gozxing.Result) {
+		c := scannedCode{Type: r.GetBarcodeFormat().String(), Payload: r.GetText()}
+		if !seen[c] {
+			seen[c] = true
+			codes = append(codes, c)
+		}
+	}
+	if results, err := multiqr.NewQRCodeMultiReader().DecodeMultiple(bitmap, hints); err == nil {
+		for _, r := range results {
+			add(r)
+		}
+	}
+	for _, newReader := range codeReaders {
+		if r, err := newReader().Decode(bitmap, hints); err == nil {
+			add(r)
+		}
+	}
+	return codes, nil
+}
+
+// printScannedCodes prints the symbols found by ScanCodes.
+func printScannedCodes(codes []scannedCode) {
+	if len(codes) == 0 {
+		fmt.Println("No QR code or barcode found.")
+		return
+	}
+	for _, c := range codes {
+		fmt.Printf("%s: %s\n", c.Type, c.Payload)
+	}
+}
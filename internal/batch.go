@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// stringsFlag is a flag that may be given several times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// RunBatch implements
+// `termagick batch <dir> --recipe <file> --out <dir> [--include glob]... [--exclude glob]... [--format ext] [--jobs n]`.
+// It walks dir recursively, applies the recipe to every matching file and
+// writes the results under the output directory with the same relative paths,
+// then prints a summary. Failed files are reported and do not stop the run.
+// With --jobs, several files are processed at once, each in its own wand.
+func RunBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	outDir := fs.String("out", "", "directory results are written to, mirroring the input tree (required)")
+	recipePath := fs.String("recipe", "", "recipe file applied to every file (required)")
+	format := fs.String("format", "", "convert results to this format, e.g. webp (default: keep each file's format)")
+	var include, exclude stringsFlag
+	fs.Var(&include, "include", "only process files whose name or relative path matches this glob (repeatable; default: all images)")
+	fs.Var(&exclude, "exclude", "skip files or directories whose name or relative path matches this glob (repeatable)")
+	jobs := fs.Int("jobs", 1, "number of files processed in parallel (0: one per CPU core)")
+	addTraceFlag(fs)
+	addNoExecFlag(fs)
+	addQuietFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return withExitCode(exitUsage, fmt.Errorf("usage: termagick batch <dir> --recipe <file> --out <dir> [--include glob] [--exclude glob] [--format ext] [--jobs n]"))
+	}
+	if *outDir == "" || *recipePath == "" {
+		return withExitCode(exitUsage, fmt.Errorf("batch requires --recipe <file> and --out <dir>"))
+	}
+	if *jobs < 0 {
+		return withExitCode(exitUsage, fmt.Errorf("--jobs must be 0 or more"))
+	}
+	for _, g := range append(append([]string(nil), include...), exclude...) {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return withExitCode(exitUsage, fmt.Errorf("invalid glob %q: %w", g, err))
+		}
+	}
+
+	steps, err := LoadRecipe(*recipePath)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.Abs(positional[0])
+	if err != nil {
+		return fmt.Errorf("resolve input dir: %w", err)
+	}
+	absOut, err := filepath.Abs(*outDir)
+	if err != nil {
+		return fmt.Errorf("resolve output dir: %w", err)
+	}
+
+	files, err := batchFiles(root, absOut, include, exclude)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return withExitCode(exitRead, fmt.Errorf("no files in %s match", root))
+	}
+	ext := strings.TrimPrefix(strings.ToLower(*format), ".")
+
+	workers := *jobs
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+	if workers > 1 {
+		// ImageMagick parallelizes single operations across all cores; share
+		// them between the workers instead of oversubscribing.
+		imagick.SetResourceLimit(imagick.RESOURCE_THREAD, uint64(max(1, runtime.NumCPU()/workers)))
+	}
+
+	store := NewMetaStore(Commands)
+	start := time.Now()
+	var (
+		mu     sync.Mutex
+		done   int
+		failed []string
+		errs   []error
+		wg     sync.WaitGroup
+	)
+	queue := make(chan string)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range queue {
+				out := filepath.Join(absOut, rel)
+				if ext != "" {
+					out = strings.TrimSuffix(out, filepath.Ext(out)) + "." + ext
+				}
+				err := withExitCode(exitWrite, os.MkdirAll(filepath.Dir(out), 0755))
+				if err == nil {
+					err = exportFile(store, steps, filepath.Join(root, rel), out)
+				}
+				mu.Lock()
+				done++
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[%d/%d] %s: %v\n", done, len(files), rel, err)
+					failed = append(failed, rel)
+					errs = append(errs, err)
+				} else {
+					fmt.Printf("[%d/%d] %s\n", done, len(files), rel)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, rel := range files {
+		queue <- rel
+	}
+	close(queue)
+	wg.Wait()
+	sort.Strings(failed)
+
+	fmt.Printf("\nProcessed %d files in %s: %d succeeded, %d failed.\n", len(files), time.Since(start).Round(time.Millisecond), len(files)-len(failed), len(failed))
+	if len(failed) > 0 {
+		fmt.Println("Failed:")
+		for _, rel := range failed {
+			fmt.Printf("  %s\n", rel)
+		}
+		return withExitCode(commonExitCode(errs), fmt.Errorf("%d of %d files failed", len(failed), len(files)))
+	}
+	return nil
+}
+
+// batchFiles returns the paths, relative to root, of the files batch should
+// process. The output directory is skipped when it lies inside root.
+func batchFiles(root, outDir string, include, exclude []string) ([]string, error) {
+	matches := func(globs []string, rel string) bool {
+		for _, g := range globs {
+			if ok, _ := filepath.Match(g, filepath.Base(rel)); ok {
+				return true
+			}
+			if ok, _ := filepath.Match(g, filepath.ToSlash(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if d.IsDir() {
+			// Backed-up originals are not part of the batch.
+			if path == outDir || d.Name() == originalsDir || matches(exclude, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matches(exclude, rel) {
+			return nil
+		}
+		if len(include) > 0 && !matches(include, rel) || len(include) == 0 && !isImageFile(path) {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, withExitCode(exitRead, fmt.Errorf("walk %s: %w", root, err))
+	}
+	return files, nil
+}
@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Image buffers.
+//
+// A session can hold several open images, like an editor's buffers. The
+// active buffer lives in the Session's own fields (Wand, Path, History,
+// Layers...) so every command keeps working on it unchanged; the other
+// buffers are parked in s.buffers until they are switched to. Any argument of
+// the form buffer:<name> (or buffer:<number>) refers to another open image,
+// e.g. as the source of composite or addLayer, without reading it from disk.
+
+// bufferPrefix marks an argument that names an open buffer instead of a file.
+const bufferPrefix = "buffer:"
+
+// buffer is the per-image state of an open image. For the active buffer only
+// name is meaningful; the rest lives in the Session fields.
+type buffer struct {
+	name        string
+	wand        *imagick.MagickWand
+	path        string
+	history     []RecipeStep
+	layers      []*Layer
+	activeLayer int
+	jpegFile    string
+	modified    bool
+}
+
+// release frees a parked buffer's images and files.
+func (b *buffer) release() {
+	for _, l := range b.layers {
+		l.Wand.Destroy()
+	}
+	b.layers = nil
+	if b.wand != nil {
+		b.wand.Destroy()
+		b.wand = nil
+	}
+	if b.jpegFile != "" {
+		os.Remove(b.jpegFile)
+		b.jpegFile = ""
+	}
+}
+
+// park moves the active image state into its buffer slot, leaving the
+// session without an image.
+func (s *Session) park() {
+	if len(s.buffers) == 0 {
+		return
+	}
+	b := s.buffers[s.current]
+	b.wand, b.path, b.history = s.Wand, s.Path, s.History
+	b.layers, b.activeLayer, b.jpegFile, b.modified = s.Layers, s.ActiveLayer, s.jpegFile, s.modified
+	s.Wand, s.Path, s.History = nil, "", nil
+	s.Layers, s.ActiveLayer, s.jpegFile, s.modified = nil, 0, "", false
+}
+
+// activate makes buffer i the active one; the current state must be parked.
+func (s *Session) activate(i int) {
+	b := s.buffers[i]
+	s.current = i
+	s.Wand, s.Path, s.History = b.wand, b.path, b.history
+	s.Layers, s.ActiveLayer, s.jpegFile, s.modified = b.layers, b.activeLayer, b.jpegFile, b.modified
+	b.wand, b.path, b.history, b.layers, b.jpegFile, b.modified = nil, "", nil, nil, "", false
+}
+
+// nameBuffer gives the active buffer a name derived from base that no other
+// buffer uses, creating the buffer entry for the first image of the session.
+func (s *Session) nameBuffer(base string) {
+	if len(s.buffers) == 0 {
+		s.buffers = []*buffer{{}}
+		s.current = 0
+	}
+	name := base
+	for n := 2; s.findBuffer(name) >= 0 && s.findBuffer(name) != s.current; n++ {
+		name = fmt.Sprintf("%s<%d>", base, n)
+	}
+	s.buffers[s.current].name = name
+}
+
+// findBuffer returns the index of the buffer with the given name or 1-based
+// number, or -1.
+func (s *Session) findBuffer(ref string) int {
+	for i, b := range s.buffers {
+		if b.name == ref {
+			return i
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 && n <= len(s.buffers) {
+		return n - 1
+	}
+	return -1
+}
+
+// BufferName returns the name of the active buffer ("" with no image).
+func (s *Session) BufferName() string {
+	if len(s.buffers) == 0 {
+		return ""
+	}
+	return s.buffers[s.current].name
+}
+
+// OpenBuffer loads path into a new buffer and makes it active, keeping the
+// previously active image open.
+func (s *Session) OpenBuffer(path string) error {
+	wand, err := LoadImage(path)
+	if err != nil {
+		return err
+	}
+	if s.Wand != nil {
+		s.park()
+		s.buffers = append(s.buffers, &buffer{})
+		s.current = len(s.buffers) - 1
+	}
+	s.replace(wand, path)
+	return nil
+}
+
+// CycleBuffer activates the buffer delta positions away, wrapping around, and
+// reports whether there was another buffer to switch to.
+func (s *Session) CycleBuffer(delta int) bool {
+	n := len(s.buffers)
+	if n < 2 {
+		return false
+	}
+	s.park()
+	s.activate(((s.current+delta)%n + n) % n)
+	return true
+}
+
+// CloseBuffer releases the active image and activates the next buffer, if any.
+func (s *Session) CloseBuffer() {
+	if len(s.buffers) == 0 {
+		return
+	}
+	s.park()
+	s.buffers[s.current].release()
+	s.buffers = append(s.buffers[:s.current], s.buffers[s.current+1:]...)
+	if len(s.buffers) == 0 {
+		s.current = 0
+		return
+	}
+	s.activate(s.current % len(s.buffers))
+}
+
+// Unsaved returns the names of the open images with changes that have not
+// been saved.
+func (s *Session) Unsaved() []string {
+	var names []string
+	for i, b := range s.buffers {
+		if (i == s.current && s.modified) || (i != s.current && b.modified) {
+			names = append(names, b.name)
+		}
+	}
+	if len(s.buffers) == 0 && s.modified {
+		names = append(names, "untitled")
+	}
+	return names
+}
+
+// releaseBuffers frees every parked buffer; the active one is released by Close.
+func (s *Session) releaseBuffers() {
+	for i, b := range s.buffers {
+		if i != s.current {
+			b.release()
+		}
+	}
+	s.buffers = nil
+	s.current = 0
+}
+
+// listBuffers prints the open images, marking the active one.
+func (s *Session) listBuffers() {
+	if len(s.buffers) == 0 {
+		fmt.Println("no open images")
+		return
+	}
+	for i, b := range s.buffers {
+		marker, wand, path := " ", b.wand, b.path
+		if i == s.current {
+			marker, wand, path = "*", s.Wand, s.Path
+		}
+		if path == "" {
+			path = "(generated)"
+		}
+		fmt.Printf("%s %d: %-20s %dx%d  %s\n", marker, i+1, b.name, wand.GetImageWidth(), wand.GetImageHeight(), path)
+	}
+}
+
+// resolveBufferArgs replaces buffer:<name> arguments with an ImageMagick
+// memory register (mpr:) holding that buffer's image, so commands that read
+// a file read the open image instead. Other arguments are returned unchanged.
+// The registers hold copies of the images; release frees them and must be
+// called once the command has run.
+func (s *Session) resolveBufferArgs(args []string) (resolved []string, release func(), err error) {
+	var out, keys []string
+	release = func() {
+		for _, key := range keys {
+			deleteImageRegistry(key)
+		}
+	}
+	for i, a := range args {
+		if !strings.HasPrefix(a, bufferPrefix) {
+			continue
+		}
+		ref := strings.TrimPrefix(a, bufferPrefix)
+		idx := s.findBuffer(ref)
+		if idx < 0 {
+			release()
+			return nil, nil, fmt.Errorf("no open image named %q", ref)
+		}
+		src, err := s.bufferImage(idx)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		key := fmt.Sprintf("termagick-buffer-%d", idx+1)
+		reg := "mpr:" + key
+		err = src.WriteImages(reg, true)
+		src.Destroy()
+		keys = append(keys, key)
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("buffer %s: %w", ref, err)
+		}
+		if out == nil {
+			out = append([]string(nil), args...)
+		}
+		out[i] = reg
+	}
+	if out == nil {
+		return args, release, nil
+	}
+	return out, release, nil
+}
+
+// bufferImage returns a new wand with buffer idx's image, layers flattened.
+func (s *Session) bufferImage(idx int) (*imagick.MagickWand, error) {
+	if idx == s.current {
+		return s.Display().Clone(), nil
+	}
+	b := s.buffers[idx]
+	if len(b.layers) == 0 {
+		return b.wand.Clone(), nil
+	}
+	return (&Session{Wand: b.wand, Layers: b.layers}).compositeLayers()
+}
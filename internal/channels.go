@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Channel separation.
+//
+// separateChannel opens channels of the active image as new grayscale
+// buffers, which every command can then edit on its own: denoise only the
+// chroma of a photo, sharpen only its lightness, retouch a mask. The
+// lightness and chroma channels come from CIELAB. combineChannels merges
+// three grayscale buffers (or files), plus an optional alpha, back into one
+// color image in a new buffer.
+
+// separateChannels are the values of separateChannel's channel parameter, in
+// EnumOptions order. RGB and LAB separate all three channels at once.
+var separateChannels = []string{"RED", "GREEN", "BLUE", "ALPHA", "LUMINANCE", "LAB_A", "LAB_B", "RGB", "LAB"}
+
+// combineSpaces are the values of combineChannels' colorspace parameter.
+var combineSpaces = []string{"RGB", "LAB"}
+
+// channelPlane names one channel to export: its pixel map letter after any
+// colorspace conversion, and the suffix of the buffer it is opened in.
+type channelPlane struct {
+	pmap, suffix string
+}
+
+var channelPlanes = map[string][]channelPlane{
+	"RED":       {{"R", "red"}},
+	"GREEN":     {{"G", "green"}},
+	"BLUE":      {{"B", "blue"}},
+	"ALPHA":     {{"A", "alpha"}},
+	"LUMINANCE": {{"R", "L"}},
+	"LAB_A":     {{"G", "a"}},
+	"LAB_B":     {{"B", "b"}},
+	"RGB":       {{"R", "red"}, {"G", "green"}, {"B", "blue"}},
+	"LAB":       {{"R", "L"}, {"G", "a"}, {"B", "b"}},
+}
+
+// SeparateChannels returns grayscale images of the chosen channels of the
+// current image of wand, with the buffer name suffix for each.
+func SeparateChannels(wand *imagick.MagickWand, channel string) ([]*imagick.MagickWand, []string, error) {
+	planes, ok := channelPlanes[channel]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown channel %q", channel)
+	}
+	img := wand.GetImage()
+	defer img.Destroy()
+	if channel == "ALPHA" && !img.GetImageAlphaChannel() {
+		return nil, nil, fmt.Errorf("image has no alpha channel")
+	}
+	switch channel {
+	case "LUMINANCE", "LAB_A", "LAB_B", "LAB":
+		if err := img.TransformImageColorspace(imagick.COLORSPACE_LAB); err != nil {
+			return nil, nil, fmt.Errorf("failed to convert to Lab: %w", err)
+		}
+	}
+
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	var out []*imagick.MagickWand
+	var suffixes []string
+	for _, p := range planes {
+		// Floats keep the full precision of the channel.
+		px, err := img.ExportImagePixels(0, 0, w, h, p.pmap, imagick.PIXEL_FLOAT)
+		if err == nil {
+			gray := imagick.NewMagickWand()
+			if err = gray.ConstituteImage(w, h, "I", imagick.PIXEL_FLOAT, px); err == nil {
+				out = append(out, gray)
+				suffixes = append(suffixes, p.suffix)
+				continue
+			}
+			gray.Destroy()
+		}
+		for _, o := range out {
+			o.Destroy()
+		}
+		return nil, nil, fmt.Errorf("failed to extract channel %s: %w", p.suffix, err)
+	}
+	return out, suffixes, nil
+}
+
+// CombineChannels reads three grayscale images, and optionally an alpha
+// image, from paths and merges them into one sRGB image. With lab set the
+// inputs are the L, a and b channels.
+func CombineChannels(paths []string, lab bool) (*imagick.MagickWand, error) {
+	src := imagick.NewMagickWand()
+	defer src.Destroy()
+	var width, height uint
+	for i, p := range paths {
+		frame := imagick.NewMagickWand()
+		if err := frame.ReadImage(p); err != nil {
+			frame.Destroy()
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		frame.SetFirstIterator()
+		single := frame.GetImage()
+		frame.Destroy()
+		if i == 0 {
+			width, height = single.GetImageWidth(), single.GetImageHeight()
+		} else if single.GetImageWidth() != width || single.GetImageHeight() != height {
+			single.Destroy()
+			return nil, fmt.Errorf("channel %d is %dx%d, not %dx%d like the first", i+1, single.GetImageWidth(), single.GetImageHeight(), width, height)
+		}
+		err := src.AddImage(single)
+		single.Destroy()
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", p, err)
+		}
+	}
+
+	space := imagick.COLORSPACE_SRGB
+	if lab {
+		space = imagick.COLORSPACE_LAB
+	}
+	src.ResetIterator()
+	combined := src.CombineImages(space)
+	if combined == nil {
+		return nil, fmt.Errorf("combining channels failed")
+	}
+	if lab {
+		if err := combined.TransformImageColorspace(imagick.COLORSPACE_SRGB); err != nil {
+			combined.Destroy()
+			return nil, fmt.Errorf("failed to convert from Lab: %w", err)
+		}
+	}
+	return combined, nil
+}
+
+// openWand opens wand as a new buffer named after base and makes it active.
+func (s *Session) openWand(wand *imagick.MagickWand, base string) {
+	if s.Wand != nil {
+		s.park()
+		s.buffers = append(s.buffers, &buffer{})
+		s.current = len(s.buffers) - 1
+	}
+	s.replace(wand, "")
+	s.nameBuffer(base)
+}
+
+// applySeparateChannel runs separateChannel with normalized arguments.
+func (s *Session) applySeparateChannel(args []string) error {
+	if s.Wand == nil {
+		return fmt.Errorf("no image loaded")
+	}
+	idx, err := strconv.Atoi(args[0])
+	if err != nil || idx < 0 || idx >= len(separateChannels) {
+		return fmt.Errorf("invalid channel %q", args[0])
+	}
+	planes, suffixes, err := SeparateChannels(s.Display(), separateChannels[idx])
+	if err != nil {
+		return err
+	}
+	base := s.BufferName()
+	for i, plane := range planes {
+		s.openWand(plane, base+"-"+suffixes[i])
+		fmt.Printf("Opened %s\n", s.BufferName())
+	}
+	return nil
+}
+
+// applyCombineChannels runs combineChannels with normalized arguments.
+func (s *Session) applyCombineChannels(args []string) error {
+	idx, err := strconv.Atoi(args[0])
+	if err != nil || idx < 0 || idx >= len(combineSpaces) {
+		return fmt.Errorf("invalid colorspace %q", args[0])
+	}
+	resolved, release, err := s.resolveBufferArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	defer release()
+	paths := resolved[:3]
+	if len(resolved) > 3 && resolved[3] != "" {
+		paths = resolved
+	}
+	combined, err := CombineChannels(paths, combineSpaces[idx] == "LAB")
+	if err != nil {
+		return err
+	}
+	s.openWand(combined, "combined")
+	fmt.Printf("Opened %s\n", s.BufferName())
+	return nil
+}
@@ -2,83 +2,264 @@ package internal
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
 
+// keyBinding is an interactive key and the action it triggers. Key is the
+// default; the [keys] section of the config file can bind the action to
+// another key.
+type keyBinding struct {
+	Action string
+	Key    rune
+	Help   string
+}
+
+// keyBindings lists the interactive keys in the order usage shows them.
+var keyBindings = []keyBinding{
+	{"command", '/', "select and apply command"},
+	{"open", 'o', "open another image in a new buffer"},
+	{"next_image", '\t', "switch to the next open image"},
+	{"close_image", 'c', "close the current image"},
+	{"prev_frame", '[', "previous frame (animations)"},
+	{"next_frame", ']', "next frame (animations)"},
+	{"all_frames", 'a', "toggle applying commands to all frames"},
+	{"repeat", '.', "repeat the last command"},
+	{"history", 'H', "browse command history to re-run or tweak a step"},
+	{"info", 'i', "show the image info panel"},
+	{"record", 'r', "start/stop recording a macro"},
+	{"play", 'p', "play a saved macro"},
+	{"save", 's', "save current image"},
+	{"update", 'u', "check for updates"},
+	{"help", 'h', "show this help message"},
+	{"quit", 'q', "quit"},
+}
+
+// findKeyBinding returns the binding for action, or nil.
+func findKeyBinding(action string) *keyBinding {
+	for i := range keyBindings {
+		if keyBindings[i].Action == action {
+			return &keyBindings[i]
+		}
+	}
+	return nil
+}
+
+// boundKey returns the key currently bound to the action.
+func boundKey(action string) rune {
+	if k, ok := config.Keys[action]; ok {
+		return k
+	}
+	return findKeyBinding(action).Key
+}
+
+// dispatchKey maps a pressed key to the default key of the action bound to
+// it, which is what the interactive loop switches on. The default key of a
+// rebound action no longer triggers it.
+func dispatchKey(r rune) rune {
+	for _, b := range keyBindings {
+		if boundKey(b.Action) == r {
+			return b.Key
+		}
+	}
+	for _, b := range keyBindings {
+		if b.Key == r {
+			return 0
+		}
+	}
+	return r
+}
+
+// keyName returns a printable name for a key.
+func keyName(r rune) string {
+	switch r {
+	case '\t':
+		return "Tab"
+	case ' ':
+		return "Space"
+	}
+	return string(r)
+}
+
+// parseKeyName is the inverse of keyName.
+func parseKeyName(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "tab":
+		return '\t', nil
+	case "space":
+		return ' ', nil
+	}
+	if r := []rune(s); len(r) == 1 {
+		return r[0], nil
+	}
+	return 0, fmt.Errorf("invalid key %q", s)
+}
+
 func usage() {
 	fmt.Println("Commands available:")
-	fmt.Println("  /  - select and apply command")
-	fmt.Println("  o  - open another image at runtime")
-	fmt.Println("  s  - save current image")
-	fmt.Println("  u  - check for updates")
-	fmt.Println("  h  - show this help message")
-	fmt.Println("  q  - quit")
+	for _, b := range keyBindings {
+		fmt.Printf("  %-3s - %s\n", keyName(boundKey(b.Action)), b.Help)
+	}
+}
+
+// subcommands maps a first command-line argument to a non-interactive entry
+// point. Each receives the remaining arguments and runs with ImageMagick
+// already initialized.
+var subcommands = map[string]func(args []string) error{
+	"apply":             RunApply,
+	"batch":             RunBatch,
+	"check-orientation": RunCheckOrientation,
+	"hotfolder":         RunHotfolder,
+	"rpc":               RunRPC,
+	"shift-dates":       RunShiftDates,
+	"thumbnail":         RunThumbnail,
+	"verify-against":    RunVerifyAgainst,
+	"watch":             RunWatch,
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments (the standard flag package stops at the first positional) and
+// returns the positional arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, withExitCode(exitUsage, err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
 }
 
 func RunCLI() {
-	var inputImagePath string
+	// Put the terminal back if anything below panics mid-preview or while
+	// keys are read raw.
+	guardTerminal()
+	defer recoverTerminal()
+	if err := LoadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v (using defaults)\n", err)
+	}
+	if err := LoadScripts(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	if err := LoadUserMeta(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	// Clean up after termagick processes that crashed or were killed.
+	sweepStaleTempDirs()
+
 	if len(os.Args) >= 2 {
-		inputImagePath = os.Args[1]
-	} else {
-		// Show usage information if no input image path is provided.
-		inputImagePath = ""
+		if run, ok := subcommands[os.Args[1]]; ok {
+			imagick.Initialize()
+			restore := handleTermination()
+			err := run(os.Args[2:])
+			restore()
+			removeSessionTempDir()
+			imagick.Terminate()
+			if err != nil && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+			}
+			os.Exit(exitCode(err))
+		}
 	}
 
-	// Use in-code commands metadata (compile-time)
-	store := NewMetaStore(Commands)
+	fs := flag.NewFlagSet("termagick", flag.ExitOnError)
+	webAddr := fs.String("web-preview", "", "serve a live browser preview of the current image on this address (e.g. :8787)")
+	addTraceFlag(fs)
+	addNoExecFlag(fs)
+	positional, _ := parseInterspersed(fs, os.Args[1:])
 
 	imagick.Initialize()
 	defer imagick.Terminate()
+	// Windows consoles only interpret the escape sequences previews and
+	// prompts use once asked to.
+	defer setupConsole()()
+	// Ctrl-C cancels the command being applied instead of ending the session.
+	defer handleInterrupts()()
+	// Temp files go when the session ends, after the session releases them.
+	defer removeSessionTempDir()
+	defer handleTermination()()
+	// Pick up edits to config.toml, commands.json and scripts as they happen.
+	defer watchUserFiles()()
 
-	var wand *imagick.MagickWand
-	// If an input path was provided, create a wand and read it. Otherwise leave wand nil.
-	if inputImagePath != "" {
-		wand = imagick.NewMagickWand()
-		// Defer a cleanup function that will destroy whatever wand is current at program exit.
-		defer func() {
-			if wand != nil {
-				wand.Destroy()
-			}
-		}()
-		if err := wand.ReadImage(inputImagePath); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to read image %s: %v\n", inputImagePath, err)
+	if *webAddr != "" {
+		if _, err := StartWebPreview(*webAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		// Try to show an initial preview in compatible terminals.
-		// Ignore errors here so preview remains optional.
-		if err := PreviewWand(wand); err == nil {
-			if info, ierr := GetImageInfo(wand); ierr == nil {
-				fmt.Println(info)
-			}
+	// Use in-code commands metadata (compile-time)
+	sess := NewSession(NewMetaStore(Commands))
+	// Destroy whatever wand is current at program exit.
+	defer sess.Close()
+	// Open every image given on the command line, each in its own buffer; the
+	// first one starts active. Without arguments start without an image.
+	for _, path := range positional {
+		if err := sess.OpenBuffer(path); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read image %s: %v\n", path, err)
+			os.Exit(exitRead)
 		}
-	} else {
-		wand = nil
+	}
+	if len(positional) > 0 {
+		sess.CycleBuffer(1)
+		// Try to show an initial preview in compatible terminals.
+		showPreview(sess.Display())
 	}
 
 	fmt.Println("Terminal Image Editor")
 	usage()
+	if config.UpdateCheck == "startup" {
+		go NotifyUpdate()
+	}
 
 	reader := bufio.NewReader(os.Stdin)
+	prompt := true
 	for {
-		fmt.Print("> ")
-		r, _, err := reader.ReadRune()
+		if prompt {
+			fmt.Print("> ")
+		}
+		prompt = true
+		r, err := readKey(reader)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "read input error: %v\n", err)
 			continue
 		}
+		// Before dispatching, so rebound keys work right away.
+		reloadUserFiles(sess)
+		store := sess.Store
+		// Windows consoles end lines with CRLF; the LF that follows is
+		// handled like any other line ending.
+		if r == '\r' {
+			prompt = false
+			continue
+		}
+		if r == 0x04 {
+			// Ctrl-D quits, after asking when that would lose changes.
+			if unsaved := sess.Unsaved(); len(unsaved) > 0 {
+				answer, _ := PromptLine(fmt.Sprintf("Unsaved changes to %s; quit anyway? [y/N] ", strings.Join(unsaved, ", ")))
+				if !strings.EqualFold(answer, "y") {
+					continue
+				}
+			}
+			r = boundKey("quit")
+		}
+		r = dispatchKey(r)
 
 		switch r {
 		case '/':
-			if wand == nil {
-				fmt.Println("No image loaded. Press 'o' to open an image first, or provide an image path as the first argument.")
-				continue
-			}
 			var commandName string
 			name, err := SelectCommandWithFzf(Commands)
 			if err != nil || name == "" {
@@ -150,6 +331,11 @@ func RunCLI() {
 				fmt.Printf("unknown command: %s\n", commandName)
 				continue
 			}
+			// Only generator commands (which build a new image) can run without an image.
+			if sess.Wand == nil && !selectedCmd.CreatesImage && !selectedCmd.NoImage {
+				fmt.Println("No image loaded. Press 'o' to open an image first, or provide an image path as the first argument.")
+				continue
+			}
 
 			// If we have metadata for this command, use it to present helpful prompts,
 			// otherwise fall back to simple prompts.
@@ -161,7 +347,13 @@ func RunCLI() {
 					tooltip, _, _ := store.GetCommandHelp(commandName)
 					fmt.Println("\n" + tooltip + "\n")
 					rawArgs = make([]string, len(metaCmd.Params))
+					pointClicked := false
 					for i, p := range metaCmd.Params {
+						if pointClicked {
+							// y was filled in by the click that gave x.
+							pointClicked = false
+							continue
+						}
 						typeLabel := string(p.Type)
 						if p.Type == ParamTypeEnum && len(p.EnumOptions) > 0 {
 							typeLabel = fmt.Sprintf("enum(%s)", strings.Join(p.EnumOptions, "|"))
@@ -175,7 +367,23 @@ func RunCLI() {
 						// PromptLineWithFzf which lets the user press '/' to invoke fzf or type normally.
 						lowerName := strings.ToLower(p.Name)
 						lowerHint := strings.ToLower(p.Hint)
-						if p.Type == ParamTypeString && (strings.Contains(lowerName, "path") || strings.Contains(lowerName, "file") || strings.Contains(lowerHint, "path") || strings.Contains(lowerHint, "file")) {
+						if p.Type == ParamTypeString && (strings.HasSuffix(lowerName, "files") || strings.HasSuffix(lowerName, "paths")) {
+							// File-list parameters accept a glob or a multi-selection from fzf.
+							prompt = fmt.Sprintf("%s (%s) [enter glob or paths, or enter '/' to multi-select with fzf]: ", p.Name, typeLabel)
+							val, perr = PromptLineWithFzfMulti(prompt)
+							if perr != nil {
+								fmt.Fprintf(os.Stderr, "input error: %v\n", perr)
+								val = ""
+							}
+						} else if p.Type == ParamTypeString && lowerName == "font" {
+							// Font names are matched fuzzily against the fonts ImageMagick knows.
+							prompt = fmt.Sprintf("%s (%s) [enter font name, part of one, a font file, or '/' to use fzf]: ", p.Name, typeLabel)
+							val, perr = PromptFont(prompt)
+							if perr != nil {
+								fmt.Fprintf(os.Stderr, "input error: %v\n", perr)
+								val = ""
+							}
+						} else if p.Type == ParamTypeString && (strings.Contains(lowerName, "path") || strings.Contains(lowerName, "file") || strings.Contains(lowerHint, "path") || strings.Contains(lowerHint, "file")) {
 							// Show the fzf hint only for file-like parameters.
 							prompt = fmt.Sprintf("%s (%s) [enter image path, url, or enter '/' to use fzf]: ", p.Name, typeLabel)
 							val, perr = PromptLineWithFzf(prompt)
@@ -183,6 +391,26 @@ func RunCLI() {
 								fmt.Fprintf(os.Stderr, "input error: %v\n", perr)
 								val = ""
 							}
+						} else if p.Type == ParamTypeString && p.Name == "region" && sess.Wand != nil {
+							// A rectangle: two corners can be clicked on the preview instead.
+							prompt = fmt.Sprintf("%s (%s) [enter WxH+X+Y, or click two corners on the preview]: ", p.Name, typeLabel)
+							val, perr = PromptRegion(sess.Display(), prompt)
+							if perr != nil {
+								fmt.Fprintf(os.Stderr, "input error: %v\n", perr)
+								val = ""
+							}
+						} else if p.Name == "x" && i+1 < len(metaCmd.Params) && metaCmd.Params[i+1].Name == "y" && sess.Wand != nil {
+							// A point: it can be clicked on the preview instead of typed.
+							prompt = fmt.Sprintf("%s (%s) [enter a value, or click the preview to pick x and y]: ", p.Name, typeLabel)
+							var y string
+							val, y, pointClicked, perr = PromptPoint(sess.Display(), prompt)
+							if perr != nil {
+								fmt.Fprintf(os.Stderr, "input error: %v\n", perr)
+								val = ""
+							}
+							if pointClicked {
+								rawArgs[i+1] = y
+							}
 						} else {
 							val, perr = PromptLine(prompt)
 							if perr != nil {
@@ -202,18 +430,37 @@ func RunCLI() {
 						continue
 					}
 
+					// A curve is shown before it is applied.
+					if commandName == "curves" && !confirmCurves(reader, normArgs) {
+						fmt.Println("\nCurve not applied.")
+						continue
+					}
+
+					// levelsUI picks the values of a level step interactively.
+					if commandName == "levelsUI" {
+						levelArgs, ok, err := AdjustLevels(reader, sess.target())
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "levelsUI: %v\n", err)
+							continue
+						}
+						if !ok {
+							fmt.Println("Levels not applied.")
+							continue
+						}
+						commandName, normArgs = "level", levelArgs
+					}
+
 					// Apply command with normalized args
-					if err := ApplyCommand(wand, commandName, normArgs); err != nil {
+					if err := sess.Apply(commandName, normArgs); err != nil {
 						fmt.Fprintf(os.Stderr, "apply command error: %v\n", err)
 						continue
 					}
+					if selectedCmd.NoImage || (commandName == "metadata" && isMetadataList(normArgs)) {
+						continue
+					}
 					fmt.Printf("Applied %s\n", commandName)
 					// Update inline terminal preview if available.
-					if err := PreviewWand(wand); err == nil {
-						if info, ierr := GetImageInfo(wand); ierr == nil {
-							fmt.Println(info)
-						}
-					}
+					showPreview(sess.Display())
 					continue
 				}
 			}
@@ -223,12 +470,28 @@ func RunCLI() {
 			continue
 
 		case 's':
-			out, _ := PromptLine("Enter output filename: ")
+			if sess.Wand == nil {
+				fmt.Println("No image loaded. Press 'o' to open an image first.")
+				continue
+			}
+			suggestion, serr := suggestFormat(sess.Display())
+			prompt := "Enter output filename: "
+			if serr == nil {
+				fmt.Printf("Suggested format: %s — %s\n", suggestion, suggestion.Reason)
+				prompt = fmt.Sprintf("Enter output filename (no extension: .%s): ", suggestion.Ext)
+			} else {
+				debugf("format suggestion: %v", serr)
+			}
+			out, _ := PromptLine(prompt)
 			if out == "" {
 				fmt.Println("no filename provided")
 				continue
 			}
-			if err := wand.WriteImage(out); err != nil {
+			if serr == nil {
+				out = confirmSaveFormat(reader, sess.Wand, suggestion, out)
+			}
+			// Layers are flattened into the saved file; the session keeps them separate.
+			if err := sess.Save(out); err != nil {
 				fmt.Fprintf(os.Stderr, "failed to write image: %v\n", err)
 				continue
 			}
@@ -249,27 +512,141 @@ func RunCLI() {
 				newPath = selected
 			}
 
-			newWand := imagick.NewMagickWand()
-			if err := newWand.ReadImage(newPath); err != nil {
+			// The new image gets its own buffer; the current one stays open.
+			if err := sess.OpenBuffer(newPath); err != nil {
 				fmt.Fprintf(os.Stderr, "failed to read image %s: %v\n", newPath, err)
-				newWand.Destroy()
 				continue
 			}
-			// Destroy the current wand (if any) and replace it with the newly opened one.
-			if wand != nil {
-				wand.Destroy()
-			}
-			wand = newWand
 			fmt.Printf("Opened %s\n", newPath)
 			// Update inline terminal preview if available.
-			if err := PreviewWand(wand); err == nil {
-				if info, ierr := GetImageInfo(wand); ierr == nil {
-					fmt.Println(info)
+			showPreview(sess.Display())
+			continue
+
+		case '\t':
+			if !sess.CycleBuffer(1) {
+				fmt.Println("Only one image is open; press 'o' to open another")
+				continue
+			}
+			fmt.Printf("Switched to %s\n", sess.BufferName())
+			showPreview(sess.Display())
+
+		case 'c':
+			if sess.Wand == nil {
+				fmt.Println("No image loaded.")
+				continue
+			}
+			name := sess.BufferName()
+			sess.CloseBuffer()
+			fmt.Printf("Closed %s\n", name)
+			if sess.Wand != nil {
+				fmt.Printf("Switched to %s\n", sess.BufferName())
+				showPreview(sess.Display())
+			}
+
+		case '[', ']':
+			if !isMultiFrame(sess.Wand) {
+				fmt.Println("current image has a single frame")
+				continue
+			}
+			if r == '[' {
+				stepFrame(sess.Wand, -1)
+			} else {
+				stepFrame(sess.Wand, 1)
+			}
+			showPreview(sess.Display())
+
+		case 'a':
+			sess.AllFrames = !sess.AllFrames
+			if sess.AllFrames {
+				fmt.Println("Commands now apply to all frames")
+			} else {
+				fmt.Println("Commands now apply to the current frame only")
+			}
+
+		case '.':
+			if len(sess.Invocations) == 0 {
+				fmt.Println("No command to repeat yet")
+				continue
+			}
+			runStep(sess, sess.Invocations[len(sess.Invocations)-1])
+
+		case 'H':
+			step, ok := browseHistory(sess)
+			if !ok {
+				continue
+			}
+			runStep(sess, step)
+
+		case 'i':
+			info, err := GetImageInfo(sess.Wand)
+			if err != nil {
+				fmt.Println("No image loaded. Press 'o' to open an image first.")
+				continue
+			}
+			fmt.Println(info)
+
+		case 'r':
+			if !sess.Recording() {
+				sess.StartRecording()
+				fmt.Println("Recording macro; press 'r' again to stop and save it")
+				continue
+			}
+			steps := sess.StopRecording()
+			if len(steps) == 0 {
+				fmt.Println("Recording stopped; no commands were recorded")
+				continue
+			}
+			name, _ := PromptLine(fmt.Sprintf("Save %d recorded commands as macro (leave empty to discard): ", len(steps)))
+			if name == "" {
+				fmt.Println("macro discarded")
+				continue
+			}
+			if err := SaveMacro(name, steps); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to save macro: %v\n", err)
+				continue
+			}
+			fmt.Printf("Saved macro %s\n", name)
+
+		case 'p':
+			if sess.Wand == nil {
+				fmt.Println("No image loaded. Press 'o' to open an image first.")
+				continue
+			}
+			names, err := ListMacros()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				continue
+			}
+			if len(names) == 0 {
+				fmt.Printf("No saved macros; press '%s' to record one\n", keyName(boundKey("record")))
+				continue
+			}
+			name, selErr := SelectItemWithFzf("macro> ", names)
+			if selErr != nil || name == "" {
+				fmt.Println("Saved macros: " + strings.Join(names, ", "))
+				name, _ = PromptLine("Enter macro name (leave empty to cancel): ")
+				if name == "" {
+					fmt.Println("playback cancelled")
+					continue
 				}
 			}
-			continue
+			steps, err := LoadMacro(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to load macro: %v\n", err)
+				continue
+			}
+			if err := sess.PlayMacro(steps); err != nil {
+				fmt.Fprintf(os.Stderr, "macro %s: %v\n", name, err)
+			} else {
+				fmt.Printf("Played macro %s (%d commands)\n", name, len(steps))
+			}
+			showPreview(sess.Display())
 
 		case 'u':
+			if config.UpdateCheck == "never" {
+				fmt.Println("Update checks are disabled in the config file")
+				continue
+			}
 			// Trigger an update check (runs the goroutine in CheckForUpdates)
 			err := CheckForUpdates()
 			if err != nil {
@@ -282,6 +659,14 @@ func RunCLI() {
 			continue
 
 		case 'q':
+			if n := sess.RunningJobs(); n > 0 {
+				answer, _ := PromptLine(fmt.Sprintf("%d background jobs are still running; stop them and quit? [y/N] ", n))
+				if !strings.EqualFold(answer, "y") {
+					continue
+				}
+				fmt.Println("Waiting for the jobs' current items to finish...")
+				sess.StopJobs()
+			}
 			fmt.Println("Exiting...")
 			return
 
@@ -290,3 +675,188 @@ func RunCLI() {
 		}
 	}
 }
+
+// handleInterrupts catches Ctrl-C (SIGINT) for the interactive session: it
+// cancels the command in progress, if any, and otherwise points at the quit
+// key. It returns a function that restores the default handling.
+func handleInterrupts() func() {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+			if cancelOperations() {
+				fmt.Fprintln(os.Stderr, "\nCancelling...")
+			} else {
+				fmt.Fprintln(os.Stderr, "\n"+interruptHint())
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+	}
+}
+
+// interruptHint is shown for Ctrl-C when no command is running.
+func interruptHint() string {
+	return fmt.Sprintf("(Ctrl-C cancels a running command; press %s to quit)", keyName(boundKey("quit")))
+}
+
+// readKey reads the next key at the main prompt. When stdin is a terminal the
+// key is read in raw mode, so it acts without Enter, and echoed; the terminal
+// is back in normal line mode before the key is handled, so parameter prompts
+// read whole lines as usual. Otherwise (input piped in) keys are read from the
+// typed line. In raw mode Ctrl-C arrives as a key rather than a signal; it
+// only shows interruptHint and returns 0, which no command uses. Ctrl-D is
+// returned as is for the session to confirm before quitting.
+func readKey(reader *bufio.Reader) (rune, error) {
+	restore, err := enableRawInput()
+	if err != nil {
+		r, _, err := reader.ReadRune()
+		return r, err
+	}
+	r, _, err := reader.ReadRune()
+	if err == nil && r == 0x1b {
+		// Escape sequences (arrow and function keys) arrive in one read; drop
+		// the rest so its bytes are not taken for keys.
+		reader.Discard(reader.Buffered())
+	}
+	restore()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case r == 0x03:
+		fmt.Println()
+		fmt.Println(interruptHint())
+		return 0, nil
+	case r == 0x04:
+		fmt.Println()
+		return r, nil
+	case r == '\r':
+		r = '\n'
+	case unicode.IsPrint(r):
+		fmt.Print(string(r))
+	}
+	fmt.Println()
+	return r, nil
+}
+
+// showPreview renders the current image inline (best-effort) and prints the
+// image info and, for animations, the selected frame. The image is also pushed
+// to the web preview when it is enabled. With clipping warnings or the onion
+// skin on, both show the overlays instead. Preview errors are ignored so
+// preview remains optional.
+func showPreview(wand *imagick.MagickWand) {
+	shown := wand
+	clipInfo := ""
+	if showClipping {
+		if overlay, high, low, err := clippingOverlay(wand); err == nil {
+			defer overlay.Destroy()
+			shown = overlay
+			clipInfo = fmt.Sprintf("Clipped: %.2f%% highlights, %.2f%% shadows", high*100, low*100)
+		} else {
+			debugf("clipping overlay failed: %v", err)
+		}
+	}
+	// After the clipping overlay, so its counts cover the current frame only.
+	if showOnionSkin && isMultiFrame(wand) {
+		if overlay, err := onionSkinOverlay(wand, shown); err == nil {
+			defer overlay.Destroy()
+			shown = overlay
+		} else {
+			debugf("onion skin failed: %v", err)
+		}
+	}
+	// The page mirrors the terminal preview, unless it is the only preview
+	// and PreviewWand publishes to it.
+	if _, webOnly := activePreviewer().(webPreviewer); webPreview != nil && !webOnly {
+		if err := webPreview.Publish(shown); err != nil {
+			debugf("web preview publish failed: %v", err)
+		}
+	}
+	if err := PreviewWand(shown); err != nil {
+		return
+	}
+	if info, ierr := imageSummary(wand); ierr == nil {
+		fmt.Println(info)
+	}
+	if clipInfo != "" {
+		fmt.Println(clipInfo)
+	}
+	if status := regionStatus(wand); status != "" {
+		fmt.Println(status)
+	}
+	if status := frameStatus(wand); status != "" {
+		fmt.Println(status)
+	}
+}
+
+// runStep applies a previously used command through the session, reporting
+// the outcome and refreshing the preview like the '/' flow does.
+func runStep(sess *Session, step RecipeStep) {
+	normArgs, err := NormalizeArgs(sess.Store, step.Command, step.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "input validation error: %v\n", err)
+		return
+	}
+	if err := sess.Apply(step.Command, normArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "apply command error: %v\n", err)
+		return
+	}
+	fmt.Printf("Applied %s\n", RecipeStep{Command: step.Command, Args: normArgs})
+	showPreview(sess.Display())
+}
+
+// browseHistory lets the user pick an earlier invocation (newest first) with
+// fzf, or from a numbered list when fzf is unavailable, and then optionally
+// replace its arguments. ok is false when the user cancels.
+func browseHistory(sess *Session) (RecipeStep, bool) {
+	n := len(sess.Invocations)
+	if n == 0 {
+		fmt.Println("No commands in history yet")
+		return RecipeStep{}, false
+	}
+	lines := make([]string, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		lines = append(lines, fmt.Sprintf("%d: %s", i+1, sess.Invocations[i]))
+	}
+
+	var idx int
+	sel, err := SelectItemWithFzf("history> ", lines)
+	if err == nil && sel != "" {
+		num, _, _ := strings.Cut(sel, ":")
+		idx, err = strconv.Atoi(num)
+	}
+	if err != nil || sel == "" {
+		for _, l := range lines {
+			fmt.Println("  " + l)
+		}
+		choice, _ := PromptLine("Enter number (leave empty to cancel): ")
+		if choice == "" {
+			return RecipeStep{}, false
+		}
+		if idx, err = strconv.Atoi(choice); err != nil {
+			fmt.Println("invalid selection")
+			return RecipeStep{}, false
+		}
+	}
+	if idx < 1 || idx > n {
+		fmt.Println("invalid selection")
+		return RecipeStep{}, false
+	}
+
+	step := sess.Invocations[idx-1]
+	fmt.Printf("Selected: %s\n", step)
+	tweak, _ := PromptLine("Press Enter to re-run, or type new arguments (quote values with spaces): ")
+	if tweak == "" {
+		return step, true
+	}
+	args, err := splitRecipeLine(tweak)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid arguments: %v\n", err)
+		return RecipeStep{}, false
+	}
+	return RecipeStep{Command: step.Command, Args: args}, true
+}
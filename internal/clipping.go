@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Clipping warnings.
+//
+// While enabled with the clipping command, previews mark pixels whose
+// highlights are blown (any channel at the maximum) with red stripes and
+// pixels whose shadows are crushed (every channel at zero) with blue stripes,
+// like a camera's zebra display. The image itself is never changed.
+
+// showClipping enables the clipping overlay on previews.
+var showClipping bool
+
+// clipHigh and clipLow are the 8-bit levels counted as clipped.
+const (
+	clipHigh = 254
+	clipLow  = 1
+)
+
+// clipSampleSize bounds the longer side of the overlay; point sampling keeps
+// isolated clipped pixels visible, unlike averaging.
+const clipSampleSize = 1600
+
+// zebraWidth is the width of the overlay's diagonal stripes in pixels.
+const zebraWidth = 4
+
+// clippingOverlay returns a copy of the current image of wand with clipped
+// areas striped, and the fractions of highlight and shadow pixels clipped.
+func clippingOverlay(wand *imagick.MagickWand) (*imagick.MagickWand, float64, float64, error) {
+	img := wand.GetImage()
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	if w == 0 || h == 0 {
+		img.Destroy()
+		return nil, 0, 0, fmt.Errorf("image has zero dimensions")
+	}
+	if long := max(w, h); long > clipSampleSize {
+		scale := float64(clipSampleSize) / float64(long)
+		w = max(1, uint(float64(w)*scale))
+		h = max(1, uint(float64(h)*scale))
+		if err := img.SampleImage(w, h); err != nil {
+			img.Destroy()
+			return nil, 0, 0, fmt.Errorf("failed to sample image: %w", err)
+		}
+	}
+	px, err := img.ExportImagePixels(0, 0, w, h, "RGB", imagick.PIXEL_CHAR)
+	if err != nil {
+		img.Destroy()
+		return nil, 0, 0, fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	rgb, ok := px.([]byte)
+	if !ok {
+		img.Destroy()
+		return nil, 0, 0, fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+
+	var high, low int
+	for y := 0; y < int(h); y++ {
+		for x := 0; x < int(w); x++ {
+			i := (y*int(w) + x) * 3
+			r, g, b := rgb[i], rgb[i+1], rgb[i+2]
+			var mark [3]byte
+			switch {
+			case r >= clipHigh || g >= clipHigh || b >= clipHigh:
+				high++
+				mark = [3]byte{255, 0, 0}
+			case r <= clipLow && g <= clipLow && b <= clipLow:
+				low++
+				mark = [3]byte{0, 64, 255}
+			default:
+				continue
+			}
+			if ((x+y)/zebraWidth)%2 == 0 {
+				copy(rgb[i:i+3], mark[:])
+			}
+		}
+	}
+	if err := img.ImportImagePixels(0, 0, w, h, "RGB", imagick.PIXEL_CHAR, rgb); err != nil {
+		img.Destroy()
+		return nil, 0, 0, fmt.Errorf("ImportImagePixels failed: %w", err)
+	}
+	total := float64(w * h)
+	return img, float64(high) / total, float64(low) / total, nil
+}
+
+// setClipping turns the overlay on or off; an empty value toggles it.
+func setClipping(value string) {
+	switch value {
+	case "true":
+		showClipping = true
+	case "false":
+		showClipping = false
+	default:
+		showClipping = !showClipping
+	}
+	if showClipping {
+		fmt.Println("Clipping warnings on: red = blown highlights, blue = crushed shadows")
+	} else {
+		fmt.Println("Clipping warnings off")
+	}
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Color matching: transfer the color character of a reference image onto the
+// current image, e.g. to make a series of photos look consistent.
+
+// matchMethods are the values of matchColors' method parameter, in
+// EnumOptions order (the parameter normalizes to the option index).
+var matchMethods = []string{"LAB_STATS", "HISTOGRAM"}
+
+// matchSampleSize bounds the longer side of the copies statistics are
+// gathered from; color distributions barely change with resolution.
+const matchSampleSize = 512
+
+// matchBins is the number of levels per channel used for histogram matching.
+const matchBins = 256
+
+// MatchColors adjusts the current image of wand towards the colors of ref.
+//
+//   - LAB_STATS shifts and scales each CIELAB channel so its mean and standard
+//     deviation match the reference (Reinhard color transfer). It keeps the
+//     image's own tonal structure and is robust for photos of different scenes.
+//   - HISTOGRAM remaps each RGB channel so its cumulative histogram matches the
+//     reference. It matches more closely but can exaggerate differences in
+//     content between the two images.
+//
+// strength (0-1) blends between the original (0) and the full match (1).
+// Alpha is left untouched.
+func MatchColors(wand, ref *imagick.MagickWand, method string, strength float64) error {
+	if strength <= 0 {
+		return nil
+	}
+	switch method {
+	case "LAB_STATS":
+		return matchLabStats(wand, ref, strength)
+	case "HISTOGRAM":
+		return matchHistogram(wand, ref, strength)
+	}
+	return fmt.Errorf("unknown match method %q", method)
+}
+
+// samplePixels returns the RGB values (0-1) of a reduced copy of the current
+// image of wand, converted to colorspace first when it is not undefined.
+func samplePixels(wand *imagick.MagickWand, colorspace imagick.ColorspaceType) ([]float64, error) {
+	sample := wand.GetImage()
+	defer sample.Destroy()
+	w, h := sample.GetImageWidth(), sample.GetImageHeight()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("image has zero dimensions")
+	}
+	if long := max(w, h); long > matchSampleSize {
+		scale := float64(matchSampleSize) / float64(long)
+		w = max(1, uint(float64(w)*scale))
+		h = max(1, uint(float64(h)*scale))
+		if err := sample.SampleImage(w, h); err != nil {
+			return nil, fmt.Errorf("failed to sample image: %w", err)
+		}
+	}
+	if colorspace != imagick.COLORSPACE_UNDEFINED {
+		if err := sample.TransformImageColorspace(colorspace); err != nil {
+			return nil, fmt.Errorf("failed to convert colorspace: %w", err)
+		}
+	}
+	px, err := sample.ExportImagePixels(0, 0, w, h, "RGB", imagick.PIXEL_DOUBLE)
+	if err != nil {
+		return nil, fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	vals, ok := px.([]float64)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+	return vals, nil
+}
+
+// channelStats returns the mean and standard deviation of channel c (0-2)
+// of interleaved RGB values.
+func channelStats(vals []float64, c int) (mean, stddev float64) {
+	n := float64(len(vals) / 3)
+	for i := c; i < len(vals); i += 3 {
+		mean += vals[i]
+	}
+	mean /= n
+	for i := c; i < len(vals); i += 3 {
+		d := vals[i] - mean
+		stddev += d * d
+	}
+	return mean, math.Sqrt(stddev / n)
+}
+
+func matchLabStats(wand, ref *imagick.MagickWand, strength float64) error {
+	src, err := samplePixels(wand, imagick.COLORSPACE_LAB)
+	if err != nil {
+		return err
+	}
+	dst, err := samplePixels(ref, imagick.COLORSPACE_LAB)
+	if err != nil {
+		return fmt.Errorf("reference: %w", err)
+	}
+
+	colorspace := wand.GetImageColorspace()
+	if err := wand.TransformImageColorspace(imagick.COLORSPACE_LAB); err != nil {
+		return fmt.Errorf("failed to convert to LAB: %w", err)
+	}
+	// In LAB the red, green and blue channels hold L, a and b.
+	channels := []imagick.ChannelType{imagick.CHANNEL_RED, imagick.CHANNEL_GREEN, imagick.CHANNEL_BLUE}
+	for c, ch := range channels {
+		srcMean, srcStd := channelStats(src, c)
+		dstMean, dstStd := channelStats(dst, c)
+		scale := 1.0
+		if srcStd > 1e-6 {
+			scale = dstStd / srcStd
+		}
+		// out = (x - srcMean) * scale + dstMean, blended with x by strength.
+		a := 1 + strength*(scale-1)
+		b := strength * (dstMean - srcMean*scale)
+		prev := wand.SetImageChannelMask(ch)
+		err := wand.FunctionImage(imagick.FUNCTION_POLYNOMIAL, []float64{a, b})
+		wand.SetImageChannelMask(prev)
+		if err != nil {
+			return fmt.Errorf("failed to adjust channel: %w", err)
+		}
+	}
+	if colorspace == imagick.COLORSPACE_UNDEFINED || colorspace == imagick.COLORSPACE_LAB {
+		colorspace = imagick.COLORSPACE_SRGB
+	}
+	if err := wand.TransformImageColorspace(colorspace); err != nil {
+		return fmt.Errorf("failed to convert back from LAB: %w", err)
+	}
+	return nil
+}
+
+// cumulativeHistogram returns the normalized cumulative histogram of channel c.
+func cumulativeHistogram(vals []float64, c int) []float64 {
+	cdf := make([]float64, matchBins)
+	for i := c; i < len(vals); i += 3 {
+		bin := int(math.Round(vals[i] * (matchBins - 1)))
+		cdf[min(max(bin, 0), matchBins-1)]++
+	}
+	total := float64(len(vals) / 3)
+	sum := 0.0
+	for i := range cdf {
+		sum += cdf[i]
+		cdf[i] = sum / total
+	}
+	return cdf
+}
+
+func matchHistogram(wand, ref *imagick.MagickWand, strength float64) error {
+	src, err := samplePixels(wand, imagick.COLORSPACE_UNDEFINED)
+	if err != nil {
+		return err
+	}
+	dst, err := samplePixels(ref, imagick.COLORSPACE_UNDEFINED)
+	if err != nil {
+		return fmt.Errorf("reference: %w", err)
+	}
+
+	// Build a 1-pixel-high lookup table: each level maps to the reference level
+	// with the same cumulative frequency. ClutImage maps every channel through
+	// the matching channel of the table.
+	lut := make([]float64, matchBins*3)
+	for c := 0; c < 3; c++ {
+		srcCDF := cumulativeHistogram(src, c)
+		dstCDF := cumulativeHistogram(dst, c)
+		j := 0
+		for i := 0; i < matchBins; i++ {
+			for j < matchBins-1 && dstCDF[j] < srcCDF[i] {
+				j++
+			}
+			level := float64(i) / (matchBins - 1)
+			matched := float64(j) / (matchBins - 1)
+			lut[i*3+c] = level + strength*(matched-level)
+		}
+	}
+	clut := imagick.NewMagickWand()
+	defer clut.Destroy()
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	bg.SetColor("black")
+	if err := clut.NewImage(matchBins, 1, bg); err != nil {
+		return fmt.Errorf("failed to create lookup table: %w", err)
+	}
+	if err := clut.ImportImagePixels(0, 0, matchBins, 1, "RGB", imagick.PIXEL_DOUBLE, lut); err != nil {
+		return fmt.Errorf("failed to fill lookup table: %w", err)
+	}
+
+	prev := wand.SetImageChannelMask(imagick.CHANNEL_RED | imagick.CHANNEL_GREEN | imagick.CHANNEL_BLUE)
+	err = wand.ClutImage(clut, imagick.INTERPOLATE_PIXEL_BILINEAR)
+	wand.SetImageChannelMask(prev)
+	if err != nil {
+		return fmt.Errorf("failed to apply lookup table: %w", err)
+	}
+	return nil
+}
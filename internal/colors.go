@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Color analysis.
+//
+// colors reports what an image actually uses of its color space: how many
+// distinct colors, whether it is gray in all but name, what kind of
+// transparency it has and how many levels of each channel appear. That
+// decides whether a palette format (PNG8, GIF) can hold it without loss, or
+// whether a 16-bit file carries anything an 8-bit one would not.
+
+// colorStatsRows is how many rows are read at a time, to bound memory on
+// large images.
+const colorStatsRows = 256
+
+// grayTolerance is the largest channel difference, in 16-bit units, that
+// still counts as gray: one 8-bit step.
+const grayTolerance = 257
+
+// colorStats summarizes the colors an image uses.
+type colorStats struct {
+	Unique  uint
+	Depth   uint
+	Levels  [3]int // distinct values per RGB channel, at Depth bits
+	Fits8   bool   // every value is representable in 8 bits
+	MaxDiff int    // largest difference between two channels of a pixel, 16-bit
+	Alpha   string // "none", "binary" or "partial"
+}
+
+// Gray reports whether the image is effectively grayscale.
+func (c colorStats) Gray() bool {
+	return c.MaxDiff <= grayTolerance
+}
+
+// analyzeColors scans the current image of wand.
+func analyzeColors(wand *imagick.MagickWand) (colorStats, error) {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if w == 0 || h == 0 {
+		return colorStats{}, fmt.Errorf("image has zero dimensions")
+	}
+	stats := colorStats{Unique: wand.GetImageColors(), Depth: wand.GetImageDepth(), Fits8: true, Alpha: "none"}
+	hasAlpha := wand.GetImageAlphaChannel()
+	var seen [3][65536]bool
+	for y := uint(0); y < h; y += colorStatsRows {
+		rows := min(colorStatsRows, h-y)
+		out, err := wand.ExportImagePixels(0, int(y), w, rows, "RGBA", imagick.PIXEL_SHORT)
+		if err != nil {
+			return colorStats{}, fmt.Errorf("failed to read pixels: %w", err)
+		}
+		px, ok := out.([]int16)
+		if !ok {
+			return colorStats{}, fmt.Errorf("unsupported pixel data type: %T", out)
+		}
+		for i := 0; i+3 < len(px); i += 4 {
+			r, g, b, a := int(uint16(px[i])), int(uint16(px[i+1])), int(uint16(px[i+2])), int(uint16(px[i+3]))
+			seen[0][r], seen[1][g], seen[2][b] = true, true, true
+			if stats.Fits8 && (r%257 != 0 || g%257 != 0 || b%257 != 0) {
+				stats.Fits8 = false
+			}
+			stats.MaxDiff = max(stats.MaxDiff, abs(r-g), abs(g-b), abs(r-b))
+			if hasAlpha && a != 65535 {
+				if a == 0 {
+					if stats.Alpha == "none" {
+						stats.Alpha = "binary"
+					}
+				} else {
+					stats.Alpha = "partial"
+				}
+			}
+		}
+	}
+	// Count levels at the image's own depth.
+	depth := min(max(stats.Depth, 1), 16)
+	shift := 16 - depth
+	for c := 0; c < 3; c++ {
+		levels := map[int]bool{}
+		for v, ok := range seen[c] {
+			if ok {
+				levels[v>>shift] = true
+			}
+		}
+		stats.Levels[c] = len(levels)
+	}
+	return stats, nil
+}
+
+// abs returns the absolute value of v.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// printColorStats reports the analysis with a suggestion for the output
+// format.
+func printColorStats(s colorStats) {
+	total := 1 << min(max(s.Depth, 1), 16)
+	fmt.Printf("Unique colors: %d\n", s.Unique)
+	if s.Gray() {
+		fmt.Println("Grayscale:     yes")
+	} else {
+		fmt.Printf("Grayscale:     no (channels differ by up to %.0f%%)\n", float64(s.MaxDiff)/655.35)
+	}
+	alpha := map[string]string{
+		"none":    "none (fully opaque)",
+		"binary":  "on/off only",
+		"partial": "partial transparency",
+	}[s.Alpha]
+	fmt.Printf("Alpha:         %s\n", alpha)
+	fmt.Printf("Bit depth:     %d bits per channel", s.Depth)
+	if s.Depth > 8 && s.Fits8 {
+		fmt.Print(" (all values fit in 8 bits)")
+	}
+	fmt.Println()
+	fmt.Printf("Levels used:   R %d, G %d, B %d of %d\n", s.Levels[0], s.Levels[1], s.Levels[2], total)
+
+	var suggestion string
+	switch {
+	case s.Unique <= 256 && s.Alpha != "partial":
+		suggestion = "PNG8 or GIF store this image without loss (256 colors or fewer"
+		if s.Alpha == "binary" {
+			suggestion += ", on/off transparency"
+		}
+		suggestion += ")."
+	case s.Unique <= 256:
+		suggestion = "PNG8 with an alpha palette stores this image without loss; GIF would lose the partial transparency."
+	case s.Gray() && s.Alpha == "none":
+		suggestion = "Save as 8-bit grayscale PNG (or JPEG) to drop the redundant color channels."
+	case s.Alpha != "none":
+		suggestion = "Needs PNG32 (or WebP) to keep the transparency; a palette would band."
+	default:
+		suggestion = "Needs PNG24 (or JPEG for photos); a 256-color palette would band or dither."
+	}
+	if s.Depth > 8 && s.Fits8 {
+		suggestion += " 16-bit output would only double the size."
+	}
+	if s.Depth > 8 && max(s.Levels[0], s.Levels[1], s.Levels[2]) > 256 {
+		suggestion += " Keep 16 bits if further edits follow; the image uses more than 256 levels."
+	}
+	fmt.Println("Suggestion:    " + suggestion)
+}
@@ -34,7 +34,18 @@ var Commands = []CommandMeta{
 		Params: []ParamMeta{
 			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Block width in pixels used for local thresholding. Lower = finer local adaptation.", Example: "15", Unit: "px"},
 			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Block height in pixels used for local thresholding. Lower = finer local adaptation.", Example: "15", Unit: "px"},
-			{Name: "offset", Type: ParamTypeFloat, Required: true, Hint: "Offset applied during threshold test. Negative offsets favor black; positive favor white.", Example: "0.0"},
+			{Name: "offset", Type: ParamTypeQuantum, Required: true, Hint: "Offset applied during threshold test, in the quantum range or as a percentage. Negative offsets favor black; positive favor white.", Example: "5%"},
+		},
+	},
+	{
+		Name:        "addLayer",
+		Description: "Add an image as a new layer above the current stack and select it",
+		Params: []ParamMeta{
+			{Name: "sourceImagePath", Type: ParamTypeString, Required: true, Hint: "Filesystem path to the layer image, or buffer:<name> for an open image.", Example: "logo.png"},
+			{Name: "x", Type: ParamTypeInt, Required: false, Hint: "X offset of the layer relative to the background's top-left. Default 0.", Example: "0", Unit: "px"},
+			{Name: "y", Type: ParamTypeInt, Required: false, Hint: "Y offset of the layer relative to the background's top-left. Default 0.", Example: "0", Unit: "px"},
+			{Name: "opacity", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0.0), Max: float64Ptr(1.0), Hint: "Layer opacity from 0.0 to 1.0. Default 1.0.", Example: "1.0"},
+			{Name: "composeOperator", Type: ParamTypeEnum, Required: false, Hint: "Blend mode used when the layer is composited. Default OVER.", Example: "OVER", EnumOptions: composeOperatorOptions},
 		},
 	},
 	{
@@ -49,6 +60,8 @@ var Commands = []CommandMeta{
 				Example:     "GAUSSIAN",
 				EnumOptions: []string{"UNDEFINED", "UNIFORM", "GAUSSIAN", "MULTIPLICATIVE", "IMPULSE", "LAPLACIAN", "POISSON", "RANDOM"},
 			},
+			{Name: "attenuate", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0), Hint: "Strength of the noise. 1.0 = ImageMagick's default amount; 0.2-0.5 gives subtle film grain. Default 1.0.", Example: "0.3"},
+			{Name: "channel", Type: ParamTypeEnum, Required: false, Hint: "Channel to add noise to. Default ALL.", Example: "ALL", EnumOptions: noiseChannels},
 		},
 	},
 	{
@@ -61,6 +74,7 @@ var Commands = []CommandMeta{
 			{Name: "x", Type: ParamTypeInt, Required: true, Hint: "X coordinate for the text baseline origin.", Example: "10", Unit: "px"},
 			{Name: "y", Type: ParamTypeInt, Required: true, Hint: "Y coordinate for the text baseline origin.", Example: "50", Unit: "px"},
 			{Name: "color", Type: ParamTypeString, Required: true, Hint: "Text color (hex, rgb(), or name).", Example: "#ffffff"},
+			{Name: "markup", Type: ParamTypeBool, Required: false, Hint: "Treat text as Pango markup (e.g. <b>bold</b>); requires ImageMagick built with Pango. Default false.", Example: "false"},
 		},
 	},
 	{
@@ -78,6 +92,13 @@ var Commands = []CommandMeta{
 		Description: "Automatically orient the image using EXIF Orientation",
 		Params:      []ParamMeta{},
 	},
+	{
+		Name: "avgColor",
+		Description: "Print the image's average color and a CSS placeholder snippet (background color and gradient)\n" +
+			"This command does not modify the image; it only outputs information.",
+		NoImage: true,
+		Params:  []ParamMeta{},
+	},
 	{
 		Name:        "blackThreshold",
 		Description: "Threshold the image to black and white using a black threshold color",
@@ -100,6 +121,22 @@ var Commands = []CommandMeta{
 			{Name: "sigma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Hint: "Standard deviation (strength). Lower = subtle; higher = stronger blur.", Example: "1.5"},
 		},
 	},
+	{
+		Name:        "border",
+		Description: "Surround the image with a flat border of a color",
+		Params: []ParamMeta{
+			{Name: "color", Type: ParamTypeString, Required: true, Hint: "Border color: a name, #rrggbb or rgb(...).", Example: "#dfdfdf"},
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Border width on the left and right.", Example: "10", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Border height at the top and bottom. Default the same as width.", Example: "10", Unit: "px"},
+		},
+	},
+	{
+		Name: "buffers",
+		Description: "List the open images (buffers); refer to one as buffer:<name> or buffer:<number> in file parameters\n" +
+			"This command does not modify the image; it only outputs information.",
+		NoImage: true,
+		Params:  []ParamMeta{},
+	},
 	{
 		Name:        "charcoal",
 		Description: "Simulate a charcoal drawing",
@@ -108,6 +145,43 @@ var Commands = []CommandMeta{
 			{Name: "sigma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Hint: "Intensity/softening of strokes. Lower = crisper; higher = softer.", Example: "0.5"},
 		},
 	},
+	{
+		Name: "checkOrientation",
+		Description: "Find photos whose EXIF orientation would rotate pixels that are already upright, and optionally reset the tag without re-encoding\n" +
+			"Checks files on disk; the open image is not changed.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "files", Type: ParamTypeString, Required: true, Hint: "Directory, glob pattern (e.g. photos/*.jpg) or list of images; enter '/' to multi-select with fzf (Tab marks files).", Example: "photos/"},
+			{Name: "fix", Type: ParamTypeBool, Required: false, Hint: "Reset stale orientation tags of JPEG files to 1 in place. Default false (report only).", Example: "false"},
+		},
+	},
+	{
+		Name:        "chop",
+		Description: "Cut a band of columns and/or rows out of the image and close the gap, e.g. to remove letterbox bars or a strip through the middle",
+		Params: []ParamMeta{
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Columns to remove, starting at x. 0 = none.", Example: "0", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Rows to remove, starting at y. 0 = none.", Example: "140", Unit: "px"},
+			{Name: "x", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "First column removed.", Example: "0", Unit: "px"},
+			{Name: "y", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "First row removed.", Example: "0", Unit: "px"},
+		},
+	},
+	{
+		Name:        "clahe",
+		Description: "Local contrast enhancement (CLAHE): lift detail in shadows and flat areas tile by tile without blowing out highlights",
+		Params: []ParamMeta{
+			{Name: "tileWidth", Type: ParamTypeInt, Required: true, Min: float64Ptr(2), Hint: "Width of the tiles equalized separately; smaller = more local contrast. About 1/8 of the image width is a good start.", Example: "256", Unit: "px"},
+			{Name: "tileHeight", Type: ParamTypeInt, Required: true, Min: float64Ptr(2), Hint: "Height of the tiles.", Example: "256", Unit: "px"},
+			{Name: "bins", Type: ParamTypeInt, Required: false, Min: float64Ptr(2), Max: float64Ptr(65536), Hint: "Histogram bins per tile. Default 128.", Example: "128"},
+			{Name: "clipLimit", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0), Hint: "Contrast limit; 1 = almost no change, 2-4 = natural, higher = gritty. Default 3.", Example: "3"},
+		},
+	},
+	{
+		Name:        "clipping",
+		Description: "Toggle clipping warnings: previews stripe blown highlights red and crushed shadows blue (the image is not changed)",
+		Params: []ParamMeta{
+			{Name: "enabled", Type: ParamTypeBool, Required: false, Hint: "true = on, false = off; empty toggles.", Example: "true"},
+		},
+	},
 	{
 		Name:        "colorize",
 		Description: "Colorize (tint) the image with a given color and opacity",
@@ -116,26 +190,42 @@ var Commands = []CommandMeta{
 			{Name: "opacity", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Max: float64Ptr(1.0), Hint: "Opacity of the tint from 0.0 to 1.0.", Example: "0.5"},
 		},
 	},
+	{
+		Name:        "colors",
+		Description: "Count unique colors and report grayscale, alpha and bit-depth use, to choose between PNG8/GIF and PNG24",
+		NoImage:     true,
+	},
+	{
+		Name: "combineChannels",
+		Description: "Merge three grayscale channels (and an optional alpha) from open images or files into a color image in a new buffer\n" +
+			"The counterpart of separateChannel; the current image is not changed.",
+		CreatesImage: true,
+		Params: []ParamMeta{
+			{Name: "colorspace", Type: ParamTypeEnum, Required: true, Hint: "RGB for red, green and blue channels; LAB for the lightness and a/b chroma channels made by separateChannel LAB.", Example: "RGB", EnumOptions: combineSpaces},
+			{Name: "first", Type: ParamTypeString, Required: true, Hint: "Red or L channel: buffer:<name> for an open image, or an image file.", Example: "buffer:photo.jpg-L"},
+			{Name: "second", Type: ParamTypeString, Required: true, Hint: "Green or a channel: buffer:<name> or an image file.", Example: "buffer:photo.jpg-a"},
+			{Name: "third", Type: ParamTypeString, Required: true, Hint: "Blue or b channel: buffer:<name> or an image file.", Example: "buffer:photo.jpg-b"},
+			{Name: "alpha", Type: ParamTypeString, Required: false, Hint: "Optional alpha channel: buffer:<name> or an image file.", Example: "buffer:photo.jpg-alpha"},
+		},
+	},
+	{
+		Name:        "compare",
+		Description: "Measure how far the image is from another one (SSIM, PSNR, RMSE), e.g. to see how lossy a compression setting was",
+		NoImage:     true,
+		Params: []ParamMeta{
+			{Name: "otherPath", Type: ParamTypeString, Required: true, Hint: "Image of the same size to compare with: a file, or buffer:<name> for an open image.", Example: "buffer:photo-q70.jpg"},
+			{Name: "showDiff", Type: ParamTypeBool, Required: false, Hint: "Preview the differences highlighted in red. Default false.", Example: "true"},
+		},
+	},
 	{
 		Name:        "composite",
 		Description: "Composite an image onto another",
 		Params: []ParamMeta{
-			{Name: "sourceImagePath", Type: ParamTypeString, Required: true, Hint: "Filesystem path or URL to the overlay/source image.", Example: "overlay.png"},
-			{Name: "composeOperator", Type: ParamTypeEnum, Required: true, Hint: "Compositing operator / blend mode. Choose the desired blend behavior.", Example: "OVER", EnumOptions: []string{
-				"UNDEFINED", "ALPHA", "ATOP", "BLEND", "BLUR", "BUMPMAP", "CHANGE_MASK", "CLEAR",
-				"COLOR_BURN", "COLOR_DODGE", "COLORIZE", "COPY", "COPY_ALPHA", "COPY_BLACK", "COPY_BLUE",
-				"COPY_CYAN", "COPY_GREEN", "COPY_MAGENTA", "COPY_RED", "COPY_YELLOW", "DARKEN",
-				"DARKEN_INTENSITY", "DIFFERENCE", "DISPLACE", "DISSOLVE", "DISTORT", "DIVIDE__DST",
-				"DIVIDE_SRC", "DST", "DST_ATOP", "DST_IN", "DST_OUT", "DST_OVER", "EXCLUSION",
-				"HARD_LIGHT", "HARD_MIX", "HUE", "IN", "INTENSITY", "LIGHTEN", "LIGHTEN_INTENSITY",
-				"LINEAR_BURN", "LINEAR_DODGE", "LINEAR_LIGHT", "LUMINIZE", "MATHEMATICS", "MINUS_DST",
-				"MINUS_SRC", "MODULATE", "MODULUS_ADD", "MODULUS_SUBTRACT", "MULTIPLY", "NO", "OUT",
-				"OVER", "OVERLAY", "PEGTOP_LIGHT", "PIN_LIGHT", "PLUS", "REPLACE", "SATURATE", "SCREEN",
-				"SOFT_LIGHT", "SRC", "SRC_ATOP", "SRC_IN", "SRC_OUT", "SRC_OVER", "THRESHOLD", "VIVID_LIGHT",
-				"XOR",
-			}},
+			{Name: "sourceImagePath", Type: ParamTypeString, Required: true, Hint: "Filesystem path or URL to the overlay/source image, or buffer:<name> for an open image.", Example: "overlay.png"},
+			{Name: "composeOperator", Type: ParamTypeEnum, Required: true, Hint: "Compositing operator / blend mode. Choose the desired blend behavior.", Example: "OVER", EnumOptions: composeOperatorOptions},
 			{Name: "x", Type: ParamTypeInt, Required: true, Hint: "X offset in pixels where the source is placed relative to top-left.", Example: "100", Unit: "px"},
 			{Name: "y", Type: ParamTypeInt, Required: true, Hint: "Y offset in pixels where the source is placed relative to top-left.", Example: "50", Unit: "px"},
+			{Name: "clipToSelf", Type: ParamTypeBool, Required: false, Hint: "Limit the operator to the area the source covers. Set false for operators such as SRC_IN or DST_IN that should also clear the rest of the image. Default true.", Example: "true"},
 		},
 	},
 	{
@@ -161,6 +251,14 @@ var Commands = []CommandMeta{
 			{Name: "high", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Max: float64Ptr(100.0), Hint: "Upper percent to clip (0-100).", Unit: "%", Example: "99.5"},
 		},
 	},
+	{
+		Name:        "convolve",
+		Description: "Filter the image with a custom convolution kernel",
+		Params: []ParamMeta{
+			{Name: "kernel", Type: ParamTypeString, Required: true, Hint: "Kernel values, rows separated by ';' and values by ','. Each output pixel is the weighted sum of its neighborhood. -1,-1,-1;-1,9,-1;-1,-1,-1 sharpens; 1,1,1;1,1,1;1,1,1 with normalize blurs.", Example: "-1,-1,-1;-1,9,-1;-1,-1,-1"},
+			{Name: "normalize", Type: ParamTypeBool, Required: false, Hint: "Scale the kernel so its values sum to 1, keeping the overall brightness. Default false.", Example: "false"},
+		},
+	},
 	{
 		Name:        "crop",
 		Description: "Crop the image to a rectangle",
@@ -171,11 +269,50 @@ var Commands = []CommandMeta{
 			{Name: "y", Type: ParamTypeInt, Required: true, Hint: "Y offset in pixels of the crop origin.", Example: "0", Unit: "px"},
 		},
 	},
+	{
+		Name:        "cropAspect",
+		Description: "Crop to the largest rectangle of a standard aspect ratio (1:1, 4:3, 3:2, 16:9, A4)",
+		Params: []ParamMeta{
+			{Name: "ratio", Type: ParamTypeEnum, Required: true, Hint: "Aspect ratio, long side to short side. A4 is 1:1.414, the ratio of all ISO paper sizes.", Example: "3:2", EnumOptions: aspectRatios},
+			{Name: "orientation", Type: ParamTypeEnum, Required: false, Hint: "AUTO (default) keeps the image's own orientation; LANDSCAPE or PORTRAIT force one, e.g. 4:3 as 3:4.", Example: "AUTO", EnumOptions: aspectOrientations},
+			{Name: "gravity", Type: ParamTypeEnum, Required: false, Hint: "Which part of the image to keep. Default CENTER; NORTH keeps the top, e.g. for heads in portraits.", Example: "CENTER", EnumOptions: cropGravities},
+		},
+	},
+	{
+		Name:        "curves",
+		Description: "Adjust tones with a curve through control points",
+		Params: []ParamMeta{
+			{Name: "points", Type: ParamTypeString, Required: true, Hint: "Control points as input,output pairs separated by spaces, in 0-1 or 0-255. The curve passes through every point; levels beyond the first and last point keep their outputs.", Example: "0,0 0.25,0.2 0.75,0.8 1,1"},
+			{Name: "channel", Type: ParamTypeEnum, Required: false, Hint: "Channel the curve applies to. Default RGB (all three).", Example: "RGB", EnumOptions: curveChannels},
+		},
+	},
+	{
+		Name: "deepzoom",
+		Description: "Export a Deep Zoom (DZI) tile pyramid for zoomable web viewers such as OpenSeadragon\n" +
+			"Writes <name>.dzi and a <name>_files directory of tiles; the current image is not changed.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "output", Type: ParamTypeString, Required: true, Hint: "Path of the .dzi file; the tiles go in a _files directory next to it.", Example: "web/photo.dzi"},
+			{Name: "tileSize", Type: ParamTypeInt, Required: false, Min: float64Ptr(16), Max: float64Ptr(4096), Hint: "Tile width and height before overlap. Default 254 (256 px tiles with the overlap).", Example: "254", Unit: "px"},
+			{Name: "overlap", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Max: float64Ptr(64), Hint: "Pixels each tile shares with its neighbors. Default 1.", Example: "1", Unit: "px"},
+			{Name: "format", Type: ParamTypeEnum, Required: false, Hint: "Tile format. Default JPG (small, no transparency); PNG keeps alpha and exact pixels.", Example: "JPG", EnumOptions: deepZoomFormats},
+		},
+	},
+	{
+		Name:        "descreen",
+		Description: "Remove the halftone dot pattern (moiré) of a scanned magazine or book page",
+		Params: []ParamMeta{
+			{Name: "lpi", Type: ParamTypeFloat, Required: true, Min: float64Ptr(10), Max: float64Ptr(600), Hint: "Screen ruling of the print in lines per inch: about 85 for newspapers, 133-150 for magazines and books, 175+ for art prints.", Example: "150", Unit: "lpi"},
+			{Name: "dpi", Type: ParamTypeFloat, Required: false, Min: float64Ptr(1), Hint: "Scan resolution. Default: the image's own resolution, or 300.", Example: "600", Unit: "dpi"},
+			{Name: "method", Type: ParamTypeEnum, Required: false, Hint: "GAUSSIAN = blur over one screen period (default, fast); FFT = cut the spectrum off below the screen frequency (keeps more detail).", Example: "FFT", EnumOptions: descreenMethods},
+			{Name: "sharpen", Type: ParamTypeBool, Required: false, Hint: "Restore edge contrast with a light unsharp mask afterwards. Default true.", Example: "true"},
+		},
+	},
 	{
 		Name:        "deskew",
 		Description: "Reduce skew in the image using an automatic algorithm",
 		Params: []ParamMeta{
-			{Name: "threshold", Type: ParamTypeFloat, Required: true, Hint: "Threshold used to detect skew; smaller values = more sensitive.", Example: "40.0"},
+			{Name: "threshold", Type: ParamTypeQuantum, Required: true, Min: float64Ptr(0), Hint: "Threshold used to detect skew, in the quantum range or as a percentage; smaller values = more sensitive.", Example: "40%"},
 		},
 	},
 	{
@@ -183,6 +320,15 @@ var Commands = []CommandMeta{
 		Description: "Reduce speckle noise in the image",
 		Params:      []ParamMeta{},
 	},
+	{
+		Name:        "distort",
+		Description: "Distort the image geometrically, e.g. correct the perspective of a photographed document",
+		Params: []ParamMeta{
+			{Name: "distortMethod", Type: ParamTypeEnum, Required: true, Hint: "PERSPECTIVE maps 4 points to 4 others (document correction); ARC bends into an arc; BARREL corrects lens distortion; POLYNOMIAL and SHEPARDS warp through any number of point pairs.", Example: "PERSPECTIVE", EnumOptions: distortMethodNames},
+			{Name: "points", Type: ParamTypeString, Required: true, Hint: "Numbers separated by spaces or commas, as for magick -distort. PERSPECTIVE: x,y of each source corner followed by where it goes (16 numbers); ARC: degrees; BARREL: a b c [d].", Example: "12,40 0,0 580,22 600,0 596,810 600,800 8,790 0,800"},
+			{Name: "bestfit", Type: ParamTypeBool, Required: false, Hint: "Enlarge the canvas to hold the whole distorted image instead of keeping the original size. Default false.", Example: "false"},
+		},
+	},
 	{
 		Name:        "edge",
 		Description: "Detect edges in the image",
@@ -190,6 +336,25 @@ var Commands = []CommandMeta{
 			{Name: "radius", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Unit: "px", Hint: "Filter radius for edge detection. Lower = detect thin details; higher = thicker edges.", Example: "1.0"},
 		},
 	},
+	{
+		Name:        "editIn",
+		Description: "Open the image in an external editor (GIMP, Krita...) and reload it when the editor exits",
+		Params: []ParamMeta{
+			{Name: "program", Type: ParamTypeString, Required: false, Hint: "Editor to run, with any arguments. Default: $TERMAGICK_IMAGE_EDITOR, else the first of gimp, krita or pinta found.", Example: "krita --nosplash"},
+		},
+	},
+	{
+		Name: "eink",
+		Description: "Export a 1-bit dithered copy for an e-ink display: fitted to the panel, grayscale, gamma-corrected, ordered dither\n" +
+			"The current image is not changed; the copy is written as a 1-bit PNG or PBM.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "output", Type: ParamTypeString, Required: true, Hint: "Output file path ending in .png or .pbm.", Example: "dashboard.png"},
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Panel width in pixels; the image is fitted and padded with white.", Example: "800", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Panel height in pixels.", Example: "480", Unit: "px"},
+			{Name: "gamma", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0.1), Max: float64Ptr(5), Hint: "Gamma applied before dithering; above 1 lightens midtones, which e-paper renders dark. Default 1.5.", Example: "1.5"},
+		},
+	},
 	{
 		Name:        "emboss",
 		Description: "Create an embossed effect",
@@ -203,11 +368,69 @@ var Commands = []CommandMeta{
 		Description: "Equalize the image histogram to boost global contrast",
 		Params:      []ParamMeta{},
 	},
+	{
+		Name:        "equalizeRGB",
+		Description: "Equalize the histogram of each RGB channel separately, which also evens out color casts",
+		Params:      []ParamMeta{},
+	},
 	{
 		Name:        "enhance",
 		Description: "Enhance image quality (reduce noise and improve clarity)",
 		Params:      []ParamMeta{},
 	},
+	{
+		Name: "exportAsync",
+		Description: "Save a copy of the current image in the background, optionally after one more command (e.g. an upscale)\n" +
+			"The session stays usable while the job runs; see the jobs command.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "output", Type: ParamTypeString, Required: true, Hint: "Output file path; the extension selects the format.", Example: "large.png"},
+			{Name: "command", Type: ParamTypeString, Required: false, Hint: "Command applied to the copy before saving, written as in a recipe. The current image is not changed.", Example: "resize 6000 4000"},
+		},
+	},
+	{
+		Name: "exportBatch",
+		Description: "Apply the commands used on the current image to a set of files in the background\n" +
+			"Results are written to the output directory under the same names; see the jobs command.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "files", Type: ParamTypeString, Required: true, Hint: "Glob, directory or list of images to process.", Example: "raw/*.jpg"},
+			{Name: "outDir", Type: ParamTypeString, Required: true, Hint: "Directory the results are written to; created if missing.", Example: "out"},
+			{Name: "format", Type: ParamTypeString, Required: false, Hint: "Output file extension, e.g. webp. Default keeps each file's format.", Example: "webp"},
+		},
+	},
+	{
+		Name:        "extent",
+		Description: "Change the canvas size without scaling, adding background or cutting off the edges",
+		Params: []ParamMeta{
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "New canvas width. 0 keeps the current width.", Example: "1200", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "New canvas height. 0 keeps the current height.", Example: "800", Unit: "px"},
+			{Name: "gravity", Type: ParamTypeEnum, Required: false, Hint: "Where the image sits on the canvas. Default CENTER.", Example: "CENTER", EnumOptions: cropGravities},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Color of the added canvas. Default white; none is transparent.", Example: "white"},
+		},
+	},
+	{
+		Name:        "fftNotch",
+		Description: "Remove periodic patterns (moiré, fabric, screen dots) by notching their peaks out of the Fourier spectrum",
+		Params: []ParamMeta{
+			{Name: "notches", Type: ParamTypeString, Required: true, Hint: "auto = suppress the strongest peaks, or dx,dy offsets from the spectrum center as listed by fftSpectrum (mirrored peaks are included).", Example: "40,-12 0,35"},
+			{Name: "radius", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0.5), Hint: "Notch radius in spectrum pixels. Default 3; larger removes more but can blur detail.", Example: "3", Unit: "px"},
+		},
+	},
+	{
+		Name: "fftSpectrum",
+		Description: "Show the Fourier magnitude spectrum of the image and list its periodic peaks\n" +
+			"This command does not modify the image; use fftNotch to remove the peaks it finds.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "output", Type: ParamTypeString, Required: false, Hint: "Also write the spectrum to this file path.", Example: "spectrum.png"},
+		},
+	},
+	{
+		Name:        "flatten",
+		Description: "Merge all layers into the background image",
+		Params:      []ParamMeta{},
+	},
 	{
 		Name:        "flip",
 		Description: "Flip the image vertically (top ↔ bottom)",
@@ -230,6 +453,40 @@ var Commands = []CommandMeta{
 		Description: "Flip the image horizontally (left ↔ right)",
 		Params:      []ParamMeta{},
 	},
+	{
+		Name: "fonts",
+		Description: "List the fonts known to ImageMagick, optionally filtered by a pattern\n" +
+			"This command does not modify the image; it only outputs information.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "pattern", Type: ParamTypeString, Required: false, Hint: "Glob-style filter on font names (e.g. *Sans*). Default lists all fonts.", Example: "*Sans*"},
+		},
+	},
+	{
+		Name: "frameTiming",
+		Description: "List the delay and dispose method of every animation frame\n" +
+			"This command does not modify the image; it only outputs information.",
+		WholeSequence: true,
+		Params:        []ParamMeta{},
+	},
+	{
+		Name:        "frame",
+		Description: "Surround the image with a raised 3D matte frame",
+		Params: []ParamMeta{
+			{Name: "color", Type: ParamTypeString, Required: true, Hint: "Frame color; the bevels are lighter and darker shades of it.", Example: "#bdbdbd"},
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Frame width on the left and right, bevels included.", Example: "25", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: false, Min: float64Ptr(1), Hint: "Frame height at the top and bottom. Default the same as width.", Example: "25", Unit: "px"},
+			{Name: "outerBevel", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Width of the lit outer edge. Default a quarter of the frame.", Example: "6", Unit: "px"},
+			{Name: "innerBevel", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Width of the shaded inner edge. Default a quarter of the frame.", Example: "6", Unit: "px"},
+		},
+	},
+	{
+		Name:        "fx",
+		Description: "Evaluate an ImageMagick FX expression for every pixel, for operations no other command covers",
+		Params: []ParamMeta{
+			{Name: "expression", Type: ParamTypeString, Required: true, Hint: "FX expression, evaluated per channel with u as the pixel value (0-1), e.g. u*1.2-0.1 for contrast or (r+g+b)/3 for gray. See https://imagemagick.org/script/fx.php.", Example: "u*1.2-0.1"},
+		},
+	},
 	{
 		Name:        "gamma",
 		Description: "Apply gamma correction",
@@ -237,6 +494,19 @@ var Commands = []CommandMeta{
 			{Name: "gamma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Hint: "Gamma factor. < 1 brightens midtones; > 1 darkens midtones. 1.0 = neutral.", Example: "1.0"},
 		},
 	},
+	{
+		Name:         "generateNoise",
+		Description:  "Create a new noise, plasma or gradient image (replaces the current image)",
+		CreatesImage: true,
+		Params: []ParamMeta{
+			{Name: "pattern", Type: ParamTypeEnum, Required: true, Hint: "NOISE = per-pixel noise, PLASMA = fractal clouds, GRADIENT = linear top-to-bottom, RADIAL_GRADIENT = center-to-edge.", Example: "PLASMA", EnumOptions: noisePatterns},
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Image width in pixels.", Example: "512", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Image height in pixels.", Example: "512", Unit: "px"},
+			{Name: "color1", Type: ParamTypeString, Required: false, Hint: "Base color for NOISE (default gray50) or start color for plasma/gradients (default white).", Example: "#ffffff"},
+			{Name: "color2", Type: ParamTypeString, Required: false, Hint: "End color for plasma/gradients. Default black.", Example: "#000000"},
+			{Name: "noiseType", Type: ParamTypeEnum, Required: false, Hint: "Noise distribution for NOISE. Default RANDOM.", Example: "GAUSSIAN", EnumOptions: []string{"UNDEFINED", "UNIFORM", "GAUSSIAN", "MULTIPLICATIVE", "IMPULSE", "LAPLACIAN", "POISSON", "RANDOM"}},
+		},
+	},
 	{
 		Name:        "grayscale",
 		Description: "Convert the image to grayscale colorspace",
@@ -249,19 +519,165 @@ var Commands = []CommandMeta{
 			{Name: "n", Type: ParamTypeInt, Required: false, Min: float64Ptr(1), Max: float64Ptr(4096), Hint: "Number of bins to group intensities for the plotted histograms. Default 256 — lower = smoother, higher = more detailed (may be slower).", Example: "256"},
 		},
 	},
+	{
+		Name: "icons",
+		Description: "Export a favicon and app icon set: PNGs from 16 to 1024 px, favicon.ico and icon.icns where supported\n" +
+			"The current image is not changed; non-square images are centered on a transparent square.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "outDir", Type: ParamTypeString, Required: true, Hint: "Directory the icons are written to; created if missing.", Example: "icons"},
+		},
+	},
 	{
 		Name: "identify",
 		Description: "Identify and display image metadata (format, dimensions, color depth, profiles, etc.)\n" +
 			"This command does not modify the image; it only outputs information.",
 		Params: []ParamMeta{},
 	},
+	{
+		Name:        "implode",
+		Description: "Pull the image in towards its center, or push it out with a negative amount",
+		Params: []ParamMeta{
+			{Name: "amount", Type: ParamTypeFloat, Required: true, Min: float64Ptr(-10), Max: float64Ptr(10), Hint: "Strength of the effect. 0.5 = a strong pinch; negative values bulge outwards (explode).", Example: "0.5"},
+		},
+	},
+	{
+		Name: "import",
+		Description: "List or import photos from a camera (over USB with gphoto2) or from a phone or card mounted as a folder\n" +
+			"Copies files to disk; without a destination it only lists them.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "source", Type: ParamTypeString, Required: true, Hint: "camera for the first camera gphoto2 detects, or a mounted folder such as a phone's DCIM.", Example: "camera"},
+			{Name: "destination", Type: ParamTypeString, Required: false, Hint: "Directory the photos are copied to; files already there are skipped. Empty lists the photos only.", Example: "photos/2026-10"},
+			{Name: "open", Type: ParamTypeBool, Required: false, Hint: "Open the first imported photo for editing. Default false.", Example: "true"},
+		},
+	},
+	{
+		Name: "inspectPixel",
+		Description: "Print the color of the pixel at a point (click the preview to pick it in supported terminals)\n" +
+			"This command does not modify the image; it only outputs information.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "x", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "X coordinate of the pixel.", Example: "10", Unit: "px"},
+			{Name: "y", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Y coordinate of the pixel.", Example: "20", Unit: "px"},
+		},
+	},
+	{
+		Name: "jobs",
+		Description: "List background jobs with their progress\n" +
+			"This command does not modify the image; it only outputs information.",
+		NoImage: true,
+		Params:  []ParamMeta{},
+	},
+	{
+		Name:        "kuwahara",
+		Description: "Smooth the image while keeping edges sharp (Kuwahara filter), for portraits and scans",
+		Params: []ParamMeta{
+			{Name: "radius", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Max: float64Ptr(50), Hint: "Size of the smoothing neighborhood. 2-4 = gentle skin and paper smoothing; 8+ = painted look.", Example: "3", Unit: "px"},
+		},
+	},
+	{
+		Name:        "layerProps",
+		Description: "Change the offset, opacity or blend mode of the selected layer",
+		Params: []ParamMeta{
+			{Name: "x", Type: ParamTypeInt, Required: false, Hint: "New X offset. Empty keeps the current value.", Example: "10", Unit: "px"},
+			{Name: "y", Type: ParamTypeInt, Required: false, Hint: "New Y offset. Empty keeps the current value.", Example: "10", Unit: "px"},
+			{Name: "opacity", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0.0), Max: float64Ptr(1.0), Hint: "New opacity from 0.0 to 1.0. Empty keeps the current value.", Example: "0.5"},
+			{Name: "composeOperator", Type: ParamTypeEnum, Required: false, Hint: "New blend mode. Empty keeps the current value.", Example: "MULTIPLY", EnumOptions: composeOperatorOptions},
+		},
+	},
+	{
+		Name: "layers",
+		Description: "List the layer stack (0 is the background; * marks the layer commands apply to)\n" +
+			"This command does not modify the image; it only outputs information.",
+		Params: []ParamMeta{},
+	},
 	{
 		Name:        "level",
 		Description: "Remap image levels (black point, gamma, white point)",
 		Params: []ParamMeta{
-			{Name: "blackPoint", Type: ParamTypeFloat, Required: true, Hint: "Black point (0-QuantumRange).", Example: "0.0"},
+			{Name: "blackPoint", Type: ParamTypeQuantum, Required: true, Min: float64Ptr(0), Hint: "Black point: a value in the quantum range (0-65535 on Q16 builds) or a percentage such as 5%.", Example: "5%"},
 			{Name: "gamma", Type: ParamTypeFloat, Required: true, Hint: "Gamma adjustment value.", Example: "1.0"},
-			{Name: "whitePoint", Type: ParamTypeFloat, Required: true, Hint: "White point (0-QuantumRange).", Example: "100.0"},
+			{Name: "whitePoint", Type: ParamTypeQuantum, Required: true, Min: float64Ptr(0), Hint: "White point: a value in the quantum range or a percentage such as 95%.", Example: "95%"},
+		},
+	},
+	{
+		Name:        "levelChannel",
+		Description: "Remap the levels of one channel (black point, gamma, white point), e.g. to correct a color cast",
+		Params: []ParamMeta{
+			{Name: "channel", Type: ParamTypeEnum, Required: true, Hint: "Channel to adjust. A blue cast is reduced by raising the blue black point or lowering blue's gamma.", Example: "BLUE", EnumOptions: levelChannels},
+			{Name: "blackPoint", Type: ParamTypeQuantum, Required: true, Min: float64Ptr(0), Hint: "Black point: a value in the quantum range (0-65535 on Q16 builds) or a percentage such as 5%.", Example: "5%"},
+			{Name: "gamma", Type: ParamTypeFloat, Required: true, Hint: "Gamma adjustment value; below 1 darkens the channel's midtones, above 1 lightens them.", Example: "0.9"},
+			{Name: "whitePoint", Type: ParamTypeQuantum, Required: true, Min: float64Ptr(0), Hint: "White point: a value in the quantum range or a percentage such as 95%.", Example: "95%"},
+		},
+	},
+	{
+		Name: "levelsUI",
+		Description: "Set levels interactively: move black, gamma and white markers under a histogram with the arrow keys while the preview updates\n" +
+			"Enter applies the values as a level step; Esc cancels.",
+		Params: []ParamMeta{},
+	},
+	{
+		Name:        "liquidRescale",
+		Description: "Resize by removing or adding low-detail seams (seam carving), keeping the main subjects undistorted",
+		Params: []ParamMeta{
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Target width in pixels.", Example: "1080", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Target height in pixels.", Example: "1080", Unit: "px"},
+			{Name: "rigidity", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0), Hint: "Bias against slanted seams. 0 (default) lets seams bend freely; higher values keep straight lines straighter.", Example: "0"},
+		},
+	},
+	{
+		Name:        "losslessCrop",
+		Description: "Crop a JPEG without recompressing it (jpegtran); the offset snaps to the 8/16 px block grid",
+		Params: []ParamMeta{
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Crop width in pixels.", Example: "800", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Crop height in pixels.", Example: "600", Unit: "px"},
+			{Name: "x", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "X offset; rounded down to a multiple of the JPEG block size (usually 8 or 16).", Example: "0", Unit: "px"},
+			{Name: "y", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Y offset; rounded down to a multiple of the JPEG block size (usually 8 or 16).", Example: "0", Unit: "px"},
+		},
+	},
+	{
+		Name:        "losslessRotate",
+		Description: "Rotate a JPEG by 90/180/270 degrees without recompressing it (jpegtran)",
+		Params: []ParamMeta{
+			{Name: "degrees", Type: ParamTypeInt, Required: true, Hint: "Clockwise rotation: 90, 180 or 270.", Example: "90", Unit: "deg"},
+		},
+	},
+	{
+		Name:        "lut",
+		Description: "Apply a color lookup table: a .cube file (1D or 3D) or a HALD CLUT image",
+		Params: []ParamMeta{
+			{Name: "lutPath", Type: ParamTypeString, Required: true, Hint: "Path to a .cube file or a HALD CLUT image (e.g. a 512x512 PNG), or buffer:<name>.", Example: "kodak-portra.cube"},
+			{Name: "strength", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0), Max: float64Ptr(100), Hint: "How much of the LUT to apply, from 0 (none) to 100 (full). Default 100.", Example: "70", Unit: "%"},
+		},
+	},
+	{
+		Name:         "makeGif",
+		Description:  "Assemble a set of still images into an animated GIF/WebP (replaces the current image)",
+		CreatesImage: true,
+		Params: []ParamMeta{
+			{Name: "files", Type: ParamTypeString, Required: true, Hint: "Glob pattern (e.g. frames/*.png) or list of image files; enter '/' to multi-select with fzf (Tab marks files).", Example: "frames/*.png"},
+			{Name: "delay", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Delay between frames in 1/100 s. Lower = faster animation.", Example: "10", Unit: "cs"},
+			{Name: "loops", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Number of times the animation repeats. 0 = loop forever.", Example: "0"},
+		},
+	},
+	{
+		Name:        "mask",
+		Description: "Restrict later commands to part of the image with a grayscale mask, until mask CLEAR",
+		Params: []ParamMeta{
+			{Name: "action", Type: ParamTypeEnum, Required: true, Hint: "LOAD a mask image (white = editable, black = protected), build a RECT or ELLIPSE one, or CLEAR the mask.", Example: "LOAD", EnumOptions: maskActions},
+			{Name: "source", Type: ParamTypeString, Required: false, Hint: "Mask image file or buffer:<name> for LOAD (scaled to the image); region as WxH+X+Y for RECT and ELLIPSE.", Example: "200x150+40+30"},
+			{Name: "feather", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0), Hint: "Blur the mask's edges by this many pixels for a soft transition. Default 0.", Example: "8", Unit: "px"},
+			{Name: "invert", Type: ParamTypeBool, Required: false, Hint: "Protect the white areas instead and edit everything else.", Example: "false"},
+		},
+	},
+	{
+		Name:        "matchColors",
+		Description: "Transfer the color distribution of a reference image onto the current one (for consistent photo series)",
+		Params: []ParamMeta{
+			{Name: "referencePath", Type: ParamTypeString, Required: true, Hint: "Image whose colors should be matched, or buffer:<name> for an open image.", Example: "reference.jpg"},
+			{Name: "method", Type: ParamTypeEnum, Required: false, Hint: "LAB_STATS = match mean/contrast of each LAB channel (natural, default); HISTOGRAM = match each RGB channel's histogram (closer, can exaggerate).", Example: "LAB_STATS", EnumOptions: matchMethods},
+			{Name: "strength", Type: ParamTypePercent, Required: false, Min: float64Ptr(0), Max: float64Ptr(100), Hint: "How far to move towards the reference; 100 = full match (default).", Example: "80", Unit: "%"},
 		},
 	},
 	{
@@ -271,6 +687,15 @@ var Commands = []CommandMeta{
 			{Name: "radius", Type: ParamTypeInt, Required: true, Min: float64Ptr(0.0), Hint: "Radius for the median filter kernel.", Example: "1"},
 		},
 	},
+	{
+		Name:        "metadata",
+		Description: "List, set or delete EXIF, IPTC and other metadata properties and profiles, one key at a time",
+		Params: []ParamMeta{
+			{Name: "action", Type: ParamTypeEnum, Required: true, Hint: "LIST properties and profiles, SET one property, or DELETE the properties matching a pattern.", Example: "LIST", EnumOptions: metadataActions},
+			{Name: "key", Type: ParamTypeString, Required: false, Hint: "Property name, e.g. exif:DateTimeOriginal or comment; profile:xmp names a whole profile. LIST and DELETE accept globs such as exif:GPS*. Empty lists everything.", Example: "exif:GPS*"},
+			{Name: "value", Type: ParamTypeString, Required: false, Hint: "New value for SET. EXIF dates are written as YYYY:MM:DD HH:MM:SS.", Example: "2024:06:01 18:30:00"},
+		},
+	},
 	{
 		Name:        "modulate",
 		Description: "Adjust brightness, saturation and hue",
@@ -285,6 +710,34 @@ var Commands = []CommandMeta{
 		Description: "Convert the image to bilevel (pure black & white)",
 		Params:      []ParamMeta{},
 	},
+	{
+		Name:         "montage",
+		Description:  "Tile a directory or list of images into a contact sheet (replaces the current image)",
+		CreatesImage: true,
+		Params: []ParamMeta{
+			{Name: "files", Type: ParamTypeString, Required: true, Hint: "Directory, glob pattern (e.g. shots/*.jpg) or list of image files; enter '/' to multi-select with fzf (Tab marks files).", Example: "shots/"},
+			{Name: "tile", Type: ParamTypeString, Required: false, Hint: "Grid as COLUMNSxROWS; either may be omitted (e.g. 5x). Empty = choose automatically.", Example: "5x"},
+			{Name: "thumbnail", Type: ParamTypeString, Required: false, Hint: "Tile size and spacing as WxH+X+Y. Default 200x200+4+4.", Example: "200x200+4+4"},
+			{Name: "labels", Type: ParamTypeBool, Required: false, Hint: "Caption each tile with its file name. Default true.", Example: "true"},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Sheet background color (hex, rgb(), or name). Default white.", Example: "#ffffff"},
+		},
+	},
+	{
+		Name:        "motionBlur",
+		Description: "Blur the image along one direction, as if the camera or subject moved",
+		Params: []ParamMeta{
+			{Name: "radius", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Hint: "Radius of the blur in pixels. 0 lets ImageMagick pick one from sigma.", Example: "0", Unit: "px"},
+			{Name: "sigma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Hint: "Length of the streak; higher = longer motion trail.", Example: "12", Unit: "px"},
+			{Name: "angle", Type: ParamTypeFloat, Required: true, Hint: "Direction of the motion. 0 = streaks to the right, 90 = downwards.", Example: "0", Unit: "deg"},
+		},
+	},
+	{
+		Name:        "moveLayer",
+		Description: "Move the selected layer to another position in the stack",
+		Params: []ParamMeta{
+			{Name: "position", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "New position: 1 = just above the background; higher = closer to the top.", Example: "1"},
+		},
+	},
 	{
 		Name:        "negate",
 		Description: "Negate (invert) the colors of the image",
@@ -292,6 +745,20 @@ var Commands = []CommandMeta{
 			{Name: "only_gray", Type: ParamTypeBool, Required: true, Hint: "true = invert only grayscale channel; false = invert all channels (full negative).", Example: "false"},
 		},
 	},
+	{
+		Name: "ninePatch",
+		Description: "Export the image as a nine-patch: an Android .9.png, or CSS border-image slices with the matching rule\n" +
+			"Corners keep their size when the asset is scaled; the current image is not changed.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "output", Type: ParamTypeString, Required: true, Hint: "ANDROID: output file (the .9.png extension is added). CSS: directory for border.png and the nine slices.", Example: "button.9.png"},
+			{Name: "style", Type: ParamTypeEnum, Required: false, Hint: "ANDROID 9-patch or CSS border-image slices. Default ANDROID.", Example: "ANDROID", EnumOptions: ninePatchStyles},
+			{Name: "top", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Height of the top corners in pixels.", Example: "12", Unit: "px"},
+			{Name: "right", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Width of the right corners. Default: same as top.", Example: "12", Unit: "px"},
+			{Name: "bottom", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Height of the bottom corners. Default: same as top.", Example: "12", Unit: "px"},
+			{Name: "left", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Width of the left corners. Default: same as right.", Example: "12", Unit: "px"},
+		},
+	},
 	{
 		Name:        "normalize",
 		Description: "Normalize image to use full dynamic range",
@@ -305,6 +772,57 @@ var Commands = []CommandMeta{
 			{Name: "sigma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Hint: "Smoothness/intensity of the oil effect. Lower = more texture; higher = softer.", Example: "1.0"},
 		},
 	},
+	{
+		Name:        "onionSkin",
+		Description: "Toggle the onion skin: previews of an animation show the neighboring frames faded over the current one (the image is not changed)",
+		Params: []ParamMeta{
+			{Name: "enabled", Type: ParamTypeBool, Required: false, Hint: "true = on, false = off; empty toggles, or turns it on when opacity or frames are given.", Example: "true"},
+			{Name: "opacity", Type: ParamTypeFloat, Required: false, Min: float64Ptr(1), Max: float64Ptr(100), Hint: "Opacity of the nearest frames; farther ones fade with distance. Default 30.", Example: "30", Unit: "%"},
+			{Name: "frames", Type: ParamTypeInt, Required: false, Min: float64Ptr(1), Max: float64Ptr(maxOnionFrames), Hint: "Frames shown on each side. Default 1.", Example: "1"},
+		},
+	},
+	{
+		Name:         "packSheet",
+		Description:  "Pack a directory or list of sprites into a sprite sheet on a grid of equal cells (replaces the current image)",
+		CreatesImage: true,
+		Params: []ParamMeta{
+			{Name: "files", Type: ParamTypeString, Required: true, Hint: "Directory, glob pattern (e.g. walk/*.png) or list of images, packed in name order; enter '/' to multi-select with fzf (Tab marks files).", Example: "sprites/walk/"},
+			{Name: "columns", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Cells per row. Default 0 = a near-square grid.", Example: "8"},
+			{Name: "padding", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Pixels between cells and around the sheet. Default 0.", Example: "2", Unit: "px"},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Sheet background color (hex, rgb(), or name). Default none (transparent).", Example: "none"},
+		},
+	},
+	{
+		Name:        "padToAspect",
+		Description: "Pad the canvas to an aspect ratio such as 16:9 or 1:1, keeping the whole image",
+		Params: []ParamMeta{
+			{Name: "ratio", Type: ParamTypeString, Required: true, Hint: "Target ratio as W:H: 1:1, 4:5, 16:9, 9:16 for portrait...", Example: "16:9"},
+			{Name: "gravity", Type: ParamTypeEnum, Required: false, Hint: "Where the image sits on the padded canvas. Default CENTER.", Example: "CENTER", EnumOptions: cropGravities},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Color of the padding. Default white; none is transparent.", Example: "black"},
+		},
+	},
+	{
+		Name:        "palette",
+		Description: "Print the image's main colors as swatches with hex codes, optionally saving them as a palette file",
+		NoImage:     true,
+		Params: []ParamMeta{
+			{Name: "colors", Type: ParamTypeInt, Required: false, Min: float64Ptr(1), Max: float64Ptr(64), Hint: "Number of colors to extract. Default 8.", Example: "6"},
+			{Name: "output", Type: ParamTypeString, Required: false, Hint: "Palette file to write: .gpl (GIMP, Inkscape, Krita) or .json. Default: print only.", Example: "sunset.gpl"},
+		},
+	},
+	{
+		Name:          "pingPongFrames",
+		Description:   "Append the frames in reverse so the animation plays forwards then backwards",
+		WholeSequence: true,
+		Params:        []ParamMeta{},
+	},
+	{
+		Name:        "pipe",
+		Description: "Filter the image through a shell command: the image goes to its stdin as PNG and is replaced by the image it writes to stdout",
+		Params: []ParamMeta{
+			{Name: "command", Type: ParamTypeString, Required: true, Hint: "Shell command reading an image on stdin and writing one to stdout; use - for stdin/stdout where the tool wants file names.", Example: "pngquant 64 -"},
+		},
+	},
 	{
 		Name:        "polaroid",
 		Description: "Simulate a Polaroid picture",
@@ -322,12 +840,66 @@ var Commands = []CommandMeta{
 			{Name: "dither", Type: ParamTypeBool, Required: true, Hint: "Enable dithering to reduce visual banding (adds grain-like pattern).", Example: "true"},
 		},
 	},
+	{
+		Name:        "region",
+		Description: "Confine later commands to a rectangle, applying them to it alone and putting it back, until region CLEAR",
+		Params: []ParamMeta{
+			{Name: "action", Type: ParamTypeEnum, Required: true, Hint: "SET a region, or CLEAR it to edit the whole image again.", Example: "SET", EnumOptions: regionActions},
+			{Name: "region", Type: ParamTypeString, Required: false, Hint: "Rectangle as WxH+X+Y in pixels for SET; in interactive mode two opposite corners can be clicked on the preview.", Example: "240x80+610+420"},
+		},
+	},
+	{
+		Name:        "removeBackground",
+		Description: "Make the background transparent by flood-filling from the four corners, e.g. to cut out a product photo",
+		Params: []ParamMeta{
+			{Name: "fuzz", Type: ParamTypePercent, Required: true, Min: float64Ptr(0.0), Max: float64Ptr(100.0), Hint: "How far a pixel's color may be from the corner's and still count as background. 5-15 suits an even studio backdrop; too high eats into the subject.", Example: "10", Unit: "%"},
+			{Name: "feather", Type: ParamTypeFloat, Required: false, Min: float64Ptr(0.0), Hint: "Soften the edge of the cut-out by this many pixels. Default 0 (hard edge).", Example: "1.5", Unit: "px"},
+		},
+	},
+	{
+		Name:        "removeLayer",
+		Description: "Delete the selected layer and select the background",
+		Params:      []ParamMeta{},
+	},
 	{
 		Name:        "resize",
 		Description: "Resize the image",
 		Params: []ParamMeta{
-			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Target width in pixels. Use 0 to preserve aspect ratio if supported by your UI.", Example: "1024", Unit: "px"},
-			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Target height in pixels. Use 0 to preserve aspect ratio if supported by your UI.", Example: "768", Unit: "px"},
+			{Name: "width", Type: ParamTypeString, Required: true, Hint: "Target width in pixels or as a percentage (50%); 0 follows the height to keep the aspect ratio. A geometry such as 800x, x600 or 1600x1200> may be given here instead of both sides.", Example: "1024"},
+			{Name: "height", Type: ParamTypeString, Required: false, Hint: "Target height in pixels or as a percentage. 0 or empty follows the width to keep the aspect ratio.", Example: "768"},
+			{Name: "filter", Type: ParamTypeEnum, Required: false, Hint: "Resampling filter. Default LANCZOS suits photos; POINT keeps pixel art crisp; MITCHELL or CATROM are softer/sharper alternatives for upscaling.", Example: "POINT", EnumOptions: resizeFilterNames},
+		},
+	},
+	{
+		Name:        "resizeLinear",
+		Description: "Resize in linear light, avoiding the darkened detail and dark halos of resizing sRGB values directly",
+		Params: []ParamMeta{
+			{Name: "width", Type: ParamTypeString, Required: true, Hint: "Target width in pixels or as a percentage (50%); 0 follows the height to keep the aspect ratio. A geometry such as 800x, x600 or 1600x1200> may be given here instead of both sides.", Example: "1024"},
+			{Name: "height", Type: ParamTypeString, Required: false, Hint: "Target height in pixels or as a percentage. 0 or empty follows the width to keep the aspect ratio.", Example: "768"},
+			{Name: "filter", Type: ParamTypeEnum, Required: false, Hint: "Resampling filter, as for resize. Default LANCZOS.", Example: "LANCZOS", EnumOptions: resizeFilterNames},
+		},
+	},
+	{
+		Name: "restoreOriginal",
+		Description: "Put back the original of a file saved over while save.backup_originals was on\n" +
+			"Restoring the current image's file reopens it; its history is discarded.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "file", Type: ParamTypeString, Required: false, Hint: "File to restore. Default: the current image's file.", Example: "photo.jpg"},
+		},
+	},
+	{
+		Name:          "reverseFrames",
+		Description:   "Reverse the order of the animation frames",
+		WholeSequence: true,
+		Params:        []ParamMeta{},
+	},
+	{
+		Name:        "roll",
+		Description: "Shift the image by an offset, wrapping what moves off one edge around to the opposite edge, e.g. to check a texture tiles seamlessly",
+		Params: []ParamMeta{
+			{Name: "offsetX", Type: ParamTypeInt, Required: true, Hint: "Horizontal shift; positive moves the image right, negative left.", Example: "50", Unit: "px"},
+			{Name: "offsetY", Type: ParamTypeInt, Required: true, Hint: "Vertical shift; positive moves the image down, negative up.", Example: "50", Unit: "px"},
 		},
 	},
 	{
@@ -335,6 +907,37 @@ var Commands = []CommandMeta{
 		Description: "Rotate the image",
 		Params: []ParamMeta{
 			{Name: "degrees", Type: ParamTypeFloat, Required: true, Hint: "Degrees to rotate. Positive values rotate clockwise (wraps beyond 360).", Example: "90.0", Unit: "deg"},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Color of the corners uncovered by the rotation, e.g. white, #202020 or transparent. Default black.", Example: "transparent"},
+			{Name: "autoCrop", Type: ParamTypeBool, Required: false, Hint: "Crop to the largest rectangle inside the rotated image, so no corners show. Default false.", Example: "true"},
+		},
+	},
+	{
+		Name:        "rotationalBlur",
+		Description: "Blur the image around its center, as if it were spinning",
+		Params: []ParamMeta{
+			{Name: "angle", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Max: float64Ptr(360), Hint: "Arc of the blur in degrees. A few degrees suggests a turning wheel; 30+ blurs everything but the center.", Example: "10", Unit: "deg"},
+		},
+	},
+	{
+		Name: "scanCode",
+		Description: "Decode the QR codes and barcodes in the image and print their contents\n" +
+			"This command does not modify the image.",
+		NoImage: true,
+		Params:  []ParamMeta{},
+	},
+	{
+		Name:        "selectLayer",
+		Description: "Choose which layer subsequent commands apply to",
+		Params: []ParamMeta{
+			{Name: "index", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Layer number as shown by the layers command; 0 = background.", Example: "0"},
+		},
+	},
+	{
+		Name: "separateChannel",
+		Description: "Open a channel of the image (red, green, blue, alpha, Lab lightness or chroma) as a new grayscale buffer\n" +
+			"Edit it like any image and merge the channels again with combineChannels.",
+		Params: []ParamMeta{
+			{Name: "channel", Type: ParamTypeEnum, Required: true, Hint: "Channel to extract. LUMINANCE, LAB_A and LAB_B are CIELAB lightness and chroma; RGB and LAB open all three channels as separate buffers.", Example: "LAB", EnumOptions: separateChannels},
 		},
 	},
 	{
@@ -344,6 +947,31 @@ var Commands = []CommandMeta{
 			{Name: "threshold", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Max: float64Ptr(100.0), Hint: "Strength/threshold for sepia toning. Lower = subtle; higher = stronger brown/yellow cast.", Example: "80"},
 		},
 	},
+	{
+		Name:        "setFrameDelay",
+		Description: "Set how long the current frame (or every frame, after 'a') is shown",
+		Params: []ParamMeta{
+			{Name: "delay", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Frame delay in 1/100 s. Lower = faster.", Example: "10", Unit: "cs"},
+		},
+	},
+	{
+		Name:        "setFrameDispose",
+		Description: "Set how the current frame (or every frame, after 'a') is disposed before the next one is drawn",
+		Params: []ParamMeta{
+			{Name: "method", Type: ParamTypeEnum, Required: true, Hint: "NONE = leave in place, BACKGROUND = clear to background, PREVIOUS = restore the prior frame.", Example: "NONE", EnumOptions: disposeMethods},
+		},
+	},
+	{
+		Name:        "shadow",
+		Description: "Put a soft drop shadow behind the image, on a canvas grown to hold it",
+		Params: []ParamMeta{
+			{Name: "opacity", Type: ParamTypePercent, Required: true, Min: float64Ptr(0), Max: float64Ptr(100), Hint: "How dark the shadow is; 60-80 looks natural.", Example: "70", Unit: "%"},
+			{Name: "sigma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Hint: "Blur of the shadow's edge. Higher = softer and wider.", Example: "4", Unit: "px"},
+			{Name: "offsetX", Type: ParamTypeInt, Required: true, Hint: "Horizontal offset of the shadow; positive moves it right.", Example: "6", Unit: "px"},
+			{Name: "offsetY", Type: ParamTypeInt, Required: true, Hint: "Vertical offset of the shadow; positive moves it down.", Example: "6", Unit: "px"},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Canvas color around the image and shadow. Default none (transparent); use white for JPEG.", Example: "white"},
+		},
+	},
 	{
 		Name:        "sharpen",
 		Description: "Sharpen the image",
@@ -352,11 +980,108 @@ var Commands = []CommandMeta{
 			{Name: "sigma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0.0), Hint: "Amount/strength of sharpening. Lower = subtle; higher = stronger (may produce halos).", Example: "1.0"},
 		},
 	},
+	{
+		Name:        "shear",
+		Description: "Slant the image by sliding its rows sideways and/or its columns up or down, e.g. for an italic or isometric look",
+		Params: []ParamMeta{
+			{Name: "xDegrees", Type: ParamTypeFloat, Required: true, Min: float64Ptr(-89), Max: float64Ptr(89), Hint: "Horizontal shear: how far the rows slide, as an angle. 0 = none.", Example: "20", Unit: "deg"},
+			{Name: "yDegrees", Type: ParamTypeFloat, Required: false, Min: float64Ptr(-89), Max: float64Ptr(89), Hint: "Vertical shear: how far the columns slide, as an angle. Default 0.", Example: "0", Unit: "deg"},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Color of the triangles uncovered by the shear, e.g. white or transparent. Default black.", Example: "transparent"},
+		},
+	},
+	{
+		Name: "shiftDates",
+		Description: "Add a time offset to the EXIF dates of photos, e.g. to fix a camera clock that was wrong, without re-encoding\n" +
+			"Changes files on disk; the open image is not changed.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "files", Type: ParamTypeString, Required: true, Hint: "Directory, glob pattern (e.g. photos/*.jpg) or list of JPEG files; enter '/' to multi-select with fzf (Tab marks files).", Example: "photos/"},
+			{Name: "offset", Type: ParamTypeString, Required: true, Hint: "Time to add, with d for days: +1h30m, -45m, -1d2h, +365d.", Example: "-1h"},
+			{Name: "dryRun", Type: ParamTypeBool, Required: false, Hint: "If true, only print the old and new dates. Default false (the files are changed).", Example: "true"},
+		},
+	},
+	{
+		Name:        "sketch",
+		Description: "Simulate a pencil sketch",
+		Params: []ParamMeta{
+			{Name: "radius", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Hint: "Radius of the stroke blur in pixels. 0 lets ImageMagick pick one from sigma.", Example: "0", Unit: "px"},
+			{Name: "sigma", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Hint: "Length of the pencil strokes; higher = longer, looser strokes.", Example: "20", Unit: "px"},
+			{Name: "angle", Type: ParamTypeFloat, Required: true, Hint: "Direction of the strokes. 120 gives the usual right-handed hatching.", Example: "120", Unit: "deg"},
+		},
+	},
+	{
+		Name: "sliceSheet",
+		Description: "Cut a sprite sheet into WxH tiles written as numbered PNG files to a directory\n" +
+			"The current image is not changed.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "outDir", Type: ParamTypeString, Required: true, Hint: "Directory the tiles are written to as tile_000.png, tile_001.png... in reading order; created if missing.", Example: "tiles"},
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Tile width in pixels.", Example: "32", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Tile height in pixels.", Example: "32", Unit: "px"},
+			{Name: "spacing", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Pixels between tiles. Default 0.", Example: "1", Unit: "px"},
+			{Name: "margin", Type: ParamTypeInt, Required: false, Min: float64Ptr(0), Hint: "Pixels around the edge of the sheet. Default 0.", Example: "0", Unit: "px"},
+			{Name: "skipEmpty", Type: ParamTypeBool, Required: false, Hint: "Do not write fully transparent tiles. Default true.", Example: "true"},
+		},
+	},
+	{
+		Name:        "snapshot",
+		Description: "Save, restore, list, delete or compare named checkpoints of the whole editing state",
+		Params: []ParamMeta{
+			{Name: "action", Type: ParamTypeEnum, Required: true, Hint: "SAVE the current state, RESTORE a saved one, LIST snapshots, DELETE one, or COMPARE one side by side with the current image.", Example: "SAVE", EnumOptions: snapshotActions},
+			{Name: "name", Type: ParamTypeString, Required: false, Hint: "Snapshot name (not needed for LIST).", Example: "before-crop"},
+		},
+	},
+	{
+		Name: "social",
+		Description: "Export the image at the sizes social platforms expect (og:image, Twitter card, Instagram, YouTube thumbnail)\n" +
+			"Each target is cropped or padded to its aspect ratio and written as a JPEG; the current image is not changed.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "outDir", Type: ParamTypeString, Required: true, Hint: "Directory the images are written to; created if missing.", Example: "social"},
+			{Name: "targets", Type: ParamTypeString, Required: false, Hint: "Comma-separated targets: og, twitter, instagram-square, instagram-portrait, instagram-story, youtube. Default all.", Example: "og,youtube"},
+			{Name: "fit", Type: ParamTypeEnum, Required: false, Hint: "CROP fills each frame and trims the overflow from the center; PAD fits the whole image and fills the rest. Default CROP.", Example: "CROP", EnumOptions: socialFits},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Padding and transparency color (hex, rgb(), or name). Default white.", Example: "#ffffff"},
+		},
+	},
 	{
 		Name:        "solarize",
 		Description: "Solarize the image (partially invert pixels)",
 		Params: []ParamMeta{
-			{Name: "threshold", Type: ParamTypeFloat, Required: true, Hint: "Threshold at which pixels are inverted. Lower = subtle effect; higher = stronger inversion.", Example: "50.0"},
+			{Name: "threshold", Type: ParamTypeQuantum, Required: true, Min: float64Ptr(0), Hint: "Level above which pixels are inverted, in the quantum range or as a percentage. Lower = stronger inversion; higher = subtler effect.", Example: "50%"},
+		},
+	},
+	{
+		Name:        "splice",
+		Description: "Insert a blank band of columns and/or rows into the image, e.g. to make room for a caption",
+		Params: []ParamMeta{
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Columns to insert at x. 0 = none.", Example: "0", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Rows to insert at y. 0 = none.", Example: "120", Unit: "px"},
+			{Name: "x", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Column the band is inserted before; the image width puts it at the right edge.", Example: "0", Unit: "px"},
+			{Name: "y", Type: ParamTypeInt, Required: true, Min: float64Ptr(0), Hint: "Row the band is inserted before; the image height puts it at the bottom.", Example: "0", Unit: "px"},
+			{Name: "background", Type: ParamTypeString, Required: false, Hint: "Color of the band (hex, rgb(), name or none). Default white.", Example: "black"},
+		},
+	},
+	{
+		Name:        "spread",
+		Description: "Scatter each pixel randomly within a radius, like frosted glass",
+		Params: []ParamMeta{
+			{Name: "radius", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Hint: "How far pixels may move. 1-3 = a grainy dissolve; higher = frosted glass.", Example: "3", Unit: "px"},
+		},
+	},
+	{
+		Name:         "stack",
+		Description:  "Combine aligned shots of the same scene pixel by pixel: average away noise, or build star trails (replaces the current image)",
+		CreatesImage: true,
+		Params: []ParamMeta{
+			{Name: "files", Type: ParamTypeString, Required: true, Hint: "Glob pattern (e.g. shots/*.jpg), directory or list of images of the same size; enter '/' to multi-select with fzf (Tab marks files).", Example: "shots/*.jpg"},
+			{
+				Name:        "method",
+				Type:        ParamTypeEnum,
+				Required:    false,
+				Hint:        "MEAN (default) or MEDIAN reduce noise, MEDIAN also removes moving people; MAX draws star trails, MIN keeps the darkest; SUM adds exposures up.",
+				Example:     "MEAN",
+				EnumOptions: stackMethods,
+			},
 		},
 	},
 	{
@@ -369,15 +1094,73 @@ var Commands = []CommandMeta{
 		Description: "Swirl the image by a number of degrees",
 		Params: []ParamMeta{
 			{Name: "degrees", Type: ParamTypeFloat, Required: true, Hint: "Angle of swirl distortion. Lower = gentle; higher = dramatic twisting.", Example: "90.0", Unit: "deg"},
+			{Name: "interpolate", Type: ParamTypeEnum, Required: false, Hint: "How pixels are sampled between grid points. Default BILINEAR (smooth); NEAREST keeps pixel art blocky; CATROM or SPLINE are sharper or softer.", Example: "BILINEAR", EnumOptions: interpolateNames},
+		},
+	},
+	{
+		Name:         "testChart",
+		Description:  "Create a calibration chart: color bars, gray ramps or resolution wedges (replaces the current image)",
+		CreatesImage: true,
+		Params: []ParamMeta{
+			{Name: "chart", Type: ParamTypeEnum, Required: true, Hint: "COLOR_BARS = primaries/secondaries + ramp, GRAY_RAMP = continuous + 11-step wedge, RESOLUTION = 1-8 px line groups.", Example: "COLOR_BARS", EnumOptions: testCharts},
+			{Name: "width", Type: ParamTypeInt, Required: true, Min: float64Ptr(16), Hint: "Chart width in pixels.", Example: "800", Unit: "px"},
+			{Name: "height", Type: ParamTypeInt, Required: true, Min: float64Ptr(16), Hint: "Chart height in pixels.", Example: "600", Unit: "px"},
 		},
 	},
 	{
 		Name:        "threshold",
 		Description: "Threshold the image to pure black and white",
 		Params: []ParamMeta{
-			{Name: "threshold", Type: ParamTypeFloat, Required: true, Hint: "Threshold value; pixels above become white, below become black.", Example: "128.0"},
+			{Name: "threshold", Type: ParamTypeQuantum, Required: true, Min: float64Ptr(0), Hint: "Threshold level, in the quantum range or as a percentage; pixels above become white, below become black.", Example: "50%"},
 		},
 	},
+	{
+		Name: "tiles",
+		Description: "Cut the whole image into a grid of tiles written as numbered files to a directory, for map tiles or slicing artwork\n" +
+			"The current image is not changed.",
+		NoImage: true,
+		Params: []ParamMeta{
+			{Name: "outDir", Type: ParamTypeString, Required: true, Hint: "Directory the tiles are written to as tile_<row>_<column>.<format>, counting from 0; created if missing.", Example: "tiles"},
+			{
+				Name:        "mode",
+				Type:        ParamTypeEnum,
+				Required:    true,
+				Hint:        "GRID = across x down tiles of equal size; SIZE = tiles of across x down pixels, partial at the right and bottom edges.",
+				Example:     "GRID",
+				EnumOptions: tileModes,
+			},
+			{Name: "across", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Columns in GRID mode, tile width in pixels in SIZE mode.", Example: "4"},
+			{Name: "down", Type: ParamTypeInt, Required: true, Min: float64Ptr(1), Hint: "Rows in GRID mode, tile height in pixels in SIZE mode.", Example: "3"},
+			{Name: "format", Type: ParamTypeString, Required: false, Hint: "File format of the tiles, e.g. png, jpg or webp. Default png.", Example: "png"},
+		},
+	},
+	{
+		Name: "toMagickCmd",
+		Description: "Print an equivalent ImageMagick `magick` command line for the commands applied so far\n" +
+			"This command does not modify the image; it only outputs the command.",
+		Params: []ParamMeta{
+			{Name: "output", Type: ParamTypeString, Required: false, Hint: "Output filename used at the end of the command line. Default output.png.", Example: "output.png"},
+		},
+	},
+	{
+		Name:        "transparent",
+		Description: "Make every pixel close to a color transparent, e.g. to knock out a green screen or a white background",
+		Params: []ParamMeta{
+			{Name: "color", Type: ParamTypeString, Required: true, Hint: "Color to remove (hex, rgb(), or name).", Example: "#00ff00"},
+			{Name: "fuzz", Type: ParamTypePercent, Required: true, Min: float64Ptr(0.0), Max: float64Ptr(100.0), Hint: "How far a pixel's color may be from the target and still be removed. 0 = exact matches only; 10-30 catches uneven green screens and JPEG noise.", Example: "15", Unit: "%"},
+			{Name: "invert", Type: ParamTypeBool, Required: false, Hint: "If true, keep the matching pixels and make everything else transparent. Default false.", Example: "false"},
+		},
+	},
+	{
+		Name:        "transpose",
+		Description: "Mirror the image along its top-left to bottom-right diagonal, swapping rows and columns",
+		Params:      []ParamMeta{},
+	},
+	{
+		Name:        "transverse",
+		Description: "Mirror the image along its top-right to bottom-left diagonal",
+		Params:      []ParamMeta{},
+	},
 	{
 		Name:        "trim",
 		Description: "Remove blank/background edges from the image",
@@ -405,4 +1188,34 @@ var Commands = []CommandMeta{
 			{Name: "y", Type: ParamTypeInt, Required: true, Hint: "Y coordinate of the vignette center.", Example: "0", Unit: "px"},
 		},
 	},
+	{
+		Name:        "wave",
+		Description: "Ripple the image along a sine wave; the image grows by twice the amplitude in height",
+		Params: []ParamMeta{
+			{Name: "amplitude", Type: ParamTypeFloat, Required: true, Min: float64Ptr(0), Hint: "Height of the wave; higher = deeper ripples.", Example: "10", Unit: "px"},
+			{Name: "wavelength", Type: ParamTypeFloat, Required: true, Min: float64Ptr(1), Hint: "Distance between wave crests; lower = tighter ripples.", Example: "100", Unit: "px"},
+		},
+	},
+}
+
+// composeOperatorOptions lists the compose operators accepted by composeOperator parameters.
+var composeOperatorOptions = []string{
+	"UNDEFINED", "ALPHA", "ATOP", "BLEND", "BLUR", "BUMPMAP", "CHANGE_MASK", "CLEAR",
+	"COLOR_BURN", "COLOR_DODGE", "COLORIZE", "COPY", "COPY_ALPHA", "COPY_BLACK", "COPY_BLUE",
+	"COPY_CYAN", "COPY_GREEN", "COPY_MAGENTA", "COPY_RED", "COPY_YELLOW", "DARKEN",
+	"DARKEN_INTENSITY", "DIFFERENCE", "DISPLACE", "DISSOLVE", "DISTORT", "DIVIDE__DST",
+	"DIVIDE_SRC", "DST", "DST_ATOP", "DST_IN", "DST_OUT", "DST_OVER", "EXCLUSION",
+	"HARD_LIGHT", "HARD_MIX", "HUE", "IN", "INTENSITY", "LIGHTEN", "LIGHTEN_INTENSITY",
+	"LINEAR_BURN", "LINEAR_DODGE", "LINEAR_LIGHT", "LUMINIZE", "MATHEMATICS", "MINUS_DST",
+	"MINUS_SRC", "MODULATE", "MODULUS_ADD", "MODULUS_SUBTRACT", "MULTIPLY", "NO", "OUT",
+	"OVER", "OVERLAY", "PEGTOP_LIGHT", "PIN_LIGHT", "PLUS", "REPLACE", "SATURATE", "SCREEN",
+	"SOFT_LIGHT", "SRC", "SRC_ATOP", "SRC_IN", "SRC_OUT", "SRC_OVER", "THRESHOLD", "VIVID_LIGHT",
+	"XOR",
 }
+
+// levelChannels lists the channels levelChannel can adjust.
+var levelChannels = []string{"RED", "GREEN", "BLUE", "ALPHA"}
+
+// noiseChannels lists the channels addNoise can be limited to; ALL leaves
+// ImageMagick's default channel set.
+var noiseChannels = []string{"ALL", "RED", "GREEN", "BLUE"}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// imageMetrics measures how far an image is from a reference.
+type imageMetrics struct {
+	// RMSE is the root mean squared error, normalized to 0-1.
+	RMSE float64
+	// PSNR is the peak signal-to-noise ratio in dB; +Inf for identical images.
+	PSNR float64
+	// SSIM is the structural similarity index, 1 for identical images.
+	SSIM float64
+}
+
+// String formats the metrics on one line.
+func (m imageMetrics) String() string {
+	psnr := "inf"
+	if !math.IsInf(m.PSNR, 1) {
+		psnr = fmt.Sprintf("%.2f dB", m.PSNR)
+	}
+	return fmt.Sprintf("SSIM %.4f, PSNR %s, RMSE %.5f", m.SSIM, psnr, m.RMSE)
+}
+
+// compareImages measures the current image of wand against the current image
+// of ref, which must have the same size.
+func compareImages(wand, ref *imagick.MagickWand) (imageMetrics, error) {
+	if wand.GetImageWidth() != ref.GetImageWidth() || wand.GetImageHeight() != ref.GetImageHeight() {
+		return imageMetrics{}, fmt.Errorf("sizes differ: %dx%d vs %dx%d",
+			wand.GetImageWidth(), wand.GetImageHeight(), ref.GetImageWidth(), ref.GetImageHeight())
+	}
+	rmse, err := wand.GetImageDistortion(ref, imagick.METRIC_ROOT_MEAN_SQUARED_ERROR)
+	if err != nil {
+		return imageMetrics{}, fmt.Errorf("failed to measure RMSE: %w", err)
+	}
+	// ImageMagick versions disagree on what the SSIM metric returns; the
+	// dissimilarity, (1 - SSIM) / 2, is reported the same way by all of them.
+	dssim, err := wand.GetImageDistortion(ref, imagick.METRIC_STRUCTURAL_DISSIMILARITY_ERROR)
+	if err != nil {
+		return imageMetrics{}, fmt.Errorf("failed to measure SSIM: %w", err)
+	}
+	// PSNR follows from the normalized RMSE; computing it here avoids
+	// versions that report 0 for identical images.
+	psnr := math.Inf(1)
+	if rmse > 0 {
+		psnr = 20 * math.Log10(1/rmse)
+	}
+	return imageMetrics{RMSE: rmse, PSNR: psnr, SSIM: 1 - 2*dssim}, nil
+}
+
+// compareSequences compares two images frame by frame and returns the worst
+// value of each metric over the frames.
+func compareSequences(wand, ref *imagick.MagickWand) (imageMetrics, error) {
+	if n, m := wand.GetNumberImages(), ref.GetNumberImages(); n != m {
+		return imageMetrics{}, fmt.Errorf("frame counts differ: %d vs %d", n, m)
+	}
+	worst := imageMetrics{PSNR: math.Inf(1), SSIM: 1}
+	for i := 0; i < int(wand.GetNumberImages()); i++ {
+		wand.SetIteratorIndex(i)
+		ref.SetIteratorIndex(i)
+		m, err := compareImages(wand, ref)
+		if err != nil {
+			if wand.GetNumberImages() > 1 {
+				return imageMetrics{}, fmt.Errorf("frame %d: %w", i+1, err)
+			}
+			return imageMetrics{}, err
+		}
+		worst.RMSE = math.Max(worst.RMSE, m.RMSE)
+		worst.PSNR = math.Min(worst.PSNR, m.PSNR)
+		worst.SSIM = math.Min(worst.SSIM, m.SSIM)
+	}
+	return worst, nil
+}
+
+// differenceImage returns an image highlighting where the current images of
+// wand and ref differ, in red over a faded copy of ref.
+func differenceImage(wand, ref *imagick.MagickWand) (*imagick.MagickWand, error) {
+	diff, _ := ref.CompareImages(wand, imagick.METRIC_ROOT_MEAN_SQUARED_ERROR)
+	if diff == nil {
+		return nil, fmt.Errorf("failed to create difference image")
+	}
+	return diff, nil
+}
+
+// CompareWith reports how far the current image of wand is from the image at
+// path (a file or buffer), and previews where they differ when showDiff is
+// set. The other image's first frame is used.
+func CompareWith(wand *imagick.MagickWand, path string, showDiff bool) error {
+	other, err := LoadImage(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer other.Destroy()
+	other.SetIteratorIndex(0)
+	m, err := compareImages(wand, other)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("SSIM: %.4f (1 = identical)\n", m.SSIM)
+	if math.IsInf(m.PSNR, 1) {
+		fmt.Println("PSNR: inf (identical)")
+	} else {
+		fmt.Printf("PSNR: %.2f dB (above 40 dB differences are rarely visible)\n", m.PSNR)
+	}
+	fmt.Printf("RMSE: %.5f (%.2f%%)\n", m.RMSE, m.RMSE*100)
+	if !showDiff {
+		return nil
+	}
+	diff, err := differenceImage(wand, other)
+	if err != nil {
+		return err
+	}
+	defer diff.Destroy()
+	fmt.Println("Differences in red:")
+	return PreviewWand(diff)
+}
@@ -0,0 +1,341 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// User configuration.
+//
+// Settings are read once at startup from config.toml in the termagick config
+// dir. Only the subset of TOML the file needs is understood: [sections],
+// key = value pairs with string, integer or boolean values, and # comments.
+// Environment variables that predate the file (KITTY_PREVIEW_COLS, CHAFA_SIZE,
+// ...) still win over it, so existing setups keep working.
+//
+//	[preview]
+//	backend = "auto"   # auto, kitty, inline, sixel, chafa, web or none
+//	cols = 60
+//	rows = 20
+//
+//	[save]
+//	quality = 90       # used when the image has no quality of its own
+//	backup_originals = false  # keep a copy of a file before saving over it
+//
+//	[fzf]
+//	options = "--height 40% --reverse"
+//
+//	[histogram]
+//	theme = "auto"     # auto, dark or light
+//	line_width = 2     # pixels, 1-8
+//	filled = false     # shade the area under each curve
+//
+//	[keys]
+//	save = "w"         # see keyBindings for the action names
+//
+//	[updates]
+//	check = "manual"   # manual, startup or never
+//
+//	[security]
+//	no_exec = false    # true = never run external programs, like --no-exec
+
+// Config holds the user settings; zero values mean "use the built-in default".
+type Config struct {
+	PreviewBackend string
+	PreviewCols    int
+	PreviewRows    int
+	SaveQuality    int
+	// BackupOriginals keeps a copy of a file the first time it is saved
+	// over (see originals.go).
+	BackupOriginals bool
+	FzfOptions      string
+	// HistogramTheme is the background of histogram plots: auto, dark or
+	// light.
+	HistogramTheme     string
+	HistogramLineWidth int
+	HistogramFilled    bool
+	// Keys maps an action name from keyBindings to the key that triggers it.
+	Keys        map[string]rune
+	UpdateCheck string
+	// NoExec forbids running external programs (see exec.go).
+	NoExec bool
+}
+
+// defaultConfig holds the built-in defaults that config.toml overrides.
+var defaultConfig = Config{PreviewBackend: "auto", HistogramTheme: "auto", UpdateCheck: "manual"}
+
+// config is the active configuration, replaced by LoadConfig.
+var config = defaultConfig
+
+// histogramThemes are the accepted values of histogram.theme.
+var histogramThemes = []string{"auto", "dark", "light"}
+
+// updateChecks are the accepted values of updates.check.
+var updateChecks = []string{"manual", "startup", "never"}
+
+// configDir returns termagick's configuration directory:
+// $XDG_CONFIG_HOME/termagick, or ~/.config/termagick.
+func configDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "termagick"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "termagick"), nil
+}
+
+// configPath returns the location of config.toml.
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// LoadConfig reads config.toml, if present, into the active configuration,
+// with the defaults for settings it leaves out; without the file the defaults
+// apply. On error the active settings stay in effect.
+func LoadConfig() error {
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+	config = cfg
+	return nil
+}
+
+// readConfig returns the configuration config.toml describes, or the
+// defaults when there is no file.
+func readConfig() (Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return Config{}, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return defaultConfig, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("open config: %w", err)
+	}
+	defer f.Close()
+
+	values, err := parseTOML(bufio.NewScanner(f))
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg, err := configFromValues(values)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// tomlValue is a parsed value and the line it came from.
+type tomlValue struct {
+	raw  interface{}
+	line int
+}
+
+// parseTOML reads the supported TOML subset into a map keyed "section.key".
+func parseTOML(sc *bufio.Scanner) (map[string]tomlValue, error) {
+	values := map[string]tomlValue{}
+	section := ""
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripTOMLComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header", n)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		v, err := parseTOMLValue(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		values[key] = tomlValue{raw: v, line: n}
+	}
+	return values, sc.Err()
+}
+
+// stripTOMLComment removes a trailing # comment that is not inside a string.
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseTOMLValue parses a string, integer or boolean value.
+func parseTOMLValue(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("invalid string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	case s == "true" || s == "false":
+		return s == "true", nil
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(s, "_", ""))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported value %s", s)
+	}
+	return n, nil
+}
+
+// configFromValues validates parsed values and builds a Config.
+func configFromValues(values map[string]tomlValue) (Config, error) {
+	cfg := defaultConfig
+	cfg.Keys = map[string]rune{}
+	for key, v := range values {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("line %d: %s: %s", v.line, key, fmt.Sprintf(format, args...))
+		}
+		str, isStr := v.raw.(string)
+		num, isNum := v.raw.(int)
+		boolean, isBool := v.raw.(bool)
+
+		if action, ok := strings.CutPrefix(key, "keys."); ok {
+			if findKeyBinding(action) == nil {
+				return cfg, fail("unknown action")
+			}
+			k, err := parseKeyName(str)
+			if !isStr || err != nil {
+				return cfg, fail("expected a single key such as \"w\" or \"Tab\"")
+			}
+			cfg.Keys[action] = k
+			continue
+		}
+
+		switch key {
+		case "preview.backend":
+			if backends := previewBackendNames(); !isStr || !containsString(backends, str) {
+				return cfg, fail("expected one of %s", strings.Join(backends, ", "))
+			}
+			cfg.PreviewBackend = str
+		case "preview.cols", "preview.rows":
+			if !isNum || num <= 0 {
+				return cfg, fail("expected a positive integer")
+			}
+			if key == "preview.cols" {
+				cfg.PreviewCols = num
+			} else {
+				cfg.PreviewRows = num
+			}
+		case "save.quality":
+			if !isNum || num < 1 || num > 100 {
+				return cfg, fail("expected an integer between 1 and 100")
+			}
+			cfg.SaveQuality = num
+		case "save.backup_originals":
+			if !isBool {
+				return cfg, fail("expected true or false")
+			}
+			cfg.BackupOriginals = boolean
+		case "fzf.options":
+			if !isStr {
+				return cfg, fail("expected a string")
+			}
+			cfg.FzfOptions = str
+		case "histogram.theme":
+			if !isStr || !containsString(histogramThemes, str) {
+				return cfg, fail("expected one of %s", strings.Join(histogramThemes, ", "))
+			}
+			cfg.HistogramTheme = str
+		case "histogram.line_width":
+			if !isNum || num < 1 || num > 8 {
+				return cfg, fail("expected an integer between 1 and 8")
+			}
+			cfg.HistogramLineWidth = num
+		case "histogram.filled":
+			if !isBool {
+				return cfg, fail("expected true or false")
+			}
+			cfg.HistogramFilled = boolean
+		case "updates.check":
+			if !isStr || !containsString(updateChecks, str) {
+				return cfg, fail("expected one of %s", strings.Join(updateChecks, ", "))
+			}
+			cfg.UpdateCheck = str
+		case "security.no_exec":
+			if !isBool {
+				return cfg, fail("expected true or false")
+			}
+			cfg.NoExec = boolean
+		default:
+			return cfg, fail("unknown setting")
+		}
+	}
+	return cfg, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// previewSize returns the preview size in terminal cells: the configured
+// size, or the backend's defaults.
+func previewSize(defCols, defRows int) (int, int) {
+	cols, rows := defCols, defRows
+	if config.PreviewCols > 0 {
+		cols = config.PreviewCols
+	}
+	if config.PreviewRows > 0 {
+		rows = config.PreviewRows
+	}
+	return cols, rows
+}
+
+// fzfOptions returns the extra command-line options for fzf from the config.
+func fzfOptions() []string {
+	if config.FzfOptions == "" {
+		return nil
+	}
+	opts, err := splitRecipeLine(config.FzfOptions)
+	if err != nil {
+		debugf("ignoring fzf.options: %v", err)
+		return nil
+	}
+	return opts
+}
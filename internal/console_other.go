@@ -0,0 +1,41 @@
+//go:build !windows && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// setupConsole prepares the terminal for the editor; nothing to do here.
+func setupConsole() func() {
+	return func() {}
+}
+
+// enableRawInput is not supported on this platform; keys are read a line at
+// a time.
+func enableRawInput() (func(), error) {
+	return nil, fmt.Errorf("raw input is not supported on this platform")
+}
+
+// saveTerminalMode has nothing to save on this platform, where the terminal
+// mode is never changed.
+func saveTerminalMode() func() {
+	return nil
+}
+
+// terminalSize is not supported on this platform.
+func terminalSize() (cols, rows, width, height int, err error) {
+	return 0, 0, 0, 0, fmt.Errorf("terminal size is not available on this platform")
+}
+
+// waitForInput cannot wait with a timeout on this platform.
+func waitForInput(d time.Duration) bool {
+	return false
+}
+
+// processAlive cannot tell on this platform and assumes the process exists,
+// so no temp directory is ever swept.
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,72 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// setupConsole prepares the terminal for the editor. Unix terminals need no
+// setup; see console_windows.go.
+func setupConsole() func() {
+	return func() {}
+}
+
+// enableRawInput switches the terminal to raw input, so a key press is read
+// without waiting for Enter and is not echoed, and returns a function that
+// restores the previous settings. Output processing is left on so newlines
+// still return the cursor. It fails when stdin is not a terminal.
+func enableRawInput() (func(), error) {
+	fd := int(os.Stdin.Fd())
+	old, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal: %w", err)
+	}
+	raw := *old
+	raw.Lflag &^= unix.ICANON | unix.ECHO | unix.ISIG | unix.IEXTEN
+	raw.Iflag &^= unix.IXON
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &raw); err != nil {
+		return nil, fmt.Errorf("set terminal mode: %w", err)
+	}
+	return func() { unix.IoctlSetTermios(fd, ioctlWriteTermios, old) }, nil
+}
+
+// saveTerminalMode returns a function that puts the terminal back into its
+// current mode, or nil when stdin is not a terminal.
+func saveTerminalMode() func() {
+	fd := int(os.Stdin.Fd())
+	saved, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil
+	}
+	return func() { unix.IoctlSetTermios(fd, ioctlWriteTermios, saved) }
+}
+
+// terminalSize returns the size of the terminal in cells and, when the
+// terminal reports it, in pixels (zero otherwise).
+func terminalSize() (cols, rows, width, height int, err error) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("stdout is not a terminal: %w", err)
+	}
+	return int(ws.Col), int(ws.Row), int(ws.Xpixel), int(ws.Ypixel), nil
+}
+
+// waitForInput reports whether stdin has input within d.
+func waitForInput(d time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(d/time.Millisecond))
+	return err == nil && n > 0
+}
+
+// processAlive reports whether a process with the given ID exists.
+func processAlive(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
@@ -0,0 +1,115 @@
+//go:build windows
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const utf8CodePage = 65001
+
+// x/sys/windows does not wrap the code page functions.
+var (
+	kernel32               = windows.NewLazySystemDLL("kernel32.dll")
+	procGetConsoleOutputCP = kernel32.NewProc("GetConsoleOutputCP")
+	procSetConsoleOutputCP = kernel32.NewProc("SetConsoleOutputCP")
+)
+
+// setupConsole prepares a Windows console for the editor: output escape
+// sequences (colors, kitty/iTerm2/sixel previews, cursor movement) are
+// interpreted instead of printed, keys arrive as VT sequences, and text is
+// UTF-8. It returns a function that restores the previous modes. Handles that
+// are not consoles (pipes, redirected files, mintty) are left alone.
+func setupConsole() func() {
+	var restore []func()
+	stdout := windows.Handle(os.Stdout.Fd())
+	var outMode uint32
+	if windows.GetConsoleMode(stdout, &outMode) == nil {
+		windows.SetConsoleMode(stdout, outMode|windows.ENABLE_PROCESSED_OUTPUT|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+		restore = append(restore, func() { windows.SetConsoleMode(stdout, outMode) })
+	}
+	stdin := windows.Handle(os.Stdin.Fd())
+	var inMode uint32
+	if windows.GetConsoleMode(stdin, &inMode) == nil {
+		windows.SetConsoleMode(stdin, inMode|windows.ENABLE_VIRTUAL_TERMINAL_INPUT)
+		restore = append(restore, func() { windows.SetConsoleMode(stdin, inMode) })
+	}
+	if cp, _, _ := procGetConsoleOutputCP.Call(); cp != 0 && cp != utf8CodePage {
+		procSetConsoleOutputCP.Call(utf8CodePage)
+		restore = append(restore, func() { procSetConsoleOutputCP.Call(cp) })
+	}
+	return func() {
+		for _, r := range restore {
+			r()
+		}
+	}
+}
+
+// enableRawInput switches the console to raw input, so a key press is read
+// without waiting for Enter and is not echoed, and returns a function that
+// restores the previous mode. It fails when stdin is not a console.
+func enableRawInput() (func(), error) {
+	stdin := windows.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(stdin, &mode); err != nil {
+		return nil, fmt.Errorf("stdin is not a console: %w", err)
+	}
+	raw := mode &^ (windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT | windows.ENABLE_PROCESSED_INPUT)
+	if err := windows.SetConsoleMode(stdin, raw); err != nil {
+		return nil, fmt.Errorf("set console mode: %w", err)
+	}
+	return func() { windows.SetConsoleMode(stdin, mode) }, nil
+}
+
+// saveTerminalMode returns a function that puts the console input and output
+// modes back to their current values, or nil when neither is a console.
+func saveTerminalMode() func() {
+	stdin, stdout := windows.Handle(os.Stdin.Fd()), windows.Handle(os.Stdout.Fd())
+	var inMode, outMode uint32
+	inOK := windows.GetConsoleMode(stdin, &inMode) == nil
+	outOK := windows.GetConsoleMode(stdout, &outMode) == nil
+	if !inOK && !outOK {
+		return nil
+	}
+	return func() {
+		if inOK {
+			windows.SetConsoleMode(stdin, inMode)
+		}
+		if outOK {
+			windows.SetConsoleMode(stdout, outMode)
+		}
+	}
+}
+
+// terminalSize returns the size of the console window in cells. Windows
+// consoles do not report pixel sizes, so those are zero.
+func terminalSize() (cols, rows, width, height int, err error) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("stdout is not a console: %w", err)
+	}
+	w := info.Window
+	return int(w.Right-w.Left) + 1, int(w.Bottom-w.Top) + 1, 0, 0, nil
+}
+
+// waitForInput reports whether stdin has input within d.
+func waitForInput(d time.Duration) bool {
+	ev, err := windows.WaitForSingleObject(windows.Handle(os.Stdin.Fd()), uint32(d/time.Millisecond))
+	return err == nil && ev == windows.WAIT_OBJECT_0
+}
+
+// processAlive reports whether a process with the given ID is running.
+func processAlive(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Processes of other users cannot be opened but do exist.
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+	var code uint32
+	return windows.GetExitCodeProcess(h, &code) == nil && code == 259 // STILL_ACTIVE
+}
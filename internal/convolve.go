@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// kernelSpec converts a convolution kernel written as rows separated by
+// semicolons and values separated by commas ("-1,-1,-1;-1,9,-1;-1,-1,-1")
+// into ImageMagick's kernel syntax ("3x3: -1,-1,-1,-1,9,-1,-1,-1,-1"). The
+// origin is the center of the kernel.
+func kernelSpec(s string) (string, error) {
+	var values []string
+	width := 0
+	rows := strings.Split(strings.TrimSuffix(strings.TrimSpace(s), ";"), ";")
+	for i, row := range rows {
+		fields := strings.Split(row, ",")
+		if i == 0 {
+			width = len(fields)
+		} else if len(fields) != width {
+			return "", fmt.Errorf("kernel row %d has %d values, expected %d like the first row", i+1, len(fields), width)
+		}
+		for _, f := range fields {
+			f = strings.TrimSpace(f)
+			if _, err := strconv.ParseFloat(f, 64); err != nil {
+				return "", fmt.Errorf("invalid kernel value %q in row %d", f, i+1)
+			}
+			values = append(values, f)
+		}
+	}
+	if width < 1 || len(rows) < 1 || len(values) < 2 {
+		return "", fmt.Errorf("a kernel needs at least two values")
+	}
+	return fmt.Sprintf("%dx%d: %s", width, len(rows), strings.Join(values, ",")), nil
+}
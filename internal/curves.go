@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Curves.
+//
+// A curve maps input levels to output levels through control points, as in
+// the curves tool of photo editors. The points are joined by a monotone cubic
+// spline, which passes through every point without overshooting between
+// them. The spline is applied as an FX expression, one cubic per segment, so
+// toMagickCmd can pass the same expression to -fx.
+
+// curveChannels are the values of the curves command's channel parameter, in
+// EnumOptions order.
+var curveChannels = []string{"RGB", "RED", "GREEN", "BLUE"}
+
+// Size of the ASCII plot in characters, axes excluded.
+const (
+	curvePlotWidth  = 41
+	curvePlotHeight = 17
+)
+
+// curvePoint is a control point, both coordinates in 0-1.
+type curvePoint struct{ x, y float64 }
+
+// parseCurvePoints parses control points written as "x,y x,y ...". Values
+// may be given in 0-1 or, if any exceeds 1, in 0-255.
+func parseCurvePoints(s string) ([]curvePoint, error) {
+	var pts []curvePoint
+	scale := 1.0
+	for _, field := range strings.Fields(s) {
+		xs, ys, ok := strings.Cut(field, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid point %q: want x,y", field)
+		}
+		x, err := strconv.ParseFloat(xs, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point %q: %w", field, err)
+		}
+		y, err := strconv.ParseFloat(ys, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point %q: %w", field, err)
+		}
+		if x > 1 || y > 1 {
+			scale = 255
+		}
+		pts = append(pts, curvePoint{x, y})
+	}
+	if len(pts) < 2 {
+		return nil, fmt.Errorf("a curve needs at least 2 points")
+	}
+	for i := range pts {
+		pts[i].x /= scale
+		pts[i].y /= scale
+		if pts[i].x < 0 || pts[i].x > 1 || pts[i].y < 0 || pts[i].y > 1 {
+			return nil, fmt.Errorf("point %s is out of range (use 0-1 or 0-255)", strings.Fields(s)[i])
+		}
+	}
+	sort.Slice(pts, func(i, j int) bool { return pts[i].x < pts[j].x })
+	for i := 1; i < len(pts); i++ {
+		if pts[i].x == pts[i-1].x {
+			return nil, fmt.Errorf("two points have the same input level %g", pts[i].x*scale)
+		}
+	}
+	return pts, nil
+}
+
+// curveTangents returns the tangents at pts of their monotone cubic
+// (Fritsch-Carlson) interpolation.
+func curveTangents(pts []curvePoint) []float64 {
+	n := len(pts)
+	slopes := make([]float64, n-1)
+	for i := range slopes {
+		slopes[i] = (pts[i+1].y - pts[i].y) / (pts[i+1].x - pts[i].x)
+	}
+	tangents := make([]float64, n)
+	tangents[0], tangents[n-1] = slopes[0], slopes[n-2]
+	for i := 1; i < n-1; i++ {
+		if slopes[i-1]*slopes[i] <= 0 {
+			tangents[i] = 0
+		} else {
+			tangents[i] = (slopes[i-1] + slopes[i]) / 2
+		}
+	}
+	// Limit the tangents so no segment overshoots its end points.
+	for i, d := range slopes {
+		if d == 0 {
+			tangents[i], tangents[i+1] = 0, 0
+			continue
+		}
+		a, b := tangents[i]/d, tangents[i+1]/d
+		if h := math.Hypot(a, b); h > 3 {
+			tangents[i], tangents[i+1] = 3*a/h*d, 3*b/h*d
+		}
+	}
+	return tangents
+}
+
+// curveFunc returns the monotone cubic interpolation of pts. Levels outside
+// the first and last point keep those points' outputs.
+func curveFunc(pts []curvePoint) func(float64) float64 {
+	n := len(pts)
+	tangents := curveTangents(pts)
+	return func(x float64) float64 {
+		if x <= pts[0].x {
+			return pts[0].y
+		}
+		if x >= pts[n-1].x {
+			return pts[n-1].y
+		}
+		i := sort.Search(n, func(i int) bool { return pts[i].x > x }) - 1
+		h := pts[i+1].x - pts[i].x
+		t := (x - pts[i].x) / h
+		t2, t3 := t*t, t*t*t
+		y := (2*t3-3*t2+1)*pts[i].y + (t3-2*t2+t)*h*tangents[i] +
+			(-2*t3+3*t2)*pts[i+1].y + (t3-t2)*h*tangents[i+1]
+		return math.Min(1, math.Max(0, y))
+	}
+}
+
+// curveFX returns an FX expression that evaluates the curve through pts at
+// the pixel value u: the cubic of the segment u falls in, written in Horner
+// form, or the end point's output outside the points.
+func curveFX(pts []curvePoint) string {
+	n := len(pts)
+	tangents := curveTangents(pts)
+	expr := fxNum(pts[n-1].y)
+	for i := n - 2; i >= 0; i-- {
+		h := pts[i+1].x - pts[i].x
+		s := (pts[i+1].y - pts[i].y) / h
+		c2 := (3*s - 2*tangents[i] - tangents[i+1]) / h
+		c3 := (tangents[i] + tangents[i+1] - 2*s) / (h * h)
+		d := "(u-" + fxNum(pts[i].x) + ")"
+		seg := fmt.Sprintf("%s+%s*(%s+%s*(%s+%s*%s))", fxNum(pts[i].y), d, fxNum(tangents[i]), d, fxNum(c2), d, fxNum(c3))
+		expr = fmt.Sprintf("u<%s ? %s : (%s)", fxNum(pts[i+1].x), seg, expr)
+	}
+	return fmt.Sprintf("min(1,max(0,u<=%s ? %s : (%s)))", fxNum(pts[0].x), fxNum(pts[0].y), expr)
+}
+
+// fxNum formats v for an FX expression: fixed-point, since FX reads e as
+// Euler's number, and parenthesized when negative.
+func fxNum(v float64) string {
+	s := strconv.FormatFloat(math.Round(v*1e8)/1e8, 'f', -1, 64)
+	if strings.HasPrefix(s, "-") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// curveChannelArg returns the -channel value for a curveChannels entry.
+func curveChannelArg(channel string) string {
+	switch channel {
+	case "RED":
+		return "R"
+	case "GREEN":
+		return "G"
+	case "BLUE":
+		return "B"
+	}
+	return "RGB"
+}
+
+// ApplyCurves maps the chosen channels of the current image of wand through
+// the curve.
+func ApplyCurves(wand *imagick.MagickWand, pts []curvePoint, channel string) error {
+	mask := imagick.CHANNEL_RED | imagick.CHANNEL_GREEN | imagick.CHANNEL_BLUE
+	switch channel {
+	case "RED":
+		mask = imagick.CHANNEL_RED
+	case "GREEN":
+		mask = imagick.CHANNEL_GREEN
+	case "BLUE":
+		mask = imagick.CHANNEL_BLUE
+	}
+	prev := wand.SetImageChannelMask(mask)
+	result, err := wand.FxImage(curveFX(pts))
+	wand.SetImageChannelMask(prev)
+	if err != nil {
+		return fmt.Errorf("failed to apply curve: %w", err)
+	}
+	defer result.Destroy()
+	result.SetImageChannelMask(prev)
+	result.SetImageDelay(wand.GetImageDelay())
+	result.SetImageDispose(wand.GetImageDispose())
+	return wand.SetImage(result)
+}
+
+// plotCurve draws the curve as text: '*' for the curve, 'o' for the control
+// points and '.' for the unchanged diagonal, input along the bottom.
+func plotCurve(pts []curvePoint) string {
+	f := curveFunc(pts)
+	grid := make([][]byte, curvePlotHeight)
+	for r := range grid {
+		grid[r] = []byte(strings.Repeat(" ", curvePlotWidth))
+	}
+	row := func(y float64) int {
+		return curvePlotHeight - 1 - int(math.Round(y*(curvePlotHeight-1)))
+	}
+	for c := 0; c < curvePlotWidth; c++ {
+		x := float64(c) / (curvePlotWidth - 1)
+		grid[row(x)][c] = '.'
+	}
+	for c := 0; c < curvePlotWidth; c++ {
+		grid[row(f(float64(c)/(curvePlotWidth-1)))][c] = '*'
+	}
+	for _, p := range pts {
+		grid[row(p.y)][int(math.Round(p.x*(curvePlotWidth-1)))] = 'o'
+	}
+
+	var sb strings.Builder
+	for r, line := range grid {
+		label := "   "
+		switch r {
+		case 0:
+			label = "  1"
+		case curvePlotHeight - 1:
+			label = "  0"
+		}
+		fmt.Fprintf(&sb, "%s |%s\n", label, line)
+	}
+	fmt.Fprintf(&sb, "    +%s\n", strings.Repeat("-", curvePlotWidth))
+	fmt.Fprintf(&sb, "     0%s1\n", strings.Repeat(" ", curvePlotWidth-2))
+	return sb.String()
+}
+
+// confirmCurves shows the curve given by normalized curves arguments and asks
+// whether to apply it. Arguments that do not parse are let through so the
+// command reports the error.
+func confirmCurves(reader *bufio.Reader, args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	pts, err := parseCurvePoints(args[0])
+	if err != nil {
+		return true
+	}
+	fmt.Print(plotCurve(pts))
+	fmt.Print("Apply this curve? [Y/n] ")
+	r, err := readKey(reader)
+	return err == nil && r != 'n' && r != 'N' && r != boundKey("quit")
+}
@@ -0,0 +1,142 @@
+package internal
+
+// Calls into MagickWand that imagick does not wrap.
+//
+// imagick has no binding for MagickCLAHEImage or MagickSetProgressMonitor,
+// and its SetImageMask cannot pass the NULL mask that removes a mask. These
+// are declared below to match MagickWand 7's headers (MagickBooleanType is an
+// int-sized enum) and called on the C wand that imagick.MagickWand keeps as
+// its first field. Both the declarations and that layout are assumptions
+// about the libraries, so cWand checks them before handing out the pointer:
+// the first field must be a pointer, and the ImageMagick linked at run time
+// must be at least minCWandVersion, the first release with all three
+// functions. DeleteImageRegistry, which frees the mpr: registers buffer
+// arguments are passed in, needs no wand and has been in MagickCore since
+// before version 7. The symbols come from the MagickWand library imagick
+// links.
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+// PixelMask values, from MagickCore/image.h.
+#define termagickWritePixelMask 2
+
+typedef int (*termagickMonitor)(const char *, const long long, const unsigned long long, void *);
+
+extern int MagickCLAHEImage(void *wand, const size_t width, const size_t height, const double number_bins, const double clip_limit);
+extern int MagickSetImageMask(void *wand, const int type, const void *mask);
+extern int DeleteImageRegistry(const char *key);
+extern termagickMonitor MagickSetProgressMonitor(void *wand, const termagickMonitor monitor, void *client_data);
+extern int termagickProgress(char *tag, long long offset, unsigned long long span, uintptr_t id);
+
+static int termagickMonitorCallback(const char *tag, const long long offset, const unsigned long long span, void *client_data) {
+	return termagickProgress((char *)tag, offset, span, (uintptr_t)client_data);
+}
+
+static void termagickSetProgressMonitor(void *wand, uintptr_t id) {
+	MagickSetProgressMonitor(wand, id ? termagickMonitorCallback : 0, (void *)id);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"unsafe"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// minCWandVersion is the oldest ImageMagick cWand accepts, as major, minor,
+// patch and release: MagickCLAHEImage appeared in 7.0.8-24.
+var minCWandVersion = [4]int{7, 0, 8, 24}
+
+// cWandCheck reports, once, why the C wand cannot be used, or nil.
+var cWandCheck = sync.OnceValue(func() error {
+	field := reflect.TypeFor[imagick.MagickWand]().Field(0)
+	if field.Offset != 0 || field.Type.Kind() != reflect.Pointer {
+		return fmt.Errorf("this build of imagick does not keep the C wand where termagick expects it")
+	}
+	version, _ := imagick.GetVersion()
+	m := regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)-(\d+)`).FindStringSubmatch(version)
+	if m == nil {
+		return fmt.Errorf("cannot tell the ImageMagick version from %q", version)
+	}
+	var have [4]int
+	for i := range have {
+		have[i], _ = strconv.Atoi(m[i+1])
+	}
+	if slices.Compare(have[:], minCWandVersion[:]) < 0 {
+		return fmt.Errorf("needs ImageMagick %d.%d.%d-%d or later (found %s)", minCWandVersion[0], minCWandVersion[1], minCWandVersion[2], minCWandVersion[3], m[0])
+	}
+	return nil
+})
+
+// cWand returns the C wand behind wand, or an error when the libraries fail
+// the checks described above or no image is loaded.
+func cWand(wand *imagick.MagickWand) (unsafe.Pointer, error) {
+	if err := cWandCheck(); err != nil {
+		return nil, err
+	}
+	cwand := *(*unsafe.Pointer)(unsafe.Pointer(wand))
+	if cwand == nil {
+		return nil, fmt.Errorf("no image loaded")
+	}
+	return cwand, nil
+}
+
+// claheImage applies contrast limited adaptive histogram equalization to the
+// current image of wand, with tiles of width x height pixels.
+func claheImage(wand *imagick.MagickWand, width, height uint, bins, clipLimit float64) error {
+	cwand, err := cWand(wand)
+	if err != nil {
+		return fmt.Errorf("clahe: %w", err)
+	}
+	if C.MagickCLAHEImage(cwand, C.size_t(width), C.size_t(height), C.double(bins), C.double(clipLimit)) == 0 {
+		if err := wand.GetLastError(); err != nil {
+			return err
+		}
+		return fmt.Errorf("CLAHE failed")
+	}
+	return nil
+}
+
+// clearWriteMask removes the write mask of the current image of wand.
+func clearWriteMask(wand *imagick.MagickWand) error {
+	cwand, err := cWand(wand)
+	if err != nil {
+		return fmt.Errorf("failed to clear the mask: %w", err)
+	}
+	if C.MagickSetImageMask(cwand, C.termagickWritePixelMask, nil) == 0 {
+		if err := wand.GetLastError(); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to clear the mask")
+	}
+	return nil
+}
+
+// setProgressMonitor installs the progress monitor id on the current image of
+// wand, or removes it when id is 0. When the C wand cannot be used no
+// progress is shown.
+func setProgressMonitor(wand *imagick.MagickWand, id uintptr) {
+	cwand, err := cWand(wand)
+	if err != nil {
+		return
+	}
+	C.termagickSetProgressMonitor(cwand, C.uintptr_t(id))
+}
+
+// deleteImageRegistry frees the image ImageMagick keeps in the mpr:key
+// register.
+func deleteImageRegistry(key string) {
+	ckey := C.CString(key)
+	defer C.free(unsafe.Pointer(ckey))
+	C.DeleteImageRegistry(ckey)
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Shadows, borders and frames.
+//
+// These dress up screenshots and photos for documentation: shadow puts a
+// soft drop shadow behind the image on a larger canvas, border surrounds it
+// with a flat band of color, and frame with a raised 3D matte frame. All of
+// them make the image larger.
+
+// AddShadow puts a black drop shadow behind the current image of wand,
+// offset by x, y pixels and blurred by sigma, at opacity percent, on a
+// canvas of the background color ("none" for transparent) grown to hold it.
+func AddShadow(wand *imagick.MagickWand, opacity, sigma float64, x, y int, background string) error {
+	if opacity < 0 || opacity > 100 {
+		return fmt.Errorf("opacity must be between 0 and 100")
+	}
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	if !bg.SetColor(background) {
+		return fmt.Errorf("invalid background color %q", background)
+	}
+	black := imagick.NewPixelWand()
+	defer black.Destroy()
+	black.SetColor("black")
+
+	// ShadowImage turns the image into its own shadow, in the background
+	// color, with a page offset that places it relative to the image.
+	shadow := wand.GetImage()
+	defer shadow.Destroy()
+	shadow.SetImageBackgroundColor(black)
+	if err := shadow.ShadowImage(opacity, sigma, x, y); err != nil {
+		return fmt.Errorf("failed to make shadow: %w", err)
+	}
+	image := wand.GetImage()
+	defer image.Destroy()
+	image.SetImagePage(0, 0, 0, 0)
+
+	layers := imagick.NewMagickWand()
+	defer layers.Destroy()
+	if err := layers.AddImage(shadow); err != nil {
+		return fmt.Errorf("failed to make shadow: %w", err)
+	}
+	if err := layers.AddImage(image); err != nil {
+		return fmt.Errorf("failed to make shadow: %w", err)
+	}
+	layers.SetFirstIterator()
+	layers.SetImageBackgroundColor(bg)
+	merged := layers.MergeImageLayers(imagick.IMAGE_LAYER_MERGE)
+	if merged == nil {
+		return fmt.Errorf("failed to merge shadow: %w", layers.GetLastError())
+	}
+	defer merged.Destroy()
+	merged.SetImagePage(merged.GetImageWidth(), merged.GetImageHeight(), 0, 0)
+	merged.SetImageDelay(wand.GetImageDelay())
+	merged.SetImageDispose(wand.GetImageDispose())
+	return wand.SetImage(merged)
+}
+
+// AddBorder surrounds the current image of wand with width pixels of color
+// on the left and right and height pixels at the top and bottom.
+func AddBorder(wand *imagick.MagickWand, color string, width, height uint) error {
+	pw := imagick.NewPixelWand()
+	defer pw.Destroy()
+	if !pw.SetColor(color) {
+		return fmt.Errorf("invalid color %q", color)
+	}
+	if err := wand.BorderImage(pw, width, height, imagick.COMPOSITE_OP_OVER); err != nil {
+		return fmt.Errorf("failed to add border: %w", err)
+	}
+	return nil
+}
+
+// AddFrame surrounds the current image of wand with a 3D frame of color,
+// width and height pixels wide, whose outer and inner edges are beveled by
+// outerBevel and innerBevel pixels: the outer bevel is lit from the top
+// left, the inner one shaded, so the image looks set into a raised matte.
+func AddFrame(wand *imagick.MagickWand, color string, width, height uint, outerBevel, innerBevel int) error {
+	if outerBevel < 0 || innerBevel < 0 {
+		return fmt.Errorf("bevels must not be negative")
+	}
+	if uint(outerBevel+innerBevel) > min(width, height) {
+		return fmt.Errorf("the bevels (%d+%d) do not fit in a %dx%d frame", outerBevel, innerBevel, width, height)
+	}
+	pw := imagick.NewPixelWand()
+	defer pw.Destroy()
+	if !pw.SetColor(color) {
+		return fmt.Errorf("invalid color %q", color)
+	}
+	if err := wand.FrameImage(pw, width, height, innerBevel, outerBevel, imagick.COMPOSITE_OP_OVER); err != nil {
+		return fmt.Errorf("failed to add frame: %w", err)
+	}
+	return nil
+}
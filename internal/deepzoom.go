@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Deep Zoom export.
+//
+// deepzoom writes the current image as a Deep Zoom Image (DZI) tile pyramid,
+// the format OpenSeadragon and other zoomable web viewers load: a small XML
+// descriptor, photo.dzi, next to a photo_files directory holding one
+// subdirectory per level. Level 0 is a single pixel and each level doubles
+// the size of the one before up to the full image; every level is cut into
+// square tiles named <column>_<row>.<format>, overlapping their neighbors by
+// a few pixels so the viewer can blend the seams.
+
+// deepZoomFormats are the tile formats offered, in EnumOptions order.
+var deepZoomFormats = []string{"JPG", "PNG", "WEBP"}
+
+// deepZoomQuality is the compression quality of JPEG and WebP tiles.
+const deepZoomQuality = 90
+
+// ExportDeepZoom writes the DZI pyramid of the current image of wand to
+// path (the .dzi file; the extension is added if missing) and returns the
+// path written and the number of levels and tiles.
+func ExportDeepZoom(wand *imagick.MagickWand, path string, tileSize, overlap uint, format string) (string, int, int, error) {
+	if tileSize < 16 {
+		return "", 0, 0, fmt.Errorf("tile size must be at least 16")
+	}
+	if overlap*2 >= tileSize {
+		return "", 0, 0, fmt.Errorf("overlap must be less than half the tile size")
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".dzi") {
+		path += ".dzi"
+	}
+	format = strings.ToLower(format)
+	tilesDir := strings.TrimSuffix(path, filepath.Ext(path)) + "_files"
+	if err := os.MkdirAll(tilesDir, 0755); err != nil {
+		return "", 0, 0, fmt.Errorf("create %s: %w", tilesDir, err)
+	}
+
+	img := wand.GetImage()
+	defer img.Destroy()
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	if w == 0 || h == 0 {
+		return "", 0, 0, fmt.Errorf("image has zero dimensions")
+	}
+	if format == "jpg" {
+		// JPEG tiles have no alpha; flatten onto white like the viewer's page.
+		white := imagick.NewPixelWand()
+		defer white.Destroy()
+		white.SetColor("white")
+		img.SetImageBackgroundColor(white)
+		if err := img.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE); err != nil {
+			return "", 0, 0, fmt.Errorf("failed to flatten alpha: %w", err)
+		}
+	}
+	img.StripImage()
+
+	maxLevel := int(math.Ceil(math.Log2(float64(max(w, h)))))
+	tiles := 0
+	for level := maxLevel; level >= 0; level-- {
+		lw, lh := img.GetImageWidth(), img.GetImageHeight()
+		dir := filepath.Join(tilesDir, fmt.Sprint(level))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", 0, tiles, fmt.Errorf("create %s: %w", dir, err)
+		}
+		for row := uint(0); row*tileSize < lh; row++ {
+			for col := uint(0); col*tileSize < lw; col++ {
+				if err := writeDeepZoomTile(img, dir, col, row, tileSize, overlap, format); err != nil {
+					return "", 0, tiles, err
+				}
+				tiles++
+			}
+		}
+		if level > 0 {
+			// Each level halves the one above, rounding up as DZI requires.
+			if err := img.ResizeImage(max(1, (lw+1)/2), max(1, (lh+1)/2), imagick.FILTER_BOX); err != nil {
+				return "", 0, tiles, fmt.Errorf("failed to scale level %d: %w", level-1, err)
+			}
+		}
+	}
+
+	descriptor := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" Format="%s" Overlap="%d" TileSize="%d">
+  <Size Width="%d" Height="%d"/>
+</Image>
+`, format, overlap, tileSize, w, h)
+	if err := os.WriteFile(path, []byte(descriptor), 0644); err != nil {
+		return "", 0, tiles, fmt.Errorf("write %s: %w", path, err)
+	}
+	return path, maxLevel + 1, tiles, nil
+}
+
+// writeDeepZoomTile writes the tile at col, row of the level image to dir.
+// Tiles extend by overlap pixels into each neighbor that exists.
+func writeDeepZoomTile(img *imagick.MagickWand, dir string, col, row, tileSize, overlap uint, format string) error {
+	lw, lh := img.GetImageWidth(), img.GetImageHeight()
+	x0, y0 := col*tileSize, row*tileSize
+	if col > 0 {
+		x0 -= overlap
+	}
+	if row > 0 {
+		y0 -= overlap
+	}
+	x1 := min(lw, (col+1)*tileSize+overlap)
+	y1 := min(lh, (row+1)*tileSize+overlap)
+	tile := img.GetImageRegion(x1-x0, y1-y0, int(x0), int(y0))
+	if tile == nil {
+		return fmt.Errorf("failed to cut tile %d_%d", col, row)
+	}
+	defer tile.Destroy()
+	tile.SetImagePage(0, 0, 0, 0)
+	if format != "png" {
+		tile.SetImageCompressionQuality(deepZoomQuality)
+	}
+	name := filepath.Join(dir, fmt.Sprintf("%d_%d.%s", col, row, format))
+	if err := tile.WriteImage(format + ":" + name); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Descreening: removing the halftone dot pattern of scanned prints.
+//
+// A print screened at L lines per inch and scanned at D dpi repeats every
+// D/L pixels, and its dots show up in the spectrum on a ring at N*L/D from the
+// center of an N-pixel spectrum. Everything at and above that frequency is
+// screen, not picture, so both methods below remove it: GAUSSIAN by blurring
+// over one screen period, FFT by cutting the spectrum off just below the
+// ring. A light unsharp mask afterwards restores some of the edge contrast
+// lost with the dots.
+
+// descreenMethods are the values of descreen's method parameter, in
+// EnumOptions order (the parameter normalizes to the option index).
+var descreenMethods = []string{"GAUSSIAN", "FFT"}
+
+// descreenDefaultDPI is assumed when the image carries no resolution.
+const descreenDefaultDPI = 300
+
+// descreenCutoff is the fraction of the screen frequency kept by FFT descreening.
+const descreenCutoff = 0.7
+
+// scanDPI returns the horizontal resolution of the current image in pixels per
+// inch, or 0 when it is unknown.
+func scanDPI(wand *imagick.MagickWand) float64 {
+	x, _, err := wand.GetImageResolution()
+	if err != nil || x <= 1 {
+		return 0
+	}
+	if wand.GetImageUnits() == imagick.RESOLUTION_PIXELS_PER_CENTIMETER {
+		x *= 2.54
+	}
+	return x
+}
+
+// Descreen removes a halftone screen of lpi lines per inch from the current
+// image of wand, scanned at dpi (0 = use the image's resolution).
+func Descreen(wand *imagick.MagickWand, lpi, dpi float64, method string, sharpen bool) error {
+	if lpi <= 0 {
+		return fmt.Errorf("lpi must be positive")
+	}
+	if dpi <= 0 {
+		dpi = scanDPI(wand)
+	}
+	if dpi <= 0 {
+		dpi = descreenDefaultDPI
+	}
+	period := dpi / lpi
+	if period < 2 {
+		return fmt.Errorf("a %.0f lpi screen cannot be resolved at %.0f dpi; the scan must be at least twice the screen frequency", lpi, dpi)
+	}
+
+	switch method {
+	case "GAUSSIAN":
+		if err := wand.GaussianBlurImage(0, period/2); err != nil {
+			return fmt.Errorf("failed to blur: %w", err)
+		}
+	case "FFT":
+		if err := fftLowPass(wand, descreenCutoff/period); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown descreen method %q", method)
+	}
+
+	if sharpen {
+		if err := wand.UnsharpMaskImage(0, period/2, 0.8, 0.02); err != nil {
+			return fmt.Errorf("failed to sharpen: %w", err)
+		}
+	}
+	return nil
+}
+
+// fftLowPass removes the frequencies of the current image of wand above
+// cutoff cycles per pixel, with a soft edge.
+func fftLowPass(wand *imagick.MagickWand, cutoff float64) error {
+	mag, phase, err := forwardFFT(wand)
+	if err != nil {
+		return err
+	}
+	defer mag.Destroy()
+	defer phase.Destroy()
+
+	w, h := mag.GetImageWidth(), mag.GetImageHeight()
+	radius := cutoff * float64(w)
+
+	mask := imagick.NewMagickWand()
+	defer mask.Destroy()
+	black := imagick.NewPixelWand()
+	defer black.Destroy()
+	black.SetColor("black")
+	if err := mask.NewImage(w, h, black); err != nil {
+		return fmt.Errorf("failed to create low-pass mask: %w", err)
+	}
+	dw := imagick.NewDrawingWand()
+	defer dw.Destroy()
+	white := imagick.NewPixelWand()
+	defer white.Destroy()
+	white.SetColor("white")
+	dw.SetFillColor(white)
+	cx, cy := float64(w/2), float64(h/2)
+	dw.Circle(cx, cy, cx+radius, cy)
+	if err := mask.DrawImage(dw); err != nil {
+		return fmt.Errorf("failed to draw low-pass mask: %w", err)
+	}
+	if err := mask.GaussianBlurImage(0, max(1, radius/10)); err != nil {
+		return fmt.Errorf("failed to soften low-pass mask: %w", err)
+	}
+
+	if err := mag.CompositeImage(mask, imagick.COMPOSITE_OP_MULTIPLY, true, 0, 0); err != nil {
+		return fmt.Errorf("failed to apply low-pass mask: %w", err)
+	}
+	return inverseFFT(wand, mag, phase)
+}
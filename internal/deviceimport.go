@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Importing from cameras and phones.
+//
+// Cameras attached over USB speak PTP, which gphoto2 talks to: `import
+// camera` lists the photos on the first camera it detects, and with a
+// destination downloads every file that is not there yet. Phones and cameras
+// that show up as a folder instead (MTP mounted by gvfs or a file manager, or
+// a memory card) are imported from that folder: photos anywhere below it are
+// copied, keeping their names and modification times, and files already in
+// the destination with the same name and size are skipped, so an import can
+// be repeated after taking more pictures.
+
+// cameraSource is the import source that means a camera reached through
+// gphoto2.
+const cameraSource = "camera"
+
+// photoExtensions are the files a folder import copies: the image formats
+// termagick scans for plus the raw and HEIF files cameras and phones write.
+var photoExtensions = []string{".heic", ".heif", ".dng", ".cr2", ".cr3", ".nef", ".arw", ".orf", ".rw2", ".raf", ".pef", ".srw"}
+
+// isPhotoFile reports whether a folder import copies the file at path.
+func isPhotoFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range photoExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return isImageFile(path)
+}
+
+// listCameraFiles prints the files on the camera as gphoto2 lists them.
+func listCameraFiles() error {
+	if err := requireProgram("gphoto2", "gphoto2"); err != nil {
+		return err
+	}
+	cmd, err := externalCommand("gphoto2", "--list-files")
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gphoto2: %w (is a camera connected and not mounted elsewhere?)", err)
+	}
+	return nil
+}
+
+// importFromCamera downloads the files on the camera that dest does not have
+// yet and returns the paths of the new files, in name order.
+func importFromCamera(dest string) ([]string, error) {
+	if err := requireProgram("gphoto2", "gphoto2"); err != nil {
+		return nil, err
+	}
+	names, err := dirFileNames(dest)
+	if err != nil {
+		return nil, err
+	}
+	before := make(map[string]bool, len(names))
+	for _, name := range names {
+		before[name] = true
+	}
+	cmd, err := externalCommand("gphoto2", "--get-all-files", "--skip-existing", "--filename", filepath.Join(dest, "%f.%C"))
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gphoto2: %w (is a camera connected and not mounted elsewhere?)", err)
+	}
+	after, err := dirFileNames(dest)
+	if err != nil {
+		return nil, err
+	}
+	var added []string
+	for _, name := range after {
+		if !before[name] {
+			added = append(added, filepath.Join(dest, name))
+		}
+	}
+	return added, nil
+}
+
+// dirFileNames returns the names of the files in dir, sorted.
+func dirFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read directory %s: %w", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// devicePhotos returns the photos below the folder src, in path order.
+func devicePhotos(src string) ([]string, error) {
+	var photos []string
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Phones keep thumbnails and trash in hidden folders.
+		if d.IsDir() && path != src && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && isPhotoFile(path) {
+			photos = append(photos, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", src, err)
+	}
+	return photos, nil
+}
+
+// importFromFolder copies the photos below src into dest and returns the
+// paths of the copies made and the number of photos skipped because dest
+// already has them. A different file with the same name is copied under a
+// numbered name.
+func importFromFolder(src, dest string) ([]string, int, error) {
+	photos, err := devicePhotos(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	var added []string
+	skipped := 0
+	for _, p := range photos {
+		info, err := os.Stat(p)
+		if err != nil {
+			return added, skipped, err
+		}
+		target := filepath.Join(dest, filepath.Base(p))
+		ext := filepath.Ext(target)
+		duplicate := false
+		for i := 2; ; i++ {
+			existing, err := os.Stat(target)
+			if err != nil {
+				break
+			}
+			if existing.Size() == info.Size() {
+				duplicate = true
+				break
+			}
+			target = strings.TrimSuffix(filepath.Join(dest, filepath.Base(p)), ext) + fmt.Sprintf("-%d", i) + ext
+		}
+		if duplicate {
+			skipped++
+			continue
+		}
+		// Copy under a temporary name so an interrupted import leaves no
+		// partial file that a later run would take for a finished one.
+		tmp := target + ".part"
+		if err := copyFile(p, tmp); err != nil {
+			os.Remove(tmp)
+			return added, skipped, fmt.Errorf("copy %s: %w", p, err)
+		}
+		if err := os.Rename(tmp, target); err != nil {
+			os.Remove(tmp)
+			return added, skipped, err
+		}
+		os.Chtimes(target, info.ModTime(), info.ModTime())
+		fmt.Printf("%s -> %s\n", p, target)
+		added = append(added, target)
+	}
+	return added, skipped, nil
+}
+
+// applyImport implements the import command: args are the source ("camera"
+// or a folder), the destination directory (empty to only list) and whether
+// to open the first imported photo.
+func (s *Session) applyImport(args []string) error {
+	source := args[0]
+	dest := ""
+	if len(args) > 1 {
+		dest = args[1]
+	}
+	open := len(args) > 2 && args[2] == "true"
+
+	if source != cameraSource {
+		if info, err := os.Stat(source); err != nil || !info.IsDir() {
+			return fmt.Errorf("source must be %q or a folder, got %q", cameraSource, source)
+		}
+	}
+	if dest == "" {
+		if source == cameraSource {
+			return listCameraFiles()
+		}
+		photos, err := devicePhotos(source)
+		if err != nil {
+			return err
+		}
+		for _, p := range photos {
+			fmt.Println(p)
+		}
+		fmt.Printf("%d photos in %s\n", len(photos), source)
+		return nil
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("create destination: %w", err)
+	}
+	var added []string
+	var err error
+	if source == cameraSource {
+		added, err = importFromCamera(dest)
+		if err == nil {
+			fmt.Printf("Imported %d new files to %s\n", len(added), dest)
+		}
+	} else {
+		var skipped int
+		added, skipped, err = importFromFolder(source, dest)
+		if err == nil {
+			fmt.Printf("Imported %d photos to %s (%d already there)\n", len(added), dest, skipped)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !open {
+		return nil
+	}
+	if len(added) == 0 {
+		fmt.Println("Nothing new to open")
+		return nil
+	}
+	// Prefer the JPEG of a raw+JPEG pair: raw and HEIF files open only with
+	// the matching delegates.
+	sort.Strings(added)
+	first := added[0]
+	for _, p := range added {
+		if isImageFile(p) {
+			first = p
+			break
+		}
+	}
+	if err := s.OpenBuffer(first); err != nil {
+		return fmt.Errorf("open %s: %w", first, err)
+	}
+	showPreview(s.Display())
+	return nil
+}
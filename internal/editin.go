@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Editing in another program.
+//
+// editIn hands the image to a full editor for what termagick cannot do
+// (painting, retouching, selections): the frame being edited, or the active
+// layer, is written losslessly to a temporary PNG, the program is run on it
+// and, once it exits, the saved file is read back in place of the frame. The
+// step can be repeated and recorded in macros but is left out of the image
+// history, since a recipe or magick command line cannot replay it.
+
+// imageEditors are the programs editIn tries, in order, when none is given
+// and TERMAGICK_IMAGE_EDITOR is unset. GIMP is started as a new instance so
+// it does not hand the file to a running one and exit at once.
+var imageEditors = [][]string{
+	{"gimp", "-n"},
+	{"krita", "--nosplash"},
+	{"pinta"},
+}
+
+// imageEditor returns the program and leading arguments to edit with.
+// program may include arguments ("krita --nosplash").
+func imageEditor(program string) ([]string, error) {
+	if program == "" {
+		program = os.Getenv("TERMAGICK_IMAGE_EDITOR")
+	}
+	if program != "" {
+		argv := strings.Fields(program)
+		if err := requireProgram(argv[0], argv[0]); err != nil {
+			return nil, err
+		}
+		return argv, nil
+	}
+	for _, argv := range imageEditors {
+		if hasProgram(argv[0]) {
+			return argv, nil
+		}
+	}
+	if config.NoExec {
+		return nil, errExecDisabled
+	}
+	return nil, fmt.Errorf("no image editor found (install GIMP or Krita, or set TERMAGICK_IMAGE_EDITOR)")
+}
+
+// applyEditIn runs the editIn command: editIn [program].
+func (s *Session) applyEditIn(args []string) error {
+	if s.Wand == nil {
+		return fmt.Errorf("no image loaded")
+	}
+	program := ""
+	if len(args) > 0 {
+		program = args[0]
+	}
+	argv, err := imageEditor(program)
+	if err != nil {
+		return err
+	}
+
+	dir, err := mkdirSessionTemp("edit-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	// A readable name, as editors show it in their title bar.
+	base := strings.TrimSuffix(s.BufferName(), filepath.Ext(s.BufferName()))
+	path := filepath.Join(dir, base+".png")
+
+	target := s.target()
+	frame := target.GetImage()
+	defer frame.Destroy()
+	if err := frame.WriteImage("png:" + path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	written, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	cmd, err := externalCommand(argv[0], append(argv[1:], path)...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	fmt.Printf("Editing in %s; save the file and quit the editor to return.\n", filepath.Base(argv[0]))
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+
+	if !modifiedSince(path, written) {
+		// Some launchers return at once and leave the editor running; give
+		// the user the chance to finish there.
+		if time.Since(start) < 5*time.Second {
+			answer, err := PromptLine("The editor returned without saving. Press Enter once the file is saved, or type q to cancel: ")
+			if err != nil || strings.EqualFold(strings.TrimSpace(answer), "q") {
+				return fmt.Errorf("edit cancelled")
+			}
+		}
+		if !modifiedSince(path, written) {
+			fmt.Println("The file was not changed; the image is left as it was.")
+			return nil
+		}
+	}
+
+	edited, err := LoadImage(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the edited file: %w", err)
+	}
+	defer edited.Destroy()
+	edited.ResetIterator()
+	if err := target.SetImage(edited); err != nil {
+		return fmt.Errorf("failed to replace image: %w", err)
+	}
+	s.dropJPEGFile()
+	s.recordInvocation(RecipeStep{Command: "editIn", Args: append([]string(nil), args...)})
+	fmt.Printf("Reloaded the edited image (%dx%d).\n", target.GetImageWidth(), target.GetImageHeight())
+	return nil
+}
+
+// modifiedSince reports whether the file at path differs from when it was
+// described by before.
+func modifiedSince(path string, before os.FileInfo) bool {
+	info, err := os.Stat(path)
+	return err == nil && (!info.ModTime().Equal(before.ModTime()) || info.Size() != before.Size())
+}
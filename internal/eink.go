@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// E-ink export.
+//
+// E-paper panels show black and white only, at a fixed resolution, and render
+// midtones darker than a monitor. ExportEink prepares a copy of the image for
+// such a panel: flattened onto white, converted to grayscale, fitted and
+// centered on a white canvas of the panel's size, lightened with a gamma
+// curve, ordered-dithered (a regular Bayer pattern, which unlike error
+// diffusion does not crawl when a dashboard is redrawn with small changes) and
+// written as a 1-bit PNG or PBM.
+
+// einkDefaultGamma lightens midtones to compensate for e-paper's dark tone
+// response.
+const einkDefaultGamma = 1.5
+
+// bayer8 is the 8x8 Bayer threshold matrix used for ordered dithering.
+var bayer8 = [8][8]int{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// ExportEink writes the current image of wand to path as a width x height
+// 1-bit image for an e-ink panel. gamma 0 uses einkDefaultGamma. The wand is
+// not modified.
+func ExportEink(wand *imagick.MagickWand, path string, width, height uint, gamma float64) error {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if ext != "png" && ext != "pbm" {
+		return fmt.Errorf("eink writes .png or .pbm files, not %q", filepath.Ext(path))
+	}
+	if width == 0 || height == 0 {
+		return fmt.Errorf("panel size must be positive")
+	}
+	if gamma == 0 {
+		gamma = einkDefaultGamma
+	}
+
+	img := wand.GetImage()
+	defer img.Destroy()
+	white := imagick.NewPixelWand()
+	defer white.Destroy()
+	white.SetColor("white")
+	if err := img.SetImageBackgroundColor(white); err != nil {
+		return fmt.Errorf("failed to set background: %w", err)
+	}
+	if img.GetImageAlphaChannel() {
+		if err := img.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE); err != nil {
+			return fmt.Errorf("failed to remove alpha: %w", err)
+		}
+	}
+	if err := img.TransformImageColorspace(imagick.COLORSPACE_GRAY); err != nil {
+		return fmt.Errorf("failed to convert to grayscale: %w", err)
+	}
+
+	// Fit inside the panel, then pad to its exact size.
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	scale := min(float64(width)/float64(w), float64(height)/float64(h))
+	fw, fh := max(1, uint(float64(w)*scale+0.5)), max(1, uint(float64(h)*scale+0.5))
+	if err := img.ResizeImage(fw, fh, imagick.FILTER_LANCZOS); err != nil {
+		return fmt.Errorf("failed to resize: %w", err)
+	}
+	if err := img.ExtentImage(width, height, -int(width-fw)/2, -int(height-fh)/2); err != nil {
+		return fmt.Errorf("failed to pad to panel size: %w", err)
+	}
+	if err := img.GammaImage(gamma); err != nil {
+		return fmt.Errorf("failed to apply gamma: %w", err)
+	}
+
+	px, err := img.ExportImagePixels(0, 0, width, height, "I", imagick.PIXEL_CHAR)
+	if err != nil {
+		return fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	gray, ok := px.([]byte)
+	if !ok {
+		return fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+	for y := 0; y < int(height); y++ {
+		for x := 0; x < int(width); x++ {
+			i := y*int(width) + x
+			// Thresholds are spread evenly over 0-255 in the matrix order.
+			if int(gray[i])*64 > bayer8[y%8][x%8]*256+128 {
+				gray[i] = 255
+			} else {
+				gray[i] = 0
+			}
+		}
+	}
+	if err := img.ImportImagePixels(0, 0, width, height, "I", imagick.PIXEL_CHAR, gray); err != nil {
+		return fmt.Errorf("ImportImagePixels failed: %w", err)
+	}
+
+	if err := img.SetImageType(imagick.IMAGE_TYPE_BILEVEL); err != nil {
+		return fmt.Errorf("failed to set bilevel type: %w", err)
+	}
+	if err := img.SetImageDepth(1); err != nil {
+		return fmt.Errorf("failed to set bit depth: %w", err)
+	}
+	if err := img.WriteImage(ext + ":" + path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
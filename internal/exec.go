@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os/exec"
+)
+
+// External programs.
+//
+// Everything termagick runs outside its own process (fzf and the find
+// pipeline behind file selection, img2sixel, chafa, jpegtran, fontconfig's
+// fc-match and fc-query, gphoto2, and the restart after a self-update) goes
+// through externalCommand, hasProgram or requireProgram, so --no-exec or
+// security.no_exec in the config file turns all of it off for locked-down
+// environments. Callers treat a disabled program like a missing one and fall
+// back to built-in behavior where there is one: typed prompts instead of fzf,
+// ImageMagick's own sixel encoder, re-encoding rotate/crop instead of
+// jpegtran.
+
+// errExecDisabled is returned instead of running a program under --no-exec.
+var errExecDisabled = errors.New("running external programs is disabled (--no-exec)")
+
+// addNoExecFlag registers --no-exec on a subcommand's flag set. It defaults to
+// the config file's setting, which must already be loaded.
+func addNoExecFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&config.NoExec, "no-exec", config.NoExec, "never run external programs (fzf, chafa, img2sixel, jpegtran, ...); use built-in fallbacks")
+}
+
+// externalCommand returns the command to run name with args, or
+// errExecDisabled.
+func externalCommand(name string, args ...string) (*exec.Cmd, error) {
+	if config.NoExec {
+		return nil, errExecDisabled
+	}
+	return exec.Command(name, args...), nil
+}
+
+// hasProgram reports whether name is in PATH and may be run.
+func hasProgram(name string) bool {
+	if config.NoExec {
+		return false
+	}
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// requireProgram returns an error explaining why name cannot be run, naming
+// the package that provides it, or nil.
+func requireProgram(name, pkg string) error {
+	if config.NoExec {
+		return fmt.Errorf("%s: %w", name, errExecDisabled)
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s not found in PATH (install %s)", name, pkg)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+)
+
+// Exit codes of the subcommands, stable so scripts and CI pipelines can
+// branch on them. An error that carries none of the specific codes exits
+// with exitFailure.
+const (
+	exitOK         = 0
+	exitFailure    = 1 // any other failure
+	exitUsage      = 2 // invalid arguments or flags
+	exitRead       = 3 // an input image, recipe or directory could not be read
+	exitValidation = 4 // a recipe step or option is invalid, or a check found problems
+	exitWrite      = 5 // a result could not be written
+)
+
+// exitError is an error with the exit code it should end the program with.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode attaches an exit code to err. A nil err stays nil, and an
+// error that already carries a code keeps it.
+func withExitCode(code int, err error) error {
+	var e *exitError
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the exit code for the error a subcommand returned.
+func exitCode(err error) int {
+	var e *exitError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &e):
+		return e.code
+	}
+	return exitFailure
+}
+
+// commonExitCode returns the exit code shared by all errs, or exitFailure
+// when they differ, for subcommands that report many failed files at once.
+func commonExitCode(errs []error) int {
+	code := exitFailure
+	for i, err := range errs {
+		c := exitCode(err)
+		if i > 0 && c != code {
+			return exitFailure
+		}
+		code = c
+	}
+	return code
+}
+
+// quiet is set by --quiet; standard output is then discarded, and only
+// errors, on standard error, and the exit code remain.
+var quiet bool
+
+func addQuietFlag(fs *flag.FlagSet) {
+	fs.BoolFunc("quiet", "print nothing but errors; check the exit code for the result", func(s string) error {
+		on, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		if on && !quiet {
+			quiet = true
+			if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+				os.Stdout = devNull
+			}
+		}
+		return nil
+	})
+}
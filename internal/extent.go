@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Canvas size.
+//
+// extent changes the size of the canvas without scaling the image: a larger
+// canvas adds background around it, a smaller one cuts it off, and gravity
+// says where the image sits. padToAspect is the counterpart of cropAspect:
+// it adds only as much background as it takes to reach an aspect ratio, so
+// a portrait photo can be posted as a 1:1 square or shown in a 16:9 slot
+// without losing any of it. chop and splice work on bands instead: chop cuts
+// rows and columns out of the middle of the image and closes the gap, splice
+// opens a gap of background.
+
+// optionalGravity returns the gravity a normalized enum argument names, or
+// CENTER when it is empty.
+func optionalGravity(arg string) (string, error) {
+	if arg == "" {
+		return "CENTER", nil
+	}
+	idx, err := strconv.Atoi(arg)
+	if err != nil || idx < 0 || idx >= len(cropGravities) {
+		return "", fmt.Errorf("invalid gravity %q", arg)
+	}
+	return cropGravities[idx], nil
+}
+
+// ExtendCanvas sets the canvas of the current image of wand to width x
+// height, placing the image by gravity (one of cropGravities) and filling
+// new areas with background. A width or height of 0 keeps that side.
+func ExtendCanvas(wand *imagick.MagickWand, width, height uint, gravity, background string) error {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if width == 0 {
+		width = w
+	}
+	if height == 0 {
+		height = h
+	}
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	if !bg.SetColor(background) {
+		return fmt.Errorf("invalid background color %q", background)
+	}
+	if err := wand.SetImageBackgroundColor(bg); err != nil {
+		return fmt.Errorf("failed to set background: %w", err)
+	}
+	// ExtentImage takes the canvas's offset from the image, the opposite of
+	// where the image goes on the canvas.
+	x, y := gravityOffset(int(width), int(height), int(w), int(h), gravity)
+	if err := wand.ExtentImage(width, height, -x, -y); err != nil {
+		return fmt.Errorf("failed to extend canvas: %w", err)
+	}
+	return wand.SetImagePage(width, height, 0, 0)
+}
+
+// SpliceBand inserts width columns at x and height rows at y into the
+// current image of wand, filled with background. Either may be 0 to insert
+// only rows or only columns.
+func SpliceBand(wand *imagick.MagickWand, width, height uint, x, y int, background string) error {
+	if width == 0 && height == 0 {
+		return fmt.Errorf("splice needs a width or a height")
+	}
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	if !bg.SetColor(background) {
+		return fmt.Errorf("invalid background color %q", background)
+	}
+	if err := wand.SetImageBackgroundColor(bg); err != nil {
+		return fmt.Errorf("failed to set background: %w", err)
+	}
+	if err := wand.SpliceImage(width, height, x, y); err != nil {
+		return fmt.Errorf("failed to splice: %w", err)
+	}
+	return wand.SetImagePage(wand.GetImageWidth(), wand.GetImageHeight(), 0, 0)
+}
+
+// parseRatio parses an aspect ratio written W:H, such as 16:9, 9:16, 1:1
+// or 1.91:1, into W/H.
+func parseRatio(s string) (float64, error) {
+	a, b, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid ratio %q: write it as W:H, e.g. 16:9", s)
+	}
+	w, errW := strconv.ParseFloat(a, 64)
+	h, errH := strconv.ParseFloat(b, 64)
+	if errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return 0, fmt.Errorf("invalid ratio %q: write it as W:H, e.g. 16:9", s)
+	}
+	return w / h, nil
+}
+
+// padSize returns the smallest canvas of the given ratio (width over height)
+// that holds a width x height image.
+func padSize(width, height uint, ratio float64) (uint, uint) {
+	w, h := float64(width), float64(height)
+	if w/h < ratio {
+		return uint(math.Round(h * ratio)), height
+	}
+	return width, uint(math.Round(w / ratio))
+}
+
+// PadToAspect extends the canvas of the current image of wand to the
+// smallest size with the aspect ratio given as W:H, placing the image by
+// gravity and filling the rest with background.
+func PadToAspect(wand *imagick.MagickWand, ratio, gravity, background string) error {
+	r, err := parseRatio(ratio)
+	if err != nil {
+		return err
+	}
+	if wand.GetImageWidth() == 0 || wand.GetImageHeight() == 0 {
+		return fmt.Errorf("image has zero dimensions")
+	}
+	w, h := padSize(wand.GetImageWidth(), wand.GetImageHeight(), r)
+	return ExtendCanvas(wand, w, h, gravity, background)
+}
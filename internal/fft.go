@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Frequency-domain filtering.
+//
+// ForwardFourierTransformImage splits an image into a magnitude and a phase
+// image, padded to an even square. ImageMagick centers the spectrum: the zero
+// frequency sits at (N/2, N/2) and a periodic pattern (scanner moiré, fabric,
+// halftone dots) shows up as pairs of bright spikes mirrored through the
+// center. Spikes are addressed by their offset dx,dy from the center, which is
+// how fftSpectrum reports them and how fftNotch takes them.
+
+// fftMaxPeaks bounds the number of peaks fftSpectrum lists and fftNotch
+// suppresses in auto mode.
+const fftMaxPeaks = 8
+
+// fftPeak is a spectrum position as an offset from the center.
+type fftPeak struct {
+	dx, dy   int
+	strength float64
+}
+
+func (p fftPeak) String() string {
+	return fmt.Sprintf("%d,%d", p.dx, p.dy)
+}
+
+// forwardFFT returns the magnitude and phase images of the current image of
+// wand. Alpha is not transformed; inverseFFT restores it.
+func forwardFFT(wand *imagick.MagickWand) (mag, phase *imagick.MagickWand, err error) {
+	fw := wand.GetImage()
+	defer fw.Destroy()
+	if fw.GetImageAlphaChannel() {
+		if err := fw.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_OFF); err != nil {
+			return nil, nil, fmt.Errorf("failed to disable alpha: %w", err)
+		}
+	}
+	if err := fw.ForwardFourierTransformImage(true); err != nil {
+		return nil, nil, fmt.Errorf("forward FFT failed: %w", err)
+	}
+	if fw.GetNumberImages() < 2 {
+		return nil, nil, fmt.Errorf("forward FFT returned no phase image")
+	}
+	fw.SetIteratorIndex(0)
+	mag = fw.GetImage()
+	fw.SetIteratorIndex(1)
+	phase = fw.GetImage()
+	return mag, phase, nil
+}
+
+// inverseFFT transforms mag and phase back and replaces the current image of
+// wand with the result, cropped to the original size and with its alpha.
+func inverseFFT(wand, mag, phase *imagick.MagickWand) error {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	out := mag.Clone()
+	defer out.Destroy()
+	if err := out.InverseFourierTransformImage(phase, true); err != nil {
+		return fmt.Errorf("inverse FFT failed: %w", err)
+	}
+	if err := out.CropImage(w, h, 0, 0); err != nil {
+		return fmt.Errorf("failed to crop padding: %w", err)
+	}
+	if err := out.SetImagePage(w, h, 0, 0); err != nil {
+		return fmt.Errorf("failed to reset page: %w", err)
+	}
+	if wand.GetImageAlphaChannel() {
+		if err := out.CompositeImage(wand, imagick.COMPOSITE_OP_COPY_ALPHA, true, 0, 0); err != nil {
+			return fmt.Errorf("failed to restore alpha: %w", err)
+		}
+	}
+	out.SetImageDelay(wand.GetImageDelay())
+	return wand.SetImage(out)
+}
+
+// fftSpectrumImage returns a viewable spectrum: the magnitude, normalized and
+// log-scaled so the faint high frequencies are visible next to the center.
+func fftSpectrumImage(mag *imagick.MagickWand) (*imagick.MagickWand, error) {
+	spectrum := mag.Clone()
+	if err := spectrum.AutoLevelImage(); err != nil {
+		spectrum.Destroy()
+		return nil, fmt.Errorf("failed to normalize spectrum: %w", err)
+	}
+	if err := spectrum.EvaluateImage(imagick.EVAL_OP_LOG, 10000); err != nil {
+		spectrum.Destroy()
+		return nil, fmt.Errorf("failed to log-scale spectrum: %w", err)
+	}
+	return spectrum, nil
+}
+
+// findFFTPeaks returns the strongest isolated spikes of a log-scaled spectrum,
+// one per mirrored pair, strongest first. The area around the center holds the
+// image content itself and is skipped.
+func findFFTPeaks(spectrum *imagick.MagickWand, limit int) ([]fftPeak, error) {
+	w, h := int(spectrum.GetImageWidth()), int(spectrum.GetImageHeight())
+	px, err := spectrum.ExportImagePixels(0, 0, uint(w), uint(h), "I", imagick.PIXEL_DOUBLE)
+	if err != nil {
+		return nil, fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	vals, ok := px.([]float64)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+	cx, cy := w/2, h/2
+	exclude := max(4, min(w, h)/32)
+	outside := func(x, y int) bool {
+		dx, dy := x-cx, y-cy
+		return dx*dx+dy*dy > exclude*exclude
+	}
+
+	// Peaks must stand well above the rest of the spectrum.
+	var sum, sumSq, n float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if outside(x, y) {
+				v := vals[y*w+x]
+				sum += v
+				sumSq += v * v
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	mean := sum / n
+	threshold := mean + 4*math.Sqrt(math.Max(0, sumSq/n-mean*mean))
+
+	var peaks []fftPeak
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			dx, dy := x-cx, y-cy
+			// Keep one spike of each mirrored pair: the upper half.
+			if dy > 0 || (dy == 0 && dx < 0) || !outside(x, y) {
+				continue
+			}
+			v := vals[y*w+x]
+			if v < threshold || !isLocalMax(vals, w, x, y) {
+				continue
+			}
+			peaks = append(peaks, fftPeak{dx: dx, dy: dy, strength: v})
+		}
+	}
+	sort.Slice(peaks, func(i, j int) bool { return peaks[i].strength > peaks[j].strength })
+	if len(peaks) > limit {
+		peaks = peaks[:limit]
+	}
+	return peaks, nil
+}
+
+// isLocalMax reports whether (x, y) is at least as bright as its 8 neighbors.
+func isLocalMax(vals []float64, w, x, y int) bool {
+	v := vals[y*w+x]
+	for j := -1; j <= 1; j++ {
+		for i := -1; i <= 1; i++ {
+			if (i != 0 || j != 0) && vals[(y+j)*w+x+i] > v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseNotches parses notch offsets such as "40,-12 0,35" (separated by
+// spaces or semicolons).
+func parseNotches(s string) ([]fftPeak, error) {
+	var notches []fftPeak
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ';' }) {
+		xs, ys, ok := strings.Cut(f, ",")
+		dx, errX := strconv.Atoi(strings.TrimSpace(xs))
+		dy, errY := strconv.Atoi(strings.TrimSpace(ys))
+		if !ok || errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid notch %q (expected dx,dy)", f)
+		}
+		notches = append(notches, fftPeak{dx: dx, dy: dy})
+	}
+	if len(notches) == 0 {
+		return nil, fmt.Errorf("no notches given")
+	}
+	return notches, nil
+}
+
+// ShowFFTSpectrum previews the spectrum of the current image of wand, lists
+// its strongest peaks and optionally writes the spectrum to output.
+func ShowFFTSpectrum(wand *imagick.MagickWand, output string) error {
+	mag, phase, err := forwardFFT(wand)
+	if err != nil {
+		return err
+	}
+	defer mag.Destroy()
+	defer phase.Destroy()
+	spectrum, err := fftSpectrumImage(mag)
+	if err != nil {
+		return err
+	}
+	defer spectrum.Destroy()
+
+	w, h := spectrum.GetImageWidth(), spectrum.GetImageHeight()
+	fmt.Printf("Spectrum %dx%d, center at %d,%d\n", w, h, w/2, h/2)
+	peaks, err := findFFTPeaks(spectrum, fftMaxPeaks)
+	if err != nil {
+		return err
+	}
+	if len(peaks) == 0 {
+		fmt.Println("No isolated peaks found: the image shows no strong periodic pattern.")
+	} else {
+		names := make([]string, len(peaks))
+		for i, p := range peaks {
+			names[i] = p.String()
+		}
+		fmt.Printf("Peaks (dx,dy from center, strongest first): %s\n", strings.Join(names, " "))
+		fmt.Println("Suppress them with fftNotch, giving these offsets or \"auto\".")
+	}
+	if output != "" {
+		if err := spectrum.WriteImage(output); err != nil {
+			return fmt.Errorf("failed to write spectrum: %w", err)
+		}
+		fmt.Printf("Wrote spectrum to %s\n", output)
+	}
+	if err := PreviewWand(spectrum); err != nil {
+		debugf("spectrum preview failed: %v", err)
+	}
+	return nil
+}
+
+// FFTNotch suppresses the given spectrum positions (and their mirror images)
+// in the current image of wand with soft-edged notches of the given radius,
+// then transforms back. With auto set, the strongest peaks are found first.
+func FFTNotch(wand *imagick.MagickWand, notches []fftPeak, auto bool, radius float64) error {
+	mag, phase, err := forwardFFT(wand)
+	if err != nil {
+		return err
+	}
+	defer mag.Destroy()
+	defer phase.Destroy()
+
+	if auto {
+		spectrum, err := fftSpectrumImage(mag)
+		if err != nil {
+			return err
+		}
+		notches, err = findFFTPeaks(spectrum, fftMaxPeaks)
+		spectrum.Destroy()
+		if err != nil {
+			return err
+		}
+		if len(notches) == 0 {
+			return fmt.Errorf("no periodic pattern found to remove")
+		}
+	}
+
+	w, h := mag.GetImageWidth(), mag.GetImageHeight()
+	mask, err := fftNotchMask(w, h, notches, radius)
+	if err != nil {
+		return err
+	}
+	defer mask.Destroy()
+	if err := mag.CompositeImage(mask, imagick.COMPOSITE_OP_MULTIPLY, true, 0, 0); err != nil {
+		return fmt.Errorf("failed to apply notch mask: %w", err)
+	}
+	return inverseFFT(wand, mag, phase)
+}
+
+// fftNotchMask returns a white w x h mask with a black, slightly blurred disc
+// at each notch and at its mirror position through the center.
+func fftNotchMask(w, h uint, notches []fftPeak, radius float64) (*imagick.MagickWand, error) {
+	mask := imagick.NewMagickWand()
+	white := imagick.NewPixelWand()
+	defer white.Destroy()
+	white.SetColor("white")
+	if err := mask.NewImage(w, h, white); err != nil {
+		mask.Destroy()
+		return nil, fmt.Errorf("failed to create notch mask: %w", err)
+	}
+
+	dw := imagick.NewDrawingWand()
+	defer dw.Destroy()
+	black := imagick.NewPixelWand()
+	defer black.Destroy()
+	black.SetColor("black")
+	dw.SetFillColor(black)
+	cx, cy := float64(w/2), float64(h/2)
+	for _, n := range notches {
+		for _, s := range []float64{1, -1} {
+			x, y := cx+s*float64(n.dx), cy+s*float64(n.dy)
+			dw.Circle(x, y, x+radius, y)
+		}
+	}
+	if err := mask.DrawImage(dw); err != nil {
+		mask.Destroy()
+		return nil, fmt.Errorf("failed to draw notch mask: %w", err)
+	}
+	// Hard-edged notches ring; soften them a little.
+	if err := mask.GaussianBlurImage(0, math.Max(0.5, radius/3)); err != nil {
+		mask.Destroy()
+		return nil, fmt.Errorf("failed to soften notch mask: %w", err)
+	}
+	return mask, nil
+}
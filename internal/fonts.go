@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// ListFonts returns the fonts ImageMagick knows (from its type.xml/fontconfig
+// configuration) whose names match pattern, sorted by name. An empty pattern
+// lists every font.
+func ListFonts(pattern string) []string {
+	if pattern == "" {
+		pattern = "*"
+	}
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+	fonts := mw.QueryFonts(pattern)
+	sort.Strings(fonts)
+	return fonts
+}
+
+// matchFonts returns the fonts whose names contain every whitespace-separated
+// word of query, ignoring case and the separators ImageMagick uses in font
+// names ("dejavu sans bold" matches "DejaVu-Sans-Bold"). An exact
+// case-insensitive match is returned on its own.
+func matchFonts(query string, fonts []string) []string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("-", " ", "_", " ").Replace(s))
+	}
+	q := normalize(query)
+	words := strings.Fields(q)
+	var matches []string
+	for _, f := range fonts {
+		nf := normalize(f)
+		if nf == q {
+			return []string{f}
+		}
+		all := true
+		for _, w := range words {
+			if !strings.Contains(nf, w) {
+				all = false
+				break
+			}
+		}
+		if all {
+			matches = append(matches, f)
+		}
+	}
+	return matches
+}
+
+// PromptFont prompts for a font. Entering "/" opens fzf over the known fonts;
+// otherwise the input may be an exact font name, a font file path, or part of
+// a name, which is resolved fuzzily. Ambiguous input lists the candidates and
+// asks again. An empty answer keeps the default font.
+func PromptFont(prompt string) (string, error) {
+	fonts := ListFonts("")
+	for {
+		input, err := PromptLine(prompt)
+		if err != nil {
+			return "", err
+		}
+		if input == "" {
+			return "", nil
+		}
+		if input == "/" {
+			sel, selErr := SelectFontWithFzf(fonts)
+			if selErr == nil && sel != "" {
+				fmt.Printf(" [fzf] %s\n", sel)
+				return sel, nil
+			}
+			// fzf not available or selection cancelled — ask again.
+			continue
+		}
+		// Font files are used as given.
+		if _, statErr := os.Stat(input); statErr == nil {
+			return input, nil
+		}
+		matches := matchFonts(input, fonts)
+		switch {
+		case len(matches) == 1:
+			if matches[0] != input {
+				fmt.Printf(" using font %s\n", matches[0])
+			}
+			return matches[0], nil
+		case len(matches) == 0:
+			fmt.Printf("no font matches %q (run the fonts command to list them)\n", input)
+		default:
+			fmt.Println("ambiguous font, candidates:")
+			for i, m := range matches {
+				if i == 10 {
+					fmt.Printf("  ... and %d more\n", len(matches)-10)
+					break
+				}
+				fmt.Println("  " + m)
+			}
+		}
+	}
+}
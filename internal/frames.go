@@ -0,0 +1,297 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Multi-frame (animated GIF/WebP, multi-page TIFF) support.
+//
+// MagickWand operations only touch the image at the wand's current iterator
+// position, so without special handling editing an animation silently changes
+// just one frame. The helpers below coalesce animations on load so each frame
+// is a full canvas, let the caller move between frames, optionally apply a
+// command to every frame, and re-optimize the frame layers on save.
+
+// LoadImage reads path into a new wand. Multi-frame images are coalesced so
+// every frame can be edited independently, and the iterator is positioned on
+// the first frame.
+func LoadImage(path string) (*imagick.MagickWand, error) {
+	wand := imagick.NewMagickWand()
+	if err := wand.ReadImage(path); err != nil {
+		wand.Destroy()
+		return nil, err
+	}
+	if wand.GetNumberImages() <= 1 {
+		return wand, nil
+	}
+	coalesced := wand.CoalesceImages()
+	if coalesced == nil {
+		// Fall back to the raw frames; editing still works, just with partial canvases.
+		wand.ResetIterator()
+		return wand, nil
+	}
+	wand.Destroy()
+	coalesced.ResetIterator()
+	return coalesced, nil
+}
+
+// isMultiFrame reports whether the wand holds more than one frame.
+func isMultiFrame(wand *imagick.MagickWand) bool {
+	return wand != nil && wand.GetNumberImages() > 1
+}
+
+// frameStatus returns a short "frame i/n" description for multi-frame images
+// and an empty string otherwise.
+func frameStatus(wand *imagick.MagickWand) string {
+	if !isMultiFrame(wand) {
+		return ""
+	}
+	return fmt.Sprintf("Frame %d/%d", wand.GetIteratorIndex()+1, wand.GetNumberImages())
+}
+
+// stepFrame moves the wand iterator by delta frames, wrapping around at both
+// ends. It is a no-op for single-frame images.
+func stepFrame(wand *imagick.MagickWand, delta int) {
+	if !isMultiFrame(wand) {
+		return
+	}
+	n := int(wand.GetNumberImages())
+	idx := (int(wand.GetIteratorIndex()) + delta) % n
+	if idx < 0 {
+		idx += n
+	}
+	wand.SetIteratorIndex(idx)
+}
+
+// ApplyCommandFrames applies a command to the current frame, or to every frame
+// when allFrames is set. The iterator position is restored afterwards.
+// Generators and other whole-sequence commands always run once. Each frame's
+// region, if set, confines the command (see region.go).
+func ApplyCommandFrames(wand *imagick.MagickWand, commandName string, args []string, allFrames bool) error {
+	if wholeSequence(commandName) {
+		return ApplyCommand(wand, commandName, args)
+	}
+	if !allFrames || !isMultiFrame(wand) {
+		return applyInRegion(wand, commandName, args)
+	}
+	current := int(wand.GetIteratorIndex())
+	defer wand.SetIteratorIndex(current)
+
+	wand.ResetIterator()
+	frame := 0
+	for wand.NextImage() {
+		frame++
+		if err := applyInRegion(wand, commandName, args); err != nil {
+			return fmt.Errorf("frame %d: %w", frame, err)
+		}
+	}
+	return nil
+}
+
+// WriteWand saves the wand to path. Multi-frame images are re-optimized (the
+// inverse of the coalesce performed on load) and written as a single
+// animation; single images are written as before. Images without a quality
+// of their own get save.quality from the config file. With
+// save.backup_originals on, a file about to be overwritten is backed up
+// first, and nothing is written if that fails.
+func WriteWand(wand *imagick.MagickWand, path string) error {
+	if wand == nil {
+		return fmt.Errorf("nil wand")
+	}
+	if err := backupBeforeWrite(path); err != nil {
+		return err
+	}
+	applyDefaultQuality(wand)
+	if !isMultiFrame(wand) {
+		return wand.WriteImage(path)
+	}
+	optimized := wand.OptimizeImageLayers()
+	if optimized == nil {
+		return wand.WriteImages(path, true)
+	}
+	defer optimized.Destroy()
+	return optimized.WriteImages(path, true)
+}
+
+// applyDefaultQuality sets the configured save quality on every frame whose
+// compression quality is undefined (0), e.g. images not read from a JPEG.
+func applyDefaultQuality(wand *imagick.MagickWand) {
+	if config.SaveQuality == 0 {
+		return
+	}
+	current := wand.GetIteratorIndex()
+	wand.ResetIterator()
+	for wand.NextImage() {
+		if wand.GetImageCompressionQuality() == 0 {
+			wand.SetImageCompressionQuality(uint(config.SaveQuality))
+		}
+	}
+	wand.SetIteratorIndex(int(current))
+}
+
+// replaceWandImages swaps the contents of dst for the images held by src,
+// leaving the iterator on the first frame. It lets generator commands produce
+// a new image while callers keep using the same wand.
+func replaceWandImages(dst, src *imagick.MagickWand) error {
+	dst.Clear()
+	if err := dst.AddImage(src); err != nil {
+		return fmt.Errorf("failed to replace image: %w", err)
+	}
+	dst.ResetIterator()
+	return nil
+}
+
+// BuildAnimation reads the given still images into a single animated sequence.
+// delay is the per-frame delay in ticks (1/100 s) and loops the number of
+// repetitions (0 = loop forever). Frames whose size differs from the first
+// image are scaled to match so the animation has a consistent canvas.
+func BuildAnimation(paths []string, delay, loops uint) (*imagick.MagickWand, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no input images")
+	}
+	anim := imagick.NewMagickWand()
+	var width, height uint
+	for i, p := range paths {
+		frame := imagick.NewMagickWand()
+		if err := frame.ReadImage(p); err != nil {
+			frame.Destroy()
+			anim.Destroy()
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		// Only the first frame of an already animated input is used.
+		frame.SetFirstIterator()
+		if i == 0 {
+			width, height = frame.GetImageWidth(), frame.GetImageHeight()
+		} else if frame.GetImageWidth() != width || frame.GetImageHeight() != height {
+			if err := frame.ResizeImage(width, height, imagick.FILTER_LANCZOS); err != nil {
+				frame.Destroy()
+				anim.Destroy()
+				return nil, fmt.Errorf("failed to scale %s: %w", p, err)
+			}
+		}
+		single := frame.GetImage()
+		frame.Destroy()
+		err := anim.AddImage(single)
+		single.Destroy()
+		if err != nil {
+			anim.Destroy()
+			return nil, fmt.Errorf("failed to add %s: %w", p, err)
+		}
+	}
+
+	anim.ResetIterator()
+	for anim.NextImage() {
+		if err := anim.SetImageDelay(delay); err != nil {
+			anim.Destroy()
+			return nil, fmt.Errorf("failed to set delay: %w", err)
+		}
+		if err := anim.SetImageDispose(imagick.DISPOSE_NONE); err != nil {
+			anim.Destroy()
+			return nil, fmt.Errorf("failed to set dispose method: %w", err)
+		}
+		if err := anim.SetImageIterations(loops); err != nil {
+			anim.Destroy()
+			return nil, fmt.Errorf("failed to set loop count: %w", err)
+		}
+	}
+	// Default to GIF so previews and blobs behave; saving with another
+	// extension (e.g. .webp) still picks the format from the filename.
+	if err := anim.SetImageFormat("GIF"); err != nil {
+		anim.Destroy()
+		return nil, fmt.Errorf("failed to set format: %w", err)
+	}
+	anim.ResetIterator()
+	return anim, nil
+}
+
+// disposeMethods are the values of setFrameDispose's method parameter, in
+// EnumOptions order, with the matching ImageMagick constants.
+var disposeMethods = []string{"UNDEFINED", "NONE", "BACKGROUND", "PREVIOUS"}
+
+var disposeTypes = []imagick.DisposeType{imagick.DISPOSE_UNDEFINED, imagick.DISPOSE_NONE, imagick.DISPOSE_BACKGROUND, imagick.DISPOSE_PREVIOUS}
+
+// disposeName returns the display name of a dispose method.
+func disposeName(d imagick.DisposeType) string {
+	for i, t := range disposeTypes {
+		if t == d {
+			return disposeMethods[i]
+		}
+	}
+	return "UNKNOWN"
+}
+
+// printFrameTiming lists every frame's delay and dispose method, marking the
+// current frame, followed by the total duration and loop count.
+func printFrameTiming(wand *imagick.MagickWand) {
+	current := int(wand.GetIteratorIndex())
+	defer wand.SetIteratorIndex(current)
+
+	tps := wand.GetImageTicksPerSecond()
+	if tps == 0 {
+		tps = 100
+	}
+	var total uint
+	n := int(wand.GetNumberImages())
+	for i := 0; i < n; i++ {
+		wand.SetIteratorIndex(i)
+		marker := " "
+		if i == current {
+			marker = "*"
+		}
+		delay := wand.GetImageDelay()
+		total += delay
+		fmt.Printf("%s frame %3d: delay %4d (%.3fs)  dispose %s\n", marker, i+1, delay, float64(delay)/float64(tps), disposeName(wand.GetImageDispose()))
+	}
+	loops := "forever"
+	if it := wand.GetImageIterations(); it > 0 {
+		loops = fmt.Sprintf("%d times", it)
+	}
+	fmt.Printf("%d frames, total %.3fs, loops %s\n", n, float64(total)/float64(tps), loops)
+}
+
+// reorderFrames replaces the wand's frames with the frames at the given
+// indices, in order (indices may repeat). The iterator ends on the first frame.
+func reorderFrames(wand *imagick.MagickWand, order []int) error {
+	out := imagick.NewMagickWand()
+	defer out.Destroy()
+	for _, i := range order {
+		wand.SetIteratorIndex(i)
+		frame := wand.GetImage()
+		err := out.AddImage(frame)
+		frame.Destroy()
+		if err != nil {
+			return fmt.Errorf("failed to copy frame %d: %w", i+1, err)
+		}
+	}
+	return replaceWandImages(wand, out)
+}
+
+// reverseFrames reverses the frame order.
+func reverseFrames(wand *imagick.MagickWand) error {
+	n := int(wand.GetNumberImages())
+	order := make([]int, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		order = append(order, i)
+	}
+	return reorderFrames(wand, order)
+}
+
+// pingPongFrames appends the frames in reverse (without repeating the first
+// and last frame) so the animation plays forwards then backwards seamlessly.
+func pingPongFrames(wand *imagick.MagickWand) error {
+	n := int(wand.GetNumberImages())
+	if n < 3 {
+		// One or two frames already play identically in both directions.
+		return nil
+	}
+	order := make([]int, 0, 2*n-2)
+	for i := 0; i < n; i++ {
+		order = append(order, i)
+	}
+	for i := n - 2; i >= 1; i-- {
+		order = append(order, i)
+	}
+	return reorderFrames(wand, order)
+}
@@ -2,10 +2,12 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
-	"os/exec"
-	"strconv"
+	"path/filepath"
+	"runtime"
 	"strings"
 )
 
@@ -17,7 +19,10 @@ func SelectCommandWithFzf(commands []CommandMeta) (string, error) {
 		b.WriteString(fmt.Sprintf("%s: %s\n", c.Name, c.Description))
 	}
 
-	cmd := exec.Command("fzf")
+	cmd, err := externalCommand("fzf", fzfOptions()...)
+	if err != nil {
+		return "", err
+	}
 	cmd.Stdin = strings.NewReader(b.String())
 
 	var out bytes.Buffer
@@ -36,6 +41,37 @@ func SelectCommandWithFzf(commands []CommandMeta) (string, error) {
 	return "", fmt.Errorf("no command selected")
 }
 
+// SelectFontWithFzf displays the given font names in fzf and returns the selected one.
+func SelectFontWithFzf(fonts []string) (string, error) {
+	if len(fonts) == 0 {
+		return "", fmt.Errorf("no fonts available")
+	}
+	return SelectItemWithFzf("font> ", fonts)
+}
+
+// SelectItemWithFzf displays items (one per line) in fzf with the given prompt
+// and returns the selected line.
+func SelectItemWithFzf(prompt string, items []string) (string, error) {
+	cmd, err := externalCommand("fzf", append([]string{"--prompt", prompt}, fzfOptions()...)...)
+	if err != nil {
+		return "", err
+	}
+	cmd.Stdin = strings.NewReader(strings.Join(items, "\n") + "\n")
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error running fzf: %w", err)
+	}
+
+	selection := strings.TrimSpace(out.String())
+	if selection == "" {
+		return "", fmt.Errorf("nothing selected")
+	}
+	return selection, nil
+}
+
 // SelectFileWithFzf launches fzf with a list of common image files found under startDir.
 // It returns the full path of the selected file or an error if selection failed.
 //
@@ -44,11 +80,47 @@ func SelectCommandWithFzf(commands []CommandMeta) (string, error) {
 // reasonable --preview command for fzf. The preview will attempt to use the most
 // capable renderer available for the detected terminal.
 //
-// Note: This implementation shells out to `find` piped into `fzf`. It requires both
-// `find` and `fzf` to be available in PATH. startDir may be "." or any directory path.
+// Note: This implementation requires `fzf` to be available in PATH. startDir may
+// be "." or any directory path.
 func SelectFileWithFzf(startDir string) (string, error) {
-	// Quote the directory to safely handle spaces/special chars.
-	quotedDir := strconv.Quote(startDir)
+	files, err := runFileFzf(startDir, false)
+	if err != nil {
+		return "", err
+	}
+	return files[0], nil
+}
+
+// SelectFilesWithFzf is the multi-selection variant of SelectFileWithFzf: fzf
+// runs with --multi so several files can be marked with Tab. The selected
+// paths are returned in the order fzf prints them.
+func SelectFilesWithFzf(startDir string) ([]string, error) {
+	return runFileFzf(startDir, true)
+}
+
+// runFileFzf lists the image files under startDir, lets the user pick from
+// them in fzf and returns the non-empty selected lines. The directory is
+// walked here rather than with find so the selector works the same on Windows.
+func runFileFzf(startDir string, multi bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(startDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable directories instead of giving up on the whole tree.
+			if d != nil && d.IsDir() && path != startDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == originalsDir {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && isImageFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list files in %s: %w", startDir, err)
+	}
 
 	// Build a terminal-aware preview command for fzf. The preview command uses
 	// fzf's {} replacement for the current file path. We prefer inline/kitty/sixel
@@ -57,39 +129,55 @@ func SelectFileWithFzf(startDir string) (string, error) {
 	//
 	// The preview command tries multiple renderers in order, using `||` to fall
 	// back if the preferred renderer is not available. Errors are redirected to
-	// /dev/null to avoid cluttering the preview pane.
-	//
-	// Note: fzf's --preview option does not support complex shell constructs like
-	// conditionals or functions, so we must use a single command line with `||`
-	// chains to achieve fallback behavior.
+	// the null device to avoid cluttering the preview pane. fzf runs the command
+	// with $SHELL, or cmd.exe on Windows; both understand `||` and `2>`.
 	//
 	// We also include a control sequence to clear kitty images before rendering
 	// a new image, to avoid accumulating images in the terminal buffer.
+	chafa := "chafa --fill=block --symbols=block -s 80x40 {} 2>" + os.DevNull
 	var previewCmd string
 
 	// Helper chains: try best renderer, then fall back to others or textual viewers.
 	if isKitty() {
 		// Prefer kitty icat. If unavailable, try chafa.
-		previewCmd = "printf \"\\x1b_Ga=d\\x1b\\\\\"; kitty +kitten icat --silent {} 2>/dev/null || chafa --fill=block --symbols=block -s 80x40 {} 2>/dev/null"
+		previewCmd = "printf \"\\x1b_Ga=d\\x1b\\\\\"; kitty +kitten icat --silent {} 2>" + os.DevNull + " || " + chafa
 	} else if isInlineImageCapable() {
 		// Prefer imgcat (iTerm2 integration). If not present, try chafa.
-		previewCmd = "imgcat {} 2>/dev/null  || chafa --fill=block --symbols=block -s 80x40 {} 2>/dev/null"
+		previewCmd = "imgcat {} 2>" + os.DevNull + " || " + chafa
 	} else if isSixelCapable() {
 		// Prefer sixel renderers. If img2sixel not present, try chafa.
-		previewCmd = "img2sixel {} 2>/dev/null || chafa --fill=block --symbols=block -s 80x40 {} 2>/dev/null"
+		previewCmd = "img2sixel {} 2>" + os.DevNull + " || " + chafa
 	} else {
 		// No detected image-capable terminal: use pixel renderer if present, else textual preview.
-		previewCmd = "chafa --fill=block --symbols=block -s 80x40 {} 2>/dev/null"
+		previewCmd = chafa
+	}
+
+	// Render a cached thumbnail instead of the full file where possible (see
+	// thumbcache.go), falling back to the file itself. The command needs a
+	// POSIX shell, so fzf is told to use sh rather than the user's $SHELL.
+	exe, exeErr := os.Executable()
+	cached := exeErr == nil && runtime.GOOS != "windows"
+	if cached {
+		previewCmd = "f=$(" + shellQuote(exe) + " thumbnail {} 2>" + os.DevNull + ") || f={}; " +
+			strings.ReplaceAll(previewCmd, "{}", `"$f"`)
 	}
 
-	// Build the find + fzf command. Escape percent signs in the format string.
 	// Use --preview-window to allocate space on the right for the preview.
-	cmdStr := fmt.Sprintf(
-		"find %s -type f \\( -iname '*.jpg' -o -iname '*.jpeg' -o -iname '*.png' -o -iname '*.gif' -o -iname '*.tif' -o -iname '*.tiff' \\) | fzf --height 100%% --border --prompt='Files> ' --ansi --preview=%q --preview-window='right:60%%'",
-		quotedDir,
-		previewCmd,
-	)
-	cmd := exec.Command("bash", "-lc", cmdStr)
+	args := []string{"--height", "100%", "--border", "--prompt", "Files> ", "--ansi", "--preview", previewCmd, "--preview-window", "right:60%"}
+	if multi {
+		args = append(args, "--multi")
+	}
+	cmd, err := externalCommand("fzf", append(args, fzfOptions()...)...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = strings.NewReader(strings.Join(files, "\n") + "\n")
+	if cached {
+		cmd.Env = append(os.Environ(), "SHELL=/bin/sh")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go warmThumbnails(ctx, files)
+	}
 
 	var out bytes.Buffer
 	cmd.Stdout = &out
@@ -97,15 +185,20 @@ func SelectFileWithFzf(startDir string) (string, error) {
 	if err := cmd.Run(); err != nil {
 		// attempt to clear kitty images regardless of error
 		clearKittyImages()
-		return "", fmt.Errorf("error running fzf for files: %w", err)
+		return nil, fmt.Errorf("error running fzf for files: %w", err)
 	}
 
 	// clear preview images left behind by the previewer (kitty graphics)
 	clearKittyImages()
 
-	selection := strings.TrimSpace(out.String())
-	if selection == "" {
-		return "", fmt.Errorf("no file selected")
+	var selection []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			selection = append(selection, line)
+		}
+	}
+	if len(selection) == 0 {
+		return nil, fmt.Errorf("no file selected")
 	}
 	return selection, nil
 }
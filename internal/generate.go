@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Image generators: commands that build a new image from scratch rather than
+// editing the current one (textures, fixtures, calibration charts).
+
+// noisePatterns are the values of generateNoise's pattern parameter, in
+// EnumOptions order (the parameter normalizes to the option index).
+var noisePatterns = []string{"NOISE", "PLASMA", "GRADIENT", "RADIAL_GRADIENT"}
+
+// GenerateNoise creates a width x height image filled with the given pattern:
+//
+//   - NOISE: per-pixel noise of the given distribution over color1
+//   - PLASMA: fractal plasma clouds, seeded from color1-color2 when both are set
+//   - GRADIENT: vertical linear gradient from color1 (top) to color2 (bottom)
+//   - RADIAL_GRADIENT: circular gradient from color1 (center) to color2 (edge)
+func GenerateNoise(pattern string, width, height uint, color1, color2 string, noise imagick.NoiseType) (*imagick.MagickWand, error) {
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("width and height must be positive")
+	}
+	wand := imagick.NewMagickWand()
+	if err := wand.SetSize(width, height); err != nil {
+		wand.Destroy()
+		return nil, fmt.Errorf("failed to set size: %w", err)
+	}
+
+	colors := func(defaultFrom, defaultTo string) string {
+		from, to := color1, color2
+		if from == "" {
+			from = defaultFrom
+		}
+		if to == "" {
+			to = defaultTo
+		}
+		return from + "-" + to
+	}
+
+	var err error
+	switch pattern {
+	case "NOISE":
+		base := color1
+		if base == "" {
+			base = "gray50"
+		}
+		if err = wand.ReadImage("xc:" + base); err == nil {
+			err = wand.AddNoiseImage(noise, 1)
+		}
+	case "PLASMA":
+		if color1 == "" && color2 == "" {
+			err = wand.ReadImage("plasma:fractal")
+		} else {
+			err = wand.ReadImage("plasma:" + colors("white", "black"))
+		}
+	case "GRADIENT":
+		err = wand.ReadImage("gradient:" + colors("white", "black"))
+	case "RADIAL_GRADIENT":
+		err = wand.ReadImage("radial-gradient:" + colors("white", "black"))
+	default:
+		err = fmt.Errorf("unknown pattern %q", pattern)
+	}
+	if err != nil {
+		wand.Destroy()
+		return nil, fmt.Errorf("generate %s: %w", pattern, err)
+	}
+	if err := wand.SetImageFormat("PNG"); err != nil {
+		wand.Destroy()
+		return nil, fmt.Errorf("failed to set format: %w", err)
+	}
+	wand.ResetIterator()
+	return wand, nil
+}
+
+// testCharts are the values of testChart's chart parameter, in EnumOptions order.
+var testCharts = []string{"COLOR_BARS", "GRAY_RAMP", "RESOLUTION"}
+
+// GenerateTestChart draws a width x height calibration image:
+//
+//   - COLOR_BARS: full-intensity white, yellow, cyan, green, magenta, red,
+//     blue and black bars above a black-to-white ramp
+//   - GRAY_RAMP: a continuous black-to-white ramp above an 11-step (0-100%) wedge
+//   - RESOLUTION: groups of alternating black/white lines 1 to 8 px wide,
+//     vertical in the top half and horizontal in the bottom half
+//
+// Comparing the chart with its preview shows how faithfully a terminal
+// renderer, display or printer reproduces color, tone and fine detail.
+func GenerateTestChart(chart string, width, height uint) (*imagick.MagickWand, error) {
+	if width < 16 || height < 16 {
+		return nil, fmt.Errorf("charts need at least 16x16 pixels")
+	}
+	wand := imagick.NewMagickWand()
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	bg.SetColor("white")
+	if err := wand.NewImage(width, height, bg); err != nil {
+		wand.Destroy()
+		return nil, fmt.Errorf("failed to create canvas: %w", err)
+	}
+
+	dw := imagick.NewDrawingWand()
+	defer dw.Destroy()
+	fill := imagick.NewPixelWand()
+	defer fill.Destroy()
+	rect := func(color string, x1, y1, x2, y2 float64) {
+		fill.SetColor(color)
+		dw.SetFillColor(fill)
+		dw.Rectangle(x1, y1, x2, y2)
+	}
+	gray := func(level float64) string {
+		v := int(level*255 + 0.5)
+		return fmt.Sprintf("rgb(%d,%d,%d)", v, v, v)
+	}
+	// ramp draws a continuous horizontal black-to-white ramp, one column per pixel.
+	ramp := func(y1, y2 float64) {
+		for x := uint(0); x < width; x++ {
+			rect(gray(float64(x)/float64(width-1)), float64(x), y1, float64(x), y2)
+		}
+	}
+	w, h := float64(width), float64(height)
+
+	switch chart {
+	case "COLOR_BARS":
+		bars := []string{"#ffffff", "#ffff00", "#00ffff", "#00ff00", "#ff00ff", "#ff0000", "#0000ff", "#000000"}
+		barBottom := h*2/3 - 1
+		for i, c := range bars {
+			x1 := w * float64(i) / float64(len(bars))
+			x2 := w*float64(i+1)/float64(len(bars)) - 1
+			rect(c, x1, 0, x2, barBottom)
+		}
+		ramp(barBottom+1, h-1)
+	case "GRAY_RAMP":
+		ramp(0, h/2-1)
+		const steps = 11
+		for i := 0; i < steps; i++ {
+			x1 := w * float64(i) / steps
+			x2 := w*float64(i+1)/steps - 1
+			rect(gray(float64(i)/(steps-1)), x1, h/2, x2, h-1)
+		}
+	case "RESOLUTION":
+		lineWidths := []float64{1, 2, 3, 4, 6, 8}
+		groupW := w / float64(len(lineWidths))
+		for i, lw := range lineWidths {
+			x0 := groupW * float64(i)
+			for x := x0; x+lw <= x0+groupW; x += 2 * lw {
+				rect("#000000", x, 0, x+lw-1, h/2-1)
+			}
+		}
+		groupH := (h / 2) / float64(len(lineWidths))
+		for i, lw := range lineWidths {
+			y0 := h/2 + groupH*float64(i)
+			for y := y0; y+lw <= y0+groupH; y += 2 * lw {
+				rect("#000000", 0, y, w-1, y+lw-1)
+			}
+		}
+	default:
+		wand.Destroy()
+		return nil, fmt.Errorf("unknown chart %q", chart)
+	}
+
+	if err := wand.DrawImage(dw); err != nil {
+		wand.Destroy()
+		return nil, fmt.Errorf("failed to draw chart: %w", err)
+	}
+	if err := wand.SetImageFormat("PNG"); err != nil {
+		wand.Destroy()
+		return nil, fmt.Errorf("failed to set format: %w", err)
+	}
+	wand.ResetIterator()
+	return wand, nil
+}
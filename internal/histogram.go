@@ -9,6 +9,9 @@ import (
 	"image/png"
 	"math"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
@@ -99,53 +102,6 @@ func previewHistogramFromWand(wand *imagick.MagickWand, bins int) error {
 	hG := hist256(gVals)
 	hB := hist256(bVals)
 
-	// Compute equalization map for a 256-level channel histogram
-	equalizeMap := func(h []int) [256]uint8 {
-		total := 0
-		for _, c := range h {
-			total += c
-		}
-		var cmap [256]uint8
-		if total == 0 {
-			for i := 0; i < 256; i++ {
-				cmap[i] = uint8(i)
-			}
-			return cmap
-		}
-		// CDF
-		cdf := make([]int, 256)
-		cdf[0] = h[0]
-		for i := 1; i < 256; i++ {
-			cdf[i] = cdf[i-1] + h[i]
-		}
-		// Find cdf_min (first non-zero)
-		cdfMin := 0
-		for i := 0; i < 256; i++ {
-			if cdf[i] != 0 {
-				cdfMin = cdf[i]
-				break
-			}
-		}
-		den := float64(total - cdfMin)
-		if den <= 0 {
-			// degenerate: map to identity
-			for i := 0; i < 256; i++ {
-				cmap[i] = uint8(i)
-			}
-			return cmap
-		}
-		for i := 0; i < 256; i++ {
-			val := float64(cdf[i]-cdfMin) / den
-			if val < 0 {
-				val = 0
-			} else if val > 1 {
-				val = 1
-			}
-			cmap[i] = uint8(math.Round(val * 255.0))
-		}
-		return cmap
-	}
-
 	mapR := equalizeMap(hR)
 	mapG := equalizeMap(hG)
 	mapB := equalizeMap(hB)
@@ -177,7 +133,7 @@ func previewHistogramFromWand(wand *imagick.MagickWand, bins int) error {
 	hBEq := histBins(eqB, bins)
 
 	// Render PNG via helper
-	pngBytes, err := createHistogramPNG(bins, hREq, hGEq, hBEq)
+	pngBytes, err := createHistogramPNG(bins, hREq, hGEq, hBEq, currentHistogramStyle())
 	if err != nil {
 		return err
 	}
@@ -190,8 +146,7 @@ func previewHistogramFromWand(wand *imagick.MagickWand, bins int) error {
 	defer outWand.Destroy()
 	if err := outWand.ReadImageBlob(pngBytes); err != nil {
 		// As a fallback, write PNG to temp file so user can inspect it.
-		tmp := os.TempDir() + "/termagick_histogram.png"
-		if writeErr := os.WriteFile(tmp, pngBytes, 0644); writeErr == nil {
+		if tmp, writeErr := writeHistogramFile(pngBytes); writeErr == nil {
 			return fmt.Errorf("failed to create magick image: %v (wrote PNG to %s)", err, tmp)
 		} else {
 			return fmt.Errorf("failed to create magick image: %v (also failed to write temp PNG: %v)", err, writeErr)
@@ -200,8 +155,7 @@ func previewHistogramFromWand(wand *imagick.MagickWand, bins int) error {
 
 	// Try preview. If preview fails, write temp PNG and inform user.
 	if err := PreviewWand(outWand); err != nil {
-		tmp := os.TempDir() + "/termagick_histogram.png"
-		writeErr := os.WriteFile(tmp, pngBytes, 0644)
+		tmp, writeErr := writeHistogramFile(pngBytes)
 		if writeErr == nil {
 			fmt.Fprintf(os.Stderr, "Histogram written to %s (preview not supported or failed: %v)\n", tmp, err)
 			return nil
@@ -211,12 +165,206 @@ func previewHistogramFromWand(wand *imagick.MagickWand, bins int) error {
 	return nil
 }
 
+// equalizeMap returns the histogram equalization map of a channel from its
+// 256-level histogram: each level goes to its place in the cumulative
+// distribution, stretched so the darkest level present maps to 0.
+func equalizeMap(h []int) [256]uint8 {
+	total := 0
+	for _, c := range h {
+		total += c
+	}
+	var cmap [256]uint8
+	if total == 0 {
+		for i := 0; i < 256; i++ {
+			cmap[i] = uint8(i)
+		}
+		return cmap
+	}
+	// CDF
+	cdf := make([]int, 256)
+	cdf[0] = h[0]
+	for i := 1; i < 256; i++ {
+		cdf[i] = cdf[i-1] + h[i]
+	}
+	// Find cdf_min (first non-zero)
+	cdfMin := 0
+	for i := 0; i < 256; i++ {
+		if cdf[i] != 0 {
+			cdfMin = cdf[i]
+			break
+		}
+	}
+	den := float64(total - cdfMin)
+	if den <= 0 {
+		// degenerate: map to identity
+		for i := 0; i < 256; i++ {
+			cmap[i] = uint8(i)
+		}
+		return cmap
+	}
+	for i := 0; i < 256; i++ {
+		val := float64(cdf[i]-cdfMin) / den
+		if val < 0 {
+			val = 0
+		} else if val > 1 {
+			val = 1
+		}
+		cmap[i] = uint8(math.Round(val * 255.0))
+	}
+	return cmap
+}
+
+// EqualizeRGB equalizes the histogram of each RGB channel of the current image
+// of wand separately, with the maps the histogram preview uses. Unlike
+// EqualizeImage, which applies one map to all channels, it also evens out the
+// balance between channels, so it can neutralize a color cast (or introduce
+// one in images dominated by a single color). Fully transparent pixels do not
+// count towards the histograms, and alpha is left as it is.
+func EqualizeRGB(wand *imagick.MagickWand) error {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if w == 0 || h == 0 {
+		return fmt.Errorf("image has zero dimensions")
+	}
+	raw, err := wand.ExportImagePixels(0, 0, w, h, "RGBA", imagick.PIXEL_DOUBLE)
+	if err != nil {
+		return fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	rgba, ok := raw.([]float64)
+	if !ok {
+		return fmt.Errorf("unsupported pixel data type: %T", raw)
+	}
+	level := func(v float64) int {
+		return max(0, min(255, int(math.Round(v*255))))
+	}
+
+	hists := [3][]int{make([]int, 256), make([]int, 256), make([]int, 256)}
+	for i := 0; i+3 < len(rgba); i += 4 {
+		if rgba[i+3] == 0 {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			hists[c][level(rgba[i+c])]++
+		}
+	}
+	var maps [3][256]uint8
+	for c := range maps {
+		maps[c] = equalizeMap(hists[c])
+	}
+
+	// Map between the 8-bit levels linearly so deeper images keep their
+	// in-between values.
+	rgb := make([]float64, len(rgba)/4*3)
+	for i, j := 0, 0; i+3 < len(rgba); i, j = i+4, j+3 {
+		for c := 0; c < 3; c++ {
+			v := math.Max(0, math.Min(1, rgba[i+c])) * 255
+			lo := min(int(v), 254)
+			t := v - float64(lo)
+			rgb[j+c] = (float64(maps[c][lo])*(1-t) + float64(maps[c][lo+1])*t) / 255
+		}
+	}
+	if err := wand.ImportImagePixels(0, 0, w, h, "RGB", imagick.PIXEL_DOUBLE, rgb); err != nil {
+		return fmt.Errorf("ImportImagePixels failed: %w", err)
+	}
+	return nil
+}
+
+// writeHistogramFile saves a histogram PNG in the session's temp directory,
+// where it stays until termagick exits, and returns its path.
+func writeHistogramFile(pngBytes []byte) (string, error) {
+	dir, err := sessionTempDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "histogram.png")
+	return path, os.WriteFile(path, pngBytes, 0644)
+}
+
+// histogramStyle is how createHistogramPNG draws: the canvas size, the
+// background and the curves.
+type histogramStyle struct {
+	width, height int
+	dark          bool
+	lineWidth     int
+	filled        bool
+}
+
+// defaultHistogramLineWidth is the curve width when the config sets none.
+const defaultHistogramLineWidth = 2
+
+// currentHistogramStyle returns the style from the config, sized to the
+// terminal.
+func currentHistogramStyle() histogramStyle {
+	w, h := histogramCanvasSize()
+	style := histogramStyle{width: w, height: h, lineWidth: defaultHistogramLineWidth, filled: config.HistogramFilled}
+	if config.HistogramLineWidth > 0 {
+		style.lineWidth = config.HistogramLineWidth
+	}
+	switch config.HistogramTheme {
+	case "dark":
+		style.dark = true
+	case "light":
+		style.dark = false
+	default:
+		style.dark = terminalIsDark()
+	}
+	return style
+}
+
+// histogramCanvasSize returns a canvas size that fits the terminal: most of
+// its width, at the 8:3 aspect of the former fixed 640x240 canvas, and no
+// more than half its height. Without a terminal it is 640x240.
+func histogramCanvasSize() (int, int) {
+	cols, rows, pw, ph, err := terminalSize()
+	if err != nil || cols <= 0 || rows <= 0 {
+		return 640, 240
+	}
+	// Pixels per cell: from the window size when the terminal reports it,
+	// else by asking, else a common 8x16.
+	cellW, cellH := 8, 16
+	if pw > 0 && ph > 0 {
+		cellW, cellH = pw/cols, ph/rows
+	} else if cw, ch, err := cellSize(); err == nil {
+		cellW, cellH = cw, ch
+	}
+	w := min(max((cols-2)*cellW, 240), 1280)
+	h := min(max(w*3/8, 90), max(rows*cellH/2, 90))
+	return w, h
+}
+
+// terminalIsDark asks the terminal for its background color and reports
+// whether it is dark. Terminals that do not answer are assumed to be dark,
+// as most are.
+func terminalIsDark() bool {
+	reply, err := queryTerminal("\x1b]11;?\x07", 0x07)
+	if err != nil {
+		debugf("background color: %v", err)
+		return true
+	}
+	// The reply is rgb:R/G/B with 1-4 hex digits per component.
+	_, spec, _ := strings.Cut(strings.TrimSuffix(reply, "\x07"), "rgb:")
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 {
+		debugf("background color: unexpected reply %q", reply)
+		return true
+	}
+	var rgb [3]float64
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil || len(p) == 0 || len(p) > 4 {
+			debugf("background color: unexpected reply %q", reply)
+			return true
+		}
+		rgb[i] = float64(v) / float64(uint64(1)<<(4*len(p))-1)
+	}
+	return 0.2126*rgb[0]+0.7152*rgb[1]+0.0722*rgb[2] < 0.5
+}
+
 // createHistogramPNG renders histogram curves (R, G, B) into a PNG and returns the bytes.
 // It accepts the number of bins and per-channel counts.
-func createHistogramPNG(bins int, hREq, hGEq, hBEq []int) ([]byte, error) {
+func createHistogramPNG(bins int, hREq, hGEq, hBEq []int, style histogramStyle) ([]byte, error) {
 	// Prepare PNG canvas
-	imgW := int(math.Max(640, float64(bins*3))) // ensure reasonably visible width
-	imgH := 240
+	imgW := style.width
+	imgH := style.height
 	left := 30
 	right := 20
 	top := 10
@@ -224,9 +372,12 @@ func createHistogramPNG(bins int, hREq, hGEq, hBEq []int) ([]byte, error) {
 	plotW := imgW - left - right
 	plotH := imgH - top - bottom
 
+	background, axisColor := color.RGBA{255, 255, 255, 255}, color.RGBA{0, 0, 0, 255}
+	if style.dark {
+		background, axisColor = color.RGBA{24, 24, 24, 255}, color.RGBA{160, 160, 160, 255}
+	}
 	canvas := image.NewRGBA(image.Rect(0, 0, imgW, imgH))
-	// white background
-	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
 
 	// find global max for scaling
 	maxCount := 1
@@ -239,8 +390,9 @@ func createHistogramPNG(bins int, hREq, hGEq, hBEq []int) ([]byte, error) {
 		}
 	}
 
-	// draw line using simple Bresenham algorithm
-	drawLine := func(img *image.RGBA, x0, y0, x1, y1 int, col color.RGBA) {
+	// draw line using simple Bresenham algorithm, with a square pen of the
+	// given width
+	drawLine := func(img *image.RGBA, x0, y0, x1, y1, width int, col color.RGBA) {
 		dx := int(math.Abs(float64(x1 - x0)))
 		dy := int(math.Abs(float64(y1 - y0)))
 		sx := -1
@@ -253,9 +405,8 @@ func createHistogramPNG(bins int, hREq, hGEq, hBEq []int) ([]byte, error) {
 		}
 		errVal := dx - dy
 		for {
-			if x0 >= 0 && x0 < img.Bounds().Dx() && y0 >= 0 && y0 < img.Bounds().Dy() {
-				img.SetRGBA(x0, y0, col)
-			}
+			pen := image.Rect(x0-(width-1)/2, y0-(width-1)/2, x0+width/2+1, y0+width/2+1)
+			draw.Draw(img, pen.Intersect(img.Bounds()), &image.Uniform{C: col}, image.Point{}, draw.Src)
 			if x0 == x1 && y0 == y1 {
 				break
 			}
@@ -271,9 +422,9 @@ func createHistogramPNG(bins int, hREq, hGEq, hBEq []int) ([]byte, error) {
 		}
 	}
 
-	// Function to plot a histogram curve given counts and color
-	plotCurve := func(counts []int, col color.RGBA) {
-		prevX, prevY := -1, -1
+	// points returns the plot coordinates of a histogram curve
+	points := func(counts []int) []image.Point {
+		pts := make([]image.Point, bins)
 		for i := 0; i < bins; i++ {
 			x := left
 			if bins == 1 {
@@ -287,35 +438,79 @@ func createHistogramPNG(bins int, hREq, hGEq, hBEq []int) ([]byte, error) {
 			if y < top {
 				y = top
 			}
-			if prevX >= 0 {
-				drawLine(canvas, prevX, prevY, x, y, col)
+			pts[i] = image.Point{x, y}
+		}
+		return pts
+	}
+
+	// Function to plot a histogram curve given counts and color
+	plotCurve := func(pts []image.Point, col color.RGBA) {
+		for i := 1; i < len(pts); i++ {
+			drawLine(canvas, pts[i-1].X, pts[i-1].Y, pts[i].X, pts[i].Y, style.lineWidth, col)
+		}
+	}
+
+	// fillCurve shades the area under a curve with a translucent col. Each
+	// column is shaded once, up to the highest point of the curve in it, so
+	// several bins per pixel do not darken the shade.
+	fillCurve := func(pts []image.Point, col color.RGBA) {
+		shade := &image.Uniform{C: color.NRGBA{col.R, col.G, col.B, 80}}
+		peak := map[int]int{}
+		mark := func(x, y int) {
+			if p, ok := peak[x]; !ok || y < p {
+				peak[x] = y
+			}
+		}
+		for i, b := range pts {
+			mark(b.X, b.Y)
+			if i == 0 {
+				continue
 			}
-			prevX = x
-			prevY = y
+			a := pts[i-1]
+			for x := a.X + 1; x < b.X; x++ {
+				mark(x, a.Y+(b.Y-a.Y)*(x-a.X)/(b.X-a.X))
+			}
+		}
+		for x, y := range peak {
+			draw.Draw(canvas, image.Rect(x, y, x+1, top+plotH), shade, image.Point{}, draw.Over)
 		}
 	}
 
-	// Plot R, G, B curves (alpha=255)
-	plotCurve(hREq, color.RGBA{255, 64, 64, 255})
-	plotCurve(hGEq, color.RGBA{64, 255, 64, 255})
-	plotCurve(hBEq, color.RGBA{64, 64, 255, 255})
+	channels := []struct {
+		counts []int
+		col    color.RGBA
+	}{
+		{hREq, color.RGBA{255, 64, 64, 255}},
+		{hGEq, color.RGBA{64, 255, 64, 255}},
+		{hBEq, color.RGBA{64, 64, 255, 255}},
+	}
+	curves := make([][]image.Point, len(channels))
+	for i, ch := range channels {
+		curves[i] = points(ch.counts)
+	}
+	// Shade all areas first so no curve is covered by another's fill.
+	if style.filled {
+		for i, ch := range channels {
+			fillCurve(curves[i], ch.col)
+		}
+	}
+	for i, ch := range channels {
+		plotCurve(curves[i], ch.col)
+	}
 
 	// draw simple axes and labels
-	axisColor := color.RGBA{0, 0, 0, 255}
 	// x-axis
-	drawLine(canvas, left, top+plotH, left+plotW-1, top+plotH, axisColor)
+	drawLine(canvas, left, top+plotH, left+plotW-1, top+plotH, 1, axisColor)
 	// y-axis
-	drawLine(canvas, left, top, left, top+plotH, axisColor)
+	drawLine(canvas, left, top, left, top+plotH, 1, axisColor)
 
 	// legend boxes
 	legendY := imgH - bottom + 6
 	boxSize := 10
-	// R
-	draw.Draw(canvas, image.Rect(left, legendY, left+boxSize, legendY+boxSize), &image.Uniform{C: color.RGBA{255, 64, 64, 255}}, image.Point{}, draw.Src)
-	// G
-	draw.Draw(canvas, image.Rect(left+80, legendY, left+80+boxSize, legendY+boxSize), &image.Uniform{C: color.RGBA{64, 255, 64, 255}}, image.Point{}, draw.Src)
-	// B
-	draw.Draw(canvas, image.Rect(left+160, legendY, left+160+boxSize, legendY+boxSize), &image.Uniform{C: color.RGBA{64, 64, 255, 255}}, image.Point{}, draw.Src)
+	for i, ch := range channels {
+		x := left + 80*i
+		draw.Draw(canvas, image.Rect(x, legendY, x+boxSize, legendY+boxSize), &image.Uniform{C: ch.col}, image.Point{}, draw.Src)
+	}
 
 	// Encode to PNG
 	var buf bytes.Buffer
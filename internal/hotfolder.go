@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// hotfolderSettle is how long a file must go without further write events
+// before it is considered complete. Cameras and screenshot tools often write
+// files in several chunks, so processing on the first event would read a
+// truncated image.
+const hotfolderSettle = 750 * time.Millisecond
+
+// RunHotfolder implements `termagick hotfolder <dir> --out <dir> [--recipe file]`.
+// It watches dir for new image files, opens each one, applies the optional
+// recipe and saves the result under the output directory using the same base
+// name. The watcher runs until interrupted with Ctrl-C.
+func RunHotfolder(args []string) error {
+	fs := flag.NewFlagSet("hotfolder", flag.ContinueOnError)
+	outDir := fs.String("out", "", "directory processed images are written to (required)")
+	recipePath := fs.String("recipe", "", "optional recipe file applied to every new image")
+	preview := fs.Bool("preview", true, "preview each processed image in the terminal")
+	addTraceFlag(fs)
+	addNoExecFlag(fs)
+	addQuietFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return withExitCode(exitUsage, fmt.Errorf("usage: termagick hotfolder <dir> --out <dir> [--recipe file]"))
+	}
+	watchDir := positional[0]
+	if *outDir == "" {
+		return withExitCode(exitUsage, fmt.Errorf("hotfolder requires --out <dir>"))
+	}
+
+	absWatch, err := filepath.Abs(watchDir)
+	if err != nil {
+		return fmt.Errorf("resolve watch dir: %w", err)
+	}
+	absOut, err := filepath.Abs(*outDir)
+	if err != nil {
+		return fmt.Errorf("resolve output dir: %w", err)
+	}
+	// Writing into the watched folder would re-trigger the watcher on our own output.
+	if absWatch == absOut {
+		return fmt.Errorf("output directory must differ from the watched directory")
+	}
+	if err := os.MkdirAll(absOut, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+
+	var steps []RecipeStep
+	if *recipePath != "" {
+		steps, err = LoadRecipe(*recipePath)
+		if err != nil {
+			return err
+		}
+	}
+	store := NewMetaStore(Commands)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(absWatch); err != nil {
+		return fmt.Errorf("watch %s: %w", absWatch, err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	fmt.Printf("Watching %s (output: %s, recipe steps: %d). Press Ctrl-C to stop.\n", absWatch, absOut, len(steps))
+
+	// pending maps a path to the time of its most recent write/create event.
+	pending := map[string]time.Time{}
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			fmt.Println("Hotfolder stopped.")
+			return nil
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+				continue
+			}
+			if !isImageFile(ev.Name) {
+				continue
+			}
+			pending[ev.Name] = time.Now()
+
+		case werr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "watch error: %v\n", werr)
+
+		case now := <-ticker.C:
+			for path, last := range pending {
+				if now.Sub(last) < hotfolderSettle {
+					continue
+				}
+				delete(pending, path)
+				outPath := filepath.Join(absOut, filepath.Base(path))
+				if err := processHotfolderFile(store, steps, path, outPath, *preview); err != nil {
+					fmt.Fprintf(os.Stderr, "hotfolder: %s: %v\n", filepath.Base(path), err)
+					continue
+				}
+				fmt.Printf("Processed %s -> %s\n", filepath.Base(path), outPath)
+			}
+		}
+	}
+}
+
+// processHotfolderFile reads inPath, applies the recipe steps and writes the
+// result to outPath, optionally previewing it.
+func processHotfolderFile(store *MetaStore, steps []RecipeStep, inPath, outPath string, preview bool) error {
+	wand, err := LoadImage(inPath)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	defer wand.Destroy()
+	if err := ApplyRecipe(store, wand, steps); err != nil {
+		return err
+	}
+	if err := WriteWand(wand, outPath); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if preview {
+		// Preview is best-effort, as in the interactive loop.
+		_ = PreviewWand(wand)
+	}
+	return nil
+}
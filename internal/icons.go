@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Icon bundles.
+//
+// ExportIcons renders the current image as the usual set of favicon and app
+// icon files in one go: square PNGs from 16 to 1024 pixels, a multi-size
+// favicon.ico and, when iconutil (macOS) or png2icns (libicns) is available,
+// an icon.icns. Non-square images are centered on a transparent square.
+
+// iconSizes are the PNG sizes written, in pixels.
+var iconSizes = []uint{16, 32, 48, 64, 128, 180, 192, 256, 512, 1024}
+
+// icoSizes are the sizes stored in favicon.ico.
+var icoSizes = []uint{16, 32, 48}
+
+// iconAliases are extra names the web expects for some sizes.
+var iconAliases = map[uint]string{
+	180: "apple-touch-icon.png",
+	192: "android-chrome-192x192.png",
+	512: "android-chrome-512x512.png",
+}
+
+// iconsetNames maps the files of a macOS .iconset to the sizes they hold.
+var iconsetNames = map[string]uint{
+	"icon_16x16.png": 16, "icon_16x16@2x.png": 32,
+	"icon_32x32.png": 32, "icon_32x32@2x.png": 64,
+	"icon_128x128.png": 128, "icon_128x128@2x.png": 256,
+	"icon_256x256.png": 256, "icon_256x256@2x.png": 512,
+	"icon_512x512.png": 512, "icon_512x512@2x.png": 1024,
+}
+
+// ExportIcons writes the icon set for the current image of wand to dir and
+// returns the names of the files written.
+func ExportIcons(wand *imagick.MagickWand, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", dir, err)
+	}
+	square, err := squareIcon(wand)
+	if err != nil {
+		return nil, err
+	}
+	defer square.Destroy()
+
+	var written []string
+	write := func(img *imagick.MagickWand, name string) error {
+		if err := img.WriteImage("png32:" + filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		written = append(written, name)
+		return nil
+	}
+	ico := imagick.NewMagickWand()
+	defer ico.Destroy()
+	for _, size := range iconSizes {
+		icon, err := scaledIcon(square, size)
+		if err != nil {
+			return written, err
+		}
+		err = write(icon, fmt.Sprintf("icon-%d.png", size))
+		if err == nil && iconAliases[size] != "" {
+			err = write(icon, iconAliases[size])
+		}
+		if err == nil && slices.Contains(icoSizes, size) {
+			err = ico.AddImage(icon)
+		}
+		icon.Destroy()
+		if err != nil {
+			return written, err
+		}
+	}
+	if err := ico.WriteImages("ico:"+filepath.Join(dir, "favicon.ico"), true); err != nil {
+		return written, fmt.Errorf("failed to write favicon.ico: %w", err)
+	}
+	written = append(written, "favicon.ico")
+
+	if err := writeICNS(square, dir); err != nil {
+		fmt.Fprintf(os.Stderr, "icon.icns skipped: %v\n", err)
+	} else {
+		written = append(written, "icon.icns")
+	}
+	return written, nil
+}
+
+// squareIcon returns the current image of wand centered on a transparent
+// square canvas.
+func squareIcon(wand *imagick.MagickWand) (*imagick.MagickWand, error) {
+	img := wand.GetImage()
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	side := max(w, h)
+	if w == h {
+		return img, nil
+	}
+	none := imagick.NewPixelWand()
+	defer none.Destroy()
+	none.SetColor("none")
+	img.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET)
+	if err := img.SetImageBackgroundColor(none); err != nil {
+		img.Destroy()
+		return nil, fmt.Errorf("failed to set background: %w", err)
+	}
+	if err := img.ExtentImage(side, side, -int(side-w)/2, -int(side-h)/2); err != nil {
+		img.Destroy()
+		return nil, fmt.Errorf("failed to make the icon square: %w", err)
+	}
+	return img, nil
+}
+
+// scaledIcon returns a size x size copy of square.
+func scaledIcon(square *imagick.MagickWand, size uint) (*imagick.MagickWand, error) {
+	icon := square.Clone()
+	if err := icon.ResizeImage(size, size, imagick.FILTER_LANCZOS); err != nil {
+		icon.Destroy()
+		return nil, fmt.Errorf("failed to resize to %d px: %w", size, err)
+	}
+	icon.StripImage()
+	return icon, nil
+}
+
+// writeICNS writes icon.icns with whichever ICNS tool is installed;
+// ImageMagick cannot write the format itself.
+func writeICNS(square *imagick.MagickWand, dir string) error {
+	out := filepath.Join(dir, "icon.icns")
+	switch {
+	case hasProgram("iconutil"):
+		iconset, err := mkdirSessionTemp("*.iconset")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(iconset)
+		for name, size := range iconsetNames {
+			if err := writeScaledIcon(square, size, filepath.Join(iconset, name)); err != nil {
+				return err
+			}
+		}
+		return runIconTool("iconutil", "-c", "icns", "-o", out, iconset)
+	case hasProgram("png2icns"):
+		tmp, err := mkdirSessionTemp("icns-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		args := []string{out}
+		for _, size := range []uint{16, 32, 48, 128, 256, 512, 1024} {
+			path := filepath.Join(tmp, fmt.Sprintf("%d.png", size))
+			if err := writeScaledIcon(square, size, path); err != nil {
+				return err
+			}
+			args = append(args, path)
+		}
+		return runIconTool("png2icns", args...)
+	}
+	return fmt.Errorf("needs iconutil (macOS) or png2icns (install icnsutils or libicns)")
+}
+
+func writeScaledIcon(square *imagick.MagickWand, size uint, path string) error {
+	icon, err := scaledIcon(square, size)
+	if err != nil {
+		return err
+	}
+	defer icon.Destroy()
+	if err := icon.WriteImage("png32:" + path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func runIconTool(name string, args ...string) error {
+	cmd, err := externalCommand(name, args...)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
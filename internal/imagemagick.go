@@ -2,7 +2,9 @@ package internal
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
@@ -64,33 +66,50 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		if err != nil {
 			return fmt.Errorf("invalid height: %w", err)
 		}
-		offset, err := strconv.ParseFloat(args[2], 64)
+		offset, err := parseQuantum(args[2])
 		if err != nil {
 			return fmt.Errorf("invalid offset: %w", err)
 		}
 		return wand.AdaptiveThresholdImage(uint(width), uint(height), offset)
 
 	case "addNoise":
-		if len(args) != 1 {
-			return fmt.Errorf("addNoise requires 1 argument: noiseType")
+		if len(args) < 1 || len(args) > 3 {
+			return fmt.Errorf("addNoise requires 1 to 3 arguments: noiseType [, attenuate, channel]")
 		}
 		noiseType, err := strconv.ParseInt(args[0], 10, 64)
 		if err != nil {
 			return fmt.Errorf("invalid noiseType: %w", err)
 		}
-		return wand.AddNoiseImage(imagick.NoiseType(noiseType), 1)
+		attenuate := 1.0
+		if len(args) > 1 && args[1] != "" {
+			attenuate, err = strconv.ParseFloat(args[1], 64)
+			if err != nil || attenuate < 0 {
+				return fmt.Errorf("invalid attenuate %q", args[1])
+			}
+		}
+		if len(args) > 2 && args[2] != "" && args[2] != "0" {
+			idx, err := strconv.Atoi(args[2])
+			if err != nil || idx < 0 || idx >= len(noiseChannels) {
+				return fmt.Errorf("invalid channel %q", args[2])
+			}
+			masks := []imagick.ChannelType{imagick.CHANNEL_RED, imagick.CHANNEL_GREEN, imagick.CHANNEL_BLUE}
+			prev := wand.SetImageChannelMask(masks[idx-1])
+			defer wand.SetImageChannelMask(prev)
+		}
+		return wand.AddNoiseImage(imagick.NoiseType(noiseType), attenuate)
 
 	case "annotate":
-		// annotate supports two forms:
+		// annotate supports three forms:
 		// 5 args: text, size, x, y, color
 		// 6 args: text, font, size, x, y, color
-		if !(len(args) == 5 || len(args) == 6) {
-			return fmt.Errorf("annotate requires 5 or 6 arguments: text, [font], size, x, y, color")
+		// 7 args: text, font, size, x, y, color, markup
+		if len(args) < 5 || len(args) > 7 {
+			return fmt.Errorf("annotate requires 5 to 7 arguments: text, [font], size, x, y, color, [markup]")
 		}
 		text := args[0]
 		font := ""
 		sizeIdx := 1
-		if len(args) == 6 {
+		if len(args) >= 6 {
 			font = args[1]
 			sizeIdx = 2
 		}
@@ -107,19 +126,10 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 			return fmt.Errorf("invalid y: %w", err)
 		}
 		color := args[sizeIdx+3]
+		markup := len(args) == 7 && args[6] == "true"
 
-		dw := imagick.NewDrawingWand()
-		defer dw.Destroy()
-		if font != "" {
-			dw.SetFont(font)
-		}
-		dw.SetFontSize(size)
-		fill := imagick.NewPixelWand()
-		defer fill.Destroy()
-		fill.SetColor(color)
-		dw.SetFillColor(fill)
-
-		return wand.AnnotateImage(dw, xFloat, yFloat, 0.0, text)
+		// DrawText checks glyph coverage and falls back to Pango or another font for emoji/CJK.
+		return DrawText(wand, text, font, size, xFloat, yFloat, color, markup)
 
 	case "autoGamma":
 		return wand.AutoGammaImage()
@@ -130,6 +140,14 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 	case "autoOrient":
 		return wand.AutoOrientImage()
 
+	case "avgColor":
+		hex, css, err := AverageColor(wand)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Average color: %s\n%s\n", hex, css)
+		return nil
+
 	case "blackThreshold":
 		if len(args) != 1 {
 			return fmt.Errorf("blackThreshold requires 1 argument: threshold")
@@ -163,6 +181,22 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.BlurImage(radius, sigma)
 
+	case "border":
+		if len(args) != 3 {
+			return fmt.Errorf("border requires 3 arguments: color, width, height")
+		}
+		width, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height := width
+		if args[2] != "" {
+			if height, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+				return fmt.Errorf("invalid height: %w", err)
+			}
+		}
+		return AddBorder(wand, args[0], uint(width), uint(height))
+
 	case "charcoal":
 		if len(args) != 2 {
 			return fmt.Errorf("charcoal requires 2 arguments: radius and sigma")
@@ -177,6 +211,59 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.CharcoalImage(radius, sigma)
 
+	case "chop":
+		if len(args) != 4 {
+			return fmt.Errorf("chop requires 4 arguments: width, height, x, y")
+		}
+		width, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height: %w", err)
+		}
+		x, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := strconv.ParseInt(args[3], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid y: %w", err)
+		}
+		if width == 0 && height == 0 {
+			return fmt.Errorf("chop needs a width or a height")
+		}
+		if err := wand.ChopImage(uint(width), uint(height), int(x), int(y)); err != nil {
+			return err
+		}
+		return wand.SetImagePage(wand.GetImageWidth(), wand.GetImageHeight(), 0, 0)
+
+	case "clahe":
+		if len(args) != 4 {
+			return fmt.Errorf("clahe requires 4 arguments: tileWidth, tileHeight, bins, clipLimit")
+		}
+		tw, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid tileWidth: %w", err)
+		}
+		th, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid tileHeight: %w", err)
+		}
+		bins, clipLimit := 128.0, 3.0
+		if args[2] != "" {
+			if bins, err = strconv.ParseFloat(args[2], 64); err != nil {
+				return fmt.Errorf("invalid bins: %w", err)
+			}
+		}
+		if args[3] != "" {
+			if clipLimit, err = strconv.ParseFloat(args[3], 64); err != nil {
+				return fmt.Errorf("invalid clipLimit: %w", err)
+			}
+		}
+		return claheImage(wand, uint(tw), uint(th), bins, clipLimit)
+
 	case "colorize":
 		// colorize requires 2 args: color and opacity (0.0 - 1.0)
 		if len(args) != 2 {
@@ -206,9 +293,23 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 
 		return wand.ColorizeImage(colorPixel, opacityPixel)
 
+	case "colors":
+		stats, err := analyzeColors(wand)
+		if err != nil {
+			return err
+		}
+		printColorStats(stats)
+		return nil
+
+	case "compare":
+		if len(args) != 2 {
+			return fmt.Errorf("compare requires 2 arguments: otherPath, showDiff")
+		}
+		return CompareWith(wand, args[0], args[1] == "true")
+
 	case "composite":
-		if len(args) != 4 {
-			return fmt.Errorf("composite requires 4 arguments: sourceImagePath, composeOperator, x, y")
+		if len(args) < 4 || len(args) > 5 {
+			return fmt.Errorf("composite requires 4 or 5 arguments: sourceImagePath, composeOperator, x, y [, clipToSelf]")
 		}
 		sourceWand := imagick.NewMagickWand()
 		defer sourceWand.Destroy()
@@ -228,7 +329,8 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		if err != nil {
 			return fmt.Errorf("invalid y: %w", err)
 		}
-		return wand.CompositeImage(sourceWand, imagick.CompositeOperator(compose), true, int(x), int(y))
+		clipToSelf := len(args) < 5 || args[4] != "false"
+		return wand.CompositeImage(sourceWand, imagick.CompositeOperator(compose), clipToSelf, int(x), int(y))
 
 	case "compress":
 		// compress requires 2 args: type, quality
@@ -286,6 +388,24 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.ContrastStretchImage(low, high)
 
+	case "convolve":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("convolve requires 1 or 2 arguments: kernel [, normalize]")
+		}
+		spec, err := kernelSpec(args[0])
+		if err != nil {
+			return err
+		}
+		kernel, err := imagick.NewKernelInfo(spec)
+		if err != nil {
+			return fmt.Errorf("invalid kernel: %w", err)
+		}
+		defer kernel.Destroy()
+		if len(args) == 2 && args[1] == "true" {
+			kernel.Scale(1, imagick.KERNEL_NORMALIZE_VALUE)
+		}
+		return wand.ConvolveImage(kernel)
+
 	case "crop":
 		// crop requires width, height, x, y
 		if len(args) != 4 {
@@ -309,12 +429,109 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.CropImage(uint(width), uint(height), int(x), int(y))
 
+	case "cropAspect":
+		if len(args) != 3 {
+			return fmt.Errorf("cropAspect requires 3 arguments: ratio, orientation, gravity")
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(aspectRatios) {
+			return fmt.Errorf("invalid ratio %q", args[0])
+		}
+		ratio := aspectRatios[idx]
+		orientation := "AUTO"
+		if args[1] != "" {
+			idx, err := strconv.Atoi(args[1])
+			if err != nil || idx < 0 || idx >= len(aspectOrientations) {
+				return fmt.Errorf("invalid orientation %q", args[1])
+			}
+			orientation = aspectOrientations[idx]
+		}
+		gravity, err := optionalGravity(args[2])
+		if err != nil {
+			return err
+		}
+		return CropToAspect(wand, ratio, orientation, gravity)
+
+	case "curves":
+		if len(args) != 2 {
+			return fmt.Errorf("curves requires 2 arguments: points, channel")
+		}
+		pts, err := parseCurvePoints(args[0])
+		if err != nil {
+			return err
+		}
+		channel := curveChannels[0]
+		if args[1] != "" {
+			idx, err := strconv.Atoi(args[1])
+			if err != nil || idx < 0 || idx >= len(curveChannels) {
+				return fmt.Errorf("invalid channel: %s", args[1])
+			}
+			channel = curveChannels[idx]
+		}
+		return ApplyCurves(wand, pts, channel)
+
+	case "deepzoom":
+		if len(args) < 1 || len(args) > 4 {
+			return fmt.Errorf("deepzoom requires 1 to 4 arguments: output [, tileSize, overlap, format]")
+		}
+		tileSize, overlap, format := uint64(254), uint64(1), deepZoomFormats[0]
+		var err error
+		if len(args) > 1 && args[1] != "" {
+			if tileSize, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+				return fmt.Errorf("invalid tileSize: %w", err)
+			}
+		}
+		if len(args) > 2 && args[2] != "" {
+			if overlap, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+				return fmt.Errorf("invalid overlap: %w", err)
+			}
+		}
+		if len(args) > 3 && args[3] != "" {
+			idx, err := strconv.Atoi(args[3])
+			if err != nil || idx < 0 || idx >= len(deepZoomFormats) {
+				return fmt.Errorf("invalid format %q", args[3])
+			}
+			format = deepZoomFormats[idx]
+		}
+		path, levels, tiles, err := ExportDeepZoom(wand, args[0], uint(tileSize), uint(overlap), format)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s: %d levels, %d tiles\n", path, levels, tiles)
+		fmt.Printf("OpenSeadragon: OpenSeadragon({id: \"viewer\", tileSources: \"%s\"})\n", filepath.ToSlash(filepath.Base(path)))
+		return nil
+
+	case "descreen":
+		if len(args) != 4 {
+			return fmt.Errorf("descreen requires 4 arguments: lpi, dpi, method, sharpen")
+		}
+		lpi, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid lpi: %w", err)
+		}
+		dpi := 0.0
+		if args[1] != "" {
+			if dpi, err = strconv.ParseFloat(args[1], 64); err != nil {
+				return fmt.Errorf("invalid dpi: %w", err)
+			}
+		}
+		method := 0
+		if args[2] != "" {
+			idx, err := strconv.Atoi(args[2])
+			if err != nil || idx < 0 || idx >= len(descreenMethods) {
+				return fmt.Errorf("invalid method: %s", args[2])
+			}
+			method = idx
+		}
+		sharpen := args[3] != "false"
+		return Descreen(wand, lpi, dpi, descreenMethods[method], sharpen)
+
 	case "deskew":
 		// deskew requires 1 arg: threshold
 		if len(args) != 1 {
 			return fmt.Errorf("deskew requires 1 argument: threshold")
 		}
-		threshold, err := strconv.ParseFloat(args[0], 64)
+		threshold, err := parseQuantum(args[0])
 		if err != nil {
 			return fmt.Errorf("invalid threshold: %w", err)
 		}
@@ -327,6 +544,32 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.DespeckleImage()
 
+	case "distort":
+		// distort requires 2 or 3 args: distortMethod, points [, bestfit]
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("distort requires 2 or 3 arguments: distortMethod, points [, bestfit]")
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if _, ok := distortMethodValueToName[id]; err != nil || !ok {
+			return fmt.Errorf("invalid distortMethod %q", args[0])
+		}
+		var points []float64
+		for _, f := range strings.FieldsFunc(args[1], func(r rune) bool { return r == ' ' || r == ',' }) {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return fmt.Errorf("invalid point value %q", f)
+			}
+			points = append(points, v)
+		}
+		if len(points) == 0 {
+			return fmt.Errorf("distort needs at least one point value")
+		}
+		bestfit := len(args) == 3 && args[2] == "true"
+		if err := wand.DistortImage(imagick.DistortImageMethod(id), points, bestfit); err != nil {
+			return fmt.Errorf("distort %s: %w", distortMethodValueToName[id], err)
+		}
+		return nil
+
 	case "edge":
 		if len(args) != 1 {
 			return fmt.Errorf("edge requires 1 argument: radius")
@@ -337,6 +580,30 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.EdgeImage(radius)
 
+	case "eink":
+		if len(args) < 3 {
+			return fmt.Errorf("eink requires at least 3 arguments: output, width, height")
+		}
+		width, err := strconv.ParseUint(args[1], 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height, err := strconv.ParseUint(args[2], 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid height: %w", err)
+		}
+		gamma := 0.0
+		if len(args) > 3 && args[3] != "" {
+			if gamma, err = strconv.ParseFloat(args[3], 64); err != nil {
+				return fmt.Errorf("invalid gamma: %w", err)
+			}
+		}
+		if err := ExportEink(wand, args[0], uint(width), uint(height), gamma); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %dx%d 1-bit image to %s\n", width, height, args[0])
+		return nil
+
 	case "emboss":
 		if len(args) != 2 {
 			return fmt.Errorf("emboss requires 2 arguments: radius and sigma")
@@ -354,9 +621,63 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 	case "equalize":
 		return wand.EqualizeImage()
 
+	case "equalizeRGB":
+		return EqualizeRGB(wand)
+
 	case "enhance":
 		return wand.EnhanceImage()
 
+	case "extent":
+		if len(args) != 4 {
+			return fmt.Errorf("extent requires 4 arguments: width, height, gravity, background")
+		}
+		width, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height: %w", err)
+		}
+		gravity, err := optionalGravity(args[2])
+		if err != nil {
+			return err
+		}
+		background := args[3]
+		if background == "" {
+			background = "white"
+		}
+		return ExtendCanvas(wand, uint(width), uint(height), gravity, background)
+
+	case "fftNotch":
+		if len(args) != 2 {
+			return fmt.Errorf("fftNotch requires 2 arguments: notches, radius")
+		}
+		auto := strings.EqualFold(strings.TrimSpace(args[0]), "auto")
+		var notches []fftPeak
+		if !auto {
+			var err error
+			if notches, err = parseNotches(args[0]); err != nil {
+				return err
+			}
+		}
+		radius := 3.0
+		if args[1] != "" {
+			r, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				return fmt.Errorf("invalid radius: %w", err)
+			}
+			radius = r
+		}
+		return FFTNotch(wand, notches, auto, radius)
+
+	case "fftSpectrum":
+		output := ""
+		if len(args) > 0 {
+			output = args[0]
+		}
+		return ShowFFTSpectrum(wand, output)
+
 	case "flip":
 		return wand.FlipImage()
 
@@ -397,6 +718,48 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 	case "flop":
 		return wand.FlopImage()
 
+	case "frame":
+		if len(args) != 5 {
+			return fmt.Errorf("frame requires 5 arguments: color, width, height, outerBevel, innerBevel")
+		}
+		width, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height := width
+		if args[2] != "" {
+			if height, err = strconv.ParseUint(args[2], 10, 64); err != nil {
+				return fmt.Errorf("invalid height: %w", err)
+			}
+		}
+		bevels := []int{int(min(width, height) / 4), int(min(width, height) / 4)}
+		for i, name := range []string{"outerBevel", "innerBevel"} {
+			if args[3+i] == "" {
+				continue
+			}
+			if bevels[i], err = strconv.Atoi(args[3+i]); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+		return AddFrame(wand, args[0], uint(width), uint(height), bevels[0], bevels[1])
+
+	case "frameTiming":
+		printFrameTiming(wand)
+		return nil
+
+	case "fx":
+		if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+			return fmt.Errorf("fx requires 1 argument: expression")
+		}
+		result, err := wand.FxImage(args[0])
+		if err != nil {
+			return fmt.Errorf("fx %q: %w", args[0], err)
+		}
+		defer result.Destroy()
+		result.SetImageDelay(wand.GetImageDelay())
+		result.SetImageDispose(wand.GetImageDispose())
+		return wand.SetImage(result)
+
 	case "gamma":
 		if len(args) != 1 {
 			return fmt.Errorf("gamma requires 1 argument: gamma")
@@ -407,6 +770,37 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.GammaImage(gamma)
 
+	case "generateNoise":
+		if len(args) != 6 {
+			return fmt.Errorf("generateNoise requires 6 arguments: pattern, width, height, color1, color2, noiseType")
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(noisePatterns) {
+			return fmt.Errorf("invalid pattern: %s", args[0])
+		}
+		width, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height: %w", err)
+		}
+		noise := imagick.NOISE_RANDOM
+		if args[5] != "" {
+			n, err := strconv.ParseInt(args[5], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid noiseType: %w", err)
+			}
+			noise = imagick.NoiseType(n)
+		}
+		img, err := GenerateNoise(noisePatterns[idx], uint(width), uint(height), args[3], args[4], noise)
+		if err != nil {
+			return err
+		}
+		defer img.Destroy()
+		return replaceWandImages(wand, img)
+
 	case "grayscale":
 		return wand.SetImageColorspace(imagick.COLORSPACE_GRAY)
 
@@ -427,16 +821,69 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		// Delegate the heavy lifting to helper which computes histograms, renders PNG and previews it.
 		return previewHistogramFromWand(wand, bins)
 
+	case "icons":
+		if len(args) != 1 {
+			return fmt.Errorf("icons requires 1 argument: outDir")
+		}
+		written, err := ExportIcons(wand, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d files to %s: %s\n", len(written), args[0], strings.Join(written, ", "))
+		fmt.Println(`<link rel="icon" href="/favicon.ico" sizes="48x48">`)
+		fmt.Println(`<link rel="icon" type="image/png" sizes="32x32" href="/icon-32.png">`)
+		fmt.Println(`<link rel="apple-touch-icon" href="/apple-touch-icon.png">`)
+		return nil
+
 	case "identify":
 		info := wand.IdentifyImage()
 		fmt.Println(info)
 		return nil
 
+	case "implode":
+		if len(args) != 1 {
+			return fmt.Errorf("implode requires 1 argument: amount")
+		}
+		amount, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount: %w", err)
+		}
+		return wand.ImplodeImage(amount, imagick.INTERPOLATE_PIXEL_BILINEAR)
+
+	case "inspectPixel":
+		if len(args) != 2 {
+			return fmt.Errorf("inspectPixel requires 2 arguments: x, y")
+		}
+		x, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid y: %w", err)
+		}
+		info, err := InspectPixel(wand, x, y)
+		if err != nil {
+			return err
+		}
+		fmt.Println(info)
+		return nil
+
+	case "kuwahara":
+		if len(args) != 1 {
+			return fmt.Errorf("kuwahara requires 1 argument: radius")
+		}
+		radius, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid radius: %w", err)
+		}
+		return KuwaharaImage(wand, radius)
+
 	case "level":
 		if len(args) != 3 {
 			return fmt.Errorf("level requires 3 arguments: blackPoint, gamma, whitePoint")
 		}
-		blackPoint, err := strconv.ParseFloat(args[0], 64)
+		blackPoint, err := parseQuantum(args[0])
 		if err != nil {
 			return fmt.Errorf("invalid blackPoint: %w", err)
 		}
@@ -444,71 +891,418 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		if err != nil {
 			return fmt.Errorf("invalid gamma: %w", err)
 		}
-		whitePoint, err := strconv.ParseFloat(args[2], 64)
+		whitePoint, err := parseQuantum(args[2])
 		if err != nil {
 			return fmt.Errorf("invalid whitePoint: %w", err)
 		}
 		return wand.LevelImage(blackPoint, gamma, whitePoint)
 
-	case "medianFilter":
-		if len(args) != 1 {
-			return fmt.Errorf("medianFilter requires 1 argument: radius")
-		}
-		radius, err := strconv.ParseUint(args[0], 10, 64)
-		if err != nil {
-			return fmt.Errorf("invalid radius: %w", err)
+	case "levelChannel":
+		if len(args) != 4 {
+			return fmt.Errorf("levelChannel requires 4 arguments: channel, blackPoint, gamma, whitePoint")
 		}
-		return wand.StatisticImage(imagick.STATISTIC_MEDIAN, uint(radius), uint(radius))
-
-	case "modulate":
-		// modulate requires 3 args: brightness, saturation, hue
-		if len(args) != 3 {
-			return fmt.Errorf("modulate requires 3 arguments: brightness, saturation, hue")
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(levelChannels) {
+			return fmt.Errorf("invalid channel: %s", args[0])
 		}
-		brightness, err := strconv.ParseFloat(args[0], 64)
+		blackPoint, err := parseQuantum(args[1])
 		if err != nil {
-			return fmt.Errorf("invalid brightness: %w", err)
+			return fmt.Errorf("invalid blackPoint: %w", err)
 		}
-		saturation, err := strconv.ParseFloat(args[1], 64)
+		gamma, err := strconv.ParseFloat(args[2], 64)
 		if err != nil {
-			return fmt.Errorf("invalid saturation: %w", err)
+			return fmt.Errorf("invalid gamma: %w", err)
 		}
-		hue, err := strconv.ParseFloat(args[2], 64)
+		whitePoint, err := parseQuantum(args[3])
 		if err != nil {
-			return fmt.Errorf("invalid hue: %w", err)
+			return fmt.Errorf("invalid whitePoint: %w", err)
 		}
-		return wand.ModulateImage(brightness, saturation, hue)
+		masks := []imagick.ChannelType{imagick.CHANNEL_RED, imagick.CHANNEL_GREEN, imagick.CHANNEL_BLUE, imagick.CHANNEL_ALPHA}
+		if idx == 3 && !wand.GetImageAlphaChannel() {
+			return fmt.Errorf("the image has no alpha channel")
+		}
+		prev := wand.SetImageChannelMask(masks[idx])
+		err = wand.LevelImage(blackPoint, gamma, whitePoint)
+		wand.SetImageChannelMask(prev)
+		return err
 
-	case "monochrome":
-		return wand.SetImageType(imagick.IMAGE_TYPE_BILEVEL)
+	case "levelsUI":
+		return fmt.Errorf("levelsUI is interactive; use level with the values it shows")
 
-	case "negate":
-		if len(args) != 1 {
-			return fmt.Errorf("negate requires 1 argument: only_gray (true/false)")
+	case "liquidRescale":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("liquidRescale requires 2 or 3 arguments: width, height [, rigidity]")
 		}
-		onlyGray, err := strconv.ParseBool(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid only_gray value: %w", err)
+		width, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil || width == 0 {
+			return fmt.Errorf("invalid width %q", args[0])
 		}
-		return wand.NegateImage(onlyGray)
-
-	case "normalize":
-		return wand.NormalizeImage()
+		height, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil || height == 0 {
+			return fmt.Errorf("invalid height %q", args[1])
+		}
+		rigidity := 0.0
+		if len(args) == 3 && args[2] != "" {
+			rigidity, err = strconv.ParseFloat(args[2], 64)
+			if err != nil {
+				return fmt.Errorf("invalid rigidity: %w", err)
+			}
+		}
+		// deltaX 1 lets a seam move at most one pixel sideways per row;
+		// toMagickCmd passes it explicitly in the -liquid-rescale geometry.
+		if err := wand.LiquidRescaleImage(uint(width), uint(height), 1, rigidity); err != nil {
+			return fmt.Errorf("liquid rescale failed (ImageMagick needs liblqr support): %w", err)
+		}
+		return nil
 
-	case "oilpaint":
+	case "lut":
 		if len(args) != 2 {
-			return fmt.Errorf("oilpaint requires 2 arguments: radius and sigma")
+			return fmt.Errorf("lut requires 2 arguments: lutPath, strength")
 		}
-		radius, err := strconv.ParseFloat(args[0], 64)
-		if err != nil {
-			return fmt.Errorf("invalid radius: %w", err)
+		strength := 100.0
+		if args[1] != "" {
+			v, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				return fmt.Errorf("invalid strength: %w", err)
+			}
+			strength = v
 		}
-		sigma, err := strconv.ParseFloat(args[1], 64)
+		hald, err := LoadLUT(args[0])
 		if err != nil {
-			return fmt.Errorf("invalid sigma: %w", err)
+			return err
+		}
+		defer hald.Destroy()
+		return ApplyLUT(wand, hald, strength/100)
+
+	case "makeGif":
+		if len(args) != 3 {
+			return fmt.Errorf("makeGif requires 3 arguments: files, delay, loops")
+		}
+		files, err := expandFileList(args[0])
+		if err != nil {
+			return err
+		}
+		delay, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+		loops, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid loops: %w", err)
+		}
+		anim, err := BuildAnimation(files, uint(delay), uint(loops))
+		if err != nil {
+			return err
+		}
+		defer anim.Destroy()
+		return replaceWandImages(wand, anim)
+
+	case "mask":
+		if len(args) != 4 {
+			return fmt.Errorf("mask requires 4 arguments: action, source, feather, invert")
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(maskActions) {
+			return fmt.Errorf("invalid mask action %q", args[0])
+		}
+		feather := 0.0
+		if args[2] != "" {
+			feather, err = strconv.ParseFloat(args[2], 64)
+			if err != nil || feather < 0 {
+				return fmt.Errorf("invalid feather: %s", args[2])
+			}
+		}
+		invert := false
+		if args[3] != "" {
+			invert, err = strconv.ParseBool(args[3])
+			if err != nil {
+				return fmt.Errorf("invalid invert: %w", err)
+			}
+		}
+		return SetMask(wand, maskActions[idx], args[1], feather, invert)
+
+	case "matchColors":
+		if len(args) != 3 {
+			return fmt.Errorf("matchColors requires 3 arguments: referencePath, method, strength")
+		}
+		method := 0
+		if args[1] != "" {
+			idx, err := strconv.Atoi(args[1])
+			if err != nil || idx < 0 || idx >= len(matchMethods) {
+				return fmt.Errorf("invalid method: %s", args[1])
+			}
+			method = idx
+		}
+		strength := 100.0
+		if args[2] != "" {
+			s, err := strconv.ParseFloat(args[2], 64)
+			if err != nil {
+				return fmt.Errorf("invalid strength: %w", err)
+			}
+			strength = s
+		}
+		ref, err := LoadImage(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read reference image: %w", err)
+		}
+		defer ref.Destroy()
+		ref.SetFirstIterator()
+		return MatchColors(wand, ref, matchMethods[method], strength/100)
+
+	case "medianFilter":
+		if len(args) != 1 {
+			return fmt.Errorf("medianFilter requires 1 argument: radius")
+		}
+		radius, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid radius: %w", err)
+		}
+		return wand.StatisticImage(imagick.STATISTIC_MEDIAN, uint(radius), uint(radius))
+
+	case "metadata":
+		if len(args) != 3 {
+			return fmt.Errorf("metadata requires 3 arguments: action, key, value")
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(metadataActions) {
+			return fmt.Errorf("invalid metadata action %q", args[0])
+		}
+		key := args[1]
+		switch metadataActions[idx] {
+		case "LIST":
+			return ListMetadata(wand, key)
+		case "SET":
+			if key == "" {
+				return fmt.Errorf("metadata SET requires a key")
+			}
+			return SetMetadata(wand, key, args[2])
+		default:
+			if key == "" {
+				return fmt.Errorf("metadata DELETE requires a key or pattern")
+			}
+			n, err := DeleteMetadata(wand, key)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return fmt.Errorf("no metadata matches %q", key)
+			}
+			fmt.Printf("Deleted %d metadata entries\n", n)
+			return nil
+		}
+
+	case "modulate":
+		// modulate requires 3 args: brightness, saturation, hue
+		if len(args) != 3 {
+			return fmt.Errorf("modulate requires 3 arguments: brightness, saturation, hue")
+		}
+		brightness, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid brightness: %w", err)
+		}
+		saturation, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid saturation: %w", err)
+		}
+		hue, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid hue: %w", err)
+		}
+		return wand.ModulateImage(brightness, saturation, hue)
+
+	case "monochrome":
+		return wand.SetImageType(imagick.IMAGE_TYPE_BILEVEL)
+
+	case "montage":
+		if len(args) != 5 {
+			return fmt.Errorf("montage requires 5 arguments: files, tile, thumbnail, labels, background")
+		}
+		files, err := expandFileList(args[0])
+		if err != nil {
+			return err
+		}
+		thumb := args[2]
+		if thumb == "" {
+			thumb = "200x200+4+4"
+		}
+		labels := args[3] != "false"
+		background := args[4]
+		if background == "" {
+			background = "white"
+		}
+		sheet, err := BuildMontage(files, args[1], thumb, labels, background)
+		if err != nil {
+			return err
+		}
+		defer sheet.Destroy()
+		return replaceWandImages(wand, sheet)
+
+	case "motionBlur":
+		if len(args) != 3 {
+			return fmt.Errorf("motionBlur requires 3 arguments: radius, sigma, angle")
+		}
+		radius, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid radius: %w", err)
+		}
+		sigma, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid sigma: %w", err)
+		}
+		angle, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid angle: %w", err)
+		}
+		return wand.MotionBlurImage(radius, sigma, angle)
+
+	case "negate":
+		if len(args) != 1 {
+			return fmt.Errorf("negate requires 1 argument: only_gray (true/false)")
+		}
+		onlyGray, err := strconv.ParseBool(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid only_gray value: %w", err)
+		}
+		return wand.NegateImage(onlyGray)
+
+	case "ninePatch":
+		if len(args) != 6 {
+			return fmt.Errorf("ninePatch requires 6 arguments: output, style, top, right, bottom, left")
+		}
+		// Missing insets follow the CSS shorthand: right defaults to top,
+		// bottom to top and left to right.
+		var insets [4]uint
+		for i, name := range []string{"top", "right", "bottom", "left"} {
+			v := args[i+2]
+			if v == "" {
+				insets[i] = insets[[]int{0, 0, 0, 1}[i]]
+				continue
+			}
+			n, err := strconv.ParseUint(v, 10, 0)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			insets[i] = uint(n)
+		}
+		style := "ANDROID"
+		if args[1] != "" {
+			idx, err := strconv.Atoi(args[1])
+			if err != nil || idx < 0 || idx >= len(ninePatchStyles) {
+				return fmt.Errorf("invalid style %q", args[1])
+			}
+			style = ninePatchStyles[idx]
+		}
+		if style == "CSS" {
+			rule, err := WriteCSSNinePatch(wand, args[0], insets[0], insets[1], insets[2], insets[3])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Wrote border.png and its slices to %s\n%s\n", args[0], rule)
+			return nil
+		}
+		path, err := WriteAndroidNinePatch(wand, args[0], insets[0], insets[1], insets[2], insets[3])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+		return nil
+
+	case "normalize":
+		return wand.NormalizeImage()
+
+	case "oilpaint":
+		if len(args) != 2 {
+			return fmt.Errorf("oilpaint requires 2 arguments: radius and sigma")
+		}
+		radius, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid radius: %w", err)
+		}
+		sigma, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid sigma: %w", err)
 		}
 		return wand.OilPaintImage(radius, sigma)
 
+	case "packSheet":
+		if len(args) != 4 {
+			return fmt.Errorf("packSheet requires 4 arguments: files, columns, padding, background")
+		}
+		files, err := expandFileList(args[0])
+		if err != nil {
+			return err
+		}
+		var columns, padding uint64
+		if args[1] != "" {
+			if columns, err = strconv.ParseUint(args[1], 10, 0); err != nil {
+				return fmt.Errorf("invalid columns: %w", err)
+			}
+		}
+		if args[2] != "" {
+			if padding, err = strconv.ParseUint(args[2], 10, 0); err != nil {
+				return fmt.Errorf("invalid padding: %w", err)
+			}
+		}
+		background := args[3]
+		if background == "" {
+			background = "none"
+		}
+		sheet, cellW, cellH, err := PackSheet(files, uint(columns), uint(padding), background)
+		if err != nil {
+			return err
+		}
+		defer sheet.Destroy()
+		fmt.Printf("Packed %d sprites into %dx%d cells\n", len(files), cellW, cellH)
+		return replaceWandImages(wand, sheet)
+
+	case "padToAspect":
+		if len(args) != 3 {
+			return fmt.Errorf("padToAspect requires 3 arguments: ratio, gravity, background")
+		}
+		gravity, err := optionalGravity(args[1])
+		if err != nil {
+			return err
+		}
+		background := args[2]
+		if background == "" {
+			background = "white"
+		}
+		return PadToAspect(wand, args[0], gravity, background)
+
+	case "palette":
+		if len(args) != 2 {
+			return fmt.Errorf("palette requires 2 arguments: colors, output")
+		}
+		n := uint64(8)
+		if args[0] != "" {
+			v, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil || v == 0 {
+				return fmt.Errorf("invalid colors: %s", args[0])
+			}
+			n = v
+		}
+		colors, err := ExtractPalette(wand, uint(n))
+		if err != nil {
+			return err
+		}
+		printPalette(colors)
+		if args[1] != "" {
+			if err := WritePalette(colors, args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Saved %d colors to %s\n", len(colors), args[1])
+		}
+		return nil
+
+	case "pingPongFrames":
+		return pingPongFrames(wand)
+
+	case "pipe":
+		if len(args) != 1 {
+			return fmt.Errorf("pipe requires 1 argument: command")
+		}
+		return PipeImage(wand, args[0])
+
 	case "polaroid":
 		// polaroid requires 3 args: caption, angle, method
 		if len(args) != 3 {
@@ -551,32 +1345,103 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.PosterizeImage(uint(levels), ditherMethod)
 
-	case "resize":
+	case "region":
 		if len(args) != 2 {
-			return fmt.Errorf("resize requires 2 arguments: width and height")
+			return fmt.Errorf("region requires 2 arguments: action, region")
 		}
-		width, err := strconv.ParseUint(args[0], 10, 64)
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(regionActions) {
+			return fmt.Errorf("invalid region action %q", args[0])
+		}
+		return SetRegion(wand, regionActions[idx], args[1])
+
+	case "removeBackground":
+		if len(args) < 1 {
+			return fmt.Errorf("removeBackground requires at least 1 argument: fuzz")
+		}
+		fuzz, err := strconv.ParseFloat(args[0], 64)
 		if err != nil {
-			return fmt.Errorf("invalid width: %w", err)
+			return fmt.Errorf("invalid fuzz value: %w", err)
 		}
-		height, err := strconv.ParseUint(args[1], 10, 64)
+		feather := 0.0
+		if len(args) > 1 && args[1] != "" {
+			if feather, err = strconv.ParseFloat(args[1], 64); err != nil {
+				return fmt.Errorf("invalid feather value: %w", err)
+			}
+		}
+		return RemoveBackground(wand, fuzz, feather)
+
+	case "resize", "resizeLinear":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("%s requires 2 or 3 arguments: width, height [, filter]", commandName)
+		}
+		width, height, ok, err := resizeDimensions(wand, args[0], args[1])
+		if err != nil || !ok {
+			return err
+		}
+		filter := imagick.FILTER_LANCZOS
+		if len(args) == 3 && args[2] != "" {
+			id, err := strconv.ParseInt(args[2], 10, 64)
+			if _, ok := filterValueToName[id]; err != nil || !ok {
+				return fmt.Errorf("invalid filter %q", args[2])
+			}
+			filter = imagick.FilterType(id)
+		}
+		if commandName == "resizeLinear" {
+			return ResizeLinear(wand, width, height, filter)
+		}
+		return wand.ResizeImage(width, height, filter)
+
+	case "reverseFrames":
+		return reverseFrames(wand)
+
+	case "roll":
+		if len(args) != 2 {
+			return fmt.Errorf("roll requires 2 arguments: offsetX, offsetY")
+		}
+		x, err := strconv.Atoi(args[0])
 		if err != nil {
-			return fmt.Errorf("invalid height: %w", err)
+			return fmt.Errorf("invalid offsetX: %w", err)
+		}
+		y, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid offsetY: %w", err)
 		}
-		return wand.ResizeImage(uint(width), uint(height), imagick.FILTER_LANCZOS)
+		return wand.RollImage(x, y)
 
 	case "rotate":
-		if len(args) != 1 {
-			return fmt.Errorf("rotate requires 1 argument: degrees")
+		if len(args) < 1 || len(args) > 3 {
+			return fmt.Errorf("rotate requires 1 to 3 arguments: degrees [, background, autoCrop]")
 		}
 		degrees, err := strconv.ParseFloat(args[0], 64)
 		if err != nil {
 			return fmt.Errorf("invalid degrees: %w", err)
 		}
-		pixel := imagick.NewPixelWand()
-		defer pixel.Destroy()
-		pixel.SetColor("black")
-		return wand.RotateImage(pixel, degrees)
+		background := "black"
+		if len(args) > 1 && args[1] != "" {
+			background = args[1]
+		}
+		autoCrop := len(args) > 2 && args[2] == "true"
+		return RotateImage(wand, degrees, background, autoCrop)
+
+	case "rotationalBlur":
+		if len(args) != 1 {
+			return fmt.Errorf("rotationalBlur requires 1 argument: angle")
+		}
+		angle, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid angle: %w", err)
+		}
+		// The binding names MagickRotationalBlurImage after its old name.
+		return wand.RadialBlurImage(angle)
+
+	case "scanCode":
+		codes, err := ScanCodes(wand)
+		if err != nil {
+			return err
+		}
+		printScannedCodes(codes)
+		return nil
 
 	case "sepia":
 		if len(args) != 1 {
@@ -593,6 +1458,52 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		threshold := percentage / 100 * float64(quantumRange)
 		return wand.SepiaToneImage(threshold)
 
+	case "setFrameDelay":
+		if len(args) != 1 {
+			return fmt.Errorf("setFrameDelay requires 1 argument: delay")
+		}
+		delay, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+		return wand.SetImageDelay(uint(delay))
+
+	case "setFrameDispose":
+		if len(args) != 1 {
+			return fmt.Errorf("setFrameDispose requires 1 argument: method")
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(disposeTypes) {
+			return fmt.Errorf("invalid dispose method: %s", args[0])
+		}
+		return wand.SetImageDispose(disposeTypes[idx])
+
+	case "shadow":
+		if len(args) != 5 {
+			return fmt.Errorf("shadow requires 5 arguments: opacity, sigma, offsetX, offsetY, background")
+		}
+		opacity, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid opacity: %w", err)
+		}
+		sigma, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid sigma: %w", err)
+		}
+		x, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid offsetX: %w", err)
+		}
+		y, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("invalid offsetY: %w", err)
+		}
+		background := args[4]
+		if background == "" {
+			background = "none"
+		}
+		return AddShadow(wand, opacity, sigma, x, y, background)
+
 	case "sharpen":
 		if len(args) != 2 {
 			return fmt.Errorf("sharpen requires 2 arguments: radius and sigma")
@@ -607,40 +1518,258 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.SharpenImage(radius, sigma)
 
+	case "shear":
+		if len(args) < 1 {
+			return fmt.Errorf("shear requires at least 1 argument: xDegrees")
+		}
+		xDegrees, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid xDegrees: %w", err)
+		}
+		yDegrees := 0.0
+		if len(args) > 1 && args[1] != "" {
+			if yDegrees, err = strconv.ParseFloat(args[1], 64); err != nil {
+				return fmt.Errorf("invalid yDegrees: %w", err)
+			}
+		}
+		background := "black"
+		if len(args) > 2 && args[2] != "" {
+			background = args[2]
+		}
+		return ShearImage(wand, xDegrees, yDegrees, background)
+
+	case "sketch":
+		if len(args) != 3 {
+			return fmt.Errorf("sketch requires 3 arguments: radius, sigma, angle")
+		}
+		radius, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid radius: %w", err)
+		}
+		sigma, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid sigma: %w", err)
+		}
+		angle, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid angle: %w", err)
+		}
+		return wand.SketchImage(radius, sigma, angle)
+
+	case "sliceSheet":
+		if len(args) != 6 {
+			return fmt.Errorf("sliceSheet requires 6 arguments: outDir, width, height, spacing, margin, skipEmpty")
+		}
+		var dims [4]uint64
+		for i, name := range []string{"width", "height", "spacing", "margin"} {
+			if args[i+1] == "" {
+				continue
+			}
+			v, err := strconv.ParseUint(args[i+1], 10, 0)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			dims[i] = v
+		}
+		skipEmpty := args[5] != "false"
+		written, cols, rows, err := SliceSheet(wand, args[0], uint(dims[0]), uint(dims[1]), uint(dims[2]), uint(dims[3]), skipEmpty)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d tiles from a %dx%d grid to %s\n", written, cols, rows, args[0])
+		return nil
+
+	case "social":
+		if len(args) != 4 {
+			return fmt.Errorf("social requires 4 arguments: outDir, targets, fit, background")
+		}
+		targets, err := selectSocialTargets(args[1])
+		if err != nil {
+			return err
+		}
+		pad := false
+		if args[2] != "" {
+			idx, err := strconv.Atoi(args[2])
+			if err != nil || idx < 0 || idx >= len(socialFits) {
+				return fmt.Errorf("invalid fit %q", args[2])
+			}
+			pad = socialFits[idx] == "PAD"
+		}
+		background := args[3]
+		if background == "" {
+			background = "white"
+		}
+		return ExportSocial(wand, args[0], targets, pad, background)
+
 	case "solarize":
 		if len(args) != 1 {
 			return fmt.Errorf("solarize requires 1 argument: threshold")
 		}
-		threshold, err := strconv.ParseFloat(args[0], 64)
+		threshold, err := parseQuantum(args[0])
 		if err != nil {
 			return fmt.Errorf("invalid threshold: %w", err)
 		}
 		return wand.SolarizeImage(threshold)
 
+	case "splice":
+		if len(args) < 4 || len(args) > 5 {
+			return fmt.Errorf("splice requires 4 or 5 arguments: width, height, x, y [, background]")
+		}
+		width, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height: %w", err)
+		}
+		x, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := strconv.ParseInt(args[3], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid y: %w", err)
+		}
+		background := "white"
+		if len(args) > 4 && args[4] != "" {
+			background = args[4]
+		}
+		return SpliceBand(wand, uint(width), uint(height), int(x), int(y), background)
+
+	case "spread":
+		if len(args) != 1 {
+			return fmt.Errorf("spread requires 1 argument: radius")
+		}
+		radius, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid radius: %w", err)
+		}
+		return wand.SpreadImage(imagick.INTERPOLATE_PIXEL_BILINEAR, radius)
+
+	case "stack":
+		if len(args) != 2 {
+			return fmt.Errorf("stack requires 2 arguments: files, method")
+		}
+		files, err := expandFileList(args[0])
+		if err != nil {
+			return err
+		}
+		method := stackMethods[0]
+		if args[1] != "" {
+			idx, err := strconv.Atoi(args[1])
+			if err != nil || idx < 0 || idx >= len(stackMethods) {
+				return fmt.Errorf("invalid method %q", args[1])
+			}
+			method = stackMethods[idx]
+		}
+		stacked, err := StackImages(files, method)
+		if err != nil {
+			return err
+		}
+		defer stacked.Destroy()
+		return replaceWandImages(wand, stacked)
+
 	case "strip":
 		// Remove image profiles and comments/metadata
 		return wand.StripImage()
 
 	case "swirl":
-		if len(args) != 1 {
-			return fmt.Errorf("swirl requires 1 argument: degrees")
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("swirl requires 1 or 2 arguments: degrees [, interpolate]")
 		}
 		degrees, err := strconv.ParseFloat(args[0], 64)
 		if err != nil {
 			return fmt.Errorf("invalid degrees: %w", err)
 		}
-		return wand.SwirlImage(degrees, imagick.INTERPOLATE_PIXEL_BILINEAR)
+		method := imagick.INTERPOLATE_PIXEL_BILINEAR
+		if len(args) == 2 && args[1] != "" {
+			id, err := strconv.ParseInt(args[1], 10, 64)
+			if _, ok := interpolateValueToName[id]; err != nil || !ok {
+				return fmt.Errorf("invalid interpolate %q", args[1])
+			}
+			method = imagick.PixelInterpolateMethod(id)
+		}
+		return wand.SwirlImage(degrees, method)
+
+	case "testChart":
+		if len(args) != 3 {
+			return fmt.Errorf("testChart requires 3 arguments: chart, width, height")
+		}
+		idx, err := strconv.Atoi(args[0])
+		if err != nil || idx < 0 || idx >= len(testCharts) {
+			return fmt.Errorf("invalid chart: %s", args[0])
+		}
+		width, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid width: %w", err)
+		}
+		height, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid height: %w", err)
+		}
+		chart, err := GenerateTestChart(testCharts[idx], uint(width), uint(height))
+		if err != nil {
+			return err
+		}
+		defer chart.Destroy()
+		return replaceWandImages(wand, chart)
 
 	case "threshold":
 		if len(args) != 1 {
 			return fmt.Errorf("threshold requires 1 argument: threshold")
 		}
-		th, err := strconv.ParseFloat(args[0], 64)
+		th, err := parseQuantum(args[0])
 		if err != nil {
 			return fmt.Errorf("invalid threshold value: %w", err)
 		}
 		return wand.ThresholdImage(th)
 
+	case "tiles":
+		if len(args) != 5 {
+			return fmt.Errorf("tiles requires 5 arguments: outDir, mode, across, down, format")
+		}
+		idx, err := strconv.Atoi(args[1])
+		if err != nil || idx < 0 || idx >= len(tileModes) {
+			return fmt.Errorf("invalid mode %q", args[1])
+		}
+		across, err := strconv.ParseUint(args[2], 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid across: %w", err)
+		}
+		down, err := strconv.ParseUint(args[3], 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid down: %w", err)
+		}
+		cols, rows, err := SplitTiles(wand, args[0], tileModes[idx], uint(across), uint(down), args[4])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d tiles (%dx%d) to %s\n", cols*rows, cols, rows, args[0])
+		return nil
+
+	case "transparent":
+		if len(args) < 2 {
+			return fmt.Errorf("transparent requires at least 2 arguments: color, fuzz")
+		}
+		fuzz, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid fuzz value: %w", err)
+		}
+		invert := false
+		if len(args) > 2 && args[2] != "" {
+			if invert, err = strconv.ParseBool(args[2]); err != nil {
+				return fmt.Errorf("invalid invert value: %w", err)
+			}
+		}
+		return MakeTransparent(wand, args[0], fuzz, invert)
+
+	case "transpose":
+		return wand.TransposeImage()
+
+	case "transverse":
+		return wand.TransverseImage()
+
 	case "trim":
 		if len(args) != 1 {
 			return fmt.Errorf("trim requires 1 argument: fuzz")
@@ -695,7 +1824,24 @@ func ApplyCommand(wand *imagick.MagickWand, commandName string, args []string) e
 		}
 		return wand.VignetteImage(radius, sigma, int(x), int(y))
 
+	case "wave":
+		if len(args) != 2 {
+			return fmt.Errorf("wave requires 2 arguments: amplitude, wavelength")
+		}
+		amplitude, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid amplitude: %w", err)
+		}
+		wavelength, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid wavelength: %w", err)
+		}
+		return wand.WaveImage(amplitude, wavelength, imagick.INTERPOLATE_PIXEL_BILINEAR)
+
 	default:
+		if _, ok := scriptCommands[commandName]; ok {
+			return runScript(wand, commandName, args, 0)
+		}
 		return fmt.Errorf("unknown command: %s", commandName)
 	}
 }
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// colorspaceNames maps ImageMagick colorspaces to the names identify uses.
+var colorspaceNames = map[imagick.ColorspaceType]string{
+	imagick.COLORSPACE_CMY:         "CMY",
+	imagick.COLORSPACE_CMYK:        "CMYK",
+	imagick.COLORSPACE_GRAY:        "Gray",
+	imagick.COLORSPACE_HCL:         "HCL",
+	imagick.COLORSPACE_HCLP:        "HCLp",
+	imagick.COLORSPACE_HSB:         "HSB",
+	imagick.COLORSPACE_HSI:         "HSI",
+	imagick.COLORSPACE_HSL:         "HSL",
+	imagick.COLORSPACE_HSV:         "HSV",
+	imagick.COLORSPACE_HWB:         "HWB",
+	imagick.COLORSPACE_LAB:         "Lab",
+	imagick.COLORSPACE_LCH:         "LCH",
+	imagick.COLORSPACE_LCHAB:       "LCHab",
+	imagick.COLORSPACE_LCHUV:       "LCHuv",
+	imagick.COLORSPACE_LMS:         "LMS",
+	imagick.COLORSPACE_LOG:         "Log",
+	imagick.COLORSPACE_LUV:         "Luv",
+	imagick.COLORSPACE_OHTA:        "OHTA",
+	imagick.COLORSPACE_REC601YCBCR: "Rec601YCbCr",
+	imagick.COLORSPACE_REC709YCBCR: "Rec709YCbCr",
+	imagick.COLORSPACE_RGB:         "RGB (linear)",
+	imagick.COLORSPACE_SCRGB:       "scRGB",
+	imagick.COLORSPACE_SRGB:        "sRGB",
+	imagick.COLORSPACE_TRANSPARENT: "Transparent",
+	imagick.COLORSPACE_XYY:         "xyY",
+	imagick.COLORSPACE_XYZ:         "XYZ",
+	imagick.COLORSPACE_YCBCR:       "YCbCr",
+	imagick.COLORSPACE_YCC:         "YCC",
+	imagick.COLORSPACE_YDDDR:       "YDbDr",
+	imagick.COLORSPACE_YIQ:         "YIQ",
+	imagick.COLORSPACE_YPBPR:       "YPbPr",
+	imagick.COLORSPACE_YUV:         "YUV",
+}
+
+// imageTypeNames maps ImageMagick image types to short descriptions.
+var imageTypeNames = map[imagick.ImageType]string{
+	imagick.IMAGE_TYPE_BILEVEL:                "bilevel",
+	imagick.IMAGE_TYPE_COLOR_SEPARATION:       "color separation",
+	imagick.IMAGE_TYPE_COLOR_SEPARATION_ALPHA: "color separation with alpha",
+	imagick.IMAGE_TYPE_GRAYSCALE:              "grayscale",
+	imagick.IMAGE_TYPE_GRAYSCALE_ALPHA:        "grayscale with alpha",
+	imagick.IMAGE_TYPE_PALETTE:                "palette",
+	imagick.IMAGE_TYPE_PALETTE_ALPHA:          "palette with alpha",
+	imagick.IMAGE_TYPE_PALETTE_BILEVEL_ALPHA:  "palette with bilevel alpha",
+	imagick.IMAGE_TYPE_TRUE_COLOR:             "truecolor",
+	imagick.IMAGE_TYPE_TRUE_COLOR_ALPHA:       "truecolor with alpha",
+}
+
+// GetImageInfo returns a panel describing the current image of the wand:
+// geometry, color, resolution, frames, format, embedded profiles, the file's
+// size on disk next to an estimate of the encoded size now, and dates. The
+// estimate encodes a copy of the image, which takes a moment for large ones.
+func GetImageInfo(wand *imagick.MagickWand) (string, error) {
+	if wand == nil || wand.GetNumberImages() == 0 {
+		return "", fmt.Errorf("no image loaded")
+	}
+	var b strings.Builder
+	row := func(label, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "%-10s %s\n", label+":", fmt.Sprintf(format, args...))
+	}
+
+	width, height := wand.GetImageWidth(), wand.GetImageHeight()
+	geometry := fmt.Sprintf("%dx%d (%.1f MP)", width, height, float64(width*height)/1e6)
+	if pw, ph, px, py, err := wand.GetImagePage(); err == nil && (pw != width || ph != height || px != 0 || py != 0) {
+		geometry += fmt.Sprintf(", canvas %dx%d%+d%+d", pw, ph, px, py)
+	}
+	row("Geometry", "%s", geometry)
+
+	colorspace := colorspaceNames[wand.GetImageColorspace()]
+	if colorspace == "" {
+		colorspace = "undefined"
+	}
+	alpha := "no alpha"
+	if wand.GetImageAlphaChannel() {
+		alpha = "alpha"
+	}
+	color := fmt.Sprintf("%s, %d-bit, %s", colorspace, wand.GetImageDepth(), alpha)
+	if t, ok := imageTypeNames[wand.GetImageType()]; ok {
+		color += ", " + t
+	}
+	row("Color", "%s", color)
+
+	if xres, yres, err := wand.GetImageResolution(); err == nil && xres > 0 && yres > 0 {
+		units := wand.GetImageUnits()
+		switch units {
+		case imagick.RESOLUTION_PIXELS_PER_INCH:
+			row("Density", "%gx%g ppi (%.2fx%.2f in at that size)", xres, yres, float64(width)/xres, float64(height)/yres)
+		case imagick.RESOLUTION_PIXELS_PER_CENTIMETER:
+			row("Density", "%gx%g ppcm (%.2fx%.2f cm at that size)", xres, yres, float64(width)/xres, float64(height)/yres)
+		default:
+			row("Density", "%gx%g (no unit)", xres, yres)
+		}
+	} else {
+		row("Density", "not set")
+	}
+
+	if n := wand.GetNumberImages(); n > 1 {
+		row("Frames", "%d (showing frame %d)", n, wand.GetIteratorIndex()+1)
+	} else {
+		row("Frames", "1")
+	}
+
+	format := wand.GetImageFormat()
+	compression, ok := mapNumericToEnumName("compression", int64(wand.GetImageCompression()))
+	if !ok {
+		compression = fmt.Sprint(wand.GetImageCompression())
+	}
+	quality := wand.GetImageCompressionQuality()
+	formatInfo := fmt.Sprintf("%s, %s compression", format, compression)
+	if quality > 0 {
+		formatInfo += fmt.Sprintf(", quality %d", quality)
+	}
+	row("Format", "%s", formatInfo)
+
+	if profiles := wand.GetImageProfiles("*"); len(profiles) > 0 {
+		row("Profiles", "%s", strings.Join(profiles, ", "))
+	} else {
+		row("Profiles", "none")
+	}
+
+	path := wand.GetImageFilename()
+	var stat os.FileInfo
+	if path != "" {
+		stat, _ = os.Stat(path)
+	}
+	onDisk := "not saved"
+	if stat != nil {
+		onDisk = fmt.Sprintf("%s on disk (%s)", formatSize(stat.Size()), filepath.Base(path))
+	}
+	if size, err := encodedSize(wand); err == nil {
+		row("Size", "%s, about %s encoded as %s now", onDisk, formatSize(size), format)
+	} else {
+		row("Size", "%s", onDisk)
+	}
+
+	var dates []string
+	if taken := wand.GetImageProperty("exif:DateTimeOriginal"); taken != "" {
+		dates = append(dates, "taken "+taken)
+	}
+	if created := wand.GetImageProperty("date:create"); created != "" {
+		dates = append(dates, "created "+formatPropertyDate(created))
+	}
+	if stat != nil {
+		dates = append(dates, "modified "+stat.ModTime().Format("2006-01-02 15:04:05"))
+	} else if modified := wand.GetImageProperty("date:modify"); modified != "" {
+		dates = append(dates, "modified "+formatPropertyDate(modified))
+	}
+	if len(dates) > 0 {
+		row("Dates", "%s", strings.Join(dates, ", "))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// encodedSize returns how many bytes the current image would take written in
+// its format with its current settings.
+func encodedSize(wand *imagick.MagickWand) (int64, error) {
+	img := wand.GetImage()
+	defer img.Destroy()
+	blob, err := img.GetImageBlob()
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(blob)), nil
+}
+
+// formatPropertyDate shortens the ISO 8601 dates of ImageMagick's date:
+// properties to the local time, as file dates are shown.
+func formatPropertyDate(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatSize formats a byte count with a binary unit.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
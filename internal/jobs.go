@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Background jobs.
+//
+// Long operations (exporting a batch of files, saving an upscaled copy) run in
+// goroutines on their own wands so the interactive session stays responsive.
+// Each job works on a private copy of its input, never on the session's wand,
+// so the user can keep editing while it runs. `jobs` lists progress and a line
+// is printed when a job finishes.
+
+// jobCommands lists the session-level commands implemented in this file.
+var jobCommands = map[string]bool{
+	"jobs":        true,
+	"exportAsync": true,
+	"exportBatch": true,
+}
+
+// Job is one background operation.
+type Job struct {
+	ID       int
+	Desc     string
+	Total    int
+	Done     int
+	Failed   int
+	Err      error
+	Started  time.Time
+	Finished time.Time
+}
+
+// jobQueue tracks the session's background jobs. The zero value is ready to use.
+type jobQueue struct {
+	mu       sync.Mutex
+	jobs     []*Job
+	wg       sync.WaitGroup
+	canceled bool
+}
+
+// start registers a job and runs work in a goroutine. work reports progress
+// through step, which returns false once the queue has been canceled.
+func (q *jobQueue) start(desc string, total int, work func(step func(err error) bool) error) *Job {
+	q.mu.Lock()
+	job := &Job{ID: len(q.jobs) + 1, Desc: desc, Total: total, Started: time.Now()}
+	q.jobs = append(q.jobs, job)
+	q.mu.Unlock()
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		// Each item runs under a read lock on the user's settings and
+		// commands, released between items so a reload can swap them.
+		userFiles.RLock()
+		step := func(err error) bool {
+			userFiles.RUnlock()
+			defer userFiles.RLock()
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			job.Done++
+			if err != nil {
+				job.Failed++
+				fmt.Fprintf(os.Stderr, "\n[job %d] %v\n", job.ID, err)
+			}
+			return !q.canceled
+		}
+		err := work(step)
+		userFiles.RUnlock()
+
+		q.mu.Lock()
+		job.Err = err
+		job.Finished = time.Now()
+		summary := job.summary()
+		q.mu.Unlock()
+		// The interactive loop is usually waiting at its prompt; print it again.
+		fmt.Printf("\n[job %d] %s\n> ", job.ID, summary)
+	}()
+	return job
+}
+
+// running returns the number of unfinished jobs.
+func (q *jobQueue) running() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, j := range q.jobs {
+		if j.Finished.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+// stop asks jobs to stop after their current item and waits for them.
+func (q *jobQueue) stop() {
+	q.mu.Lock()
+	q.canceled = true
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// summary describes a job's state; the caller must hold the queue lock.
+func (j *Job) summary() string {
+	switch {
+	case j.Finished.IsZero():
+		return fmt.Sprintf("running  %d/%d  %s  (%s)", j.Done, j.Total, j.Desc, time.Since(j.Started).Round(time.Second))
+	case j.Err != nil:
+		return fmt.Sprintf("failed   %d/%d  %s: %v", j.Done, j.Total, j.Desc, j.Err)
+	case j.Failed > 0:
+		return fmt.Sprintf("done     %d/%d  %s (%d failed)", j.Done-j.Failed, j.Total, j.Desc, j.Failed)
+	case j.Done < j.Total:
+		return fmt.Sprintf("stopped  %d/%d  %s", j.Done, j.Total, j.Desc)
+	default:
+		return fmt.Sprintf("done     %d/%d  %s in %s", j.Done, j.Total, j.Desc, j.Finished.Sub(j.Started).Round(100*time.Millisecond))
+	}
+}
+
+// list prints every job of the session.
+func (q *jobQueue) list() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.jobs) == 0 {
+		fmt.Println("no background jobs")
+		return
+	}
+	for _, j := range q.jobs {
+		fmt.Printf("  %3d  %s\n", j.ID, j.summary())
+	}
+}
+
+// RunningJobs returns the number of background jobs that have not finished.
+func (s *Session) RunningJobs() int {
+	return s.jobs.running()
+}
+
+// StopJobs cancels the remaining items of every background job and waits for
+// the items in progress to finish.
+func (s *Session) StopJobs() {
+	s.jobs.stop()
+}
+
+// parseJobStep parses and normalizes a command line such as "resize 4000 3000"
+// for use in a background job. Session-level commands cannot run on a copy.
+func parseJobStep(store *MetaStore, line string) (RecipeStep, error) {
+	fields, err := splitRecipeLine(line)
+	if err != nil {
+		return RecipeStep{}, err
+	}
+	if len(fields) == 0 {
+		return RecipeStep{}, fmt.Errorf("empty command")
+	}
+	name := fields[0]
+	meta := GetCommandMetaByName(store.Commands, name)
+	if meta == nil {
+		return RecipeStep{}, fmt.Errorf("unknown command: %s", name)
+	}
+	if meta.CreatesImage || meta.NoImage || isSessionCommand(name) {
+		return RecipeStep{}, fmt.Errorf("%s cannot run as part of a background job", name)
+	}
+	args, err := NormalizeArgs(store, name, fields[1:])
+	if err != nil {
+		return RecipeStep{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return RecipeStep{Command: name, Args: args}, nil
+}
+
+// applyJobCommand runs jobs, exportAsync or exportBatch with normalized args.
+func (s *Session) applyJobCommand(commandName string, args []string) error {
+	switch commandName {
+	case "jobs":
+		s.jobs.list()
+		return nil
+
+	case "exportAsync":
+		if s.Wand == nil {
+			return fmt.Errorf("no image loaded")
+		}
+		output := args[0]
+		var extra *RecipeStep
+		if len(args) > 1 && args[1] != "" {
+			step, err := parseJobStep(s.Store, args[1])
+			if err != nil {
+				return err
+			}
+			extra = &step
+		}
+		// Copy the displayed image (layers included) before returning, so later
+		// edits do not affect the export.
+		wand := s.Display().Clone()
+		desc := "export " + output
+		if extra != nil {
+			desc = fmt.Sprintf("export %s after %s", output, extra)
+		}
+		job := s.jobs.start(desc, 1, func(step func(error) bool) error {
+			defer wand.Destroy()
+			if extra != nil {
+				err := traceStep(*extra, func() *imagick.MagickWand { return wand }, func() error {
+					return ApplyCommandFrames(wand, extra.Command, extra.Args, true)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if err := WriteWand(wand, output); err != nil {
+				return fmt.Errorf("write %s: %w", output, err)
+			}
+			step(nil)
+			return nil
+		})
+		fmt.Printf("Started job %d: %s\n", job.ID, desc)
+		return nil
+
+	case "exportBatch":
+		files, err := expandFileList(args[0])
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no files match %q", args[0])
+		}
+		outDir := args[1]
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			return fmt.Errorf("create output dir: %w", err)
+		}
+		format := strings.TrimPrefix(strings.ToLower(args[2]), ".")
+		steps := append([]RecipeStep(nil), s.History...)
+		store := s.Store
+		desc := fmt.Sprintf("exportBatch %d files (%d steps) -> %s", len(files), len(steps), outDir)
+		job := s.jobs.start(desc, len(files), func(step func(error) bool) error {
+			for _, path := range files {
+				name := filepath.Base(path)
+				if format != "" {
+					name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + format
+				}
+				err := exportFile(store, steps, path, filepath.Join(outDir, name))
+				if err != nil {
+					err = fmt.Errorf("%s: %w", filepath.Base(path), err)
+				}
+				if !step(err) {
+					return nil
+				}
+			}
+			return nil
+		})
+		fmt.Printf("Started job %d: %s\n", job.ID, desc)
+		return nil
+	}
+	return fmt.Errorf("unknown job command: %s", commandName)
+}
+
+// exportFile reads inPath, applies steps and writes the result to outPath.
+func exportFile(store *MetaStore, steps []RecipeStep, inPath, outPath string) error {
+	wand, err := LoadImage(inPath)
+	if err != nil {
+		return withExitCode(exitRead, fmt.Errorf("read: %w", err))
+	}
+	defer wand.Destroy()
+	if err := ApplyRecipe(store, wand, steps); err != nil {
+		return err
+	}
+	if err := WriteWand(wand, outPath); err != nil {
+		return withExitCode(exitWrite, fmt.Errorf("write: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Kuwahara filter.
+//
+// Each pixel is replaced by the mean color of whichever of the four
+// (radius+1)-square quadrants around it has the least variation in
+// luminance. Flat areas are averaged like a box blur, but at an edge the
+// chosen quadrant lies on one side of it, so the edge stays sharp: skin and
+// paper texture smooth out while eyes, lettering and outlines do not. Large
+// radii give a painted look. Alpha is left as it is.
+
+// kuwaharaStripe is how many rows are filtered at a time, to bound the
+// memory of the summed-area tables on large images.
+const kuwaharaStripe = 256
+
+// KuwaharaImage applies a Kuwahara filter of the given radius to the current
+// image of wand.
+func KuwaharaImage(wand *imagick.MagickWand, radius int) error {
+	if radius < 1 {
+		return fmt.Errorf("radius must be at least 1")
+	}
+	// Read from a copy: rows near a stripe border are needed again after
+	// the stripe above has been written.
+	src := wand.GetImage()
+	defer src.Destroy()
+	w, h := int(src.GetImageWidth()), int(src.GetImageHeight())
+	if w == 0 || h == 0 {
+		return fmt.Errorf("image has zero dimensions")
+	}
+
+	for y0 := 0; y0 < h; y0 += kuwaharaStripe {
+		y1 := min(y0+kuwaharaStripe, h)
+		// The rows the stripe's quadrants can reach.
+		ry0, ry1 := max(0, y0-radius), min(h, y1+radius)
+		raw, err := src.ExportImagePixels(0, ry0, uint(w), uint(ry1-ry0), "RGB", imagick.PIXEL_FLOAT)
+		if err != nil {
+			return fmt.Errorf("ExportImagePixels failed: %w", err)
+		}
+		rgb, ok := raw.([]float32)
+		if !ok {
+			return fmt.Errorf("unsupported pixel data type: %T", raw)
+		}
+
+		// Summed-area tables of R, G, B, luminance and squared luminance,
+		// one row and column larger than the pixels so sums start at zero.
+		stride := w + 1
+		var sat [5][]float64
+		for k := range sat {
+			sat[k] = make([]float64, stride*(ry1-ry0+1))
+		}
+		for y := 0; y < ry1-ry0; y++ {
+			var row [5]float64
+			for x := 0; x < w; x++ {
+				i := (y*w + x) * 3
+				r, g, b := float64(rgb[i]), float64(rgb[i+1]), float64(rgb[i+2])
+				l := 0.2126*r + 0.7152*g + 0.0722*b
+				row[0] += r
+				row[1] += g
+				row[2] += b
+				row[3] += l
+				row[4] += l * l
+				for k := range sat {
+					sat[k][(y+1)*stride+x+1] = sat[k][y*stride+x+1] + row[k]
+				}
+			}
+		}
+		// sum returns the total of table k over columns x0-x1 and rows
+		// y0-y1 inclusive, in stripe coordinates.
+		sum := func(k, x0, y0, x1, y1 int) float64 {
+			t := sat[k]
+			return t[(y1+1)*stride+x1+1] - t[y0*stride+x1+1] - t[(y1+1)*stride+x0] + t[y0*stride+x0]
+		}
+
+		out := make([]float32, w*(y1-y0)*3)
+		for y := y0; y < y1; y++ {
+			sy := y - ry0
+			top, bottom := max(0, sy-radius), min(ry1-ry0-1, sy+radius)
+			for x := 0; x < w; x++ {
+				left, right := max(0, x-radius), min(w-1, x+radius)
+				quadrants := [4][4]int{
+					{left, top, x, sy},
+					{x, top, right, sy},
+					{left, sy, x, bottom},
+					{x, sy, right, bottom},
+				}
+				best, bestVar := quadrants[0], -1.0
+				for _, q := range quadrants {
+					n := float64((q[2] - q[0] + 1) * (q[3] - q[1] + 1))
+					mean := sum(3, q[0], q[1], q[2], q[3]) / n
+					v := sum(4, q[0], q[1], q[2], q[3])/n - mean*mean
+					if bestVar < 0 || v < bestVar {
+						best, bestVar = q, v
+					}
+				}
+				n := float64((best[2] - best[0] + 1) * (best[3] - best[1] + 1))
+				o := ((y-y0)*w + x) * 3
+				for c := 0; c < 3; c++ {
+					out[o+c] = float32(sum(c, best[0], best[1], best[2], best[3]) / n)
+				}
+			}
+		}
+		if err := wand.ImportImagePixels(0, y0, uint(w), uint(y1-y0), "RGB", imagick.PIXEL_FLOAT, out); err != nil {
+			return fmt.Errorf("ImportImagePixels failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Layer stack.
+//
+// The session's Wand is the background; Layers are stacked on top of it in
+// order, each with its own offset, opacity and compose operator. Layers stay
+// separate (so they can be moved, re-blended or edited) until `flatten` merges
+// them into the background. The active layer is the target of ordinary
+// commands; index 0 always means the background.
+
+// Layer is a single overlay above the background image.
+type Layer struct {
+	Name    string
+	Wand    *imagick.MagickWand
+	X, Y    int
+	Opacity float64 // 0 (invisible) to 1 (opaque)
+	Compose imagick.CompositeOperator
+}
+
+// layerCommands lists the session-level commands implemented in this file.
+var layerCommands = map[string]bool{
+	"addLayer":    true,
+	"selectLayer": true,
+	"layerProps":  true,
+	"moveLayer":   true,
+	"removeLayer": true,
+	"layers":      true,
+	"flatten":     true,
+}
+
+// target returns the wand ordinary commands should edit: the active layer or
+// the background.
+func (s *Session) target() *imagick.MagickWand {
+	if s.ActiveLayer > 0 && s.ActiveLayer <= len(s.Layers) {
+		return s.Layers[s.ActiveLayer-1].Wand
+	}
+	return s.Wand
+}
+
+// clearLayers destroys all layers and selects the background again.
+func (s *Session) clearLayers() {
+	for _, l := range s.Layers {
+		l.Wand.Destroy()
+	}
+	s.Layers = nil
+	s.ActiveLayer = 0
+}
+
+// activeLayer returns the selected layer or an error when the background is selected.
+func (s *Session) activeLayer() (*Layer, error) {
+	if s.ActiveLayer == 0 || s.ActiveLayer > len(s.Layers) {
+		return nil, fmt.Errorf("the background is selected; use selectLayer to pick a layer")
+	}
+	return s.Layers[s.ActiveLayer-1], nil
+}
+
+// Display returns the image to preview and save: the background itself when
+// there are no layers, otherwise a composite of every layer onto each frame of
+// the background. The composite is owned by the session and rebuilt on every
+// call; callers must not destroy it.
+func (s *Session) Display() *imagick.MagickWand {
+	if s.display != nil {
+		s.display.Destroy()
+		s.display = nil
+	}
+	if s.Wand == nil || len(s.Layers) == 0 {
+		return s.Wand
+	}
+	composite, err := s.compositeLayers()
+	if err != nil {
+		debugf("layer composite failed: %v", err)
+		return s.Wand
+	}
+	s.display = composite
+	return composite
+}
+
+// compositeLayers returns a new wand with all layers blended onto every frame
+// of the background, positioned on the background's current frame.
+func (s *Session) compositeLayers() (*imagick.MagickWand, error) {
+	out := s.Wand.Clone()
+	current := int(s.Wand.GetIteratorIndex())
+	for _, l := range s.Layers {
+		src, err := layerSource(l)
+		if err != nil {
+			out.Destroy()
+			return nil, err
+		}
+		out.ResetIterator()
+		for out.NextImage() {
+			if err := out.CompositeImage(src, l.Compose, true, l.X, l.Y); err != nil {
+				src.Destroy()
+				out.Destroy()
+				return nil, fmt.Errorf("layer %s: %w", l.Name, err)
+			}
+		}
+		src.Destroy()
+	}
+	out.SetIteratorIndex(current)
+	return out, nil
+}
+
+// layerSource returns a copy of the layer's image with its opacity applied to
+// the alpha channel.
+func layerSource(l *Layer) (*imagick.MagickWand, error) {
+	src := l.Wand.GetImage()
+	if l.Opacity >= 1 {
+		return src, nil
+	}
+	if err := src.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET); err != nil {
+		src.Destroy()
+		return nil, fmt.Errorf("layer %s: failed to enable alpha: %w", l.Name, err)
+	}
+	prev := src.SetImageChannelMask(imagick.CHANNEL_ALPHA)
+	err := src.EvaluateImage(imagick.EVAL_OP_MULTIPLY, l.Opacity)
+	src.SetImageChannelMask(prev)
+	if err != nil {
+		src.Destroy()
+		return nil, fmt.Errorf("layer %s: failed to apply opacity: %w", l.Name, err)
+	}
+	return src, nil
+}
+
+// applyLayerCommand runs one of the layerCommands with normalized arguments.
+func (s *Session) applyLayerCommand(commandName string, args []string) error {
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+
+	switch commandName {
+	case "addLayer":
+		src, err := LoadImage(arg(0))
+		if err != nil {
+			return fmt.Errorf("failed to read layer image: %w", err)
+		}
+		// Layers are single images; keep only the first frame of animated sources.
+		src.SetFirstIterator()
+		img := src.GetImage()
+		src.Destroy()
+		l := &Layer{Name: filepath.Base(arg(0)), Wand: img, Opacity: 1, Compose: imagick.COMPOSITE_OP_OVER}
+		if err := setLayerProps(l, arg(1), arg(2), arg(3), arg(4)); err != nil {
+			img.Destroy()
+			return err
+		}
+		s.Layers = append(s.Layers, l)
+		s.ActiveLayer = len(s.Layers)
+		fmt.Printf("Added layer %d (%s); commands now apply to it\n", s.ActiveLayer, l.Name)
+		return nil
+
+	case "selectLayer":
+		idx, err := strconv.Atoi(arg(0))
+		if err != nil || idx < 0 || idx > len(s.Layers) {
+			return fmt.Errorf("layer index must be between 0 (background) and %d", len(s.Layers))
+		}
+		s.ActiveLayer = idx
+		return nil
+
+	case "layerProps":
+		l, err := s.activeLayer()
+		if err != nil {
+			return err
+		}
+		return setLayerProps(l, arg(0), arg(1), arg(2), arg(3))
+
+	case "moveLayer":
+		l, err := s.activeLayer()
+		if err != nil {
+			return err
+		}
+		pos, err := strconv.Atoi(arg(0))
+		if err != nil || pos < 1 || pos > len(s.Layers) {
+			return fmt.Errorf("position must be between 1 (just above the background) and %d (top)", len(s.Layers))
+		}
+		rest := append(s.Layers[:s.ActiveLayer-1:s.ActiveLayer-1], s.Layers[s.ActiveLayer:]...)
+		s.Layers = append(rest[:pos-1:pos-1], append([]*Layer{l}, rest[pos-1:]...)...)
+		s.ActiveLayer = pos
+		return nil
+
+	case "removeLayer":
+		l, err := s.activeLayer()
+		if err != nil {
+			return err
+		}
+		l.Wand.Destroy()
+		s.Layers = append(s.Layers[:s.ActiveLayer-1], s.Layers[s.ActiveLayer:]...)
+		s.ActiveLayer = 0
+		return nil
+
+	case "layers":
+		marker := func(i int) string {
+			if i == s.ActiveLayer {
+				return "*"
+			}
+			return " "
+		}
+		fmt.Printf("%s 0: background %dx%d\n", marker(0), s.Wand.GetImageWidth(), s.Wand.GetImageHeight())
+		for i, l := range s.Layers {
+			compose, _ := mapNumericToEnumName("composeOperator", int64(l.Compose))
+			fmt.Printf("%s %d: %s %dx%d at %+d%+d opacity %.2f %s\n", marker(i+1), i+1, l.Name,
+				l.Wand.GetImageWidth(), l.Wand.GetImageHeight(), l.X, l.Y, l.Opacity, compose)
+		}
+		return nil
+
+	case "flatten":
+		if len(s.Layers) == 0 {
+			return fmt.Errorf("there are no layers to flatten")
+		}
+		flat, err := s.compositeLayers()
+		if err != nil {
+			return err
+		}
+		defer flat.Destroy()
+		current := int(s.Wand.GetIteratorIndex())
+		if err := replaceWandImages(s.Wand, flat); err != nil {
+			return err
+		}
+		s.Wand.SetIteratorIndex(current)
+		s.clearLayers()
+		return nil
+	}
+	return fmt.Errorf("unknown layer command: %s", commandName)
+}
+
+// setLayerProps updates a layer from normalized x, y, opacity and compose
+// arguments; empty arguments keep the current value.
+func setLayerProps(l *Layer, x, y, opacity, compose string) error {
+	if x != "" {
+		v, err := strconv.Atoi(x)
+		if err != nil {
+			return fmt.Errorf("invalid x: %w", err)
+		}
+		l.X = v
+	}
+	if y != "" {
+		v, err := strconv.Atoi(y)
+		if err != nil {
+			return fmt.Errorf("invalid y: %w", err)
+		}
+		l.Y = v
+	}
+	if opacity != "" {
+		v, err := strconv.ParseFloat(opacity, 64)
+		if err != nil || v < 0 || v > 1 {
+			return fmt.Errorf("opacity must be between 0 and 1")
+		}
+		l.Opacity = v
+	}
+	if compose != "" {
+		v, err := strconv.Atoi(compose)
+		if err != nil {
+			return fmt.Errorf("invalid compose operator: %w", err)
+		}
+		l.Compose = imagick.CompositeOperator(v)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Interactive levels.
+//
+// levelsUI sets the black point, gamma and white point with the keyboard
+// while watching the result. The histogram of the image is drawn as text
+// with a marker under it for each value, and the preview is redrawn with the
+// levels applied after every change, from a reduced copy so it keeps up on
+// large images. Enter commits the values as an ordinary level step, so they
+// land in the history, recipes and macros like a typed level command; Esc
+// leaves the image unchanged.
+
+// levelsPreviewSize bounds the longer side of the copy previewed while
+// adjusting.
+const levelsPreviewSize = 800
+
+// Size of the text histogram: each column covers 256/levelsHistWidth input
+// levels, and each row eight steps of a block character.
+const (
+	levelsHistWidth  = 64
+	levelsHistHeight = 8
+)
+
+// levelsMarkers names the markers in selection order.
+var levelsMarkers = []string{"black", "gamma", "white"}
+
+// levelsState is the black and white points in 0-255 and the position of the
+// gamma marker between them, as a fraction (0.5 is gamma 1).
+type levelsState struct {
+	black, white int
+	mid          float64
+	selected     int
+}
+
+// gamma returns the gamma that maps the midtone marker to middle gray.
+func (l levelsState) gamma() float64 {
+	return math.Round(math.Log(l.mid)/math.Log(0.5)*100) / 100
+}
+
+// move shifts the selected marker by steps levels (hundredths for gamma),
+// keeping the markers in order.
+func (l *levelsState) move(steps int) {
+	switch l.selected {
+	case 0:
+		l.black = min(max(l.black+steps, 0), l.white-2)
+	case 1:
+		l.mid = min(max(l.mid+float64(steps)/100, 0.01), 0.99)
+	case 2:
+		l.white = max(min(l.white+steps, 255), l.black+2)
+	}
+}
+
+// args returns the level command arguments for the state: black and white
+// point as percentages, so they mean the same on every quantum depth.
+func (l levelsState) args() []string {
+	pct := func(v int) string {
+		return strconv.FormatFloat(math.Round(float64(v)*1000/255)/10, 'f', -1, 64) + "%"
+	}
+	return []string{pct(l.black), strconv.FormatFloat(l.gamma(), 'f', -1, 64), pct(l.white)}
+}
+
+// lumaHistogram returns the 256-bin luminance histogram of the current image
+// of wand.
+func lumaHistogram(wand *imagick.MagickWand) ([]int, error) {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	px, err := wand.ExportImagePixels(0, 0, w, h, "RGB", imagick.PIXEL_CHAR)
+	if err != nil {
+		return nil, fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	rgb, ok := px.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+	hist := make([]int, 256)
+	for i := 0; i+2 < len(rgb); i += 3 {
+		y := 0.2126*float64(rgb[i]) + 0.7152*float64(rgb[i+1]) + 0.0722*float64(rgb[i+2])
+		hist[min(255, int(y+0.5))]++
+	}
+	return hist, nil
+}
+
+// plotLevels draws the histogram with the markers of l below it; the
+// selected marker is shown in reverse video.
+func plotLevels(hist []int, l levelsState) string {
+	per := 256 / levelsHistWidth
+	cols := make([]int, levelsHistWidth)
+	for i, n := range hist {
+		cols[i/per] += n
+	}
+	// Scale to the tallest column that is not pure black or white, so a
+	// clipped end does not flatten the rest.
+	peak := 1
+	for i := 1; i < levelsHistWidth-1; i++ {
+		peak = max(peak, cols[i])
+	}
+	blocks := []rune(" ▁▂▃▄▅▆▇█")
+
+	var sb strings.Builder
+	for r := levelsHistHeight - 1; r >= 0; r-- {
+		sb.WriteString("  ")
+		for _, n := range cols {
+			eighths := min(n*levelsHistHeight*8/peak, levelsHistHeight*8) - r*8
+			sb.WriteRune(blocks[min(max(eighths, 0), 8)])
+		}
+		sb.WriteByte('\n')
+	}
+
+	pos := []int{
+		l.black / per,
+		int(float64(l.black)+float64(l.white-l.black)*l.mid) / per,
+		min(l.white/per, levelsHistWidth-1),
+	}
+	symbols := []string{"▲", "◆", "△"}
+	line := make([]string, levelsHistWidth)
+	for i := range line {
+		line[i] = " "
+	}
+	for i, p := range pos {
+		s := symbols[i]
+		if i == l.selected {
+			s = "\x1b[7m" + s + "\x1b[0m"
+		}
+		line[p] = s
+	}
+	sb.WriteString("  " + strings.Join(line, "") + "\n")
+	fmt.Fprintf(&sb, "  black %3d   gamma %4.2f   white %3d   [%s]\n", l.black, l.gamma(), l.white, levelsMarkers[l.selected])
+	sb.WriteString("  ←/→ move (Shift: x10)  ↑/↓ or Tab select  r reset  Enter apply  Esc cancel\n")
+	return sb.String()
+}
+
+// AdjustLevels runs the interactive levels mode on the current image of
+// wand, reading keys from reader, and returns the chosen level arguments.
+// ok is false when the user cancels. It needs a terminal.
+func AdjustLevels(reader *bufio.Reader, wand *imagick.MagickWand) (args []string, ok bool, err error) {
+	base := wand.GetImage()
+	defer base.Destroy()
+	w, h := base.GetImageWidth(), base.GetImageHeight()
+	if w == 0 || h == 0 {
+		return nil, false, fmt.Errorf("image has zero dimensions")
+	}
+	if long := max(w, h); long > levelsPreviewSize {
+		scale := float64(levelsPreviewSize) / float64(long)
+		if err := base.ThumbnailImage(max(1, uint(float64(w)*scale)), max(1, uint(float64(h)*scale))); err != nil {
+			return nil, false, fmt.Errorf("failed to scale preview: %w", err)
+		}
+	}
+	hist, err := lumaHistogram(base)
+	if err != nil {
+		return nil, false, err
+	}
+
+	restore, err := enableRawInput()
+	if err != nil {
+		return nil, false, fmt.Errorf("levelsUI needs a terminal: %w", err)
+	}
+	defer restore()
+
+	// Reserve the lines the preview and histogram take, so redrawing never
+	// scrolls and the saved cursor position stays valid.
+	lines := levelsHistHeight + 3
+	preview := PreviewSupported()
+	if preview {
+		_, rows, ok := previewGrid(base.GetImageWidth(), base.GetImageHeight())
+		if !ok {
+			rows = 0
+			preview = false
+		}
+		lines += rows + 1
+	}
+	fmt.Print(strings.Repeat("\n", lines))
+	fmt.Printf("\x1b[%dA\x1b7", lines)
+
+	_, quantumRange := imagick.GetQuantumRange()
+	q := float64(quantumRange)
+	state := levelsState{black: 0, white: 255, mid: 0.5}
+	draw := func() {
+		fmt.Print("\x1b8\x1b[J")
+		if preview || webPreview != nil {
+			shown := base.Clone()
+			if err := shown.LevelImage(float64(state.black)/255*q, state.gamma(), float64(state.white)/255*q); err != nil {
+				debugf("levels preview: %v", err)
+			}
+			if webPreview != nil {
+				if err := webPreview.Publish(shown); err != nil {
+					debugf("web preview publish failed: %v", err)
+				}
+			}
+			if preview {
+				PreviewWand(shown)
+			}
+			shown.Destroy()
+		}
+		fmt.Print(plotLevels(hist, state))
+	}
+	draw()
+
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return nil, false, err
+		}
+		switch r {
+		case '\r', '\n':
+			return state.args(), true, nil
+		case 0x03, 'q':
+			return nil, false, nil
+		case '\t':
+			state.selected = (state.selected + 1) % len(levelsMarkers)
+		case 'b':
+			state.selected = 0
+		case 'g':
+			state.selected = 1
+		case 'w':
+			state.selected = 2
+		case 'r':
+			state = levelsState{black: 0, white: 255, mid: 0.5, selected: state.selected}
+		case 'h':
+			state.move(-1)
+		case 'l':
+			state.move(1)
+		case 'H':
+			state.move(-10)
+		case 'L':
+			state.move(10)
+		case 0x1b:
+			if reader.Buffered() == 0 {
+				// A lone Esc cancels.
+				return nil, false, nil
+			}
+			seq := readEscapeSequence(reader)
+			// Shift+arrow arrives as ESC [1;2C.
+			step := 1
+			if strings.HasPrefix(seq, "[1;2") {
+				step = 10
+			}
+			switch seq[len(seq)-1] {
+			case 'C':
+				state.move(step)
+			case 'D':
+				state.move(-step)
+			case 'A':
+				state.selected = (state.selected + len(levelsMarkers) - 1) % len(levelsMarkers)
+			case 'B':
+				state.selected = (state.selected + 1) % len(levelsMarkers)
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		draw()
+	}
+}
+
+// readEscapeSequence reads the rest of a control sequence after ESC, up to
+// and including its final byte.
+func readEscapeSequence(reader *bufio.Reader) string {
+	var seq []byte
+	for reader.Buffered() > 0 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			break
+		}
+		seq = append(seq, b)
+		if len(seq) > 1 && b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+	if len(seq) == 0 {
+		return "\x1b"
+	}
+	return string(seq)
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Lossless JPEG transforms.
+//
+// Decoding a JPEG, rotating it and encoding it again loses quality every time.
+// jpegtran instead rearranges the compressed DCT blocks directly, so 90°
+// rotations and crops aligned to the block grid are exact. The result is kept
+// as a JPEG file next to the decoded image; as long as no other command is
+// applied, saving to a .jpg/.jpeg file copies those bytes instead of
+// re-encoding.
+
+// losslessCommands lists the session-level commands implemented in this file.
+var losslessCommands = map[string]bool{
+	"losslessRotate": true,
+	"losslessCrop":   true,
+}
+
+// isJPEGPath reports whether path has a JPEG file extension.
+func isJPEGPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// losslessSource returns the JPEG file whose bytes exactly match the current
+// image, or an error explaining why a lossless transform is not possible.
+func (s *Session) losslessSource() (string, error) {
+	if s.jpegFile != "" {
+		return s.jpegFile, nil
+	}
+	if len(s.Layers) > 0 {
+		return "", fmt.Errorf("lossless transforms are not available while the image has layers")
+	}
+	if len(s.History) > 0 {
+		return "", fmt.Errorf("lossless transforms need the unedited JPEG; apply them before other commands")
+	}
+	if s.Path == "" || !strings.EqualFold(s.Wand.GetImageFormat(), "JPEG") {
+		return "", fmt.Errorf("lossless transforms only work on JPEG files")
+	}
+	return s.Path, nil
+}
+
+// jpegBlockSize returns the iMCU size (the grid lossless crops snap to) from
+// the image's chroma sampling factors, e.g. 16x16 for 4:2:0 and 8x8 for 4:4:4.
+func (s *Session) jpegBlockSize() (int, int) {
+	w, h := 8, 8
+	sf := s.Wand.GetImageProperty("jpeg:sampling-factor")
+	first, _, _ := strings.Cut(sf, ",")
+	hs, vs, ok := strings.Cut(first, "x")
+	if !ok {
+		return w, h
+	}
+	if n, err := strconv.Atoi(hs); err == nil && n > 0 {
+		w = 8 * n
+	}
+	if n, err := strconv.Atoi(vs); err == nil && n > 0 {
+		h = 8 * n
+	}
+	return w, h
+}
+
+// runJpegtran runs jpegtran with args on src and returns the path of a new
+// temporary file holding the result.
+func runJpegtran(src string, args ...string) (string, error) {
+	if err := requireProgram("jpegtran", "libjpeg-turbo or libjpeg tools"); err != nil {
+		return "", err
+	}
+	out, err := createSessionTemp("lossless-*.jpg")
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	out.Close()
+	args = append(append([]string{"-copy", "all"}, args...), "-outfile", out.Name(), src)
+	cmd, err := externalCommand("jpegtran", args...)
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("jpegtran: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Name(), nil
+}
+
+// applyLosslessCommand runs losslessRotate or losslessCrop with normalized args.
+func (s *Session) applyLosslessCommand(commandName string, args []string) error {
+	if s.Wand == nil {
+		return fmt.Errorf("no image loaded")
+	}
+	if config.NoExec {
+		// jpegtran cannot run; fall back to the same transform on the decoded image.
+		fmt.Printf("note: %s re-encodes the image when external programs are disabled\n", commandName)
+		s.dropJPEGFile()
+		fallback := map[string]string{"losslessRotate": "rotate", "losslessCrop": "crop"}[commandName]
+		return ApplyCommand(s.Wand, fallback, args)
+	}
+	src, err := s.losslessSource()
+	if err != nil {
+		return err
+	}
+
+	var result string
+	switch commandName {
+	case "losslessRotate":
+		deg := args[0]
+		if deg != "90" && deg != "180" && deg != "270" {
+			return fmt.Errorf("losslessRotate supports 90, 180 or 270 degrees")
+		}
+		result, err = runJpegtran(src, "-perfect", "-rotate", deg)
+		if err != nil {
+			// Dimensions that are not a multiple of the block size cannot be
+			// rotated perfectly; drop the partial edge blocks instead.
+			result, err = runJpegtran(src, "-trim", "-rotate", deg)
+			if err != nil {
+				return err
+			}
+			fmt.Println("note: partial edge blocks were trimmed to keep the rotation lossless")
+		}
+
+	case "losslessCrop":
+		w, _ := strconv.Atoi(args[0])
+		h, _ := strconv.Atoi(args[1])
+		x, _ := strconv.Atoi(args[2])
+		y, _ := strconv.Atoi(args[3])
+		if w <= 0 || h <= 0 {
+			return fmt.Errorf("crop width and height must be positive")
+		}
+		bw, bh := s.jpegBlockSize()
+		if x%bw != 0 || y%bh != 0 {
+			fmt.Printf("note: offset snapped to the %dx%d block grid (%d,%d)\n", bw, bh, x-x%bw, y-y%bh)
+		}
+		result, err = runJpegtran(src, "-crop", fmt.Sprintf("%dx%d+%d+%d", w, h, x, y))
+		if err != nil {
+			return err
+		}
+	}
+
+	wand, err := LoadImage(result)
+	if err != nil {
+		os.Remove(result)
+		return fmt.Errorf("read transformed JPEG: %w", err)
+	}
+	s.Wand.Destroy()
+	s.Wand = wand
+	s.dropJPEGFile()
+	s.jpegFile = result
+	return nil
+}
+
+// dropJPEGFile forgets (and deletes) the lossless JPEG once it no longer
+// matches the current image.
+func (s *Session) dropJPEGFile() {
+	if s.jpegFile != "" {
+		os.Remove(s.jpegFile)
+		s.jpegFile = ""
+	}
+}
+
+// Save writes the displayed image to path. When the image is the untouched
+// result of lossless JPEG transforms and path is a JPEG, the transformed file
+// is copied byte for byte instead of being re-encoded, after the same backup
+// WriteWand makes.
+func (s *Session) Save(path string) error {
+	var err error
+	if s.jpegFile != "" && isJPEGPath(path) {
+		if err = backupBeforeWrite(path); err == nil {
+			err = copyFile(s.jpegFile, path)
+		}
+	} else {
+		err = WriteWand(s.Display(), path)
+	}
+	if err == nil {
+		s.modified = false
+	}
+	return err
+}
+
+// copyFile copies src to dst, replacing dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
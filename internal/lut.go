@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Color lookup tables.
+//
+// The lut command applies a 3D color LUT, the format film emulations and
+// grades are shared in. A HALD CLUT image is used as is; a .cube file (Adobe /
+// Resolve format, 1D or 3D) is sampled into a HALD first, so both go through
+// ImageMagick's HaldClutImage.
+
+// cubeLUT is a parsed .cube file. table holds size^3 RGB triples (3D, red
+// changing fastest) or size triples (1D).
+type cubeLUT struct {
+	size      int
+	is3D      bool
+	domainMin [3]float64
+	domainMax [3]float64
+	table     []float64
+	path      string
+}
+
+// parseCube reads a .cube file.
+func parseCube(path string) (*cubeLUT, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lut := &cubeLUT{domainMax: [3]float64{1, 1, 1}, path: path}
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		bad := func() error {
+			return fmt.Errorf("%s:%d: invalid line %q", filepath.Base(path), lineNo, line)
+		}
+		floats := func(vals []string, n int) ([]float64, error) {
+			if len(vals) != n {
+				return nil, bad()
+			}
+			out := make([]float64, n)
+			for i, v := range vals {
+				x, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, bad()
+				}
+				out[i] = x
+			}
+			return out, nil
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "TITLE":
+		case "LUT_3D_SIZE", "LUT_1D_SIZE":
+			if len(fields) != 2 {
+				return nil, bad()
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 2 || n > 65536 {
+				return nil, bad()
+			}
+			lut.size = n
+			lut.is3D = strings.EqualFold(fields[0], "LUT_3D_SIZE")
+			if lut.is3D && n > 256 {
+				return nil, bad()
+			}
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			v, err := floats(fields[1:], 3)
+			if err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(fields[0], "DOMAIN_MIN") {
+				copy(lut.domainMin[:], v)
+			} else {
+				copy(lut.domainMax[:], v)
+			}
+		case "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			// Resolve's form of the domain, the same for all channels.
+			v, err := floats(fields[1:], 2)
+			if err != nil {
+				return nil, err
+			}
+			lut.domainMin = [3]float64{v[0], v[0], v[0]}
+			lut.domainMax = [3]float64{v[1], v[1], v[1]}
+		default:
+			v, err := floats(fields, 3)
+			if err != nil {
+				return nil, err
+			}
+			lut.table = append(lut.table, v...)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if lut.size == 0 {
+		return nil, fmt.Errorf("%s: missing LUT_3D_SIZE or LUT_1D_SIZE", filepath.Base(path))
+	}
+	want := lut.size
+	if lut.is3D {
+		want = lut.size * lut.size * lut.size
+	}
+	if len(lut.table) != want*3 {
+		return nil, fmt.Errorf("%s: expected %d entries, found %d", filepath.Base(path), want, len(lut.table)/3)
+	}
+	for c := 0; c < 3; c++ {
+		if lut.domainMax[c] <= lut.domainMin[c] {
+			return nil, fmt.Errorf("%s: empty domain", filepath.Base(path))
+		}
+	}
+	return lut, nil
+}
+
+// lookup returns the output for the input color rgb (0-1), interpolating
+// linearly between table entries.
+func (l *cubeLUT) lookup(rgb [3]float64) [3]float64 {
+	// pos converts channel c of the input to a fractional table index.
+	pos := func(c int) (int, float64) {
+		v := (rgb[c] - l.domainMin[c]) / (l.domainMax[c] - l.domainMin[c]) * float64(l.size-1)
+		v = math.Max(0, math.Min(float64(l.size-1), v))
+		i := min(int(v), l.size-2)
+		return i, v - float64(i)
+	}
+	var out [3]float64
+	if !l.is3D {
+		for c := 0; c < 3; c++ {
+			i, t := pos(c)
+			out[c] = l.table[i*3+c]*(1-t) + l.table[(i+1)*3+c]*t
+		}
+		return out
+	}
+
+	ri, rt := pos(0)
+	gi, gt := pos(1)
+	bi, bt := pos(2)
+	n := l.size
+	for corner := 0; corner < 8; corner++ {
+		dr, dg, db := corner&1, corner>>1&1, corner>>2&1
+		w := pick(dr, rt) * pick(dg, gt) * pick(db, bt)
+		if w == 0 {
+			continue
+		}
+		idx := ((bi+db)*n*n + (gi+dg)*n + ri + dr) * 3
+		for c := 0; c < 3; c++ {
+			out[c] += w * l.table[idx+c]
+		}
+	}
+	return out
+}
+
+// pick is the weight of the lower (d 0) or upper (d 1) neighbor at fraction t.
+func pick(d int, t float64) float64 {
+	if d == 1 {
+		return t
+	}
+	return 1 - t
+}
+
+// haldLevelFor returns the HALD level whose grid is at least as fine as a
+// LUT with size entries per channel.
+func haldLevelFor(size int) int {
+	level := int(math.Ceil(math.Sqrt(float64(size))))
+	return max(2, min(level, 12))
+}
+
+// toHald samples the LUT into a HALD CLUT image of the given level.
+func (l *cubeLUT) toHald(level int) (*imagick.MagickWand, error) {
+	cube := level * level
+	side := uint(level * cube)
+	pixels := make([]float64, cube*cube*cube*3)
+	for i := 0; i < cube*cube*cube; i++ {
+		in := [3]float64{
+			float64(i%cube) / float64(cube-1),
+			float64(i/cube%cube) / float64(cube-1),
+			float64(i/(cube*cube)) / float64(cube-1),
+		}
+		out := l.lookup(in)
+		for c := 0; c < 3; c++ {
+			pixels[i*3+c] = math.Max(0, math.Min(1, out[c]))
+		}
+	}
+	hald := imagick.NewMagickWand()
+	if err := hald.ConstituteImage(side, side, "RGB", imagick.PIXEL_DOUBLE, pixels); err != nil {
+		hald.Destroy()
+		return nil, fmt.Errorf("failed to build HALD image from %s: %w", filepath.Base(l.path), err)
+	}
+	return hald, nil
+}
+
+// LoadLUT returns the HALD CLUT image for path: a .cube file, sampled into a
+// HALD, or a HALD image (any file or buffer ImageMagick reads, including the
+// identity "hald:8").
+func LoadLUT(path string) (*imagick.MagickWand, error) {
+	if strings.EqualFold(filepath.Ext(path), ".cube") {
+		lut, err := parseCube(path)
+		if err != nil {
+			return nil, err
+		}
+		size := lut.size
+		if !lut.is3D {
+			size = 64
+		}
+		return lut.toHald(haldLevelFor(size))
+	}
+	hald := imagick.NewMagickWand()
+	if err := hald.ReadImage(path); err != nil {
+		hald.Destroy()
+		return nil, fmt.Errorf("failed to read LUT %s: %w", path, err)
+	}
+	if _, err := haldLevel(hald); err != nil {
+		hald.Destroy()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return hald, nil
+}
+
+// haldLevel returns the level of a HALD CLUT image, which is level^3 pixels
+// square.
+func haldLevel(hald *imagick.MagickWand) (int, error) {
+	w, h := hald.GetImageWidth(), hald.GetImageHeight()
+	level := int(math.Round(math.Cbrt(float64(w))))
+	if w != h || level < 2 || uint(level*level*level) != w {
+		return 0, fmt.Errorf("not a HALD CLUT image (%dx%d; expected level^3 pixels square, e.g. 512x512)", w, h)
+	}
+	return level, nil
+}
+
+// ApplyLUT maps the colors of the current image of wand through the HALD
+// CLUT. strength (0-1) blends between the original colors (0) and the full
+// LUT (1) by blending the table itself with the identity. Alpha is kept.
+func ApplyLUT(wand, hald *imagick.MagickWand, strength float64) error {
+	if strength <= 0 {
+		return nil
+	}
+	if strength < 1 {
+		level, err := haldLevel(hald)
+		if err != nil {
+			return err
+		}
+		cube := level * level
+		side := uint(level * cube)
+		pixels, err := hald.ExportImagePixels(0, 0, side, side, "RGB", imagick.PIXEL_DOUBLE)
+		if err != nil {
+			return fmt.Errorf("failed to read LUT: %w", err)
+		}
+		values := pixels.([]float64)
+		for i := 0; i < cube*cube*cube; i++ {
+			identity := [3]int{i % cube, i / cube % cube, i / (cube * cube)}
+			for c := 0; c < 3; c++ {
+				id := float64(identity[c]) / float64(cube-1)
+				values[i*3+c] = id + strength*(values[i*3+c]-id)
+			}
+		}
+		if err := hald.ImportImagePixels(0, 0, side, side, "RGB", imagick.PIXEL_DOUBLE, values); err != nil {
+			return fmt.Errorf("failed to blend LUT: %w", err)
+		}
+	}
+	prev := wand.SetImageChannelMask(imagick.CHANNEL_RED | imagick.CHANNEL_GREEN | imagick.CHANNEL_BLUE)
+	err := wand.HaldClutImage(hald)
+	wand.SetImageChannelMask(prev)
+	if err != nil {
+		return fmt.Errorf("failed to apply LUT: %w", err)
+	}
+	return nil
+}
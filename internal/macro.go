@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Keyboard macros.
+//
+// Pressing 'r' starts recording: every command applied afterwards is captured
+// until 'r' is pressed again, when the macro is named and saved. 'p' replays a
+// saved macro on the current image. Macros are stored as recipe files under
+// the macros directory of the termagick config dir, so they can also be used
+// with `termagick apply --recipe` or edited by hand.
+
+// macroDir returns the directory macros are stored in.
+func macroDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "macros"), nil
+}
+
+// macroPath returns the file a macro named name is stored in.
+func macroPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid macro name %q", name)
+	}
+	dir, err := macroDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".tmk"), nil
+}
+
+// SaveMacro writes steps as a recipe file for the named macro.
+func SaveMacro(name string, steps []RecipeStep) error {
+	path, err := macroPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create macro dir: %w", err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# termagick macro %s\n", name)
+	for _, step := range steps {
+		b.WriteString(step.String())
+		b.WriteString("\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("write macro: %w", err)
+	}
+	return nil
+}
+
+// LoadMacro reads the named macro.
+func LoadMacro(name string) ([]RecipeStep, error) {
+	path, err := macroPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return LoadRecipe(path)
+}
+
+// ListMacros returns the names of the saved macros, sorted.
+func ListMacros() ([]string, error) {
+	dir, err := macroDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read macro dir: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".tmk") {
+			names = append(names, strings.TrimSuffix(e.Name(), ".tmk"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// StartRecording begins capturing applied commands into a new macro.
+func (s *Session) StartRecording() {
+	s.recording = true
+	s.recorded = nil
+}
+
+// StopRecording ends capturing and returns the recorded steps.
+func (s *Session) StopRecording() []RecipeStep {
+	steps := s.recorded
+	s.recording = false
+	s.recorded = nil
+	return steps
+}
+
+// Recording reports whether a macro is being recorded.
+func (s *Session) Recording() bool {
+	return s.recording
+}
+
+// PlayMacro applies the steps of a macro in order through the session, so
+// they honor the selected layer and the all-frames setting. It stops at the
+// first failing step.
+func (s *Session) PlayMacro(steps []RecipeStep) error {
+	for i, step := range steps {
+		normArgs, err := NormalizeArgs(s.Store, step.Command, step.Args)
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Command, err)
+		}
+		if err := s.Apply(step.Command, normArgs); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Command, err)
+		}
+	}
+	return nil
+}
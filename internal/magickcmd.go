@@ -0,0 +1,580 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Translation of the session's operation history into an equivalent
+// ImageMagick `magick` command line, so a recipe prototyped interactively can
+// be pasted into scripts that do not have termagick installed.
+
+// MagickCommandLine builds a `magick` invocation that reproduces the applied
+// steps on inputPath and writes the result to output. Steps that do not modify
+// the image (identify, histogram) are skipped; steps without a CLI equivalent
+// produce an error naming the command.
+func MagickCommandLine(inputPath string, steps []RecipeStep, output string) (string, error) {
+	parts := []string{"magick"}
+
+	// A leading makeGif or stack step provides the input images itself.
+	if len(steps) > 0 && (steps[0].Command == "makeGif" || steps[0].Command == "stack") {
+		var opts []string
+		var err error
+		if steps[0].Command == "stack" {
+			// There are no images yet for stack to replace.
+			opts, err = stackOptions(steps[0])
+		} else {
+			opts, err = magickOptions(steps[0])
+		}
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, opts...)
+		steps = steps[1:]
+	} else {
+		if inputPath == "" {
+			return "", fmt.Errorf("the current image has no source file to start from")
+		}
+		parts = append(parts, shellQuote(inputPath))
+	}
+
+	for _, step := range steps {
+		opts, err := magickOptions(step)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, opts...)
+	}
+	parts = append(parts, shellQuote(output))
+	return strings.Join(parts, " "), nil
+}
+
+// magickOptions returns the CLI options (already shell-quoted where needed)
+// for a single step whose arguments are in normalized form.
+func magickOptions(step RecipeStep) ([]string, error) {
+	a := step.Args
+	arg := func(i int) string {
+		if i < len(a) {
+			return a[i]
+		}
+		return ""
+	}
+	geom := func(x, y string) string { return x + "x" + y }
+	offset := func(x, y string) string { return signed(x) + signed(y) }
+
+	switch step.Command {
+	case "adaptiveBlur":
+		return []string{"-adaptive-blur", geom(arg(0), arg(1))}, nil
+	case "adaptiveResize":
+		return []string{"-adaptive-resize", geom(arg(0), arg(1)) + "!"}, nil
+	case "adaptiveSharpen":
+		return []string{"-adaptive-sharpen", geom(arg(0), arg(1))}, nil
+	case "adaptiveThreshold":
+		// -lat reads a raw offset in the quantum range and a percentage of
+		// it, as parseQuantum does.
+		return []string{"-lat", geom(arg(0), arg(1)) + signed(arg(2))}, nil
+	case "addNoise":
+		opts := []string{"+noise", enumOptionName("noiseType", arg(0))}
+		if arg(1) != "" {
+			opts = append([]string{"-attenuate", arg(1)}, opts...)
+		}
+		if idx, err := strconv.Atoi(arg(2)); err == nil && idx > 0 && idx < len(noiseChannels) {
+			opts = append(append([]string{"-channel", noiseChannels[idx][:1]}, opts...), "+channel")
+		}
+		return opts, nil
+	case "annotate":
+		if arg(6) == "true" {
+			return nil, fmt.Errorf("annotate with markup has no magick CLI equivalent")
+		}
+		var opts []string
+		if arg(1) != "" {
+			opts = append(opts, "-font", shellQuote(arg(1)))
+		}
+		opts = append(opts, "-pointsize", arg(2), "-fill", shellQuote(arg(5)), "-annotate", offset(arg(3), arg(4)), shellQuote(arg(0)))
+		return opts, nil
+	case "autoGamma":
+		return []string{"-auto-gamma"}, nil
+	case "autoLevel":
+		return []string{"-auto-level"}, nil
+	case "autoOrient":
+		return []string{"-auto-orient"}, nil
+	case "blackThreshold":
+		return []string{"-black-threshold", shellQuote(arg(0))}, nil
+	case "blueShift":
+		return []string{"-blue-shift", arg(0)}, nil
+	case "blur":
+		return []string{"-blur", geom(arg(0), arg(1))}, nil
+	case "border":
+		height := arg(2)
+		if height == "" {
+			height = arg(1)
+		}
+		return []string{"-bordercolor", shellQuote(arg(0)), "-border", geom(arg(1), height)}, nil
+	case "charcoal":
+		return []string{"-charcoal", geom(arg(0), arg(1))}, nil
+	case "chop":
+		return []string{"-chop", geom(arg(0), arg(1)) + offset(arg(2), arg(3)), "+repage"}, nil
+	case "clahe":
+		bins, clip := arg(2), arg(3)
+		if bins == "" {
+			bins = "128"
+		}
+		if clip == "" {
+			clip = "3"
+		}
+		return []string{"-clahe", geom(arg(0), arg(1)) + "+" + bins + "+" + clip}, nil
+	case "colorize":
+		opacity, _ := strconv.ParseFloat(arg(1), 64)
+		return []string{"-fill", shellQuote(arg(0)), "-colorize", strconv.FormatFloat(opacity*100, 'f', -1, 64) + "%"}, nil
+	case "composite":
+		opts := []string{shellQuote(arg(0)), "-geometry", offset(arg(2), arg(3)), "-compose", enumOptionName("composeOperator", arg(1))}
+		if arg(4) == "false" {
+			opts = append(opts, "-define", "compose:clip-to-self=false")
+		}
+		return append(opts, "-composite"), nil
+	case "compress":
+		return []string{"-compress", enumOptionName("type", arg(0)), "-quality", arg(1)}, nil
+	case "contrast":
+		if arg(0) == "true" {
+			return []string{"-contrast"}, nil
+		}
+		return []string{"+contrast"}, nil
+	case "contrastStretch":
+		// termagick takes an upper percentile; the CLI counts the white clip from the top.
+		high, _ := strconv.ParseFloat(arg(1), 64)
+		return []string{"-contrast-stretch", arg(0) + "%x" + strconv.FormatFloat(100-high, 'f', -1, 64) + "%"}, nil
+	case "convolve":
+		spec, err := kernelSpec(arg(0))
+		if err != nil {
+			return nil, err
+		}
+		opts := []string{"-convolve", shellQuote(spec)}
+		if arg(1) == "true" {
+			opts = append([]string{"-define", shellQuote("convolve:scale=!")}, opts...)
+		}
+		return opts, nil
+	case "crop":
+		return []string{"-crop", geom(arg(0), arg(1)) + offset(arg(2), arg(3)), "+repage"}, nil
+	case "cropAspect":
+		return nil, fmt.Errorf("cropAspect depends on the image size and has no magick CLI equivalent; use crop")
+	case "curves":
+		pts, err := parseCurvePoints(arg(0))
+		if err != nil {
+			return nil, err
+		}
+		channel := curveChannels[0]
+		if arg(1) != "" {
+			idx, err := strconv.Atoi(arg(1))
+			if err != nil || idx < 0 || idx >= len(curveChannels) {
+				return nil, fmt.Errorf("invalid channel: %s", arg(1))
+			}
+			channel = curveChannels[idx]
+		}
+		return []string{"-channel", curveChannelArg(channel), "-fx", shellQuote(curveFX(pts)), "+channel"}, nil
+	case "descreen":
+		lpi, _ := strconv.ParseFloat(arg(0), 64)
+		dpi, _ := strconv.ParseFloat(arg(1), 64)
+		if arg(2) == "1" || lpi <= 0 || dpi <= 0 {
+			return nil, fmt.Errorf("descreen has a magick CLI equivalent only with the GAUSSIAN method and an explicit dpi")
+		}
+		sigma := "0x" + strconv.FormatFloat(dpi/lpi/2, 'g', 4, 64)
+		opts := []string{"-gaussian-blur", sigma}
+		if arg(3) != "false" {
+			opts = append(opts, "-unsharp", sigma+"+0.8+0.02")
+		}
+		return opts, nil
+	case "deskew":
+		return []string{"-deskew", arg(0)}, nil
+	case "despeckle":
+		return []string{"-despeckle"}, nil
+	case "distort":
+		// +distort is the bestfit form.
+		opt := "-distort"
+		if arg(2) == "true" {
+			opt = "+distort"
+		}
+		return []string{opt, enumOptionName("distortMethod", arg(0)), shellQuote(arg(1))}, nil
+	case "edge":
+		return []string{"-edge", arg(0)}, nil
+	case "editIn":
+		return nil, fmt.Errorf("%s has no magick CLI equivalent", step.Command)
+	case "emboss":
+		return []string{"-emboss", geom(arg(0), arg(1))}, nil
+	case "equalize":
+		return []string{"-equalize"}, nil
+	case "equalizeRGB":
+		return []string{"-channel", "R", "-equalize", "-channel", "G", "-equalize", "-channel", "B", "-equalize", "+channel"}, nil
+	case "enhance":
+		return []string{"-enhance"}, nil
+	case "extent":
+		if arg(0) == "0" || arg(1) == "0" {
+			return nil, fmt.Errorf("extent keeping a side depends on the image size and has no magick CLI equivalent")
+		}
+		gravity, err := optionalGravity(arg(2))
+		if err != nil {
+			return nil, err
+		}
+		background := arg(3)
+		if background == "" {
+			background = "white"
+		}
+		return []string{"-background", shellQuote(background), "-gravity", strings.ToLower(gravity), "-extent", geom(arg(0), arg(1)), "+gravity", "+repage"}, nil
+	case "flip":
+		return []string{"-flip"}, nil
+	case "floodfillPaint":
+		if arg(5) == "true" {
+			return nil, fmt.Errorf("floodfillPaint with invert has no magick CLI equivalent")
+		}
+		return []string{"-fuzz", arg(1) + "%", "-fill", shellQuote(arg(0)), "-bordercolor", shellQuote(arg(2)),
+			"-draw", shellQuote(fmt.Sprintf("color %s,%s filltoborder", arg(3), arg(4)))}, nil
+	case "flop":
+		return []string{"-flop"}, nil
+	case "frame":
+		height := arg(2)
+		if height == "" {
+			height = arg(1)
+		}
+		w, _ := strconv.Atoi(arg(1))
+		h, _ := strconv.Atoi(height)
+		bevel := strconv.Itoa(min(w, h) / 4)
+		outer, inner := arg(3), arg(4)
+		if outer == "" {
+			outer = bevel
+		}
+		if inner == "" {
+			inner = bevel
+		}
+		return []string{"-mattecolor", shellQuote(arg(0)), "-frame", geom(arg(1), height) + "+" + outer + "+" + inner}, nil
+	case "fx":
+		return []string{"-fx", shellQuote(arg(0))}, nil
+	case "gamma":
+		return []string{"-gamma", arg(0)}, nil
+	case "generateNoise", "testChart":
+		return nil, fmt.Errorf("%s has no magick CLI equivalent", step.Command)
+	case "grayscale":
+		return []string{"-colorspace", "Gray"}, nil
+	case "implode":
+		return []string{"-implode", arg(0)}, nil
+	case "kuwahara":
+		return []string{"-kuwahara", arg(0)}, nil
+	case "avgColor", "colors", "compare", "palette", "histogram", "identify", "inspectPixel", "frameTiming", "fftSpectrum", "scanCode":
+		// Informational only; nothing to reproduce.
+		return nil, nil
+	case "deepzoom", "eink", "icons", "ninePatch", "sliceSheet", "social", "tiles":
+		// Writes separate files; the image itself is unchanged.
+		return nil, nil
+	case "level":
+		return []string{"-level", levelArg(arg(0), arg(2), arg(1))}, nil
+	case "levelChannel":
+		idx, err := strconv.Atoi(arg(0))
+		if err != nil || idx < 0 || idx >= len(levelChannels) {
+			return nil, fmt.Errorf("invalid channel %q", arg(0))
+		}
+		channel := levelChannels[idx][:1]
+		return []string{"-channel", channel, "-level", levelArg(arg(1), arg(3), arg(2)), "+channel"}, nil
+	case "liquidRescale":
+		// The geometry offsets carry deltaX and rigidity, as integers. Both
+		// are always given so the command uses the deltaX of 1 applied here.
+		r := arg(2)
+		if r == "" {
+			r = "0"
+		}
+		if _, err := strconv.Atoi(r); err != nil {
+			return nil, fmt.Errorf("liquidRescale with a fractional rigidity has no magick CLI equivalent")
+		}
+		return []string{"-liquid-rescale", geom(arg(0), arg(1)) + "!+1" + signed(r)}, nil
+	case "losslessCrop":
+		return []string{"-crop", geom(arg(0), arg(1)) + offset(arg(2), arg(3)), "+repage"}, nil
+	case "losslessRotate":
+		return []string{"-rotate", arg(0)}, nil
+	case "lut":
+		if arg(1) != "" && arg(1) != "100" {
+			return nil, fmt.Errorf("lut with a strength below 100 has no magick CLI equivalent")
+		}
+		path := arg(0)
+		if strings.EqualFold(filepath.Ext(path), ".cube") {
+			// ImageMagick 7.0.10 and later read .cube files as HALD images.
+			path = "cube:" + path
+		}
+		return []string{shellQuote(path), "-hald-clut"}, nil
+	case "makeGif":
+		files, err := expandFileList(arg(0))
+		if err != nil {
+			return nil, err
+		}
+		opts := []string{"-delay", arg(1), "-loop", arg(2)}
+		for _, f := range files {
+			opts = append(opts, shellQuote(f))
+		}
+		return opts, nil
+	case "montage", "packSheet":
+		return nil, fmt.Errorf("%s has no magick CLI equivalent (use the separate montage tool)", step.Command)
+	case "mask":
+		idx, err := strconv.Atoi(arg(0))
+		if err != nil || idx < 0 || idx >= len(maskActions) {
+			return nil, fmt.Errorf("invalid mask action %q", arg(0))
+		}
+		switch action := maskActions[idx]; {
+		case action == "CLEAR":
+			return []string{"+write-mask"}, nil
+		case action == "LOAD" && (arg(2) == "" || arg(2) == "0") && arg(3) != "true" && !strings.HasPrefix(arg(1), "buffer:"):
+			return []string{"-write-mask", shellQuote(arg(1))}, nil
+		}
+		return nil, fmt.Errorf("mask %s has no magick CLI equivalent", maskActions[idx])
+	case "matchColors", "fftNotch":
+		return nil, fmt.Errorf("%s has no magick CLI equivalent", step.Command)
+	case "medianFilter":
+		return []string{"-statistic", "Median", geom(arg(0), arg(0))}, nil
+	case "metadata":
+		idx, err := strconv.Atoi(arg(0))
+		if err != nil || idx < 0 || idx >= len(metadataActions) {
+			return nil, fmt.Errorf("invalid metadata action %q", arg(0))
+		}
+		key := arg(1)
+		switch action := metadataActions[idx]; {
+		case action == "LIST":
+			// Listing only prints; there is nothing to reproduce.
+			return nil, nil
+		case action == "DELETE" && strings.HasPrefix(key, "profile:") && !strings.ContainsAny(key, "*?["):
+			return []string{"+profile", shellQuote(strings.TrimPrefix(key, "profile:"))}, nil
+		case action == "SET" && !strings.HasPrefix(key, "exif:"):
+			return []string{"-set", shellQuote(key), shellQuote(arg(2))}, nil
+		}
+		return nil, fmt.Errorf("metadata %s %s has no magick CLI equivalent", metadataActions[idx], key)
+	case "modulate":
+		return []string{"-modulate", arg(0) + "," + arg(1) + "," + arg(2)}, nil
+	case "monochrome":
+		return []string{"-type", "Bilevel"}, nil
+	case "motionBlur":
+		return []string{"-motion-blur", geom(arg(0), arg(1)) + signed(arg(2))}, nil
+	case "negate":
+		if arg(0) == "true" {
+			return []string{"+negate"}, nil
+		}
+		return []string{"-negate"}, nil
+	case "normalize":
+		return []string{"-normalize"}, nil
+	case "oilpaint":
+		return []string{"-paint", arg(0)}, nil
+	case "padToAspect":
+		return nil, fmt.Errorf("padToAspect depends on the image size and has no magick CLI equivalent; use extent")
+	case "pingPongFrames":
+		return []string{"-duplicate", "1,-2-1"}, nil
+	case "polaroid":
+		return []string{"-caption", shellQuote(arg(0)), "-polaroid", arg(1)}, nil
+	case "posterize":
+		if arg(1) == "true" {
+			return []string{"-dither", "Riemersma", "-posterize", arg(0)}, nil
+		}
+		return []string{"+dither", "-posterize", arg(0)}, nil
+	case "region":
+		idx, err := strconv.Atoi(arg(0))
+		if err != nil || idx < 0 || idx >= len(regionActions) {
+			return nil, fmt.Errorf("invalid region action %q", arg(0))
+		}
+		if regionActions[idx] == "CLEAR" {
+			return []string{"+region"}, nil
+		}
+		return []string{"-region", arg(1)}, nil
+	case "removeBackground":
+		// -draw takes absolute coordinates, so each corner is brought to 0,0
+		// in turn; the flips and flops cancel out.
+		fill := shellQuote("color 0,0 floodfill")
+		out := []string{"-alpha", "set", "-fuzz", arg(0) + "%", "-fill", "none",
+			"-draw", fill, "-flip", "-draw", fill, "-flop", "-draw", fill, "-flip", "-draw", fill, "-flop"}
+		if f := arg(1); f != "" && f != "0" {
+			out = append(out, "-channel", "A", "-blur", "0x"+f, "+channel")
+		}
+		return out, nil
+	case "resize", "resizeLinear":
+		filter := "Lanczos"
+		if arg(2) != "" {
+			filter = enumOptionName("filter", arg(2))
+		}
+		size, err := resizeGeometry(arg(0), arg(1))
+		if err != nil {
+			return nil, err
+		}
+		if step.Command == "resizeLinear" {
+			return []string{"-colorspace", "RGB", "-filter", filter, "-resize", size, "-colorspace", "sRGB"}, nil
+		}
+		return []string{"-filter", filter, "-resize", size}, nil
+	case "reverseFrames":
+		return []string{"-reverse"}, nil
+	case "roll":
+		return []string{"-roll", offset(arg(0), arg(1))}, nil
+	case "rotate":
+		if arg(2) == "true" {
+			return nil, fmt.Errorf("rotate with autoCrop has no magick CLI equivalent")
+		}
+		background := "black"
+		if arg(1) != "" {
+			background = arg(1)
+		}
+		return []string{"-background", shellQuote(background), "-rotate", arg(0)}, nil
+	case "rotationalBlur":
+		return []string{"-rotational-blur", arg(0)}, nil
+	case "sepia":
+		return []string{"-sepia-tone", arg(0) + "%"}, nil
+	case "setFrameDelay":
+		return []string{"-set", "delay", arg(0)}, nil
+	case "setFrameDispose":
+		if idx, err := strconv.Atoi(arg(0)); err == nil && idx >= 0 && idx < len(disposeMethods) {
+			return []string{"-set", "dispose", enumOptionName("", disposeMethods[idx])}, nil
+		}
+		return nil, fmt.Errorf("invalid dispose method %q", arg(0))
+	case "shadow":
+		background := arg(4)
+		if background == "" {
+			background = "none"
+		}
+		return []string{"\\(", "+clone", "-background", "black", "-shadow", geom(arg(0), arg(1)) + offset(arg(2), arg(3)), "\\)",
+			"+swap", "-background", shellQuote(background), "-layers", "merge", "+repage"}, nil
+	case "sharpen":
+		return []string{"-sharpen", geom(arg(0), arg(1))}, nil
+	case "shear":
+		yDegrees, background := "0", "black"
+		if arg(1) != "" {
+			yDegrees = arg(1)
+		}
+		if arg(2) != "" {
+			background = arg(2)
+		}
+		return []string{"-background", shellQuote(background), "-shear", geom(arg(0), yDegrees)}, nil
+	case "sketch":
+		return []string{"-sketch", geom(arg(0), arg(1)) + signed(arg(2))}, nil
+	case "solarize":
+		return []string{"-solarize", arg(0)}, nil
+	case "splice":
+		background := "white"
+		if arg(4) != "" {
+			background = arg(4)
+		}
+		return []string{"-background", shellQuote(background), "-splice", geom(arg(0), arg(1)) + offset(arg(2), arg(3)), "+repage"}, nil
+	case "spread":
+		return []string{"-spread", arg(0)}, nil
+	case "stack":
+		// stack replaces the images so far with the stacked files.
+		opts, err := stackOptions(step)
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{"-delete", "0--1"}, opts...), nil
+	case "strip":
+		return []string{"-strip"}, nil
+	case "swirl":
+		method := "Bilinear"
+		if arg(1) != "" {
+			method = enumOptionName("interpolate", arg(1))
+		}
+		return []string{"-interpolate", method, "-swirl", arg(0)}, nil
+	case "threshold":
+		return []string{"-threshold", arg(0)}, nil
+	case "transparent":
+		op := "-transparent"
+		if arg(2) == "true" {
+			op = "+transparent"
+		}
+		return []string{"-fuzz", arg(1) + "%", op, shellQuote(arg(0))}, nil
+	case "transpose":
+		return []string{"-transpose"}, nil
+	case "transverse":
+		return []string{"-transverse"}, nil
+	case "trim":
+		return []string{"-fuzz", arg(0) + "%", "-trim", "+repage"}, nil
+	case "unsharp":
+		return []string{"-unsharp", geom(arg(0), arg(1)) + signed(arg(2)) + signed(arg(3))}, nil
+	case "vignette":
+		return []string{"-vignette", geom(arg(0), arg(1)) + offset(arg(2), arg(3))}, nil
+	case "wave":
+		return []string{"-wave", geom(arg(0), arg(1))}, nil
+	}
+	return nil, fmt.Errorf("%s has no magick CLI equivalent", step.Command)
+}
+
+// enumOptionName converts a normalized (numeric) enum argument back into the
+// spelling ImageMagick's CLI expects, e.g. "COLOR_BURN" -> "ColorBurn".
+func enumOptionName(paramName, val string) string {
+	name := val
+	if id, err := strconv.ParseInt(val, 10, 64); err == nil {
+		if n, ok := mapNumericToEnumName(paramName, id); ok {
+			name = n
+		}
+	}
+	var sb strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		sb.WriteString(word[:1] + strings.ToLower(word[1:]))
+	}
+	return sb.String()
+}
+
+// levelArg builds a -level argument. ImageMagick reads both points as
+// percentages when either has a % sign, so a raw point mixed with a
+// percentage is converted to one.
+func levelArg(black, white, gamma string) string {
+	if strings.HasSuffix(black, "%") != strings.HasSuffix(white, "%") {
+		_, quantumRange := imagick.GetQuantumRange()
+		toPercent := func(v string) string {
+			if strings.HasSuffix(v, "%") {
+				return v
+			}
+			f, _ := strconv.ParseFloat(v, 64)
+			return formatNum(f/float64(quantumRange)*100) + "%"
+		}
+		black, white = toPercent(black), toPercent(white)
+	}
+	return black + "," + white + "," + gamma
+}
+
+// stackOptions returns the files of a stack step and the -evaluate-sequence
+// that combines them.
+func stackOptions(step RecipeStep) ([]string, error) {
+	arg := func(i int) string {
+		if i < len(step.Args) {
+			return step.Args[i]
+		}
+		return ""
+	}
+	files, err := expandFileList(arg(0))
+	if err != nil {
+		return nil, err
+	}
+	method := "mean"
+	if idx, err := strconv.Atoi(arg(1)); err == nil && idx >= 0 && idx < len(stackMethods) {
+		method = strings.ToLower(stackMethods[idx])
+	}
+	if method == "sum" {
+		method = "add"
+	}
+	var opts []string
+	for _, f := range files {
+		opts = append(opts, shellQuote(f))
+	}
+	return append(opts, "-evaluate-sequence", method), nil
+}
+
+// signed prefixes non-negative numbers with '+' as required by geometry offsets.
+func signed(v string) string {
+	if strings.HasPrefix(v, "-") || strings.HasPrefix(v, "+") {
+		return v
+	}
+	return "+" + v
+}
+
+// shellQuote single-quotes s for POSIX shells when it contains anything other
+// than characters that are safe unquoted.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./,:+=%@", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
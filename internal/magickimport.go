@@ -0,0 +1,742 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Import of ImageMagick CLI syntax, the inverse of MagickCommandLine.
+//
+// A subset of `magick` options (-resize 50%, -sharpen 0x1, -crop 100x100+10+10,
+// -modulate 110,120 ...) is translated into termagick commands so existing
+// ImageMagick users can reuse the option strings they already know. Several
+// options (percent resize, aspect-preserving geometry, quantum percentages)
+// depend on the image they are applied to, so translation happens one option
+// at a time against the current wand.
+
+// MagickOption is a single CLI option with its argument, e.g. {"-resize", "50%"}.
+// Arg is empty for options that take no argument.
+type MagickOption struct {
+	Name string
+	Arg  string
+}
+
+// magickArity lists the supported options and whether they take an argument.
+var magickArity = map[string]bool{
+	"-adaptive-blur":    true,
+	"-adaptive-resize":  true,
+	"-adaptive-sharpen": true,
+	"-annotate":         true,
+	"-auto-gamma":       false,
+	"-auto-level":       false,
+	"-auto-orient":      false,
+	"-background":       true,
+	"-black-threshold":  true,
+	"-blue-shift":       true,
+	"-blur":             true,
+	"-border":           true,
+	"-bordercolor":      true,
+	"-charcoal":         true,
+	"-chop":             true,
+	"-clahe":            true,
+	"-colorize":         true,
+	"-colorspace":       true,
+	"-contrast":         false,
+	"+contrast":         false,
+	"-contrast-stretch": true,
+	"-crop":             true,
+	"-deskew":           true,
+	"-despeckle":        false,
+	"-edge":             true,
+	"-emboss":           true,
+	"-enhance":          false,
+	"-equalize":         false,
+	"-extent":           true,
+	"-fill":             true,
+	"-filter":           true,
+	"-flip":             false,
+	"-flop":             false,
+	"-font":             true,
+	"-frame":            true,
+	"-fuzz":             true,
+	"-fx":               true,
+	"-gamma":            true,
+	"-gravity":          true,
+	"+gravity":          false,
+	"-implode":          true,
+	"-interpolate":      true,
+	"-kuwahara":         true,
+	"-level":            true,
+	"-mattecolor":       true,
+	"-median":           true,
+	"-modulate":         true,
+	"-monochrome":       false,
+	"-motion-blur":      true,
+	"-negate":           false,
+	"+negate":           false,
+	"-normalize":        false,
+	"-paint":            true,
+	"-pointsize":        true,
+	"-posterize":        true,
+	"-region":           true,
+	"+region":           false,
+	"+repage":           false,
+	"-resize":           true,
+	"-roll":             true,
+	"-rotate":           true,
+	"-rotational-blur":  true,
+	"-sepia-tone":       true,
+	"-sharpen":          true,
+	"-shear":            true,
+	"-sketch":           true,
+	"-solarize":         true,
+	"-splice":           true,
+	"-spread":           true,
+	"-strip":            false,
+	"-swirl":            true,
+	"-threshold":        true,
+	"-transparent":      true,
+	"+transparent":      true,
+	"-transpose":        false,
+	"-transverse":       false,
+	"-trim":             false,
+	"-unsharp":          true,
+	"-vignette":         true,
+	"-wave":             true,
+	"-write-mask":       true,
+	"+write-mask":       false,
+}
+
+// ParseMagickArgs splits an option string such as "-resize 50% -sharpen 0x1"
+// into options. Quotes group arguments containing spaces (e.g. -annotate text).
+// Unknown options are reported immediately so typos fail before any work.
+func ParseMagickArgs(s string) ([]MagickOption, error) {
+	fields, err := splitRecipeLine(s)
+	if err != nil {
+		return nil, err
+	}
+	var opts []MagickOption
+	for i := 0; i < len(fields); i++ {
+		name := fields[i]
+		takesArg, ok := magickArity[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported magick option %q", name)
+		}
+		opt := MagickOption{Name: name}
+		if takesArg {
+			// -annotate takes a geometry and the text.
+			if name == "-annotate" {
+				if i+2 >= len(fields) {
+					return nil, fmt.Errorf("%s requires a geometry and text", name)
+				}
+				opt.Arg = fields[i+1] + " " + quoteRecipeArg(fields[i+2])
+				i += 2
+			} else {
+				if i+1 >= len(fields) {
+					return nil, fmt.Errorf("%s requires an argument", name)
+				}
+				opt.Arg = fields[i+1]
+				i++
+			}
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+// magickSettings holds the CLI settings that influence later operators
+// (-fill, -font, -pointsize, -fuzz, -filter, -interpolate, -bordercolor,
+// -mattecolor, -background, -gravity), as in ImageMagick itself.
+type magickSettings struct {
+	fill        string
+	font        string
+	pointsize   string
+	fuzz        string
+	filter      string
+	interpolate string
+	bordercolor string
+	mattecolor  string
+	background  string
+	// gravity is one of cropGravities; -extent places the image at the
+	// top left without one.
+	gravity string
+}
+
+// ApplyMagickArgs translates and applies the options to the wand in order,
+// normalizing each resulting step through the metadata store. It returns the
+// steps that were applied so callers can record or print them.
+func ApplyMagickArgs(store *MetaStore, wand *imagick.MagickWand, opts []MagickOption) ([]RecipeStep, error) {
+	settings := magickSettings{fill: "black", pointsize: "12", fuzz: "0", bordercolor: "#dfdfdf", mattecolor: "#bdbdbd", background: "white", gravity: "NORTHWEST"}
+	var applied []RecipeStep
+	for _, opt := range opts {
+		step, ok, err := magickToStep(wand, &settings, opt)
+		if err != nil {
+			return applied, withExitCode(exitValidation, fmt.Errorf("%s %s: %w", opt.Name, opt.Arg, err))
+		}
+		if !ok {
+			continue
+		}
+		if err := ApplyRecipe(store, wand, []RecipeStep{step}); err != nil {
+			return applied, fmt.Errorf("%s %s: %w", opt.Name, opt.Arg, err)
+		}
+		applied = append(applied, step)
+	}
+	return applied, nil
+}
+
+// magickToStep converts one option into the equivalent termagick command.
+// ok is false for settings and no-op options, which update state only.
+func magickToStep(wand *imagick.MagickWand, settings *magickSettings, opt MagickOption) (RecipeStep, bool, error) {
+	step := func(name string, args ...string) (RecipeStep, bool, error) {
+		return RecipeStep{Command: name, Args: args}, true, nil
+	}
+	// radiusSigma parses "RxS" arguments; a missing sigma defaults to 1 as in ImageMagick.
+	radiusSigma := func(name string) (RecipeStep, bool, error) {
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		sigma := 1.0
+		if g.hasHeight {
+			sigma = g.height
+		}
+		return step(name, formatNum(g.width), formatNum(sigma))
+	}
+
+	switch opt.Name {
+	// Settings.
+	case "-fill":
+		settings.fill = opt.Arg
+		return RecipeStep{}, false, nil
+	case "-font":
+		settings.font = opt.Arg
+		return RecipeStep{}, false, nil
+	case "-pointsize":
+		settings.pointsize = opt.Arg
+		return RecipeStep{}, false, nil
+	case "-fuzz":
+		settings.fuzz = strings.TrimSuffix(opt.Arg, "%")
+		return RecipeStep{}, false, nil
+	case "-bordercolor":
+		settings.bordercolor = opt.Arg
+		return RecipeStep{}, false, nil
+	case "-mattecolor":
+		settings.mattecolor = opt.Arg
+		return RecipeStep{}, false, nil
+	case "-background":
+		settings.background = opt.Arg
+		return RecipeStep{}, false, nil
+	case "-gravity", "+gravity":
+		name := strings.ToUpper(opt.Arg)
+		if opt.Name == "+gravity" || name == "NONE" || name == "FORGET" {
+			name = "NORTHWEST"
+		}
+		if !slices.Contains(cropGravities, name) {
+			return RecipeStep{}, false, fmt.Errorf("unsupported -gravity %q", opt.Arg)
+		}
+		settings.gravity = name
+		return RecipeStep{}, false, nil
+	case "-filter":
+		// ImageMagick spells filters in CamelCase (SincFast), termagick in
+		// upper case with underscores (SINC_FAST).
+		for _, name := range resizeFilterNames {
+			if strings.EqualFold(strings.ReplaceAll(name, "_", ""), opt.Arg) {
+				settings.filter = name
+				return RecipeStep{}, false, nil
+			}
+		}
+		return RecipeStep{}, false, fmt.Errorf("unsupported -filter %q", opt.Arg)
+	case "-interpolate":
+		name := strings.ToUpper(opt.Arg)
+		if name == "NEARESTNEIGHBOR" {
+			name = "NEAREST"
+		}
+		if _, ok := interpolateNameToValue[name]; !ok {
+			return RecipeStep{}, false, fmt.Errorf("unsupported -interpolate %q", opt.Arg)
+		}
+		settings.interpolate = name
+		return RecipeStep{}, false, nil
+	case "+repage":
+		// Every termagick crop/trim already resets the page geometry.
+		return RecipeStep{}, false, nil
+
+	// Operators without arguments.
+	case "-auto-gamma":
+		return step("autoGamma")
+	case "-auto-level":
+		return step("autoLevel")
+	case "-auto-orient":
+		return step("autoOrient")
+	case "-contrast":
+		return step("contrast", "true")
+	case "+contrast":
+		return step("contrast", "false")
+	case "-despeckle":
+		return step("despeckle")
+	case "-enhance":
+		return step("enhance")
+	case "-equalize":
+		return step("equalize")
+	case "-flip":
+		return step("flip")
+	case "-flop":
+		return step("flop")
+	case "-transpose":
+		return step("transpose")
+	case "-transverse":
+		return step("transverse")
+	case "-monochrome":
+		return step("monochrome")
+	case "-negate":
+		return step("negate", "false")
+	case "+negate":
+		return step("negate", "true")
+	case "-normalize":
+		return step("normalize")
+	case "-strip":
+		return step("strip")
+	case "-trim":
+		return step("trim", settings.fuzz)
+
+	// Radius/sigma operators.
+	case "-adaptive-blur":
+		return radiusSigma("adaptiveBlur")
+	case "-adaptive-sharpen":
+		return radiusSigma("adaptiveSharpen")
+	case "-blur":
+		return radiusSigma("blur")
+	case "-charcoal":
+		return radiusSigma("charcoal")
+	case "-emboss":
+		return radiusSigma("emboss")
+	case "-sharpen":
+		return radiusSigma("sharpen")
+
+	case "-resize", "-adaptive-resize":
+		width, height, ok, err := resolveResizeGeometry(wand, opt.Arg)
+		if err != nil || !ok {
+			return RecipeStep{}, false, err
+		}
+		w, h := strconv.FormatUint(uint64(width), 10), strconv.FormatUint(uint64(height), 10)
+		if opt.Name == "-adaptive-resize" {
+			return step("adaptiveResize", w, h)
+		}
+		if settings.filter != "" {
+			return step("resize", w, h, settings.filter)
+		}
+		return step("resize", w, h)
+
+	case "-crop":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		w, h := g.width, g.height
+		if g.percent {
+			w = float64(wand.GetImageWidth()) * w / 100
+			h = float64(wand.GetImageHeight()) * h / 100
+		}
+		if !g.hasWidth || !g.hasHeight {
+			return RecipeStep{}, false, fmt.Errorf("crop geometry needs WxH")
+		}
+		return step("crop", formatNum(math.Round(w)), formatNum(math.Round(h)), formatNum(g.x), formatNum(g.y))
+
+	case "-annotate":
+		geom, text, _ := strings.Cut(opt.Arg, " ")
+		g, err := parseMagickGeometry(geom)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		fields, err := splitRecipeLine(text)
+		if err != nil || len(fields) != 1 {
+			return RecipeStep{}, false, fmt.Errorf("invalid annotate text")
+		}
+		return step("annotate", fields[0], settings.font, settings.pointsize, formatNum(g.x), formatNum(g.y), settings.fill)
+
+	case "-colorize":
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(opt.Arg, "%"), 64)
+		if err != nil {
+			return RecipeStep{}, false, fmt.Errorf("invalid colorize amount: %w", err)
+		}
+		return step("colorize", settings.fill, formatNum(pct/100))
+
+	case "-colorspace":
+		if strings.EqualFold(opt.Arg, "gray") {
+			return step("grayscale")
+		}
+		return RecipeStep{}, false, fmt.Errorf("only -colorspace Gray is supported")
+
+	case "-contrast-stretch":
+		low, high, found := strings.Cut(opt.Arg, "x")
+		low = strings.TrimSuffix(low, "%")
+		high = strings.TrimSuffix(high, "%")
+		if !found {
+			high = low
+		}
+		// ImageMagick counts the white point from the top; termagick takes an upper percentile.
+		h, err := strconv.ParseFloat(high, 64)
+		if err != nil {
+			return RecipeStep{}, false, fmt.Errorf("invalid contrast-stretch: %w", err)
+		}
+		return step("contrastStretch", low, formatNum(100-h))
+
+	case "-level":
+		parts := strings.Split(opt.Arg, ",")
+		black, err := quantumValue(parts[0])
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		white := "100%"
+		if len(parts) > 1 {
+			white = parts[1]
+		}
+		whiteVal, err := quantumValue(white)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		gamma := "1.0"
+		if len(parts) > 2 {
+			gamma = parts[2]
+		}
+		return step("level", black, gamma, whiteVal)
+
+	case "-modulate":
+		parts := strings.Split(opt.Arg, ",")
+		vals := []string{"100", "100", "100"}
+		copy(vals, parts)
+		return step("modulate", vals...)
+
+	case "-kuwahara":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		return step("kuwahara", formatNum(math.Max(1, math.Round(g.width))))
+
+	case "-median":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		return step("medianFilter", formatNum(math.Round(g.width)))
+
+	case "-paint":
+		return step("oilpaint", opt.Arg, "1.0")
+	case "-posterize":
+		return step("posterize", opt.Arg, "false")
+	case "-rotate":
+		return step("rotate", opt.Arg)
+	case "-shear":
+		// Xdegrees[xYdegrees]; a lone angle shears both ways.
+		xs, ys, hasY := strings.Cut(opt.Arg, "x")
+		if !hasY {
+			ys = xs
+		}
+		for _, v := range []string{xs, ys} {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return RecipeStep{}, false, fmt.Errorf("invalid shear %q", opt.Arg)
+			}
+		}
+		return step("shear", xs, ys, settings.background)
+	case "-roll":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		if g.hasWidth || g.hasHeight || !g.hasOffset {
+			return RecipeStep{}, false, fmt.Errorf("roll needs an offset, +X+Y")
+		}
+		return step("roll", formatNum(g.x), formatNum(g.y))
+	case "-gamma":
+		return step("gamma", opt.Arg)
+	case "-fx":
+		return step("fx", opt.Arg)
+	case "-edge":
+		return step("edge", opt.Arg)
+	case "-implode":
+		return step("implode", opt.Arg)
+	case "-spread":
+		return step("spread", opt.Arg)
+	case "-swirl":
+		if settings.interpolate != "" {
+			return step("swirl", opt.Arg, settings.interpolate)
+		}
+		return step("swirl", opt.Arg)
+	case "-blue-shift":
+		return step("blueShift", opt.Arg)
+	case "-black-threshold":
+		return step("blackThreshold", opt.Arg)
+	case "-sepia-tone":
+		return step("sepia", strings.TrimSuffix(opt.Arg, "%"))
+
+	case "-deskew", "-solarize", "-threshold":
+		v, err := quantumValue(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		name := strings.TrimPrefix(opt.Name, "-")
+		return step(name, v)
+
+	case "-motion-blur":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		sigma := 1.0
+		if g.hasHeight {
+			sigma = g.height
+		}
+		return step("motionBlur", formatNum(g.width), formatNum(sigma), formatNum(g.x))
+
+	case "-rotational-blur":
+		return step("rotationalBlur", opt.Arg)
+
+	case "-transparent", "+transparent":
+		return step("transparent", opt.Arg, settings.fuzz, strconv.FormatBool(opt.Name == "+transparent"))
+
+	case "-region":
+		return step("region", "SET", opt.Arg)
+
+	case "+region":
+		return step("region", "CLEAR", "")
+
+	case "-chop", "-splice":
+		name := strings.TrimPrefix(opt.Name, "-")
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		if g.percent || (!g.hasWidth && !g.hasHeight) || g.x < 0 || g.y < 0 {
+			return RecipeStep{}, false, fmt.Errorf("%s needs the band in pixels, WxH+X+Y", name)
+		}
+		if settings.gravity != "NORTHWEST" {
+			// The offset would count from another edge or the center.
+			return RecipeStep{}, false, fmt.Errorf("%s with -gravity is not supported", name)
+		}
+		if name == "chop" {
+			return step("chop", formatNum(g.width), formatNum(g.height), formatNum(g.x), formatNum(g.y))
+		}
+		return step("splice", formatNum(g.width), formatNum(g.height), formatNum(g.x), formatNum(g.y), settings.background)
+
+	case "-extent":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		if g.percent || !g.hasWidth || !g.hasHeight || g.hasOffset {
+			return RecipeStep{}, false, fmt.Errorf("extent needs the size in pixels, WxH")
+		}
+		return step("extent", formatNum(g.width), formatNum(g.height), settings.gravity, settings.background)
+
+	case "-border", "-frame":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		if g.percent || !g.hasWidth {
+			return RecipeStep{}, false, fmt.Errorf("%s needs the size in pixels, WxH", opt.Name)
+		}
+		height := g.width
+		if g.hasHeight {
+			height = g.height
+		}
+		if opt.Name == "-border" {
+			return step("border", settings.bordercolor, formatNum(g.width), formatNum(height))
+		}
+		// Without offsets magick draws no bevels, unlike frame's defaults.
+		outer, inner := "0", "0"
+		if g.hasOffset {
+			outer, inner = formatNum(g.x), formatNum(g.y)
+		}
+		return step("frame", settings.mattecolor, formatNum(g.width), formatNum(height), outer, inner)
+
+	case "-write-mask":
+		return step("mask", "LOAD", opt.Arg, "", "")
+
+	case "+write-mask":
+		return step("mask", "CLEAR", "", "", "")
+
+	case "-clahe":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		if g.percent || !g.hasWidth || !g.hasHeight {
+			return RecipeStep{}, false, fmt.Errorf("clahe needs the tile size in pixels, WxH")
+		}
+		bins, clip := "", ""
+		if g.hasOffset {
+			bins, clip = formatNum(g.x), formatNum(g.y)
+		}
+		return step("clahe", formatNum(g.width), formatNum(g.height), bins, clip)
+
+	case "-sketch":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		sigma := 1.0
+		if g.hasHeight {
+			sigma = g.height
+		}
+		return step("sketch", formatNum(g.width), formatNum(sigma), formatNum(g.x))
+
+	case "-wave":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		wavelength := 1.0
+		if g.hasHeight {
+			wavelength = g.height
+		}
+		return step("wave", formatNum(g.width), formatNum(wavelength))
+
+	case "-unsharp":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		sigma := 1.0
+		if g.hasHeight {
+			sigma = g.height
+		}
+		// The offsets of "RxS+A+T" carry the amount and threshold.
+		amount, threshold := 1.0, 0.05
+		if g.hasOffset {
+			amount, threshold = g.x, g.y
+		}
+		return step("unsharp", formatNum(g.width), formatNum(sigma), formatNum(amount), formatNum(threshold))
+
+	case "-vignette":
+		g, err := parseMagickGeometry(opt.Arg)
+		if err != nil {
+			return RecipeStep{}, false, err
+		}
+		return step("vignette", formatNum(g.width), formatNum(g.height), formatNum(g.x), formatNum(g.y))
+	}
+	return RecipeStep{}, false, fmt.Errorf("unsupported magick option %q", opt.Name)
+}
+
+// magickGeometry is a parsed ImageMagick geometry: WxH{+-}X{+-}Y plus flags.
+type magickGeometry struct {
+	width, height       float64
+	hasWidth, hasHeight bool
+	x, y                float64
+	hasOffset           bool
+	percent             bool
+	flags               string // any of ! < > ^
+}
+
+var magickGeometryRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)?(?:x(\d+(?:\.\d+)?)?)?([%!<>^]*)([+-]\d+(?:\.\d+)?)?([+-]\d+(?:\.\d+)?)?$`)
+
+// parseMagickGeometry parses a geometry string such as "50%", "800x600!",
+// "0x1" or "100x100+10+20".
+func parseMagickGeometry(s string) (magickGeometry, error) {
+	var g magickGeometry
+	m := magickGeometryRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || s == "" {
+		return g, fmt.Errorf("invalid geometry %q", s)
+	}
+	if m[1] != "" {
+		g.width, _ = strconv.ParseFloat(m[1], 64)
+		g.hasWidth = true
+	}
+	if m[2] != "" {
+		g.height, _ = strconv.ParseFloat(m[2], 64)
+		g.hasHeight = true
+	}
+	g.percent = strings.Contains(m[3], "%")
+	g.flags = strings.ReplaceAll(m[3], "%", "")
+	if m[4] != "" {
+		g.x, _ = strconv.ParseFloat(m[4], 64)
+		g.hasOffset = true
+	}
+	if m[5] != "" {
+		g.y, _ = strconv.ParseFloat(m[5], 64)
+	}
+	return g, nil
+}
+
+// resolveResizeGeometry computes the target size for a -resize geometry
+// against the current image, following ImageMagick's rules: percentages scale,
+// WxH fits inside the box keeping the aspect ratio unless '!' is given, and
+// '>' / '<' only shrink / enlarge. ok is false when the geometry leaves the
+// image unchanged.
+func resolveResizeGeometry(wand *imagick.MagickWand, s string) (uint, uint, bool, error) {
+	g, err := parseMagickGeometry(s)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if !g.hasWidth && !g.hasHeight {
+		return 0, 0, false, fmt.Errorf("resize geometry needs a size")
+	}
+	w := float64(wand.GetImageWidth())
+	h := float64(wand.GetImageHeight())
+
+	var nw, nh float64
+	switch {
+	case g.percent:
+		sx := g.width
+		sy := sx
+		if g.hasHeight {
+			sy = g.height
+		}
+		if !g.hasWidth {
+			sx = sy
+		}
+		nw, nh = w*sx/100, h*sy/100
+	case strings.Contains(g.flags, "!") && g.hasWidth && g.hasHeight:
+		nw, nh = g.width, g.height
+	default:
+		scale := math.Inf(1)
+		if g.hasWidth && g.width > 0 {
+			scale = g.width / w
+		}
+		if g.hasHeight && g.height > 0 {
+			sy := g.height / h
+			if strings.Contains(g.flags, "^") {
+				// '^' fills the box instead of fitting inside it.
+				if math.IsInf(scale, 1) || sy > scale {
+					scale = sy
+				}
+			} else if sy < scale {
+				scale = sy
+			}
+		}
+		if math.IsInf(scale, 1) {
+			return 0, 0, false, fmt.Errorf("invalid resize geometry %q", s)
+		}
+		nw, nh = w*scale, h*scale
+	}
+
+	if strings.Contains(g.flags, ">") && nw >= w && nh >= h {
+		return 0, 0, false, nil
+	}
+	if strings.Contains(g.flags, "<") && nw <= w && nh <= h {
+		return 0, 0, false, nil
+	}
+	return uint(math.Max(1, math.Round(nw))), uint(math.Max(1, math.Round(nh))), true, nil
+}
+
+// quantumValue checks a quantum-range argument. Percentages such as "50%"
+// are kept as they are, so the recipe stays portable between builds with
+// different quantum depths.
+func quantumValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if _, err := parseQuantum(s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// formatNum renders a float without trailing zeros.
+func formatNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Masks.
+//
+// mask restricts later commands to part of the image, for retouching: it
+// sets a grayscale write mask on the image, and while it is set every
+// command changes the pixels under white fully, those under gray partly and
+// those under black not at all. The mask is loaded from an image file (a
+// painted mask, or a selection saved from another program) or built from a
+// rectangle or ellipse, with optional feathering and inversion, and stays
+// until `mask CLEAR`. Commands that change the image's geometry (resize,
+// crop, rotate...) reshape or drop the mask, so it should be cleared and set
+// again after them.
+
+// maskActions are the values of the mask command's action parameter, in
+// EnumOptions order.
+var maskActions = []string{"LOAD", "RECT", "ELLIPSE", "CLEAR"}
+
+// buildMask returns a grayscale mask the size of the current image of wand:
+// the first frame of the image file source for LOAD, or a white rectangle or
+// ellipse given as WxH+X+Y on black for RECT and ELLIPSE.
+func buildMask(wand *imagick.MagickWand, action, source string) (*imagick.MagickWand, error) {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if action == "LOAD" {
+		if source == "" {
+			return nil, fmt.Errorf("mask LOAD requires an image file")
+		}
+		file, err := LoadImage(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mask %s: %w", source, err)
+		}
+		defer file.Destroy()
+		file.SetFirstIterator()
+		mask := file.GetImage()
+		// Transparent areas of a mask painted on a transparent layer count
+		// as black.
+		black := imagick.NewPixelWand()
+		defer black.Destroy()
+		black.SetColor("black")
+		mask.SetImageBackgroundColor(black)
+		if err := mask.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE); err != nil {
+			mask.Destroy()
+			return nil, fmt.Errorf("failed to flatten mask: %w", err)
+		}
+		if mask.GetImageWidth() != w || mask.GetImageHeight() != h {
+			fmt.Printf("Mask is %dx%d, scaled to the image's %dx%d\n", mask.GetImageWidth(), mask.GetImageHeight(), w, h)
+			if err := mask.ResizeImage(w, h, imagick.FILTER_TRIANGLE); err != nil {
+				mask.Destroy()
+				return nil, fmt.Errorf("failed to scale mask: %w", err)
+			}
+		}
+		return mask, nil
+	}
+
+	g, err := parseMagickGeometry(source)
+	if err != nil || !g.hasWidth || !g.hasHeight || g.percent {
+		return nil, fmt.Errorf("mask %s requires a region as WxH+X+Y, got %q", action, source)
+	}
+	mask := imagick.NewMagickWand()
+	black := imagick.NewPixelWand()
+	defer black.Destroy()
+	black.SetColor("black")
+	if err := mask.NewImage(w, h, black); err != nil {
+		mask.Destroy()
+		return nil, fmt.Errorf("failed to create mask: %w", err)
+	}
+	dw := imagick.NewDrawingWand()
+	defer dw.Destroy()
+	white := imagick.NewPixelWand()
+	defer white.Destroy()
+	white.SetColor("white")
+	dw.SetFillColor(white)
+	if action == "ELLIPSE" {
+		rx, ry := g.width/2, g.height/2
+		dw.Ellipse(g.x+rx, g.y+ry, rx, ry, 0, 360)
+	} else {
+		dw.Rectangle(g.x, g.y, g.x+g.width-1, g.y+g.height-1)
+	}
+	if err := mask.DrawImage(dw); err != nil {
+		mask.Destroy()
+		return nil, fmt.Errorf("failed to draw mask: %w", err)
+	}
+	return mask, nil
+}
+
+// SetMask sets the write mask of the current image of wand (see buildMask),
+// blurred by feather pixels and inverted if asked, or removes it for CLEAR.
+func SetMask(wand *imagick.MagickWand, action, source string, feather float64, invert bool) error {
+	if action == "CLEAR" {
+		return clearWriteMask(wand)
+	}
+	mask, err := buildMask(wand, action, source)
+	if err != nil {
+		return err
+	}
+	defer mask.Destroy()
+	if err := mask.TransformImageColorspace(imagick.COLORSPACE_GRAY); err != nil {
+		return fmt.Errorf("failed to convert mask to grayscale: %w", err)
+	}
+	if invert {
+		if err := mask.NegateImage(false); err != nil {
+			return fmt.Errorf("failed to invert mask: %w", err)
+		}
+	}
+	if feather > 0 {
+		if err := mask.GaussianBlurImage(0, feather); err != nil {
+			return fmt.Errorf("failed to feather mask: %w", err)
+		}
+	}
+	if err := wand.SetImageMask(imagick.PIXEL_MASK_WRITE, mask); err != nil {
+		return fmt.Errorf("failed to set mask: %w", err)
+	}
+	fmt.Println("Mask set: commands now change only the white areas of the mask; mask CLEAR removes it")
+	return nil
+}
@@ -20,6 +20,12 @@ const (
 	ParamTypeString  ParamType = "string"
 	ParamTypeEnum    ParamType = "enum"
 	ParamTypePercent ParamType = "percent"
+	// ParamTypeQuantum is a level in ImageMagick's quantum range, given as a
+	// raw value (0-255 on Q8 builds, 0-65535 on Q16) or, portably, as a
+	// percentage of the range ("50%"). Percentages keep their % sign when
+	// normalized and are resolved by parseQuantum when the command runs.
+	// Min and Max are in the quantum range; Max defaults to its top.
+	ParamTypeQuantum ParamType = "quantum"
 )
 
 // ParamMeta describes a single parameter for a command.
@@ -36,10 +42,19 @@ type ParamMeta struct {
 }
 
 // CommandMeta ties a command name to its params and description.
+// CreatesImage marks generator commands that replace the current image with a
+// newly built one; they can run before any image has been opened. NoImage marks
+// commands that leave the current image untouched (informational output,
+// background jobs), so no preview follows them.
+// WholeSequence marks commands that operate on all frames of an animation at
+// once (reordering, timing reports) and so must not be repeated per frame.
 type CommandMeta struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	Params      []ParamMeta `json:"params"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Params        []ParamMeta `json:"params"`
+	CreatesImage  bool        `json:"createsImage,omitempty"`
+	NoImage       bool        `json:"noImage,omitempty"`
+	WholeSequence bool        `json:"wholeSequence,omitempty"`
 }
 
 // ValidationRule is a machine-friendly representation of the constraints
@@ -80,6 +95,20 @@ func GetCommandMetaByName(all []CommandMeta, name string) *CommandMeta {
 	return nil
 }
 
+// createsImage reports whether the named built-in command is a generator
+// that replaces the whole image rather than editing the current frame.
+func createsImage(name string) bool {
+	c := GetCommandMetaByName(Commands, name)
+	return c != nil && c.CreatesImage
+}
+
+// wholeSequence reports whether the named built-in command handles every
+// frame itself (generators included) instead of editing the current frame.
+func wholeSequence(name string) bool {
+	c := GetCommandMetaByName(Commands, name)
+	return c != nil && (c.CreatesImage || c.WholeSequence)
+}
+
 // GenerateTooltip produces a human-friendly tooltip string for the command.
 // The output is intended for UI tooltips/help text.
 func GenerateTooltip(cmd CommandMeta) string {
@@ -226,6 +255,24 @@ func parsePercentValue(s string) (string, error) {
 	return s, nil
 }
 
+// parseQuantum returns the value of a normalized quantum parameter: a raw
+// quantum value, or a percentage of this build's quantum range.
+func parseQuantum(s string) (float64, error) {
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		f, err := strconv.ParseFloat(pct, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage %q", s)
+		}
+		_, quantumRange := imagick.GetQuantumRange()
+		return f / 100 * float64(quantumRange), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return f, nil
+}
+
 /*
 Package-level enum maps and helpers.
 
@@ -462,6 +509,163 @@ var (
 		int64(imagick.COMPRESSION_JBIG1):         "JBIG1",
 		int64(imagick.COMPRESSION_JBIG2):         "JBIG2",
 	}
+
+	// Resize filter names, in the order offered by resize, mapped to the
+	// ImageMagick filter constants. POINT keeps hard pixel edges (pixel art),
+	// LANCZOS is the default for photos.
+	resizeFilterNames = []string{
+		"POINT", "BOX", "TRIANGLE", "HERMITE", "HANNING", "HAMMING",
+		"BLACKMAN", "GAUSSIAN", "QUADRATIC", "CUBIC", "CATROM", "MITCHELL",
+		"JINC", "SINC", "SINC_FAST", "KAISER", "WELSH", "PARZEN", "BOHMAN",
+		"BARTLETT", "LAGRANGE", "LANCZOS", "LANCZOS_SHARP", "LANCZOS2",
+		"LANCZOS2_SHARP", "ROBIDOUX", "ROBIDOUX_SHARP", "COSINE", "SPLINE",
+	}
+
+	filterNameToValue = map[string]int64{
+		"POINT":          int64(imagick.FILTER_POINT),
+		"BOX":            int64(imagick.FILTER_BOX),
+		"TRIANGLE":       int64(imagick.FILTER_TRIANGLE),
+		"HERMITE":        int64(imagick.FILTER_HERMITE),
+		"HANNING":        int64(imagick.FILTER_HANNING),
+		"HAMMING":        int64(imagick.FILTER_HAMMING),
+		"BLACKMAN":       int64(imagick.FILTER_BLACKMAN),
+		"GAUSSIAN":       int64(imagick.FILTER_GAUSSIAN),
+		"QUADRATIC":      int64(imagick.FILTER_QUADRATIC),
+		"CUBIC":          int64(imagick.FILTER_CUBIC),
+		"CATROM":         int64(imagick.FILTER_CATROM),
+		"MITCHELL":       int64(imagick.FILTER_MITCHELL),
+		"JINC":           int64(imagick.FILTER_JINC),
+		"SINC":           int64(imagick.FILTER_SINC),
+		"SINC_FAST":      int64(imagick.FILTER_SINC_FAST),
+		"KAISER":         int64(imagick.FILTER_KAISER),
+		"WELSH":          int64(imagick.FILTER_WELSH),
+		"PARZEN":         int64(imagick.FILTER_PARZEN),
+		"BOHMAN":         int64(imagick.FILTER_BOHMAN),
+		"BARTLETT":       int64(imagick.FILTER_BARTLETT),
+		"LAGRANGE":       int64(imagick.FILTER_LAGRANGE),
+		"LANCZOS":        int64(imagick.FILTER_LANCZOS),
+		"LANCZOS_SHARP":  int64(imagick.FILTER_LANCZOS_SHARP),
+		"LANCZOS2":       int64(imagick.FILTER_LANCZOS2),
+		"LANCZOS2_SHARP": int64(imagick.FILTER_LANCZOS2_SHARP),
+		"ROBIDOUX":       int64(imagick.FILTER_ROBIDOUX),
+		"ROBIDOUX_SHARP": int64(imagick.FILTER_ROBIDOUX_SHARP),
+		"COSINE":         int64(imagick.FILTER_COSINE),
+		"SPLINE":         int64(imagick.FILTER_SPLINE),
+	}
+
+	filterValueToName = map[int64]string{
+		int64(imagick.FILTER_POINT):          "POINT",
+		int64(imagick.FILTER_BOX):            "BOX",
+		int64(imagick.FILTER_TRIANGLE):       "TRIANGLE",
+		int64(imagick.FILTER_HERMITE):        "HERMITE",
+		int64(imagick.FILTER_HANNING):        "HANNING",
+		int64(imagick.FILTER_HAMMING):        "HAMMING",
+		int64(imagick.FILTER_BLACKMAN):       "BLACKMAN",
+		int64(imagick.FILTER_GAUSSIAN):       "GAUSSIAN",
+		int64(imagick.FILTER_QUADRATIC):      "QUADRATIC",
+		int64(imagick.FILTER_CUBIC):          "CUBIC",
+		int64(imagick.FILTER_CATROM):         "CATROM",
+		int64(imagick.FILTER_MITCHELL):       "MITCHELL",
+		int64(imagick.FILTER_JINC):           "JINC",
+		int64(imagick.FILTER_SINC):           "SINC",
+		int64(imagick.FILTER_SINC_FAST):      "SINC_FAST",
+		int64(imagick.FILTER_KAISER):         "KAISER",
+		int64(imagick.FILTER_WELSH):          "WELSH",
+		int64(imagick.FILTER_PARZEN):         "PARZEN",
+		int64(imagick.FILTER_BOHMAN):         "BOHMAN",
+		int64(imagick.FILTER_BARTLETT):       "BARTLETT",
+		int64(imagick.FILTER_LAGRANGE):       "LAGRANGE",
+		int64(imagick.FILTER_LANCZOS):        "LANCZOS",
+		int64(imagick.FILTER_LANCZOS_SHARP):  "LANCZOS_SHARP",
+		int64(imagick.FILTER_LANCZOS2):       "LANCZOS2",
+		int64(imagick.FILTER_LANCZOS2_SHARP): "LANCZOS2_SHARP",
+		int64(imagick.FILTER_ROBIDOUX):       "ROBIDOUX",
+		int64(imagick.FILTER_ROBIDOUX_SHARP): "ROBIDOUX_SHARP",
+		int64(imagick.FILTER_COSINE):         "COSINE",
+		int64(imagick.FILTER_SPLINE):         "SPLINE",
+	}
+
+	// Distortion methods offered by distort, mapped to the ImageMagick
+	// constants. BILINEAR and RESIZE are left out: the first is an alias of
+	// BILINEAR_FORWARD, the second is not a distortion users ask for.
+	distortMethodNames = []string{
+		"AFFINE", "AFFINE_PROJECTION", "ARC", "BARREL", "BARREL_INVERSE",
+		"BILINEAR_FORWARD", "BILINEAR_REVERSE", "CYLINDER_2_PLANE", "DE_POLAR",
+		"PERSPECTIVE", "PERSPECTIVE_PROJECTION", "PLANE_2_CYLINDER", "POLAR",
+		"POLYNOMIAL", "SCALE_ROTATE_TRANSLATE", "SHEPARDS",
+	}
+
+	distortMethodNameToValue = map[string]int64{
+		"AFFINE":                 int64(imagick.DISTORTION_AFFINE),
+		"AFFINE_PROJECTION":      int64(imagick.DISTORTION_AFFINE_PROJECTION),
+		"ARC":                    int64(imagick.DISTORTION_ARC),
+		"BARREL":                 int64(imagick.DISTORTION_BARREL),
+		"BARREL_INVERSE":         int64(imagick.DISTORTION_BARREL_INVERSE),
+		"BILINEAR_FORWARD":       int64(imagick.DISTORTION_BILINEAR_FORWARD),
+		"BILINEAR_REVERSE":       int64(imagick.DISTORTION_BILINEAR_REVERSE),
+		"CYLINDER_2_PLANE":       int64(imagick.DISTORTION_CYLINDER_2_PLANE),
+		"DE_POLAR":               int64(imagick.DISTORTION_DE_POLAR),
+		"PERSPECTIVE":            int64(imagick.DISTORTION_PERSPECTIVE),
+		"PERSPECTIVE_PROJECTION": int64(imagick.DISTORTION_PERSPECTIVE_PROJECTION),
+		"PLANE_2_CYLINDER":       int64(imagick.DISTORTION_PLANE_2_CYLINDER),
+		"POLAR":                  int64(imagick.DISTORTION_POLAR),
+		"POLYNOMIAL":             int64(imagick.DISTORTION_POLYNOMIAL),
+		"SCALE_ROTATE_TRANSLATE": int64(imagick.DISTORTION_SCALE_ROTATE_TRANSLATE),
+		"SHEPARDS":               int64(imagick.DISTORTION_SHEPARDS),
+	}
+
+	distortMethodValueToName = map[int64]string{
+		int64(imagick.DISTORTION_AFFINE):                 "AFFINE",
+		int64(imagick.DISTORTION_AFFINE_PROJECTION):      "AFFINE_PROJECTION",
+		int64(imagick.DISTORTION_ARC):                    "ARC",
+		int64(imagick.DISTORTION_BARREL):                 "BARREL",
+		int64(imagick.DISTORTION_BARREL_INVERSE):         "BARREL_INVERSE",
+		int64(imagick.DISTORTION_BILINEAR_FORWARD):       "BILINEAR_FORWARD",
+		int64(imagick.DISTORTION_BILINEAR_REVERSE):       "BILINEAR_REVERSE",
+		int64(imagick.DISTORTION_CYLINDER_2_PLANE):       "CYLINDER_2_PLANE",
+		int64(imagick.DISTORTION_DE_POLAR):               "DE_POLAR",
+		int64(imagick.DISTORTION_PERSPECTIVE):            "PERSPECTIVE",
+		int64(imagick.DISTORTION_PERSPECTIVE_PROJECTION): "PERSPECTIVE_PROJECTION",
+		int64(imagick.DISTORTION_PLANE_2_CYLINDER):       "PLANE_2_CYLINDER",
+		int64(imagick.DISTORTION_POLAR):                  "POLAR",
+		int64(imagick.DISTORTION_POLYNOMIAL):             "POLYNOMIAL",
+		int64(imagick.DISTORTION_SCALE_ROTATE_TRANSLATE): "SCALE_ROTATE_TRANSLATE",
+		int64(imagick.DISTORTION_SHEPARDS):               "SHEPARDS",
+	}
+
+	// Pixel interpolation methods, used where a command samples between
+	// pixels (swirl). NEAREST keeps hard edges, BILINEAR is the usual default.
+	interpolateNames = []string{
+		"AVERAGE", "AVERAGE9", "AVERAGE16", "BACKGROUND", "BILINEAR", "BLEND", "CATROM", "INTEGER", "MESH", "NEAREST", "SPLINE",
+	}
+
+	interpolateNameToValue = map[string]int64{
+		"AVERAGE":    int64(imagick.INTERPOLATE_PIXEL_AVERAGE),
+		"AVERAGE9":   int64(imagick.INTERPOLATE_PIXEL_AVERAGE9),
+		"AVERAGE16":  int64(imagick.INTERPOLATE_PIXEL_AVERAGE16),
+		"BACKGROUND": int64(imagick.INTERPOLATE_PIXEL_BACKGROUND),
+		"BILINEAR":   int64(imagick.INTERPOLATE_PIXEL_BILINEAR),
+		"BLEND":      int64(imagick.INTERPOLATE_PIXEL_BLEND),
+		"CATROM":     int64(imagick.INTERPOLATE_PIXEL_CATROM),
+		"INTEGER":    int64(imagick.INTERPOLATE_PIXEL_INTEGER),
+		"MESH":       int64(imagick.INTERPOLATE_PIXEL_MESH),
+		"NEAREST":    int64(imagick.INTERPOLATE_PIXEL_NEAREST_INTERPOLATE),
+		"SPLINE":     int64(imagick.INTERPOLATE_PIXEL_SPLINE),
+	}
+
+	interpolateValueToName = map[int64]string{
+		int64(imagick.INTERPOLATE_PIXEL_AVERAGE):             "AVERAGE",
+		int64(imagick.INTERPOLATE_PIXEL_AVERAGE9):            "AVERAGE9",
+		int64(imagick.INTERPOLATE_PIXEL_AVERAGE16):           "AVERAGE16",
+		int64(imagick.INTERPOLATE_PIXEL_BACKGROUND):          "BACKGROUND",
+		int64(imagick.INTERPOLATE_PIXEL_BILINEAR):            "BILINEAR",
+		int64(imagick.INTERPOLATE_PIXEL_BLEND):               "BLEND",
+		int64(imagick.INTERPOLATE_PIXEL_CATROM):              "CATROM",
+		int64(imagick.INTERPOLATE_PIXEL_INTEGER):             "INTEGER",
+		int64(imagick.INTERPOLATE_PIXEL_MESH):                "MESH",
+		int64(imagick.INTERPOLATE_PIXEL_NEAREST_INTERPOLATE): "NEAREST",
+		int64(imagick.INTERPOLATE_PIXEL_SPLINE):              "SPLINE",
+	}
 )
 
 // mapEnumToNumeric attempts to translate some known enum textual values to numeric IDs
@@ -486,6 +690,18 @@ func mapEnumToNumeric(paramName string, val string) (string, bool) {
 		if id, ok := compressionNameToValue[strings.ToUpper(v)]; ok {
 			return strconv.FormatInt(id, 10), true
 		}
+	case "filter":
+		if id, ok := filterNameToValue[strings.ToUpper(v)]; ok {
+			return strconv.FormatInt(id, 10), true
+		}
+	case "distortmethod":
+		if id, ok := distortMethodNameToValue[strings.ToUpper(v)]; ok {
+			return strconv.FormatInt(id, 10), true
+		}
+	case "interpolate":
+		if id, ok := interpolateNameToValue[strings.ToUpper(v)]; ok {
+			return strconv.FormatInt(id, 10), true
+		}
 	}
 
 	// Not a known mapping
@@ -510,6 +726,18 @@ func mapNumericToEnumName(paramName string, id int64) (string, bool) {
 		if s, ok := compressionValueToName[id]; ok {
 			return s, true
 		}
+	case "filter":
+		if s, ok := filterValueToName[id]; ok {
+			return s, true
+		}
+	case "distortmethod":
+		if s, ok := distortMethodValueToName[id]; ok {
+			return s, true
+		}
+	case "interpolate":
+		if s, ok := interpolateValueToName[id]; ok {
+			return s, true
+		}
 	}
 	return "", false
 }
@@ -598,6 +826,30 @@ func NormalizeArgs(store *MetaStore, cmdName string, args []string) ([]string, e
 			}
 			out[i] = n
 
+		case ParamTypeQuantum:
+			n, err := parsePercentValue(raw)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", p.Name, err)
+			}
+			if strings.HasSuffix(raw, "%") {
+				n += "%"
+			}
+			// Min and Max are in the quantum range, so a percentage is
+			// converted to it to be checked.
+			f, _ := parseQuantum(n)
+			if p.Min != nil && f < *p.Min {
+				return nil, fmt.Errorf("parameter %s: %s < min %v", p.Name, n, *p.Min)
+			}
+			_, quantumRange := imagick.GetQuantumRange()
+			limit := float64(quantumRange)
+			if p.Max != nil {
+				limit = *p.Max
+			}
+			if f > limit {
+				return nil, fmt.Errorf("parameter %s: %s > max %v", p.Name, n, limit)
+			}
+			out[i] = n
+
 		case ParamTypeBool:
 			bs, err := parseBoolLikeToString(raw)
 			if err != nil {
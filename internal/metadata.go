@@ -0,0 +1,533 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Metadata editing.
+//
+// ImageMagick lists EXIF, IPTC and other properties, but writes the EXIF
+// profile back exactly as it was read, so changing an exif: property alone
+// would be lost on save. EXIF keys are therefore edited in the profile itself:
+// a small TIFF directory editor deletes entries (zeroing their data, so
+// removed GPS coordinates do not linger in the file) and sets text values,
+// moving a value or directory to the end of the profile when it grows. Other
+// properties (comment, label...) are set on the image, and whole profiles
+// (xmp, iptc, icc...) can be removed as profile:<name>.
+
+// metadataActions are the values of the metadata command's action parameter,
+// in EnumOptions order.
+var metadataActions = []string{"LIST", "SET", "DELETE"}
+
+// isMetadataList reports whether normalized metadata arguments ask for LIST,
+// which only prints and leaves the image alone.
+func isMetadataList(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	idx, err := strconv.Atoi(args[0])
+	return err == nil && idx >= 0 && idx < len(metadataActions) && metadataActions[idx] == "LIST"
+}
+
+// exifHeader precedes the TIFF data in EXIF profiles read from JPEG files.
+const exifHeader = "Exif\x00\x00"
+
+// EXIF directories.
+const (
+	ifd0 = iota
+	ifdExif
+	ifdGPS
+)
+
+// Tags that point to the Exif and GPS directories from IFD0.
+const (
+	tagExifIFD = 0x8769
+	tagGPSIFD  = 0x8825
+)
+
+// exifTag identifies a tag by the directory it lives in; text tags can be set.
+type exifTag struct {
+	ifd  int
+	id   uint16
+	text bool
+}
+
+// exifTags maps ImageMagick's exif: property names to tags.
+var exifTags = map[string]exifTag{
+	"ImageDescription":     {ifd0, 0x010e, true},
+	"Make":                 {ifd0, 0x010f, true},
+	"Model":                {ifd0, 0x0110, true},
+	"Orientation":          {ifd0, 0x0112, false},
+	"XResolution":          {ifd0, 0x011a, false},
+	"YResolution":          {ifd0, 0x011b, false},
+	"ResolutionUnit":       {ifd0, 0x0128, false},
+	"Software":             {ifd0, 0x0131, true},
+	"DateTime":             {ifd0, 0x0132, true},
+	"Artist":               {ifd0, 0x013b, true},
+	"HostComputer":         {ifd0, 0x013c, true},
+	"Copyright":            {ifd0, 0x8298, true},
+	"ExposureTime":         {ifdExif, 0x829a, false},
+	"FNumber":              {ifdExif, 0x829d, false},
+	"ExposureProgram":      {ifdExif, 0x8822, false},
+	"ISOSpeedRatings":      {ifdExif, 0x8827, false},
+	"DateTimeOriginal":     {ifdExif, 0x9003, true},
+	"DateTimeDigitized":    {ifdExif, 0x9004, true},
+	"OffsetTime":           {ifdExif, 0x9010, true},
+	"OffsetTimeOriginal":   {ifdExif, 0x9011, true},
+	"OffsetTimeDigitized":  {ifdExif, 0x9012, true},
+	"Flash":                {ifdExif, 0x9209, false},
+	"FocalLength":          {ifdExif, 0x920a, false},
+	"MakerNote":            {ifdExif, 0x927c, false},
+	"UserComment":          {ifdExif, 0x9286, false},
+	"SubSecTime":           {ifdExif, 0x9290, true},
+	"SubSecTimeOriginal":   {ifdExif, 0x9291, true},
+	"SubSecTimeDigitized":  {ifdExif, 0x9292, true},
+	"ImageUniqueID":        {ifdExif, 0xa420, true},
+	"CameraOwnerName":      {ifdExif, 0xa430, true},
+	"BodySerialNumber":     {ifdExif, 0xa431, true},
+	"LensMake":             {ifdExif, 0xa433, true},
+	"LensModel":            {ifdExif, 0xa434, true},
+	"LensSerialNumber":     {ifdExif, 0xa435, true},
+	"GPSVersionID":         {ifdGPS, 0x00, false},
+	"GPSLatitudeRef":       {ifdGPS, 0x01, true},
+	"GPSLatitude":          {ifdGPS, 0x02, false},
+	"GPSLongitudeRef":      {ifdGPS, 0x03, true},
+	"GPSLongitude":         {ifdGPS, 0x04, false},
+	"GPSAltitudeRef":       {ifdGPS, 0x05, false},
+	"GPSAltitude":          {ifdGPS, 0x06, false},
+	"GPSTimeStamp":         {ifdGPS, 0x07, false},
+	"GPSSatellites":        {ifdGPS, 0x08, true},
+	"GPSStatus":            {ifdGPS, 0x09, true},
+	"GPSMeasureMode":       {ifdGPS, 0x0a, true},
+	"GPSDOP":               {ifdGPS, 0x0b, false},
+	"GPSSpeedRef":          {ifdGPS, 0x0c, true},
+	"GPSSpeed":             {ifdGPS, 0x0d, false},
+	"GPSTrackRef":          {ifdGPS, 0x0e, true},
+	"GPSTrack":             {ifdGPS, 0x0f, false},
+	"GPSImgDirectionRef":   {ifdGPS, 0x10, true},
+	"GPSImgDirection":      {ifdGPS, 0x11, false},
+	"GPSMapDatum":          {ifdGPS, 0x12, true},
+	"GPSDestLatitudeRef":   {ifdGPS, 0x13, true},
+	"GPSDestLatitude":      {ifdGPS, 0x14, false},
+	"GPSDestLongitudeRef":  {ifdGPS, 0x15, true},
+	"GPSDestLongitude":     {ifdGPS, 0x16, false},
+	"GPSDestBearingRef":    {ifdGPS, 0x17, true},
+	"GPSDestBearing":       {ifdGPS, 0x18, false},
+	"GPSDestDistanceRef":   {ifdGPS, 0x19, true},
+	"GPSDestDistance":      {ifdGPS, 0x1a, false},
+	"GPSProcessingMethod":  {ifdGPS, 0x1b, false},
+	"GPSAreaInformation":   {ifdGPS, 0x1c, false},
+	"GPSDateStamp":         {ifdGPS, 0x1d, true},
+	"GPSDifferential":      {ifdGPS, 0x1e, false},
+	"GPSHPositioningError": {ifdGPS, 0x1f, false},
+}
+
+// ListMetadata prints the properties of the current image of wand whose names
+// match the glob pattern (all when empty), followed by its profiles.
+func ListMetadata(wand *imagick.MagickWand, pattern string) error {
+	if pattern == "" {
+		pattern = "*"
+	}
+	// ImageMagick parses the EXIF profile into properties on first request.
+	wand.GetImageProperty("exif:*")
+	names, err := matchProperties(wand, pattern)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Printf("%-32s %s\n", name, wand.GetImageProperty(name))
+	}
+	for _, name := range wand.GetImageProfiles("*") {
+		if ok, _ := path.Match(pattern, "profile:"+name); ok || pattern == "*" {
+			fmt.Printf("%-32s %s\n", "profile:"+name, formatSize(int64(len(wand.GetImageProfileBytes(name)))))
+		}
+	}
+	if len(names) == 0 {
+		fmt.Printf("No properties match %q.\n", pattern)
+	}
+	return nil
+}
+
+// matchProperties returns the sorted names of the properties of wand that
+// match pattern.
+func matchProperties(wand *imagick.MagickWand, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	var names []string
+	for _, name := range wand.GetImageProperties("*") {
+		if ok, _ := path.Match(pattern, name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SetMetadata sets one property. exif: keys are written to the EXIF profile
+// and must name a text tag.
+func SetMetadata(wand *imagick.MagickWand, key, value string) error {
+	if strings.HasPrefix(key, "profile:") {
+		return fmt.Errorf("profiles can only be deleted")
+	}
+	if name, ok := strings.CutPrefix(key, "exif:"); ok {
+		tag, known := exifTags[name]
+		if !known {
+			return fmt.Errorf("unknown EXIF tag %q", name)
+		}
+		if !tag.text {
+			return fmt.Errorf("only text EXIF tags can be set; %s is not one", name)
+		}
+		exif, err := loadExif(wand)
+		if err != nil {
+			return err
+		}
+		if err := exif.setText(tag, value); err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+		if err := wand.SetImageProfile("exif", exif.data); err != nil {
+			return fmt.Errorf("failed to store EXIF profile: %w", err)
+		}
+	}
+	if err := wand.SetImageProperty(key, value); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteMetadata removes the properties, EXIF tags and profile:<name>
+// profiles matching the glob pattern and returns how many were removed.
+func DeleteMetadata(wand *imagick.MagickWand, pattern string) (int, error) {
+	wand.GetImageProperty("exif:*")
+	names, err := matchProperties(wand, pattern)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, name := range wand.GetImageProfiles("*") {
+		if ok, _ := path.Match(pattern, "profile:"+name); ok {
+			wand.RemoveImageProfile(name)
+			removed++
+		}
+	}
+
+	var exif *exifData
+	if len(wand.GetImageProfileBytes("exif")) > 0 {
+		if exif, err = loadExif(wand); err != nil {
+			return removed, err
+		}
+	}
+	exifChanged := false
+	for _, name := range names {
+		if tagName, ok := strings.CutPrefix(name, "exif:"); ok && exif != nil {
+			if tag, known := exifTags[tagName]; known {
+				deleted, err := exif.delete(tag)
+				if err != nil {
+					return removed, fmt.Errorf("delete %s: %w", name, err)
+				}
+				exifChanged = exifChanged || deleted
+			}
+		}
+		if err := wand.DeleteImageProperty(name); err != nil {
+			return removed, fmt.Errorf("failed to delete %s: %w", name, err)
+		}
+		removed++
+	}
+	if exifChanged {
+		if err := exif.dropEmptyGPS(); err != nil {
+			return removed, err
+		}
+		if err := wand.SetImageProfile("exif", exif.data); err != nil {
+			return removed, fmt.Errorf("failed to store EXIF profile: %w", err)
+		}
+	}
+	return removed, nil
+}
+
+// exifData is an EXIF profile being edited. Offsets in the TIFF structure
+// are relative to start.
+type exifData struct {
+	data  []byte
+	start int
+	order binary.ByteOrder
+}
+
+// ifdEntry is one 12-byte directory entry; value holds the data itself when
+// it fits in four bytes, otherwise its offset.
+type ifdEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    [4]byte
+}
+
+// ifdDir is a parsed directory and where it is stored.
+type ifdDir struct {
+	offset  int
+	size    int // entries the stored directory has room for
+	entries []ifdEntry
+	next    uint32
+}
+
+// tiffTypeSizes are the byte sizes of the TIFF field types 1-12.
+var tiffTypeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+// loadExif reads the EXIF profile of wand for editing.
+func loadExif(wand *imagick.MagickWand) (*exifData, error) {
+	profile := wand.GetImageProfileBytes("exif")
+	if len(profile) == 0 {
+		return nil, fmt.Errorf("image has no EXIF data")
+	}
+	return parseExif(append([]byte(nil), profile...))
+}
+
+// parseExif wraps an EXIF profile, with or without the JPEG "Exif" header,
+// for editing in place.
+func parseExif(profile []byte) (*exifData, error) {
+	e := &exifData{data: profile}
+	if bytes.HasPrefix(e.data, []byte(exifHeader)) {
+		e.start = len(exifHeader)
+	}
+	if len(e.data) < e.start+8 {
+		return nil, fmt.Errorf("EXIF profile is truncated")
+	}
+	switch string(e.data[e.start : e.start+4]) {
+	case "II*\x00":
+		e.order = binary.LittleEndian
+	case "MM\x00*":
+		e.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("EXIF profile has no TIFF header")
+	}
+	return e, nil
+}
+
+func (e *exifData) tiff() []byte { return e.data[e.start:] }
+
+// entrySize returns the size of an entry's data in bytes.
+func entrySize(en ifdEntry) int {
+	if int(en.typ) >= len(tiffTypeSizes) {
+		return 0
+	}
+	return tiffTypeSizes[en.typ] * int(en.count)
+}
+
+// dirOffset returns the offset of a directory, 0 when the image has none.
+func (e *exifData) dirOffset(ifd int) (uint32, error) {
+	root := e.order.Uint32(e.tiff()[4:])
+	if ifd == ifd0 {
+		return root, nil
+	}
+	dir, err := e.readDir(root)
+	if err != nil {
+		return 0, err
+	}
+	pointer := uint16(tagExifIFD)
+	if ifd == ifdGPS {
+		pointer = tagGPSIFD
+	}
+	for _, en := range dir.entries {
+		if en.tag == pointer {
+			return e.order.Uint32(en.value[:]), nil
+		}
+	}
+	return 0, nil
+}
+
+// readDir parses the directory at offset.
+func (e *exifData) readDir(offset uint32) (*ifdDir, error) {
+	t := e.tiff()
+	if int(offset)+2 > len(t) {
+		return nil, fmt.Errorf("EXIF directory out of range")
+	}
+	n := int(e.order.Uint16(t[offset:]))
+	end := int(offset) + 2 + 12*n + 4
+	if end > len(t) {
+		return nil, fmt.Errorf("EXIF directory out of range")
+	}
+	dir := &ifdDir{offset: int(offset), size: n}
+	for i := 0; i < n; i++ {
+		p := int(offset) + 2 + 12*i
+		en := ifdEntry{tag: e.order.Uint16(t[p:]), typ: e.order.Uint16(t[p+2:]), count: e.order.Uint32(t[p+4:])}
+		copy(en.value[:], t[p+8:p+12])
+		dir.entries = append(dir.entries, en)
+	}
+	dir.next = e.order.Uint32(t[end-4:])
+	return dir, nil
+}
+
+// writeDir stores dir, in place when it still fits and otherwise at the end
+// of the profile, and returns its offset.
+func (e *exifData) writeDir(dir *ifdDir) uint32 {
+	offset := dir.offset
+	if len(dir.entries) > dir.size {
+		offset = e.appendData(make([]byte, 2+12*len(dir.entries)+4))
+	}
+	t := e.tiff()
+	size := max(dir.size, len(dir.entries))
+	if offset != dir.offset {
+		size = len(dir.entries)
+	}
+	e.order.PutUint16(t[offset:], uint16(len(dir.entries)))
+	for i, en := range dir.entries {
+		p := offset + 2 + 12*i
+		e.order.PutUint16(t[p:], en.tag)
+		e.order.PutUint16(t[p+2:], en.typ)
+		e.order.PutUint32(t[p+4:], en.count)
+		copy(t[p+8:p+12], en.value[:])
+	}
+	p := offset + 2 + 12*len(dir.entries)
+	e.order.PutUint32(t[p:], dir.next)
+	// Clear the slots left over after entries were removed.
+	clear(t[p+4 : offset+2+12*size+4])
+	return uint32(offset)
+}
+
+// appendData adds b at the end of the profile, word aligned as TIFF requires,
+// and returns its offset.
+func (e *exifData) appendData(b []byte) int {
+	if (len(e.data)-e.start)%2 == 1 {
+		e.data = append(e.data, 0)
+	}
+	offset := len(e.data) - e.start
+	e.data = append(e.data, b...)
+	return offset
+}
+
+// clearData zeroes the out-of-line data of an entry.
+func (e *exifData) clearData(en ifdEntry) {
+	size := entrySize(en)
+	if size <= 4 {
+		return
+	}
+	t := e.tiff()
+	off := int(e.order.Uint32(en.value[:]))
+	if off+size <= len(t) {
+		clear(t[off : off+size])
+	}
+}
+
+// delete removes tag and its data, reporting whether it was present.
+func (e *exifData) delete(tag exifTag) (bool, error) {
+	offset, err := e.dirOffset(tag.ifd)
+	if err != nil || offset == 0 {
+		return false, err
+	}
+	dir, err := e.readDir(offset)
+	if err != nil {
+		return false, err
+	}
+	for i, en := range dir.entries {
+		if en.tag == tag.id {
+			e.clearData(en)
+			dir.entries = append(dir.entries[:i], dir.entries[i+1:]...)
+			e.writeDir(dir)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setText stores value as the text of tag, adding the entry if needed.
+func (e *exifData) setText(tag exifTag, value string) error {
+	offset, err := e.dirOffset(tag.ifd)
+	if err != nil {
+		return err
+	}
+	if offset == 0 {
+		return fmt.Errorf("image has no %s directory", map[int]string{ifdExif: "Exif", ifdGPS: "GPS"}[tag.ifd])
+	}
+	dir, err := e.readDir(offset)
+	if err != nil {
+		return err
+	}
+	text := append([]byte(value), 0)
+	idx := sort.Search(len(dir.entries), func(i int) bool { return dir.entries[i].tag >= tag.id })
+	if idx == len(dir.entries) || dir.entries[idx].tag != tag.id {
+		// Entries are kept sorted by tag.
+		dir.entries = append(dir.entries, ifdEntry{})
+		copy(dir.entries[idx+1:], dir.entries[idx:])
+		dir.entries[idx] = ifdEntry{tag: tag.id, typ: 2}
+	}
+	en := &dir.entries[idx]
+	if en.typ != 2 {
+		return fmt.Errorf("tag is not stored as text")
+	}
+	switch {
+	case len(text) <= 4:
+		e.clearData(*en)
+		en.value = [4]byte{}
+		copy(en.value[:], text)
+	case entrySize(*en) >= len(text):
+		// Reuse the old space, clearing what the shorter value leaves.
+		e.clearData(*en)
+		off := e.order.Uint32(en.value[:])
+		copy(e.tiff()[off:], text)
+	default:
+		e.clearData(*en)
+		e.order.PutUint32(en.value[:], uint32(e.appendData(text)))
+	}
+	en.count = uint32(len(text))
+
+	newOffset := e.writeDir(dir)
+	if newOffset != offset {
+		return e.repoint(tag.ifd, newOffset)
+	}
+	return nil
+}
+
+// repoint updates the pointer to a directory that was moved.
+func (e *exifData) repoint(ifd int, offset uint32) error {
+	if ifd == ifd0 {
+		e.order.PutUint32(e.tiff()[4:], offset)
+		return nil
+	}
+	root, err := e.readDir(e.order.Uint32(e.tiff()[4:]))
+	if err != nil {
+		return err
+	}
+	pointer := uint16(tagExifIFD)
+	if ifd == ifdGPS {
+		pointer = tagGPSIFD
+	}
+	for i := range root.entries {
+		if root.entries[i].tag == pointer {
+			e.order.PutUint32(root.entries[i].value[:], offset)
+		}
+	}
+	e.writeDir(root)
+	return nil
+}
+
+// dropEmptyGPS removes the GPS directory and its pointer once every GPS tag
+// has been deleted.
+func (e *exifData) dropEmptyGPS() error {
+	offset, err := e.dirOffset(ifdGPS)
+	if err != nil || offset == 0 {
+		return err
+	}
+	gps, err := e.readDir(offset)
+	if err != nil || len(gps.entries) > 0 {
+		return err
+	}
+	clear(e.tiff()[offset : int(offset)+2+12*gps.size+4])
+	root, err := e.readDir(e.order.Uint32(e.tiff()[4:]))
+	if err != nil {
+		return err
+	}
+	for i, en := range root.entries {
+		if en.tag == tagGPSIFD {
+			root.entries = append(root.entries[:i], root.entries[i+1:]...)
+			e.writeDir(root)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// BuildMontage tiles the given images into a single contact sheet.
+//
+// tile is the grid geometry ("4x", "4x3"; empty lets ImageMagick choose),
+// thumb the per-tile geometry including spacing ("200x200+4+4"). When labels
+// is set, each tile is captioned with its file name. background colors the
+// sheet and the space between tiles.
+func BuildMontage(paths []string, tile, thumb string, labels bool, background string) (*imagick.MagickWand, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no input images")
+	}
+	src := imagick.NewMagickWand()
+	defer src.Destroy()
+	for _, p := range paths {
+		frame := imagick.NewMagickWand()
+		if err := frame.ReadImage(p); err != nil {
+			frame.Destroy()
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		// Only the first frame of an animated or multi-page input is used.
+		frame.SetFirstIterator()
+		single := frame.GetImage()
+		frame.Destroy()
+		if labels {
+			if err := single.SetImageProperty("label", filepath.Base(p)); err != nil {
+				single.Destroy()
+				return nil, fmt.Errorf("failed to set label for %s: %w", p, err)
+			}
+		}
+		err := src.AddImage(single)
+		single.Destroy()
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", p, err)
+		}
+	}
+
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	if !bg.SetColor(background) {
+		return nil, fmt.Errorf("invalid background color %q", background)
+	}
+	// The montage takes its background from the wand's image settings.
+	if err := src.SetBackgroundColor(bg); err != nil {
+		return nil, fmt.Errorf("failed to set background: %w", err)
+	}
+
+	dw := imagick.NewDrawingWand()
+	defer dw.Destroy()
+	fill := imagick.NewPixelWand()
+	defer fill.Destroy()
+	fill.SetColor("black")
+	dw.SetFillColor(fill)
+	dw.SetFontSize(12)
+
+	src.ResetIterator()
+	sheet := src.MontageImage(dw, tile, thumb, imagick.MONTAGE_MODE_UNFRAME, "")
+	if sheet == nil {
+		return nil, fmt.Errorf("montage failed")
+	}
+	sheet.ResetIterator()
+	return sheet, nil
+}
@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Picking points with the mouse.
+//
+// When a command asks for an x and a y coordinate (crop origin, floodfill
+// start, vignette center, inspectPixel...), the preview is drawn again above
+// the prompt and mouse reporting is switched on: clicking the preview fills
+// both coordinates with the pixel under the pointer, while typing enters x as
+// usual. The preview's position is found by asking the terminal for the
+// cursor position, and its size in cells from the placement (kitty, chafa) or
+// the terminal's cell size in pixels (inline images, sixel), so a click is
+// accurate to a terminal cell. A rectangle (the region command's) is picked
+// the same way by clicking two opposite corners.
+
+const (
+	mouseOn  = "\x1b[?1000h\x1b[?1006h"
+	mouseOff = "\x1b[?1006l\x1b[?1000l"
+
+	// terminalReplyTimeout bounds waiting for a terminal to answer a query;
+	// terminals that do not support one never answer.
+	terminalReplyTimeout = 300 * time.Millisecond
+)
+
+// previewArea is where a preview was drawn, in 1-based terminal cells, and
+// the size of the image it shows.
+type previewArea struct {
+	top, left  int
+	cols, rows int
+	width      uint
+	height     uint
+}
+
+// pixelAt returns the image pixel under the terminal cell at col, row, and
+// whether the cell is inside the preview.
+func (a previewArea) pixelAt(col, row int) (int, int, bool) {
+	if col < a.left || col >= a.left+a.cols || row < a.top || row >= a.top+a.rows {
+		return 0, 0, false
+	}
+	x := int((float64(col-a.left) + 0.5) * float64(a.width) / float64(a.cols))
+	y := int((float64(row-a.top) + 0.5) * float64(a.height) / float64(a.rows))
+	return min(x, int(a.width)-1), min(y, int(a.height)-1), true
+}
+
+// PromptPoint asks for an x coordinate with prompt after drawing the preview
+// of wand above it. The user may type x, in which case y is left for the
+// caller to ask, or click the preview, which fills both; clicked reports which
+// happened. Without a usable preview or mouse it is PromptLine.
+func PromptPoint(wand *imagick.MagickWand, prompt string) (x, y string, clicked bool, err error) {
+	area, ok := drawPickablePreview(wand)
+	if !ok {
+		x, err = PromptLine(prompt)
+		return x, "", false, err
+	}
+	restore, err := enableRawInput()
+	if err != nil {
+		x, err = PromptLine(prompt)
+		return x, "", false, err
+	}
+	defer restore()
+	fmt.Print(mouseOn)
+	defer fmt.Print(mouseOff)
+
+	fmt.Print(prompt)
+	in := bufio.NewReader(os.Stdin)
+	line, px, py, clicked, err := readLineOrClick(in, area)
+	if err != nil || !clicked {
+		return line, "", false, err
+	}
+	fmt.Printf("%d, %d (clicked)\n", px, py)
+	return fmt.Sprint(px), fmt.Sprint(py), true, nil
+}
+
+// PromptRegion asks for a rectangle as WxH+X+Y with prompt after drawing the
+// preview of wand above it. Instead of typing it, two opposite corners can be
+// clicked on the preview. Without a usable preview or mouse it is
+// PromptLine.
+func PromptRegion(wand *imagick.MagickWand, prompt string) (string, error) {
+	area, ok := drawPickablePreview(wand)
+	if !ok {
+		return PromptLine(prompt)
+	}
+	restore, err := enableRawInput()
+	if err != nil {
+		return PromptLine(prompt)
+	}
+	defer restore()
+	fmt.Print(mouseOn)
+	defer fmt.Print(mouseOff)
+
+	fmt.Print(prompt)
+	in := bufio.NewReader(os.Stdin)
+	line, x1, y1, clicked, err := readLineOrClick(in, area)
+	if err != nil || !clicked {
+		return line, err
+	}
+	fmt.Printf("%d, %d (clicked); click the opposite corner: ", x1, y1)
+	// Anything typed now cancels the selection, as an empty line would.
+	_, x2, y2, clicked, err := readLineOrClick(in, area)
+	if err != nil || !clicked {
+		return "", err
+	}
+	region := fmt.Sprintf("%dx%d+%d+%d", abs(x2-x1)+1, abs(y2-y1)+1, min(x1, x2), min(y1, y2))
+	fmt.Printf("%d, %d (clicked): %s\n", x2, y2, region)
+	return region, nil
+}
+
+// readLineOrClick reads a line typed in raw mode, echoing it, or a click on
+// the preview in area, in which case clicked is set and the typed text is
+// erased. The terminal must be in raw mode with mouse reporting on.
+func readLineOrClick(in *bufio.Reader, area previewArea) (line string, px, py int, clicked bool, err error) {
+	var typed []rune
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			fmt.Println()
+			return "", 0, 0, false, err
+		}
+		switch {
+		case r == '\r' || r == '\n':
+			fmt.Println()
+			return strings.TrimSpace(string(typed)), 0, 0, false, nil
+		case r == 0x03:
+			// Ctrl-C leaves the value empty, as an empty line would.
+			fmt.Println()
+			return "", 0, 0, false, nil
+		case r == 0x7f || r == 0x08:
+			if len(typed) > 0 {
+				typed = typed[:len(typed)-1]
+				fmt.Print("\b \b")
+			}
+		case r == 0x1b:
+			col, row, press := readMouseEvent(in)
+			if !press {
+				continue
+			}
+			if px, py, inside := area.pixelAt(col, row); inside {
+				fmt.Print(strings.Repeat("\b \b", len(typed)))
+				return "", px, py, true, nil
+			}
+		case unicode.IsPrint(r):
+			typed = append(typed, r)
+			fmt.Print(string(r))
+		}
+	}
+}
+
+// readMouseEvent reads the rest of an escape sequence after ESC and, for an
+// SGR mouse report of a left-button press, returns the cell clicked. Other
+// sequences (arrow keys, releases, other buttons) are consumed and ignored.
+func readMouseEvent(in *bufio.Reader) (col, row int, press bool) {
+	b, err := in.ReadByte()
+	if err != nil || b != '[' {
+		return 0, 0, false
+	}
+	var seq []byte
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			return 0, 0, false
+		}
+		seq = append(seq, b)
+		// A control sequence ends with a byte in @ to ~.
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+	// SGR reports look like <button;col;row followed by M (press) or m (release).
+	var button int
+	if n, _ := fmt.Sscanf(string(seq), "<%d;%d;%dM", &button, &col, &row); n != 3 {
+		return 0, 0, false
+	}
+	return col, row, button == 0
+}
+
+// drawPickablePreview draws the preview of wand below the cursor and returns
+// where it went. It fails when there is no preview or the terminal does not
+// report what is needed to locate it.
+func drawPickablePreview(wand *imagick.MagickWand) (previewArea, bool) {
+	if wand == nil || !PreviewSupported() {
+		return previewArea{}, false
+	}
+	width, height := wand.GetImageWidth(), wand.GetImageHeight()
+	cols, rows, ok := previewGrid(width, height)
+	if !ok {
+		return previewArea{}, false
+	}
+	// Make room first so drawing does not scroll the preview away from the
+	// position the terminal reports.
+	fmt.Print(strings.Repeat("\n", rows+1))
+	fmt.Printf("\x1b[%dA", rows+1)
+	row, col, err := cursorPosition()
+	if err != nil {
+		debugf("cursor position: %v", err)
+		return previewArea{}, false
+	}
+	if err := PreviewWand(wand); err != nil {
+		return previewArea{}, false
+	}
+	return previewArea{top: row, left: col, cols: cols, rows: rows, width: width, height: height}, true
+}
+
+// cursorPosition asks the terminal for the cursor position (1-based).
+func cursorPosition() (row, col int, err error) {
+	reply, err := queryTerminal("\x1b[6n", 'R')
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscanf(reply, "\x1b[%d;%dR", &row, &col); err != nil {
+		return 0, 0, fmt.Errorf("unexpected reply %q", reply)
+	}
+	return row, col, nil
+}
+
+// cellSize asks the terminal for the size of a character cell in pixels.
+func cellSize() (width, height int, err error) {
+	reply, err := queryTerminal("\x1b[16t", 't')
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscanf(reply, "\x1b[6;%d;%dt", &height, &width); err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("unexpected reply %q", reply)
+	}
+	return width, height, nil
+}
+
+// queryTerminal writes a control sequence the terminal answers and returns
+// the answer up to and including its final byte.
+func queryTerminal(seq string, final byte) (string, error) {
+	restore, err := enableRawInput()
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	os.Stdout.WriteString(seq)
+
+	var reply []byte
+	buf := make([]byte, 64)
+	for {
+		if !waitForInput(terminalReplyTimeout) {
+			return "", fmt.Errorf("no reply from terminal")
+		}
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		reply = append(reply, buf[:n]...)
+		if i := strings.IndexByte(string(reply), final); i >= 0 {
+			// Anything before the reply's ESC was typed ahead; drop it.
+			if start := strings.LastIndexByte(string(reply[:i]), 0x1b); start >= 0 {
+				return string(reply[start : i+1]), nil
+			}
+			return string(reply[:i+1]), nil
+		}
+	}
+}
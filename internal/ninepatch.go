@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Nine-patch assets.
+//
+// A nine-patch image is scaled by stretching its edges and center while the
+// corners keep their size, so one small image can frame buttons and panels
+// of any size. Android stores the stretchable area as black marks in a
+// one-pixel border around the image (file.9.png); CSS describes it with
+// border-image slice values, and some engines want the nine pieces as
+// separate files.
+
+// ninePatchStyles are the values of the ninePatch command's style parameter,
+// in EnumOptions order.
+var ninePatchStyles = []string{"ANDROID", "CSS"}
+
+// ninePatchPieces names the CSS slices in reading order.
+var ninePatchPieces = []string{"top-left", "top", "top-right", "left", "center", "right", "bottom-left", "bottom", "bottom-right"}
+
+// checkInsets verifies that the corner sizes leave a stretchable middle.
+func checkInsets(width, height, top, right, bottom, left uint) error {
+	if left+right >= width || top+bottom >= height {
+		return fmt.Errorf("corners of %d,%d,%d,%d px leave nothing to stretch in a %dx%d image", top, right, bottom, left, width, height)
+	}
+	return nil
+}
+
+// WriteAndroidNinePatch writes the current image of wand as an Android
+// 9-patch to path, which gets the .9.png extension. The corners given by the
+// insets stay fixed; the marks on the right and bottom edges set the content
+// area to the same insets. It returns the path written.
+func WriteAndroidNinePatch(wand *imagick.MagickWand, path string, top, right, bottom, left uint) (string, error) {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if err := checkInsets(w, h, top, right, bottom, left); err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(strings.ToLower(path), ".9.png") {
+		path = strings.TrimSuffix(path, filepath.Ext(path)) + ".9.png"
+	}
+
+	none := imagick.NewPixelWand()
+	defer none.Destroy()
+	none.SetColor("none")
+	out := imagick.NewMagickWand()
+	defer out.Destroy()
+	if err := out.NewImage(w+2, h+2, none); err != nil {
+		return "", fmt.Errorf("failed to create canvas: %w", err)
+	}
+	img := wand.GetImage()
+	defer img.Destroy()
+	if err := out.CompositeImage(img, imagick.COMPOSITE_OP_OVER, true, 1, 1); err != nil {
+		return "", fmt.Errorf("failed to place image: %w", err)
+	}
+
+	// Marks are opaque black pixels: top and left for the stretchable area,
+	// bottom and right for the content area.
+	mark := func(x, y int, cols, rows uint) error {
+		px := make([]byte, cols*rows*4)
+		for i := 3; i < len(px); i += 4 {
+			px[i] = 255
+		}
+		return out.ImportImagePixels(x, y, cols, rows, "RGBA", imagick.PIXEL_CHAR, px)
+	}
+	for _, m := range []struct {
+		x, y       int
+		cols, rows uint
+	}{
+		{int(1 + left), 0, w - left - right, 1},
+		{0, int(1 + top), 1, h - top - bottom},
+		{int(1 + left), int(h + 1), w - left - right, 1},
+		{int(w + 1), int(1 + top), 1, h - top - bottom},
+	} {
+		if err := mark(m.x, m.y, m.cols, m.rows); err != nil {
+			return "", fmt.Errorf("failed to draw nine-patch marks: %w", err)
+		}
+	}
+	if err := out.WriteImage("png32:" + path); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// WriteCSSNinePatch writes the current image of wand to dir as border.png
+// together with its nine slices, and returns a CSS rule using it as a
+// border image.
+func WriteCSSNinePatch(wand *imagick.MagickWand, dir string, top, right, bottom, left uint) (string, error) {
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if err := checkInsets(w, h, top, right, bottom, left); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create %s: %w", dir, err)
+	}
+	img := wand.GetImage()
+	defer img.Destroy()
+	if err := img.WriteImage("png:" + filepath.Join(dir, "border.png")); err != nil {
+		return "", fmt.Errorf("failed to write border.png: %w", err)
+	}
+
+	xs := []uint{0, left, w - right, w}
+	ys := []uint{0, top, h - bottom, h}
+	for i, name := range ninePatchPieces {
+		c, r := i%3, i/3
+		if xs[c+1] == xs[c] || ys[r+1] == ys[r] {
+			// A zero inset has no corner or edge piece.
+			continue
+		}
+		piece := wand.GetImage()
+		err := piece.CropImage(xs[c+1]-xs[c], ys[r+1]-ys[r], int(xs[c]), int(ys[r]))
+		if err == nil {
+			piece.ResetImagePage("")
+			err = piece.WriteImage("png:" + filepath.Join(dir, name+".png"))
+		}
+		piece.Destroy()
+		if err != nil {
+			return "", fmt.Errorf("failed to write %s.png: %w", name, err)
+		}
+	}
+
+	return fmt.Sprintf("border-style: solid;\nborder-width: %dpx %dpx %dpx %dpx;\nborder-image: url(border.png) %d %d %d %d fill stretch;",
+		top, right, bottom, left, top, right, bottom, left), nil
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Onion skin.
+//
+// While enabled with the onionSkin command, previews of an animation draw
+// the frames before and after the current one over it, faded, the way
+// animators check motion between drawings. Nearer frames are stronger than
+// farther ones, and the neighbors wrap around at the ends like [ and ] do, so
+// the seam of a loop can be checked too. The image itself is never changed.
+
+// Onion skin settings.
+var (
+	showOnionSkin bool
+	// onionOpacity is the opacity of the nearest neighbors, 0-1.
+	onionOpacity = 0.3
+	// onionFrames is how many frames on each side are shown.
+	onionFrames = 1
+)
+
+// maxOnionFrames bounds onionFrames; farther frames would be barely visible.
+const maxOnionFrames = 3
+
+// onionSkinOverlay returns a copy of current with the frames around the
+// selected frame of wand drawn over it. current is normally that frame, or
+// an overlay of it such as the clipping warnings, which may be scaled down;
+// the neighbors are scaled to match.
+func onionSkinOverlay(wand, current *imagick.MagickWand) (*imagick.MagickWand, error) {
+	n := int(wand.GetNumberImages())
+	if n < 2 {
+		return nil, fmt.Errorf("not an animation")
+	}
+	idx := int(wand.GetIteratorIndex())
+	defer wand.SetIteratorIndex(idx)
+
+	out := current.GetImage()
+	w, h := out.GetImageWidth(), out.GetImageHeight()
+	drawn := map[int]bool{idx: true}
+	// Farthest first, so nearer frames end up on top.
+	for d := onionFrames; d >= 1; d-- {
+		for _, j := range []int{idx - d, idx + d} {
+			j = ((j % n) + n) % n
+			if drawn[j] {
+				continue
+			}
+			drawn[j] = true
+			wand.SetIteratorIndex(j)
+			frame := wand.GetImage()
+			err := fadeFrame(frame, w, h, onionOpacity/float64(d))
+			if err == nil {
+				err = out.CompositeImage(frame, imagick.COMPOSITE_OP_OVER, true, 0, 0)
+			}
+			frame.Destroy()
+			if err != nil {
+				out.Destroy()
+				return nil, fmt.Errorf("frame %d: %w", j+1, err)
+			}
+		}
+	}
+	return out, nil
+}
+
+// fadeFrame scales frame to width x height and multiplies its alpha by
+// opacity.
+func fadeFrame(frame *imagick.MagickWand, width, height uint, opacity float64) error {
+	if frame.GetImageWidth() != width || frame.GetImageHeight() != height {
+		if err := frame.SampleImage(width, height); err != nil {
+			return fmt.Errorf("failed to sample frame: %w", err)
+		}
+	}
+	if err := frame.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET); err != nil {
+		return fmt.Errorf("failed to add alpha: %w", err)
+	}
+	prev := frame.SetImageChannelMask(imagick.CHANNEL_ALPHA)
+	err := frame.EvaluateImage(imagick.EVAL_OP_MULTIPLY, opacity)
+	frame.SetImageChannelMask(prev)
+	return err
+}
+
+// setOnionSkin turns the onion skin on or off and updates the opacity (in
+// percent) and frame count when given. An empty value toggles it, unless
+// settings are given, which turn it on.
+func setOnionSkin(value, opacity, frames string) error {
+	if opacity != "" {
+		v, err := strconv.ParseFloat(opacity, 64)
+		if err != nil || v <= 0 || v > 100 {
+			return fmt.Errorf("opacity must be a percentage above 0, got %q", opacity)
+		}
+		onionOpacity = v / 100
+	}
+	if frames != "" {
+		v, err := strconv.Atoi(frames)
+		if err != nil || v < 1 || v > maxOnionFrames {
+			return fmt.Errorf("frames must be between 1 and %d, got %q", maxOnionFrames, frames)
+		}
+		onionFrames = v
+	}
+	switch {
+	case value == "true":
+		showOnionSkin = true
+	case value == "false":
+		showOnionSkin = false
+	case opacity != "" || frames != "":
+		// Changing the settings implies wanting to see them.
+		showOnionSkin = true
+	default:
+		showOnionSkin = !showOnionSkin
+	}
+	if showOnionSkin {
+		fmt.Printf("Onion skin on: %d frame(s) on each side at %g%% opacity\n", onionFrames, onionOpacity*100)
+	} else {
+		fmt.Println("Onion skin off")
+	}
+	return nil
+}
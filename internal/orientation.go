@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Orientation checks.
+//
+// Some older tools rotate the pixels of a photo upright but leave its EXIF
+// orientation tag in place, so every viewer that honors the tag turns the
+// image a second time. checkOrientation finds such files: when the tag asks
+// for a quarter turn but the pixels already have the swapped dimensions of
+// the ones the camera recorded (PixelXDimension and PixelYDimension), the
+// turn has been applied. Fixing resets the tag to 1 (normal) by overwriting
+// its value in the file; the compressed image data is not touched.
+
+// tagOrientation is the EXIF orientation tag in IFD0.
+const tagOrientation = 0x0112
+
+// Outcomes of checking a file's orientation.
+const (
+	orientationOK         = "ok"
+	orientationStale      = "stale"
+	orientationUnverified = "unverified"
+)
+
+// orientationCheck is the result of checking one file.
+type orientationCheck struct {
+	Status      string
+	Orientation int
+	Detail      string
+}
+
+// checkFileOrientation compares the EXIF orientation of the image at path
+// with its pixel dimensions.
+func checkFileOrientation(path string) (orientationCheck, error) {
+	wand := imagick.NewMagickWand()
+	defer wand.Destroy()
+	if err := wand.PingImage(path + "[0]"); err != nil {
+		return orientationCheck{}, err
+	}
+	o := int(wand.GetImageOrientation())
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	check := orientationCheck{Status: orientationOK, Orientation: o}
+	if o <= 1 || o > 8 {
+		return check, nil
+	}
+	if o < 5 {
+		// Flips and half turns keep the dimensions, so they leave no trace.
+		check.Status = orientationUnverified
+		check.Detail = "a flip or half turn cannot be told from the dimensions"
+		return check, nil
+	}
+	rw, errW := strconv.Atoi(wand.GetImageProperty("exif:PixelXDimension"))
+	rh, errH := strconv.Atoi(wand.GetImageProperty("exif:PixelYDimension"))
+	switch {
+	case errW != nil || errH != nil || rw <= 0 || rh <= 0:
+		check.Status = orientationUnverified
+		check.Detail = "no recorded pixel dimensions to compare with"
+	case w == h || rw == rh:
+		check.Status = orientationUnverified
+		check.Detail = "square image"
+	default:
+		// Compare aspect ratios rather than sizes, so resized copies are
+		// judged too.
+		aspect, recorded := float64(w)/float64(h), float64(rw)/float64(rh)
+		switch {
+		case math.Abs(aspect*recorded-1) < 0.01:
+			check.Status = orientationStale
+			check.Detail = fmt.Sprintf("pixels are already rotated (%dx%d, camera recorded %dx%d)", w, h, rw, rh)
+		case math.Abs(aspect/recorded-1) > 0.01:
+			check.Status = orientationUnverified
+			check.Detail = fmt.Sprintf("%dx%d matches neither way of the recorded %dx%d, probably cropped", w, h, rw, rh)
+		}
+	}
+	return check, nil
+}
+
+// CheckOrientation checks the EXIF orientation of files, reporting those
+// whose tag would rotate pixels that are already upright and those that
+// cannot be verified. With fix, stale tags of JPEG files are reset. It
+// fails when a file cannot be read or a stale tag is left in place.
+func CheckOrientation(files []string, fix bool) error {
+	var ok, stale, fixed, unverified, failed int
+	var problems []error
+	for _, f := range files {
+		check, err := checkFileOrientation(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", f, err)
+			failed++
+			problems = append(problems, withExitCode(exitRead, err))
+			continue
+		}
+		switch check.Status {
+		case orientationOK:
+			ok++
+			continue
+		case orientationUnverified:
+			unverified++
+			fmt.Printf("%s: orientation %d, not verified: %s\n", f, check.Orientation, check.Detail)
+			continue
+		}
+		stale++
+		if !fix {
+			fmt.Printf("%s: orientation %d is stale: %s\n", f, check.Orientation, check.Detail)
+			problems = append(problems, withExitCode(exitValidation, fmt.Errorf("stale")))
+			continue
+		}
+		if !isJPEGPath(f) {
+			fmt.Printf("%s: orientation %d is stale: %s; only JPEG files can be fixed\n", f, check.Orientation, check.Detail)
+			problems = append(problems, withExitCode(exitValidation, fmt.Errorf("stale")))
+			continue
+		}
+		if err := resetJPEGOrientation(f); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: orientation %d is stale, fix failed: %v\n", f, check.Orientation, err)
+			problems = append(problems, withExitCode(exitWrite, err))
+			continue
+		}
+		fixed++
+		fmt.Printf("%s: orientation %d was stale, reset to 1\n", f, check.Orientation)
+	}
+	summary := fmt.Sprintf("%d files: %d ok, %d stale", len(files), ok, stale)
+	if fix {
+		summary += fmt.Sprintf(" (%d fixed)", fixed)
+	}
+	summary += fmt.Sprintf(", %d not verified", unverified)
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d unreadable", failed)
+	}
+	fmt.Println(summary)
+	if stale > 0 && !fix {
+		fmt.Println("Run again with fix to reset the stale tags; the image data is not re-encoded.")
+	}
+	if len(problems) > 0 {
+		return withExitCode(commonExitCode(problems), fmt.Errorf("%d of %d files not in order", len(problems), len(files)))
+	}
+	return nil
+}
+
+// RunCheckOrientation implements
+// `termagick check-orientation [--fix] <files or directories...>`.
+func RunCheckOrientation(args []string) error {
+	fs := flag.NewFlagSet("check-orientation", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "reset stale orientation tags of JPEG files in place")
+	addQuietFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return withExitCode(exitUsage, fmt.Errorf("usage: termagick check-orientation [--fix] <files or directories...>"))
+	}
+	files, err := expandFileList(strings.Join(positional, string(filepath.ListSeparator)))
+	if err != nil {
+		return err
+	}
+	return CheckOrientation(files, *fix)
+}
+
+// resetJPEGOrientation sets the EXIF orientation of the JPEG file at path to
+// 1 by rewriting the two bytes of the tag's value. The file is replaced
+// atomically and keeps its modification time.
+func resetJPEGOrientation(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	pos, order, err := jpegOrientationOffset(data)
+	if err != nil {
+		return err
+	}
+	order.PutUint16(data[pos:], 1)
+	return replaceFileKeepingTime(path, data)
+}
+
+// replaceFileKeepingTime atomically replaces the file at path with data,
+// keeping its permissions and modification time, after the backup
+// save.backup_originals asks for.
+func replaceFileKeepingTime(path string, data []byte) error {
+	if err := backupBeforeWrite(path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Chtimes(path, info.ModTime(), info.ModTime())
+}
+
+// jpegOrientationOffset returns where in a JPEG file the value of the EXIF
+// orientation tag is stored, and the byte order of the EXIF data.
+func jpegOrientationOffset(data []byte) (int, binary.ByteOrder, error) {
+	pos, e, err := jpegExif(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	offset, err := e.dirOffset(ifd0)
+	if err != nil {
+		return 0, nil, err
+	}
+	dir, err := e.readDir(offset)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i, en := range dir.entries {
+		if en.tag == tagOrientation && en.typ == 3 {
+			return pos + e.start + dir.offset + 2 + 12*i + 8, e.order, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("no orientation tag")
+}
+
+// errNoExif is returned by jpegExif for a JPEG file without an EXIF segment.
+var errNoExif = errors.New("no EXIF data")
+
+// jpegExif finds the EXIF segment of a JPEG file and returns where its
+// payload starts in data and the EXIF data, which shares data's bytes.
+func jpegExif(data []byte) (int, *exifData, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 0, nil, fmt.Errorf("not a JPEG file")
+	}
+	for p := 2; p+4 <= len(data) && data[p] == 0xff; {
+		marker := data[p+1]
+		if marker == 0xda || marker == 0xd9 {
+			// Image data starts; metadata segments come before it.
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[p+2:]))
+		end := p + 2 + size
+		if size < 2 || end > len(data) {
+			break
+		}
+		payload := data[p+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(payload, []byte(exifHeader)) {
+			e, err := parseExif(payload)
+			if err != nil {
+				return 0, nil, err
+			}
+			return p + 4, e, nil
+		}
+		p = end
+	}
+	return 0, nil, errNoExif
+}
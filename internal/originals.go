@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backups of originals.
+//
+// With save.backup_originals on, saving over an existing file first copies
+// the file into .termagick-originals/ next to it, once: later saves leave
+// that first copy alone, so it stays the original from before termagick
+// touched the file. Copies are named by the SHA-256 of their content, so
+// identical files share one copy, and an index file maps file names to
+// copies. restoreOriginal puts a copy back.
+
+// originalsDir is the directory, next to the files, the copies are kept in.
+const originalsDir = ".termagick-originals"
+
+// originalsIndex is the name of the index file in originalsDir. Each line is
+// "<sha256>\t<file name>\t<time of the backup>".
+const originalsIndex = "index"
+
+// storedOriginal returns the path of the stored copy of the original of
+// path, or "" when there is none.
+func storedOriginal(path string) (string, error) {
+	dir := filepath.Join(filepath.Dir(path), originalsDir)
+	f, err := os.Open(filepath.Join(dir, originalsIndex))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	name := filepath.Base(path)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), "\t")
+		if len(fields) >= 2 && fields[1] == name {
+			return filepath.Join(dir, fields[0]+filepath.Ext(name)), nil
+		}
+	}
+	return "", sc.Err()
+}
+
+// backupBeforeWrite backs up the file at path when save.backup_originals is
+// on. Everything that writes an image over a file calls it first and writes
+// nothing if it fails.
+func backupBeforeWrite(path string) error {
+	if !config.BackupOriginals {
+		return nil
+	}
+	if err := backupOriginal(path); err != nil {
+		return fmt.Errorf("back up the original of %s: %w", path, err)
+	}
+	return nil
+}
+
+// backupOriginal copies the file at path into the originals store unless a
+// copy of its original is already there. A missing file needs no backup.
+func backupOriginal(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && !info.Mode().IsRegular()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stored, err := storedOriginal(path); err != nil || stored != "" {
+		return err
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	dir := filepath.Join(filepath.Dir(path), originalsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	copyPath := filepath.Join(dir, sum+filepath.Ext(path))
+	if _, err := os.Stat(copyPath); os.IsNotExist(err) {
+		if err := copyFileAtomic(path, copyPath); err != nil {
+			return err
+		}
+		os.Chtimes(copyPath, info.ModTime(), info.ModTime())
+	}
+
+	index, err := os.OpenFile(filepath.Join(dir, originalsIndex), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(index, "%s\t%s\t%s\n", sum, filepath.Base(path), time.Now().Format(time.RFC3339))
+	if cerr := index.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// copyFileAtomic copies src to dst under a temporary name first, so dst is
+// never left half written.
+func copyFileAtomic(src, dst string) error {
+	tmp := dst + ".part"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// restoreOriginal copies the stored original of path back over it.
+func restoreOriginal(path string) error {
+	stored, err := storedOriginal(path)
+	if err != nil {
+		return fmt.Errorf("read %s index: %w", originalsDir, err)
+	}
+	if stored == "" {
+		return fmt.Errorf("no original of %s is stored in %s", path, filepath.Join(filepath.Dir(path), originalsDir))
+	}
+	if err := copyFileAtomic(stored, path); err != nil {
+		return fmt.Errorf("restore %s: %w", path, err)
+	}
+	if info, err := os.Stat(stored); err == nil {
+		os.Chtimes(path, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// applyRestoreOriginal implements the restoreOriginal command: args[0] is
+// the file to restore, the current image's file when empty. Restoring the
+// current image's file reopens it.
+func (s *Session) applyRestoreOriginal(args []string) error {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path == "" {
+		if s.Path == "" {
+			return fmt.Errorf("the current image has no file; name the file to restore")
+		}
+		path = s.Path
+	}
+	if err := restoreOriginal(path); err != nil {
+		return err
+	}
+	fmt.Printf("Restored the original of %s\n", path)
+	if s.Wand == nil || !sameFile(path, s.Path) {
+		return nil
+	}
+	if err := s.Open(path); err != nil {
+		return fmt.Errorf("reopen %s: %w", path, err)
+	}
+	showPreview(s.Display())
+	return nil
+}
+
+// sameFile reports whether a and b name the same file.
+func sameFile(a, b string) bool {
+	ia, errA := os.Stat(a)
+	ib, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ia, ib)
+}
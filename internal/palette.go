@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// paletteSampleSize bounds the longer side of the copy quantized; a palette
+// barely changes with resolution.
+const paletteSampleSize = 400
+
+// paletteColor is one color of an extracted palette.
+type paletteColor struct {
+	Hex   string  `json:"hex"`
+	RGB   [3]int  `json:"rgb"`
+	Share float64 `json:"share"`
+}
+
+// ExtractPalette quantizes a reduced copy of the current image of wand to at
+// most n colors and returns them, most common first. Share is the fraction
+// of the image's opaque area each color covers; transparent pixels are
+// ignored.
+func ExtractPalette(wand *imagick.MagickWand, n uint) ([]paletteColor, error) {
+	sample := wand.GetImage()
+	defer sample.Destroy()
+	w, h := sample.GetImageWidth(), sample.GetImageHeight()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("image has zero dimensions")
+	}
+	if long := max(w, h); long > paletteSampleSize {
+		scale := float64(paletteSampleSize) / float64(long)
+		if err := sample.ResizeImage(max(1, uint(float64(w)*scale)), max(1, uint(float64(h)*scale)), imagick.FILTER_BOX); err != nil {
+			return nil, fmt.Errorf("failed to reduce image: %w", err)
+		}
+	}
+	if err := sample.QuantizeImage(n, imagick.COLORSPACE_SRGB, 0, imagick.DITHER_METHOD_NO, false); err != nil {
+		return nil, fmt.Errorf("failed to quantize image: %w", err)
+	}
+
+	_, pixels := sample.GetImageHistogram()
+	var colors []paletteColor
+	var total float64
+	counts := map[string]float64{}
+	for _, pw := range pixels {
+		if pw.GetAlpha() >= 0.5 {
+			rgb := [3]int{
+				int(math.Round(pw.GetRed() * 255)),
+				int(math.Round(pw.GetGreen() * 255)),
+				int(math.Round(pw.GetBlue() * 255)),
+			}
+			hex := fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+			count := float64(pw.GetColorCount())
+			// Colors that differ only beyond 8 bits merge.
+			if _, ok := counts[hex]; !ok {
+				colors = append(colors, paletteColor{Hex: hex, RGB: rgb})
+			}
+			counts[hex] += count
+			total += count
+		}
+		pw.Destroy()
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("the image has no opaque pixels")
+	}
+	for i := range colors {
+		colors[i].Share = counts[colors[i].Hex] / total
+	}
+	sort.SliceStable(colors, func(i, j int) bool { return colors[i].Share > colors[j].Share })
+	return colors, nil
+}
+
+// printPalette lists the colors with their hex codes and shares, each after a
+// truecolor swatch when stdout is a terminal.
+func printPalette(colors []paletteColor) {
+	swatches := isTerminal(os.Stdout)
+	for _, c := range colors {
+		if swatches {
+			fmt.Printf("\x1b[48;2;%d;%d;%dm      \x1b[0m ", c.RGB[0], c.RGB[1], c.RGB[2])
+		}
+		fmt.Printf("%s  rgb(%3d, %3d, %3d)  %5.1f%%\n", c.Hex, c.RGB[0], c.RGB[1], c.RGB[2], c.Share*100)
+	}
+}
+
+// WritePalette saves colors to path as a GIMP palette (.gpl, also read by
+// Inkscape and Krita), named after the file, or as JSON (.json).
+func WritePalette(colors []paletteColor, path string) error {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gpl":
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "GIMP Palette\nName: %s\nColumns: %d\n#\n", name, min(len(colors), 8))
+		for _, c := range colors {
+			fmt.Fprintf(&sb, "%3d %3d %3d\t%s\n", c.RGB[0], c.RGB[1], c.RGB[2], c.Hex)
+		}
+		data = []byte(sb.String())
+	case ".json":
+		var err error
+		data, err = json.MarshalIndent(colors, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported palette format %q (use .gpl or .json)", filepath.Ext(path))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// PipeImage runs command through the shell with the current image of wand on
+// its stdin as PNG, and replaces the image with whatever the command writes to
+// stdout, in any format ImageMagick recognizes. The frame keeps its animation
+// delay and disposal.
+func PipeImage(wand *imagick.MagickWand, command string) error {
+	if strings.TrimSpace(command) == "" {
+		return fmt.Errorf("pipe requires a command")
+	}
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd, err := externalCommand(shell, flag, command)
+	if err != nil {
+		return err
+	}
+
+	img := wand.GetImage()
+	defer img.Destroy()
+	if err := img.SetImageFormat("PNG"); err != nil {
+		return fmt.Errorf("failed to set format: %w", err)
+	}
+	blob, err := img.GetImageBlob()
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(blob)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return fmt.Errorf("%s wrote no image to stdout", command)
+	}
+
+	result := imagick.NewMagickWand()
+	defer result.Destroy()
+	if err := result.ReadImageBlob(stdout.Bytes()); err != nil {
+		return fmt.Errorf("failed to read the output of %s: %w", command, err)
+	}
+	// Only the first image counts if the command wrote several.
+	result.SetIteratorIndex(0)
+	first := result.GetImage()
+	defer first.Destroy()
+	first.SetImageDelay(wand.GetImageDelay())
+	first.SetImageDispose(wand.GetImageDispose())
+	if err := wand.SetImage(first); err != nil {
+		return fmt.Errorf("failed to replace image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// Previewers.
+//
+// Each way of showing the preview image is a Previewer, and previewers lists
+// them in order of preference. With preview.backend = "auto" the first one
+// whose Detect reports true is used, and the later detected ones are tried
+// in turn when it fails; naming a previewer in the config file uses it
+// without detection or fallbacks. A new protocol only needs a type with
+// these methods and an entry in the list; its name becomes a valid
+// preview.backend value.
+
+// Previewer shows PNG previews of the current image.
+type Previewer interface {
+	// Name is the preview.backend value that selects the previewer.
+	Name() string
+	// Detect reports whether the environment likely supports it.
+	Detect() bool
+	// Show displays the PNG data below the cursor.
+	Show(png []byte) error
+	// Grid returns how many terminal cells a width x height image takes up
+	// when shown, or false when that is not known.
+	Grid(width, height uint) (cols, rows int, ok bool)
+}
+
+// previewers are the available previewers, most preferred first.
+var previewers = []Previewer{
+	kittyPreviewer{},
+	inlinePreviewer{},
+	sixelPreviewer{},
+	chafaPreviewer{},
+	webPreviewer{},
+}
+
+// previewBackendNames returns the accepted values of preview.backend.
+func previewBackendNames() []string {
+	names := []string{"auto"}
+	for _, p := range previewers {
+		names = append(names, p.Name())
+	}
+	return append(names, "none")
+}
+
+// activePreviewer returns the previewer PreviewWand tries first: the one
+// named by preview.backend, or else the first detected one. It returns nil
+// when previews are off or unsupported.
+func activePreviewer() Previewer {
+	switch config.PreviewBackend {
+	case "none":
+		return nil
+	case "auto":
+		for _, p := range previewers {
+			if p.Detect() {
+				return p
+			}
+		}
+		return nil
+	}
+	for _, p := range previewers {
+		if p.Name() == config.PreviewBackend {
+			return p
+		}
+	}
+	return nil
+}
+
+// fallbackPreviewers returns the detected previewers after p, which
+// PreviewWand tries in turn when p fails.
+func fallbackPreviewers(p Previewer) []Previewer {
+	var rest []Previewer
+	after := false
+	for _, q := range previewers {
+		if after && q.Detect() {
+			rest = append(rest, q)
+		}
+		after = after || q.Name() == p.Name()
+	}
+	return rest
+}
+
+// previewGrid returns how many terminal cells a width x height image takes up
+// in the active previewer.
+func previewGrid(width, height uint) (int, int, bool) {
+	p := activePreviewer()
+	if p == nil || width == 0 || height == 0 {
+		return 0, 0, false
+	}
+	return p.Grid(width, height)
+}
+
+// kittyPreviewer uses the kitty graphics protocol.
+type kittyPreviewer struct{}
+
+func (kittyPreviewer) Name() string          { return "kitty" }
+func (kittyPreviewer) Detect() bool          { return isKitty() }
+func (kittyPreviewer) Show(png []byte) error { return sendKittyPNG(png) }
+
+func (kittyPreviewer) Grid(width, height uint) (int, int, bool) {
+	// The placement stretches the image over exactly this many cells.
+	cols, rows := kittyPreviewSize()
+	return cols, rows, true
+}
+
+// inlinePreviewer uses the iTerm2 inline image OSC 1337 sequence.
+type inlinePreviewer struct{}
+
+func (inlinePreviewer) Name() string          { return "inline" }
+func (inlinePreviewer) Detect() bool          { return isInlineImageCapable() }
+func (inlinePreviewer) Show(png []byte) error { return sendInlineImagePNG(png) }
+
+func (inlinePreviewer) Grid(width, height uint) (int, int, bool) {
+	return pixelGrid(width, height)
+}
+
+// sixelPreviewer draws Sixel graphics through img2sixel, chafa or
+// ImageMagick's own encoder.
+type sixelPreviewer struct{}
+
+func (sixelPreviewer) Name() string          { return "sixel" }
+func (sixelPreviewer) Detect() bool          { return isSixelCapable() }
+func (sixelPreviewer) Show(png []byte) error { return sendSixelPNG(png) }
+
+func (sixelPreviewer) Grid(width, height uint) (int, int, bool) {
+	return pixelGrid(width, height)
+}
+
+// pixelGrid returns the cells covered by protocols that show images at their
+// own size in pixels, or the proxy's for large images.
+func pixelGrid(width, height uint) (int, int, bool) {
+	pw, ph := proxySize(width, height)
+	cellW, cellH, err := cellSize()
+	if err != nil {
+		debugf("cell size: %v", err)
+		return 0, 0, false
+	}
+	return int(math.Ceil(float64(pw) / float64(cellW))), int(math.Ceil(float64(ph) / float64(cellH))), true
+}
+
+// chafaPreviewer draws block art with chafa, for terminals without a
+// graphics protocol.
+type chafaPreviewer struct{}
+
+func (chafaPreviewer) Name() string          { return "chafa" }
+func (chafaPreviewer) Detect() bool          { return hasChafa() }
+func (chafaPreviewer) Show(png []byte) error { return sendChafaPNG(png) }
+
+func (chafaPreviewer) Grid(width, height uint) (int, int, bool) {
+	cols, rows := previewSize(80, 40)
+	if v := os.Getenv("CHAFA_SIZE"); v != "" {
+		fmt.Sscanf(v, "%dx%d", &cols, &rows)
+	}
+	// chafa fits the image in the box keeping its aspect ratio, with cells
+	// twice as tall as they are wide.
+	w, h := float64(width), float64(height)
+	gw, gh := float64(cols), float64(cols)*h/w/2
+	if gh > float64(rows) {
+		gw, gh = float64(rows)*2*w/h, float64(rows)
+	}
+	return max(1, int(math.Round(gw))), max(1, int(math.Round(gh))), true
+}
+
+// webPreviewer shows previews only in the browser page of --web-preview. It
+// is the last resort, for terminals that can draw neither images nor block
+// art; otherwise the page mirrors the terminal preview anyway.
+type webPreviewer struct{}
+
+func (webPreviewer) Name() string { return "web" }
+func (webPreviewer) Detect() bool { return webPreview != nil }
+
+func (webPreviewer) Show(png []byte) error {
+	if webPreview == nil {
+		return fmt.Errorf("the web preview is not running; start termagick with --web-preview")
+	}
+	webPreview.publishPNG(png)
+	return nil
+}
+
+// Nothing is drawn in the terminal, so there is nothing to click on.
+func (webPreviewer) Grid(width, height uint) (int, int, bool) {
+	return 0, 0, false
+}
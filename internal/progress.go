@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Progress reporting and cancellation.
+//
+// ImageMagick reports the progress of long operations (resizes, blurs,
+// liquid rescale...) to a progress monitor installed on the image. While the
+// interactive editor applies a command, a monitor draws a progress bar on
+// stderr once the command has run for progressDelay, so a slow operation on a
+// large file does not look like a frozen prompt. The monitor's answer also
+// tells ImageMagick whether to go on, which is how Ctrl-C cancels a command
+// (see cancelOperations).
+
+// progressDelay is how long a command runs before its progress is shown.
+const progressDelay = 500 * time.Millisecond
+
+// progressMonitor is the state of one monitored command.
+type progressMonitor struct {
+	label string
+	start time.Time
+
+	// display is set when progress is drawn, i.e. stderr is a terminal.
+	display bool
+
+	mu        sync.Mutex
+	last      time.Time
+	shown     bool
+	cancelled bool
+}
+
+var (
+	progressMu       sync.Mutex
+	progressMonitors = map[uintptr]*progressMonitor{}
+	nextProgressID   uintptr
+)
+
+// monitorProgress monitors the operations run on wand under label until the
+// returned function is called, which reports whether they were cancelled.
+// Progress is only drawn when stderr is a terminal.
+func monitorProgress(wand *imagick.MagickWand, label string) func() bool {
+	if wand == nil {
+		return func() bool { return false }
+	}
+	m := &progressMonitor{label: label, start: time.Now(), display: isTerminal(os.Stderr)}
+	progressMu.Lock()
+	nextProgressID++
+	id := nextProgressID
+	progressMonitors[id] = m
+	progressMu.Unlock()
+	setProgressMonitor(wand, id)
+
+	return func() bool {
+		setProgressMonitor(wand, 0)
+		progressMu.Lock()
+		delete(progressMonitors, id)
+		progressMu.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.shown {
+			fmt.Fprint(os.Stderr, "\r\x1b[K")
+		}
+		return m.cancelled
+	}
+}
+
+// cancelOperations asks every monitored operation to stop at its next
+// progress report and returns whether there was one to cancel.
+func cancelOperations() bool {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	for _, m := range progressMonitors {
+		m.mu.Lock()
+		m.cancelled = true
+		m.mu.Unlock()
+	}
+	return len(progressMonitors) > 0
+}
+
+// progressEvent receives a progress report from ImageMagick for the monitor
+// id: tag names the operation and offset counts the rows (or passes) done out
+// of span. It returns whether the operation should continue. Reports may come
+// from several threads at once, and from images that inherited a monitor
+// after it was removed; those are ignored.
+func progressEvent(id uintptr, tag string, offset int64, span uint64) bool {
+	progressMu.Lock()
+	m := progressMonitors[id]
+	progressMu.Unlock()
+	if m == nil || span == 0 {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancelled {
+		return false
+	}
+	now := time.Now()
+	if !m.display || now.Sub(m.start) < progressDelay || now.Sub(m.last) < 100*time.Millisecond {
+		return true
+	}
+	m.last = now
+	m.shown = true
+
+	frac := float64(offset+1) / float64(span)
+	if frac > 1 {
+		frac = 1
+	}
+	const barWidth = 30
+	filled := int(frac * barWidth)
+	// Tags look like "Resize/Image"; the first part names the operation.
+	op, _, _ := strings.Cut(tag, "/")
+	fmt.Fprintf(os.Stderr, "\r\x1b[K%s (%s) [%s%s] %3.0f%% %s", m.label, op,
+		strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled),
+		frac*100, now.Sub(m.start).Round(100*time.Millisecond))
+	return true
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,18 @@
+package internal
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+// termagickProgress is the Go half of the progress monitor bridge in
+// cwand.go. It lives apart from it because a file with //export may only
+// declare C functions.
+//
+//export termagickProgress
+func termagickProgress(tag *C.char, offset C.longlong, span C.ulonglong, id C.uintptr_t) C.int {
+	if progressEvent(uintptr(id), C.GoString(tag), int64(offset), uint64(span)) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"os"
+	"strconv"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Preview proxies.
+//
+// Encoding a full-resolution frame as PNG for every preview takes seconds on
+// a 60 MP photo, although the terminal or browser only shows a fraction of
+// those pixels. Images above a size threshold are therefore previewed from a
+// downscaled proxy. The proxy of the last previewed image is kept, so showing
+// the same state again (switching frames back, the info panel, mouse picking,
+// the web preview alongside the terminal) does not scale it again; any
+// session change drops it.
+
+// defaultProxyMP is the size in megapixels above which previews use a proxy
+// when TERMAGICK_PREVIEW_PROXY_MP is not set.
+const defaultProxyMP = 16
+
+// previewProxySize is the longer side of a proxy in pixels.
+const previewProxySize = 1600
+
+// proxyCache holds the proxy of the last previewed image. Keeping source
+// referenced also keeps a different wand from reusing its address.
+var proxyCache struct {
+	source     *imagick.MagickWand
+	frame      int
+	generation uint64
+	proxy      *imagick.MagickWand
+}
+
+// previewGeneration counts session changes; a proxy is only reused while it
+// has not changed.
+var previewGeneration uint64
+
+// invalidatePreviewProxy drops the cached proxy. The session calls it whenever
+// an image may have changed.
+func invalidatePreviewProxy() {
+	previewGeneration++
+	if proxyCache.proxy != nil {
+		proxyCache.proxy.Destroy()
+	}
+	proxyCache.source, proxyCache.proxy = nil, nil
+}
+
+// proxyThreshold returns the pixel count above which previews use a proxy, or
+// 0 when proxies are disabled.
+func proxyThreshold() uint {
+	mp := defaultProxyMP
+	if v := os.Getenv("TERMAGICK_PREVIEW_PROXY_MP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			mp = n
+		}
+	}
+	return uint(mp) * 1000000
+}
+
+// proxySize returns the size an image of width x height is previewed at.
+func proxySize(width, height uint) (uint, uint) {
+	limit := proxyThreshold()
+	if limit == 0 || width*height <= limit || max(width, height) <= previewProxySize {
+		return width, height
+	}
+	scale := float64(previewProxySize) / float64(max(width, height))
+	return max(1, uint(float64(width)*scale+0.5)), max(1, uint(float64(height)*scale+0.5))
+}
+
+// previewImage returns a copy of the current image of wand to encode for a
+// preview: the image itself when small, otherwise its proxy. The caller
+// destroys the copy.
+func previewImage(wand *imagick.MagickWand) *imagick.MagickWand {
+	width, height := wand.GetImageWidth(), wand.GetImageHeight()
+	pw, ph := proxySize(width, height)
+	if pw == width && ph == height {
+		return wand.GetImage()
+	}
+	frame := int(wand.GetIteratorIndex())
+	if proxyCache.proxy != nil && proxyCache.source == wand && proxyCache.frame == frame && proxyCache.generation == previewGeneration {
+		return proxyCache.proxy.Clone()
+	}
+
+	proxy := wand.GetImage()
+	// ThumbnailImage samples down before filtering and drops profiles, which
+	// makes it much faster than a full-quality resize.
+	if err := proxy.ThumbnailImage(pw, ph); err != nil {
+		debugf("preview proxy failed: %v", err)
+		return proxy
+	}
+	if proxyCache.proxy != nil {
+		proxyCache.proxy.Destroy()
+	}
+	proxyCache.source, proxyCache.frame, proxyCache.generation, proxyCache.proxy = wand, frame, previewGeneration, proxy
+	return proxy.Clone()
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// RecipeStep is a single command invocation inside a recipe: the command name
+// followed by the raw (not yet normalized) arguments a user would have typed at
+// the interactive prompts.
+type RecipeStep struct {
+	Command string
+	Args    []string
+}
+
+// String renders the step back into recipe syntax, quoting arguments that
+// contain whitespace so the output can be parsed again by ParseRecipe.
+func (s RecipeStep) String() string {
+	parts := []string{s.Command}
+	for _, a := range s.Args {
+		parts = append(parts, quoteRecipeArg(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseRecipe reads a recipe from r. A recipe is a plain text file with one
+// command per line:
+//
+//	# comments start with '#'
+//	resize 1024 768
+//	annotate "Hello, World!" Arial 24 10 50 "#ffffff"
+//
+// Arguments are separated by whitespace; single or double quotes group an
+// argument containing spaces. Enum arguments may use their textual names since
+// every step is normalized through the command metadata before it is applied.
+func ParseRecipe(r io.Reader) ([]RecipeStep, error) {
+	var steps []RecipeStep
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := splitRecipeLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("recipe line %d: %w", lineNo, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		steps = append(steps, RecipeStep{Command: fields[0], Args: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read recipe: %w", err)
+	}
+	return steps, nil
+}
+
+// LoadRecipe reads and parses a recipe file from disk.
+func LoadRecipe(path string) ([]RecipeStep, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, withExitCode(exitRead, fmt.Errorf("open recipe: %w", err))
+	}
+	defer f.Close()
+	steps, err := ParseRecipe(f)
+	return steps, withExitCode(exitValidation, err)
+}
+
+// ApplyRecipe normalizes each step against the metadata store and applies it to
+// the wand in order. Steps apply to every frame of multi-frame images. It stops
+// at the first failing step.
+func ApplyRecipe(store *MetaStore, wand *imagick.MagickWand, steps []RecipeStep) error {
+	for i, step := range steps {
+		normArgs, err := NormalizeArgs(store, step.Command, step.Args)
+		if err != nil {
+			return withExitCode(exitValidation, fmt.Errorf("step %d (%s): %w", i+1, step.Command, err))
+		}
+		err = traceStep(RecipeStep{Command: step.Command, Args: normArgs}, func() *imagick.MagickWand { return wand }, func() error {
+			return ApplyCommandFrames(wand, step.Command, normArgs, true)
+		})
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Command, err)
+		}
+	}
+	return nil
+}
+
+// splitRecipeLine splits a recipe line into whitespace-separated fields,
+// honoring single and double quotes. Quotes are stripped from the result.
+func splitRecipeLine(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	inField := false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
+
+// quoteRecipeArg quotes an argument if it would otherwise be split or empty.
+// An argument with both kinds of quote is single-quoted with each ' written
+// as '"'"', which splitRecipeLine joins back into one field.
+func quoteRecipeArg(a string) string {
+	if a == "" || strings.ContainsAny(a, " \t'\"") {
+		if strings.Contains(a, `"`) {
+			return "'" + strings.ReplaceAll(a, "'", `'"'"'`) + "'"
+		}
+		return `"` + a + `"`
+	}
+	return a
+}
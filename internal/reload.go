@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// User metadata and live reload.
+//
+// commands.json in the termagick config dir adjusts the metadata of existing
+// commands: descriptions, and the hints, examples, units and limits of their
+// parameters, e.g. to keep favourite values at hand as examples or to narrow
+// a range. It is a JSON list of CommandMeta (see meta.go) in which only the
+// names and the fields being changed need to be given:
+//
+//	[
+//	  {"name": "resize", "params": [{"name": "width", "example": "1920"}]},
+//	  {"name": "compress", "params": [{"name": "quality", "min": 70}]}
+//	]
+//
+// Parameter types, enum options and the order of parameters are fixed by
+// the code that runs a command and cannot be changed.
+//
+// An interactive session watches the config dir and picks up changes to
+// config.toml, commands.json and the script commands without a restart, so
+// the image being edited and its history are kept. Changes take effect
+// before the next key is handled; a file that fails to load is reported and
+// the previous settings stay in effect. Macros need no reload: they are read
+// each time one is played.
+
+// userMetaFile is the name of the user metadata file in the config dir.
+const userMetaFile = "commands.json"
+
+// builtinCommands is the compiled-in metadata, before script commands and
+// commands.json are merged into Commands.
+var builtinCommands = append([]CommandMeta(nil), Commands...)
+
+// LoadUserMeta merges commands.json, if present, into Commands.
+func LoadUserMeta() error {
+	cmds, err := mergeUserMeta(Commands)
+	if err != nil {
+		return err
+	}
+	Commands = cmds
+	return nil
+}
+
+// mergeUserMeta returns cmds with commands.json, if present, merged in.
+func mergeUserMeta(cmds []CommandMeta) ([]CommandMeta, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, userMetaFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return cmds, nil
+	}
+	overrides, err := LoadCommandMetaFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cmds, err = applyUserMeta(cmds, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cmds, nil
+}
+
+// applyUserMeta returns a copy of cmds with the changes from overrides
+// applied. Every override must name an existing command and parameter.
+func applyUserMeta(cmds, overrides []CommandMeta) ([]CommandMeta, error) {
+	out := append([]CommandMeta(nil), cmds...)
+	for _, o := range overrides {
+		c := GetCommandMetaByName(out, o.Name)
+		if c == nil {
+			return nil, fmt.Errorf("unknown command %q", o.Name)
+		}
+		if o.Description != "" {
+			c.Description = o.Description
+		}
+		// Copy the params so the built-in metadata is left untouched.
+		params := append([]ParamMeta(nil), c.Params...)
+		for _, op := range o.Params {
+			i := 0
+			for i < len(params) && params[i].Name != op.Name {
+				i++
+			}
+			if i == len(params) {
+				return nil, fmt.Errorf("%s has no parameter %q", o.Name, op.Name)
+			}
+			p := &params[i]
+			if op.Hint != "" {
+				p.Hint = op.Hint
+			}
+			if op.Example != "" {
+				p.Example = op.Example
+			}
+			if op.Unit != "" {
+				p.Unit = op.Unit
+			}
+			if op.Min != nil {
+				p.Min = op.Min
+			}
+			if op.Max != nil {
+				p.Max = op.Max
+			}
+		}
+		c.Params = params
+	}
+	return out, nil
+}
+
+// userFiles guards config, Commands and scriptCommands, which
+// reloadUserFiles replaces during a session, against the background jobs
+// that read them: each job item runs under a read lock (see jobQueue.start).
+// The session's goroutine, the only writer, reads them without it.
+var userFiles sync.RWMutex
+
+// userFileWatch holds the user files watchUserFiles has seen change since
+// the last reloadUserFiles.
+var userFileWatch struct {
+	sync.Mutex
+	// changed holds "config.toml", userMetaFile and "scripts".
+	changed map[string]bool
+}
+
+// watchUserFiles starts watching the config dir and its scripts dir for
+// changes to the files reloadUserFiles reads. It returns a function that
+// stops watching. Failing to watch only costs the live reload, so errors are
+// logged with --trace and otherwise ignored.
+func watchUserFiles() func() {
+	dir, err := configDir()
+	if err != nil {
+		debugf("watch config: %v", err)
+		return func() {}
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		debugf("watch config: %v", err)
+		return func() {}
+	}
+	scripts, err := scriptDir()
+	if err != nil {
+		watcher.Close()
+		debugf("watch config: %v", err)
+		return func() {}
+	}
+	// Watch the directories rather than the files, as in watch mode: editors
+	// often save by renaming a new file over the old one.
+	for _, d := range []string{dir, scripts} {
+		if err := watcher.Add(d); err != nil {
+			debugf("watch %s: %v", d, err)
+		}
+	}
+	go func() {
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Remove) && !ev.Has(fsnotify.Rename) {
+					continue
+				}
+				name := filepath.Clean(ev.Name)
+				kind := ""
+				switch {
+				case name == filepath.Join(dir, "config.toml"):
+					kind = "config.toml"
+				case name == filepath.Join(dir, userMetaFile):
+					kind = userMetaFile
+				case name == scripts:
+					// The scripts dir was made after startup.
+					if ev.Has(fsnotify.Create) {
+						watcher.Add(scripts)
+					}
+					kind = "scripts"
+				case filepath.Dir(name) == scripts && filepath.Ext(name) == ".lua":
+					kind = "scripts"
+				default:
+					continue
+				}
+				userFileWatch.Lock()
+				if userFileWatch.changed == nil {
+					userFileWatch.changed = map[string]bool{}
+				}
+				userFileWatch.changed[kind] = true
+				userFileWatch.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				debugf("watch config: %v", err)
+			}
+		}
+	}()
+	return func() { watcher.Close() }
+}
+
+// reloadUserFiles reloads the user files that changed since the last call
+// and gives sess a new store for the new command list. It runs on the
+// session's goroutine, between keys. The new settings and commands are built
+// aside and swapped in under userFiles, which waits for the job items in
+// progress, so background jobs never see them change halfway through an item.
+func reloadUserFiles(sess *Session) {
+	userFileWatch.Lock()
+	changed := userFileWatch.changed
+	userFileWatch.changed = nil
+	userFileWatch.Unlock()
+	if len(changed) == 0 {
+		return
+	}
+
+	cfg := config
+	if changed["config.toml"] {
+		next, err := readConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "config: %v (keeping the previous settings)\n", err)
+		} else {
+			// --no-exec cannot be lifted by editing the config file.
+			next.NoExec = next.NoExec || config.NoExec
+			cfg = next
+			fmt.Println("Reloaded config.toml")
+		}
+	}
+
+	cmds, scripts := Commands, scriptCommands
+	if changed[userMetaFile] || changed["scripts"] {
+		// Both feed into Commands, so it is rebuilt from the built-in
+		// commands; the previous list stays if the rebuild fails.
+		next, nextScripts, err := loadScripts(append([]CommandMeta(nil), builtinCommands...))
+		if err != nil {
+			// Scripts that load are kept, as at startup.
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		if next, err = mergeUserMeta(next); err != nil {
+			fmt.Fprintf(os.Stderr, "%v (keeping the previous commands)\n", err)
+		} else {
+			cmds, scripts = next, nextScripts
+			fmt.Printf("Reloaded commands (%d script commands)\n", len(scripts))
+		}
+	}
+
+	if !userFiles.TryLock() {
+		fmt.Println("Waiting for the background jobs to finish their current item...")
+		userFiles.Lock()
+	}
+	config, Commands, scriptCommands = cfg, cmds, scripts
+	userFiles.Unlock()
+	sess.Store = NewMetaStore(Commands)
+}
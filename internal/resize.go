@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Resize sizes.
+//
+// resize takes each side in pixels or as a percentage of the current size,
+// and a side given as 0, or a height left empty, follows from the other
+// one's scale, so the aspect ratio is kept: `resize 800 0`, `resize 0 600`
+// and `resize 50%` all scale proportionally, while `resize 1600 1200` sets
+// the exact size as before. The width may instead hold a whole ImageMagick
+// geometry (800x, x600, 50%, 1600x1200> to only shrink, 1080x1080^ to fill).
+
+// resizeDimensions returns the size the resize command's width and height
+// arguments ask for, given the current image of wand. ok is false when a >
+// or < geometry leaves the image as it is.
+func resizeDimensions(wand *imagick.MagickWand, width, height string) (w, h uint, ok bool, err error) {
+	if strings.Contains(width, "x") {
+		if height != "" && height != "0" {
+			return 0, 0, false, fmt.Errorf("give either a geometry such as %s as the width, or a width and a height", width)
+		}
+		return resolveResizeGeometry(wand, width)
+	}
+	cw, ch := float64(wand.GetImageWidth()), float64(wand.GetImageHeight())
+	if cw == 0 || ch == 0 {
+		return 0, 0, false, fmt.Errorf("image has zero dimensions")
+	}
+	side := func(name, arg string, current float64) (float64, error) {
+		if arg == "" {
+			return 0, nil
+		}
+		num, percent := strings.CutSuffix(arg, "%")
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid %s %q: use pixels, a percentage such as 50%%, or 0 to keep the aspect ratio", name, arg)
+		}
+		if percent {
+			v = current * v / 100
+		}
+		return v, nil
+	}
+	nw, err := side("width", width, cw)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	nh, err := side("height", height, ch)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	switch {
+	case nw == 0 && nh == 0:
+		return 0, 0, false, fmt.Errorf("resize needs a width or a height")
+	case nw == 0:
+		nw = nh * cw / ch
+	case nh == 0:
+		nh = nw * ch / cw
+	}
+	return uint(math.Max(1, math.Round(nw))), uint(math.Max(1, math.Round(nh))), true, nil
+}
+
+// resizeGeometry returns the ImageMagick geometry for the resize command's
+// width and height arguments, for toMagickCmd.
+func resizeGeometry(width, height string) (string, error) {
+	if strings.Contains(width, "x") {
+		return shellQuote(width), nil
+	}
+	if width == "0" {
+		width = ""
+	}
+	if height == "0" {
+		height = ""
+	}
+	wPercent, hPercent := strings.HasSuffix(width, "%"), strings.HasSuffix(height, "%")
+	switch {
+	case width != "" && height != "" && wPercent != hPercent:
+		return "", fmt.Errorf("resize mixing pixels and a percentage has no magick CLI equivalent")
+	case width != "" && height != "" && !wPercent:
+		return width + "x" + height + "!", nil
+	case width != "" && height != "":
+		return width + "x" + height, nil
+	case wPercent:
+		return width, nil
+	case hPercent:
+		// A percentage applies to both sides, as when the width is given.
+		return height, nil
+	case width == "" && height == "":
+		return "", fmt.Errorf("resize needs a width or a height")
+	}
+	return width + "x" + height, nil
+}
+
+// ResizeLinear resizes the current image of wand in linear RGB, converting
+// it back to sRGB (or grayscale) afterwards.
+func ResizeLinear(wand *imagick.MagickWand, width, height uint, filter imagick.FilterType) error {
+	colorspace := wand.GetImageColorspace()
+	switch colorspace {
+	case imagick.COLORSPACE_SRGB, imagick.COLORSPACE_GRAY:
+	case imagick.COLORSPACE_RGB:
+		// Already linear.
+		return wand.ResizeImage(width, height, filter)
+	default:
+		return fmt.Errorf("resizeLinear works on sRGB and grayscale images, not %s; use resize", colorspaceNames[colorspace])
+	}
+	if err := wand.TransformImageColorspace(imagick.COLORSPACE_RGB); err != nil {
+		return fmt.Errorf("failed to convert to linear RGB: %w", err)
+	}
+	if err := wand.ResizeImage(width, height, filter); err != nil {
+		return err
+	}
+	if err := wand.TransformImageColorspace(imagick.COLORSPACE_SRGB); err != nil {
+		return fmt.Errorf("failed to convert back to sRGB: %w", err)
+	}
+	if colorspace == imagick.COLORSPACE_GRAY {
+		return wand.TransformImageColorspace(imagick.COLORSPACE_GRAY)
+	}
+	return nil
+}
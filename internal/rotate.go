@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// RotateImage rotates the current image of wand clockwise by degrees, filling
+// the corners the rotation uncovers with background (any ImageMagick color,
+// including "transparent"). With autoCrop the result is cropped to the
+// largest upright rectangle that holds only image pixels, so a straightened
+// horizon leaves no corners to fill.
+func RotateImage(wand *imagick.MagickWand, degrees float64, background string, autoCrop bool) error {
+	bg, err := uncoveredFill(wand, background)
+	if err != nil {
+		return err
+	}
+	defer bg.Destroy()
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if err := wand.RotateImage(bg, degrees); err != nil {
+		return err
+	}
+	if !autoCrop || math.Mod(degrees, 90) == 0 {
+		return nil
+	}
+	cw, ch := inscribedSize(float64(w), float64(h), degrees)
+	rw, rh := wand.GetImageWidth(), wand.GetImageHeight()
+	x := (int(rw) - int(cw)) / 2
+	y := (int(rh) - int(ch)) / 2
+	if err := wand.CropImage(cw, ch, x, y); err != nil {
+		return fmt.Errorf("failed to crop rotated image: %w", err)
+	}
+	return wand.SetImagePage(cw, ch, 0, 0)
+}
+
+// ShearImage slants the current image of wand: rows slide sideways by
+// xDegrees and columns slide up or down by yDegrees. The triangles it
+// uncovers are filled with background as for RotateImage, and the image
+// grows to hold the slanted result.
+func ShearImage(wand *imagick.MagickWand, xDegrees, yDegrees float64, background string) error {
+	if math.Abs(xDegrees) >= 90 || math.Abs(yDegrees) >= 90 {
+		return fmt.Errorf("shear angles must be between -90 and 90 degrees")
+	}
+	bg, err := uncoveredFill(wand, background)
+	if err != nil {
+		return err
+	}
+	defer bg.Destroy()
+	if err := wand.ShearImage(bg, xDegrees, yDegrees); err != nil {
+		return err
+	}
+	return wand.SetImagePage(wand.GetImageWidth(), wand.GetImageHeight(), 0, 0)
+}
+
+// uncoveredFill returns a pixel wand of the background color for the areas
+// a rotation or shear uncovers, and gives the image an alpha channel first
+// when the color is transparent.
+func uncoveredFill(wand *imagick.MagickWand, background string) (*imagick.PixelWand, error) {
+	bg := imagick.NewPixelWand()
+	if !bg.SetColor(background) {
+		bg.Destroy()
+		return nil, fmt.Errorf("invalid background color %q", background)
+	}
+	if bg.GetAlpha() < 1 && !wand.GetImageAlphaChannel() {
+		// A transparent fill needs an alpha channel to land in.
+		if err := wand.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET); err != nil {
+			bg.Destroy()
+			return nil, fmt.Errorf("failed to add alpha channel: %w", err)
+		}
+	}
+	return bg, nil
+}
+
+// inscribedSize returns the size of the largest axis-aligned rectangle that
+// fits inside a w x h rectangle rotated by degrees, less a pixel on each side
+// for the antialiased edge.
+func inscribedSize(w, h, degrees float64) (uint, uint) {
+	rad := degrees * math.Pi / 180
+	sin, cos := math.Abs(math.Sin(rad)), math.Abs(math.Cos(rad))
+	long, short := math.Max(w, h), math.Min(w, h)
+	var cw, ch float64
+	if short <= 2*sin*cos*long || math.Abs(sin-cos) < 1e-10 {
+		// Thin images: the rectangle touches both long sides.
+		half := short / 2
+		if w >= h {
+			cw, ch = half/sin, half/cos
+		} else {
+			cw, ch = half/cos, half/sin
+		}
+	} else {
+		cos2 := cos*cos - sin*sin
+		cw, ch = (w*cos-h*sin)/cos2, (h*cos-w*sin)/cos2
+	}
+	return uint(max(1, math.Floor(cw)-2)), uint(max(1, math.Floor(ch)-2))
+}
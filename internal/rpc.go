@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Machine mode.
+//
+// `termagick rpc` lets another program (an editor plugin, a GUI) drive a
+// session without faking keystrokes: it reads one JSON request per line on
+// stdin and writes one JSON response per line on stdout, in order.
+//
+//	{"id": 1, "cmd": "open", "args": ["photo.jpg"]}
+//	{"id": 2, "cmd": "blur", "args": [0, 1.5]}
+//	{"id": 3, "cmd": "save", "args": ["out.png"]}
+//
+// cmd is any command from the command list, with args given as in a recipe
+// (strings, numbers or booleans), or one of the control commands in
+// rpcControls. Text a command prints (identify, fonts...) is returned in the
+// response's output field; stdout carries nothing but responses.
+
+// rpcRequest is one line of input.
+type rpcRequest struct {
+	ID   json.RawMessage `json:"id,omitempty"`
+	Cmd  string          `json:"cmd"`
+	Args []interface{}   `json:"args,omitempty"`
+}
+
+// rpcResponse is one line of output. Result is the image state after image
+// commands, or the control command's own value.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	OK     bool            `json:"ok"`
+	Error  string          `json:"error,omitempty"`
+	Output string          `json:"output,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+}
+
+// rpcImageState describes the session's active image.
+type rpcImageState struct {
+	Buffer  string   `json:"buffer"`
+	Path    string   `json:"path,omitempty"`
+	Format  string   `json:"format"`
+	Width   uint     `json:"width"`
+	Height  uint     `json:"height"`
+	Frames  uint     `json:"frames"`
+	Layers  int      `json:"layers"`
+	History []string `json:"history"`
+}
+
+// rpcControls are the commands machine mode adds to the command list.
+var rpcControls = map[string]func(s *Session, args []string) (interface{}, error){
+	// open <path>: open an image in a new buffer and make it active.
+	"open": func(s *Session, args []string) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("open requires a path")
+		}
+		if err := s.OpenBuffer(args[0]); err != nil {
+			return nil, err
+		}
+		return rpcState(s)
+	},
+	// save <path>: write the active image, layers flattened.
+	"save": func(s *Session, args []string) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("save requires a path")
+		}
+		if s.Wand == nil {
+			return nil, fmt.Errorf("no image loaded")
+		}
+		return nil, s.Save(args[0])
+	},
+	// info: the state of the active image.
+	"info": func(s *Session, args []string) (interface{}, error) {
+		return rpcState(s)
+	},
+	// render [format]: the active image encoded as base64 (default png).
+	"render": func(s *Session, args []string) (interface{}, error) {
+		if s.Wand == nil {
+			return nil, fmt.Errorf("no image loaded")
+		}
+		format := "png"
+		if len(args) > 0 && args[0] != "" {
+			format = args[0]
+		}
+		img := s.Display().GetImage()
+		defer img.Destroy()
+		if err := img.SetImageFormat(format); err != nil {
+			return nil, fmt.Errorf("unsupported format %s: %w", format, err)
+		}
+		blob, err := img.GetImageBlob()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		return map[string]string{"format": format, "data": base64.StdEncoding.EncodeToString(blob)}, nil
+	},
+	// commands: the metadata of every command, as used for prompts.
+	"commands": func(s *Session, args []string) (interface{}, error) {
+		return Commands, nil
+	},
+	// buffer <name|number>: make another open image active.
+	"buffer": func(s *Session, args []string) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("buffer requires a name or number")
+		}
+		idx := s.findBuffer(args[0])
+		if idx < 0 {
+			return nil, fmt.Errorf("no open image named %q", args[0])
+		}
+		s.CycleBuffer(idx - s.current)
+		return rpcState(s)
+	},
+}
+
+// RunRPC implements `termagick rpc [image...]`.
+func RunRPC(args []string) error {
+	fs := flag.NewFlagSet("rpc", flag.ContinueOnError)
+	addTraceFlag(fs)
+	addNoExecFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	// Responses own stdout; anything else printed outside a request (job
+	// notifications, warnings) goes to stderr, and previews are off.
+	out := json.NewEncoder(os.Stdout)
+	os.Stdout = os.Stderr
+	config.PreviewBackend = "none"
+
+	sess := NewSession(NewMetaStore(Commands))
+	defer sess.Close()
+	for _, path := range positional {
+		if err := sess.OpenBuffer(path); err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	if len(positional) > 0 {
+		sess.CycleBuffer(1)
+	}
+
+	sc := bufio.NewScanner(os.Stdin)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			out.Encode(rpcResponse{Error: "invalid request: " + err.Error()})
+			continue
+		}
+		if req.Cmd == "quit" {
+			out.Encode(rpcResponse{ID: req.ID, OK: true})
+			break
+		}
+		resp := handleRPC(sess, req)
+		resp.ID = req.ID
+		if err := out.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// handleRPC runs one request against the session.
+func handleRPC(sess *Session, req rpcRequest) rpcResponse {
+	args := make([]string, len(req.Args))
+	for i, a := range req.Args {
+		args[i] = rpcArgString(a)
+	}
+
+	var result interface{}
+	output, err := captureStdout(func() error {
+		if control, ok := rpcControls[req.Cmd]; ok {
+			var err error
+			result, err = control(sess, args)
+			return err
+		}
+		normArgs, err := NormalizeArgs(sess.Store, req.Cmd, args)
+		if err != nil {
+			return err
+		}
+		if err := sess.Apply(req.Cmd, normArgs); err != nil {
+			return err
+		}
+		if sess.Wand != nil {
+			result, err = rpcState(sess)
+		}
+		return err
+	})
+	if err != nil {
+		return rpcResponse{Error: err.Error(), Output: output}
+	}
+	return rpcResponse{OK: true, Output: output, Result: result}
+}
+
+// rpcArgString converts a JSON argument to the string form commands take.
+func rpcArgString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// rpcState returns the state of the active image.
+func rpcState(s *Session) (interface{}, error) {
+	if s.Wand == nil {
+		return nil, fmt.Errorf("no image loaded")
+	}
+	history := make([]string, len(s.History))
+	for i, step := range s.History {
+		history[i] = step.String()
+	}
+	return rpcImageState{
+		Buffer:  s.BufferName(),
+		Path:    s.Path,
+		Format:  s.Wand.GetImageFormat(),
+		Width:   s.Wand.GetImageWidth(),
+		Height:  s.Wand.GetImageHeight(),
+		Frames:  s.Wand.GetNumberImages(),
+		Layers:  len(s.Layers),
+		History: history,
+	}, nil
+}
+
+// captureStdout runs fn with os.Stdout redirected and returns what it printed.
+func captureStdout(fn func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", fn()
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		r.Close()
+		done <- string(b)
+	}()
+	ferr := fn()
+	os.Stdout = orig
+	w.Close()
+	return <-done, ferr
+}
@@ -0,0 +1,397 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Script commands.
+//
+// Every *.lua file in the scripts directory of the termagick config dir
+// defines one command. The file declares the command's metadata with
+// command{...} and implements it in a global run(img, args) function:
+//
+//	command {
+//	  name = "duotone",
+//	  description = "Map shadows and highlights to two colors",
+//	  params = {
+//	    { name = "dark",  type = "string", required = true, example = "#1d2b53" },
+//	    { name = "light", type = "string", required = true, example = "#ffcc66" },
+//	  },
+//	}
+//
+//	function run(img, args)
+//	  img:apply("modulate", 100, 0, 100)
+//	  ...
+//	end
+//
+// Script commands are appended to Commands at startup, and again when the
+// scripts change during a session (see reload.go), so they appear in the
+// command selector, recipes and macros like built-in ones. Scripts run in a
+// Lua state with only the base, table, string and math libraries; the image
+// is manipulated through the methods in scriptImageMethods.
+
+// scriptCommands maps a script command's name to its file.
+var scriptCommands = map[string]string{}
+
+// maxScriptDepth bounds scripts applying other scripts (or themselves).
+const maxScriptDepth = 8
+
+// scriptDir returns the directory script commands are loaded from.
+func scriptDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "scripts"), nil
+}
+
+// LoadScripts registers the script commands found in the scripts directory.
+// Scripts that fail to load are reported and skipped; the error lists them.
+func LoadScripts() error {
+	cmds, scripts, err := loadScripts(Commands)
+	Commands = cmds
+	maps.Copy(scriptCommands, scripts)
+	return err
+}
+
+// loadScripts returns cmds followed by the script commands found in the
+// scripts directory, and a map of their names to their files. Both hold the
+// scripts that loaded even when the error lists some that failed.
+func loadScripts(cmds []CommandMeta) ([]CommandMeta, map[string]string, error) {
+	scripts := map[string]string{}
+	dir, err := scriptDir()
+	if err != nil {
+		return cmds, scripts, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return cmds, scripts, err
+	}
+	sort.Strings(paths)
+	var failed []string
+	for _, path := range paths {
+		meta, err := loadScriptMeta(path)
+		if err == nil && GetCommandMetaByName(cmds, meta.Name) != nil {
+			err = fmt.Errorf("command %s already exists", meta.Name)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", filepath.Base(path), err))
+			continue
+		}
+		cmds = append(cmds, meta)
+		scripts[meta.Name] = path
+	}
+	if len(failed) > 0 {
+		return cmds, scripts, fmt.Errorf("failed to load scripts:\n  %s", strings.Join(failed, "\n  "))
+	}
+	return cmds, scripts, nil
+}
+
+// newScriptState returns a Lua state with the safe standard libraries open.
+func newScriptState() (*lua.LState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	libs := []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	}
+	for _, lib := range libs {
+		if err := L.CallByParam(lua.P{Fn: L.NewFunction(lib.open), NRet: 0, Protect: true}, lua.LString(lib.name)); err != nil {
+			L.Close()
+			return nil, err
+		}
+	}
+	return L, nil
+}
+
+// loadScriptMeta runs a script's top level and returns the command it declares.
+func loadScriptMeta(path string) (CommandMeta, error) {
+	L, err := newScriptState()
+	if err != nil {
+		return CommandMeta{}, err
+	}
+	defer L.Close()
+
+	var meta CommandMeta
+	var declErr error
+	declared := false
+	L.SetGlobal("command", L.NewFunction(func(L *lua.LState) int {
+		meta, declErr = scriptMetaFromTable(L.CheckTable(1))
+		declared = true
+		return 0
+	}))
+	if err := L.DoFile(path); err != nil {
+		return CommandMeta{}, err
+	}
+	if !declared {
+		return CommandMeta{}, fmt.Errorf("no command{...} declaration")
+	}
+	if declErr != nil {
+		return CommandMeta{}, declErr
+	}
+	if L.GetGlobal("run").Type() != lua.LTFunction {
+		return CommandMeta{}, fmt.Errorf("no run(img, args) function")
+	}
+	return meta, nil
+}
+
+// scriptMetaFromTable converts the table passed to command{...}.
+func scriptMetaFromTable(tbl *lua.LTable) (CommandMeta, error) {
+	str := func(t *lua.LTable, key string) string {
+		if v := t.RawGetString(key); v != lua.LNil {
+			return lua.LVAsString(v)
+		}
+		return ""
+	}
+	num := func(t *lua.LTable, key string) *float64 {
+		if v, ok := t.RawGetString(key).(lua.LNumber); ok {
+			return float64Ptr(float64(v))
+		}
+		return nil
+	}
+
+	meta := CommandMeta{Name: str(tbl, "name"), Description: str(tbl, "description"), Params: []ParamMeta{}}
+	if meta.Name == "" || strings.ContainsAny(meta.Name, " \t") {
+		return meta, fmt.Errorf("command needs a name without spaces")
+	}
+	if meta.Description == "" {
+		meta.Description = "Script command"
+	}
+	params, ok := tbl.RawGetString("params").(*lua.LTable)
+	if !ok {
+		return meta, nil
+	}
+	for i := 1; i <= params.Len(); i++ {
+		pt, ok := params.RawGetInt(i).(*lua.LTable)
+		if !ok {
+			return meta, fmt.Errorf("param %d is not a table", i)
+		}
+		p := ParamMeta{
+			Name:     str(pt, "name"),
+			Type:     ParamType(str(pt, "type")),
+			Required: lua.LVAsBool(pt.RawGetString("required")),
+			Min:      num(pt, "min"),
+			Max:      num(pt, "max"),
+			Unit:     str(pt, "unit"),
+			Hint:     str(pt, "hint"),
+			Example:  str(pt, "example"),
+		}
+		if p.Name == "" {
+			return meta, fmt.Errorf("param %d has no name", i)
+		}
+		if p.Type == "" {
+			p.Type = ParamTypeString
+		}
+		switch p.Type {
+		case ParamTypeInt, ParamTypeFloat, ParamTypeBool, ParamTypeString, ParamTypePercent, ParamTypeQuantum:
+		case ParamTypeEnum:
+			opts, ok := pt.RawGetString("options").(*lua.LTable)
+			if !ok || opts.Len() == 0 {
+				return meta, fmt.Errorf("enum param %s needs options", p.Name)
+			}
+			for j := 1; j <= opts.Len(); j++ {
+				p.EnumOptions = append(p.EnumOptions, lua.LVAsString(opts.RawGetInt(j)))
+			}
+		default:
+			return meta, fmt.Errorf("param %s has unknown type %q", p.Name, p.Type)
+		}
+		meta.Params = append(meta.Params, p)
+	}
+	return meta, nil
+}
+
+// scriptImage is the Lua-side handle of a wand.
+type scriptImage struct {
+	wand *imagick.MagickWand
+}
+
+// scriptImageMethods returns the methods of image values in a script running
+// at the given nesting depth.
+func scriptImageMethods(depth int) map[string]lua.LGFunction {
+	return map[string]lua.LGFunction{
+		// img:apply(command, args...) runs any built-in or script command.
+		"apply": func(L *lua.LState) int {
+			img := checkScriptImage(L, 1)
+			name := L.CheckString(2)
+			if isSessionCommand(name) {
+				L.RaiseError("%s cannot be used from a script", name)
+			}
+			var raw []string
+			for i := 3; i <= L.GetTop(); i++ {
+				raw = append(raw, luaArgString(L.Get(i)))
+			}
+			args, err := NormalizeArgs(NewMetaStore(Commands), name, raw)
+			if err == nil {
+				if _, ok := scriptCommands[name]; ok {
+					err = runScript(img.wand, name, args, depth+1)
+				} else {
+					err = ApplyCommand(img.wand, name, args)
+				}
+			}
+			if err != nil {
+				L.RaiseError("%s: %v", name, err)
+			}
+			return 0
+		},
+		"width": func(L *lua.LState) int {
+			L.Push(lua.LNumber(checkScriptImage(L, 1).wand.GetImageWidth()))
+			return 1
+		},
+		"height": func(L *lua.LState) int {
+			L.Push(lua.LNumber(checkScriptImage(L, 1).wand.GetImageHeight()))
+			return 1
+		},
+		// img:pixel(x, y) returns r, g, b, a in the range 0-1.
+		"pixel": func(L *lua.LState) int {
+			img := checkScriptImage(L, 1)
+			px, err := img.wand.GetImagePixelColor(L.CheckInt(2), L.CheckInt(3))
+			if err != nil {
+				L.RaiseError("pixel: %v", err)
+			}
+			defer px.Destroy()
+			L.Push(lua.LNumber(px.GetRed()))
+			L.Push(lua.LNumber(px.GetGreen()))
+			L.Push(lua.LNumber(px.GetBlue()))
+			L.Push(lua.LNumber(px.GetAlpha()))
+			return 4
+		},
+		// img:clone() returns an independent copy of the current image.
+		"clone": func(L *lua.LState) int {
+			img := checkScriptImage(L, 1)
+			L.Push(newScriptImage(L, img.wand.GetImage()))
+			return 1
+		},
+		// img:composite(src, operator, x, y) draws src onto img, e.g. "MULTIPLY".
+		"composite": func(L *lua.LState) int {
+			img := checkScriptImage(L, 1)
+			src := checkScriptImage(L, 2)
+			op := L.OptString(3, "OVER")
+			val, ok := mapEnumToNumeric("composeOperator", op)
+			if !ok {
+				L.ArgError(3, "unknown compose operator "+op)
+			}
+			id, _ := strconv.ParseInt(val, 10, 64)
+			if err := img.wand.CompositeImage(src.wand, imagick.CompositeOperator(id), true, L.OptInt(4, 0), L.OptInt(5, 0)); err != nil {
+				L.RaiseError("composite: %v", err)
+			}
+			return 0
+		},
+	}
+}
+
+// scriptImageType is the Lua metatable name of image values.
+const scriptImageType = "termagick.image"
+
+// clonesKey is the registry field listing the wands a run created.
+const clonesKey = "termagick.clones"
+
+func newScriptImage(L *lua.LState, wand *imagick.MagickWand) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &scriptImage{wand: wand}
+	L.SetMetatable(ud, L.GetTypeMetatable(scriptImageType))
+	if clones, ok := L.GetField(L.Get(lua.RegistryIndex), clonesKey).(*lua.LTable); ok {
+		clones.Append(ud)
+	}
+	return ud
+}
+
+func checkScriptImage(L *lua.LState, n int) *scriptImage {
+	if img, ok := L.CheckUserData(n).Value.(*scriptImage); ok {
+		return img
+	}
+	L.ArgError(n, "image expected")
+	return nil
+}
+
+// luaArgString converts a Lua argument to the string form commands take.
+func luaArgString(v lua.LValue) string {
+	switch v := v.(type) {
+	case lua.LNumber:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64)
+	case lua.LBool:
+		return strconv.FormatBool(bool(v))
+	}
+	return lua.LVAsString(v)
+}
+
+// runScript applies the script command name to the current image of wand
+// with normalized args. depth is the number of scripts the call is nested in.
+func runScript(wand *imagick.MagickWand, name string, args []string, depth int) error {
+	path := scriptCommands[name]
+	meta := GetCommandMetaByName(Commands, name)
+	if meta == nil {
+		return fmt.Errorf("unknown command: %s", name)
+	}
+	// depth is threaded through instead of kept globally so that scripts
+	// running concurrently in batch workers do not count against each other.
+	if depth >= maxScriptDepth {
+		return fmt.Errorf("%s: scripts nested too deeply", name)
+	}
+
+	L, err := newScriptState()
+	if err != nil {
+		return err
+	}
+	defer L.Close()
+	L.SetGlobal("command", L.NewFunction(func(L *lua.LState) int { return 0 }))
+	mt := L.NewTypeMetatable(scriptImageType)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), scriptImageMethods(depth)))
+	L.SetField(L.Get(lua.RegistryIndex), clonesKey, L.NewTable())
+	if err := L.DoFile(path); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	// Pass arguments by name: numbers and booleans as Lua values, enums as
+	// option names, omitted optional values as nil.
+	argTable := L.NewTable()
+	for i, p := range meta.Params {
+		if i >= len(args) || args[i] == "" {
+			continue
+		}
+		var v lua.LValue = lua.LString(args[i])
+		switch p.Type {
+		case ParamTypeInt, ParamTypeFloat, ParamTypePercent, ParamTypeQuantum:
+			// Quantum percentages ("50%") stay strings.
+			if f, err := strconv.ParseFloat(args[i], 64); err == nil {
+				v = lua.LNumber(f)
+			}
+		case ParamTypeBool:
+			v = lua.LBool(args[i] == "true")
+		case ParamTypeEnum:
+			if idx, err := strconv.Atoi(args[i]); err == nil && idx >= 0 && idx < len(p.EnumOptions) {
+				v = lua.LString(p.EnumOptions[idx])
+			}
+		}
+		argTable.RawSetString(p.Name, v)
+	}
+
+	// The image handle shares the caller's wand; clones are destroyed afterwards.
+	img := L.NewUserData()
+	img.Value = &scriptImage{wand: wand}
+	L.SetMetatable(img, L.GetTypeMetatable(scriptImageType))
+	defer func() {
+		clones := L.GetField(L.Get(lua.RegistryIndex), clonesKey).(*lua.LTable)
+		clones.ForEach(func(_, v lua.LValue) {
+			if ud, ok := v.(*lua.LUserData); ok {
+				ud.Value.(*scriptImage).wand.Destroy()
+			}
+		})
+	}()
+
+	if err := L.CallByParam(lua.P{Fn: L.GetGlobal("run"), NRet: 0, Protect: true}, img, argTable); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Session holds the state of an interactive editing session: the image being
+// edited, where it came from and the commands applied to it so far.
+type Session struct {
+	Store *MetaStore
+	// Wand is the image being edited; nil until an image is opened or generated.
+	Wand *imagick.MagickWand
+	// Path is the file the current image was read from ("" for generated images).
+	Path string
+	// AllFrames controls whether commands apply to every frame of an animation
+	// or only to the frame currently selected with '[' / ']'.
+	AllFrames bool
+	// History lists the commands applied to the current image, with normalized
+	// arguments, oldest first.
+	History []RecipeStep
+	// Invocations lists every command applied during the session, across all
+	// images, oldest first. It backs '.' (repeat) and the history browser.
+	Invocations []RecipeStep
+	// Layers are stacked above Wand (see layers.go); ActiveLayer selects the
+	// one commands edit, 0 meaning Wand itself.
+	Layers      []*Layer
+	ActiveLayer int
+
+	// display caches the last composite returned by Display.
+	display *imagick.MagickWand
+	// snapshots are the named states saved with the snapshot command, oldest first.
+	snapshots []*snapshot
+	// recording is set while a macro is being recorded into recorded (see macro.go).
+	recording bool
+	recorded  []RecipeStep
+	// jpegFile is a JPEG whose bytes exactly match Wand after lossless
+	// transforms (see lossless.go); "" once anything else changes the image.
+	jpegFile string
+	// modified is set when a step changes the image and cleared when the
+	// image is opened or saved.
+	modified bool
+	// jobs are the background jobs started from this session (see jobs.go).
+	jobs jobQueue
+	// buffers are the open images, the active one being buffers[current]
+	// (see buffers.go).
+	buffers []*buffer
+	current int
+}
+
+// NewSession creates an empty session using the given metadata store.
+func NewSession(store *MetaStore) *Session {
+	return &Session{Store: store}
+}
+
+// Open loads path into the active buffer, discarding the image it held and
+// its history. OpenBuffer keeps the previous image open instead.
+func (s *Session) Open(path string) error {
+	wand, err := LoadImage(path)
+	if err != nil {
+		return err
+	}
+	s.replace(wand, path)
+	return nil
+}
+
+// replace installs wand as the current image and resets per-image state.
+func (s *Session) replace(wand *imagick.MagickWand, path string) {
+	if s.Wand != nil {
+		s.Wand.Destroy()
+	}
+	s.clearLayers()
+	s.dropJPEGFile()
+	invalidatePreviewProxy()
+	s.Wand = wand
+	s.Path = path
+	s.History = nil
+	s.modified = false
+	name := "untitled"
+	if path != "" {
+		name = filepath.Base(path)
+	}
+	s.nameBuffer(name)
+}
+
+// Close waits for background jobs, then releases every open image, its
+// layers and all snapshots.
+func (s *Session) Close() {
+	s.jobs.stop()
+	s.releaseBuffers()
+	s.releaseSnapshots()
+	s.clearLayers()
+	s.dropJPEGFile()
+	invalidatePreviewProxy()
+	if s.display != nil {
+		s.display.Destroy()
+		s.display = nil
+	}
+	if s.Wand != nil {
+		s.Wand.Destroy()
+		s.Wand = nil
+	}
+}
+
+// record adds an applied step to the image history and the session's
+// invocations and, while recording, to the macro.
+func (s *Session) record(step RecipeStep) {
+	s.History = append(s.History, step)
+	s.recordInvocation(step)
+}
+
+// recordInvocation adds a step to the session's invocations and, while
+// recording, to the macro, but not to the image history. It is for steps
+// that can be repeated in the session but not replayed from a recipe.
+func (s *Session) recordInvocation(step RecipeStep) {
+	s.modified = true
+	s.Invocations = append(s.Invocations, step)
+	if s.recording {
+		s.recorded = append(s.recorded, step)
+	}
+}
+
+// sessionCommands are the commands Apply handles itself rather than passing
+// to ApplyCommandFrames.
+var sessionCommands = map[string]bool{
+	"toMagickCmd": true,
+	"snapshot":    true,
+	"fonts":       true,
+	"buffers":     true,
+	"clipping":    true,
+	"onionSkin":   true,
+	"editIn":      true,
+	// checkOrientation, import and shiftDates work on files and need no
+	// image.
+	"checkOrientation": true,
+	"import":           true,
+	"shiftDates":       true,
+	// restoreOriginal reopens the restored file itself.
+	"restoreOriginal": true,
+	// Channel commands open new buffers.
+	"separateChannel": true,
+	"combineChannels": true,
+}
+
+// isSessionCommand reports whether the named command needs the session (its
+// history, layers or snapshots) and so cannot be applied to a bare wand.
+func isSessionCommand(name string) bool {
+	return sessionCommands[name] || losslessCommands[name] || layerCommands[name] || jobCommands[name]
+}
+
+// Apply runs a command with already-normalized arguments. Session-level
+// commands (which need more than the wand) are handled here; everything else is
+// delegated to ApplyCommandFrames and recorded in the history.
+func (s *Session) Apply(commandName string, args []string) error {
+	invalidatePreviewProxy()
+	step := RecipeStep{Command: commandName, Args: args}
+	return traceStep(step, func() *imagick.MagickWand { return s.Wand }, func() error {
+		return s.apply(commandName, args)
+	})
+}
+
+func (s *Session) apply(commandName string, args []string) error {
+	switch commandName {
+	case "toMagickCmd":
+		output := "output.png"
+		if len(args) > 0 && args[0] != "" {
+			output = args[0]
+		}
+		line, err := MagickCommandLine(s.Path, s.History, output)
+		if err != nil {
+			return err
+		}
+		fmt.Println(line)
+		return nil
+
+	case "snapshot":
+		return s.applySnapshotCommand(args)
+
+	case "buffers":
+		s.listBuffers()
+		return nil
+
+	case "clipping":
+		value := ""
+		if len(args) > 0 {
+			value = args[0]
+		}
+		setClipping(value)
+		return nil
+
+	case "onionSkin":
+		for len(args) < 3 {
+			args = append(args, "")
+		}
+		return setOnionSkin(args[0], args[1], args[2])
+
+	case "editIn":
+		return s.applyEditIn(args)
+
+	case "checkOrientation":
+		files, err := expandFileList(args[0])
+		if err != nil {
+			return err
+		}
+		return CheckOrientation(files, len(args) > 1 && args[1] == "true")
+
+	case "import":
+		return s.applyImport(args)
+
+	case "restoreOriginal":
+		return s.applyRestoreOriginal(args)
+
+	case "shiftDates":
+		files, err := expandFileList(args[0])
+		if err != nil {
+			return err
+		}
+		return ShiftDates(files, args[1], len(args) > 2 && args[2] == "true")
+
+	case "separateChannel":
+		return s.applySeparateChannel(args)
+
+	case "combineChannels":
+		return s.applyCombineChannels(args)
+
+	case "metadata":
+		// LIST is not an edit: it is not recorded and keeps the file for
+		// lossless commands. SET and DELETE go on below.
+		if isMetadataList(args) {
+			if s.Wand == nil {
+				return fmt.Errorf("no image loaded")
+			}
+			pattern := ""
+			if len(args) > 1 {
+				pattern = args[1]
+			}
+			return ListMetadata(s.target(), pattern)
+		}
+
+	case "fonts":
+		pattern := ""
+		if len(args) > 0 {
+			pattern = args[0]
+		}
+		fonts := ListFonts(pattern)
+		for _, f := range fonts {
+			fmt.Println(f)
+		}
+		fmt.Printf("%d fonts\n", len(fonts))
+		return nil
+	}
+
+	if jobCommands[commandName] {
+		return s.applyJobCommand(commandName, args)
+	}
+
+	if losslessCommands[commandName] {
+		if err := s.applyLosslessCommand(commandName, args); err != nil {
+			return err
+		}
+		s.record(RecipeStep{Command: commandName, Args: append([]string(nil), args...)})
+		return nil
+	}
+
+	if layerCommands[commandName] {
+		if s.Wand == nil {
+			return fmt.Errorf("no image loaded")
+		}
+		resolved, release, err := s.resolveBufferArgs(args)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if err := s.applyLayerCommand(commandName, resolved); err != nil {
+			return err
+		}
+		if commandName == "addLayer" && strings.HasPrefix(args[0], bufferPrefix) {
+			s.Layers[len(s.Layers)-1].Name = strings.TrimPrefix(args[0], bufferPrefix)
+		}
+		if commandName != "layers" {
+			s.dropJPEGFile()
+			s.record(RecipeStep{Command: commandName, Args: append([]string(nil), args...)})
+		}
+		return nil
+	}
+
+	// Generator commands may run before any image is loaded; give them an empty wand.
+	created := false
+	if s.Wand == nil {
+		if !createsImage(commandName) {
+			return fmt.Errorf("no image loaded")
+		}
+		s.Wand = imagick.NewMagickWand()
+		created = true
+	}
+	target := s.target()
+	if createsImage(commandName) {
+		target = s.Wand
+	}
+	resolved, release, err := s.resolveBufferArgs(args)
+	if err != nil {
+		if created {
+			s.Wand.Destroy()
+			s.Wand = nil
+		}
+		return err
+	}
+	defer release()
+	// Keep the images as they were so a cancelled command can be rolled back;
+	// ImageMagick only copies the pixels if the command writes to them.
+	backup := target.Clone()
+	defer backup.Destroy()
+	frame := int(target.GetIteratorIndex())
+	stopProgress := monitorProgress(target, commandName)
+	err = ApplyCommandFrames(target, commandName, resolved, s.AllFrames)
+	if stopProgress() && err != nil {
+		// An operation that was cut short may have changed part of the image.
+		if rerr := replaceWandImages(target, backup); rerr == nil {
+			target.SetIteratorIndex(frame)
+		}
+		err = fmt.Errorf("%s cancelled", commandName)
+	}
+	if err != nil {
+		if created {
+			s.Wand.Destroy()
+			s.Wand = nil
+		}
+		return err
+	}
+
+	s.dropJPEGFile()
+	step := RecipeStep{Command: commandName, Args: append([]string(nil), args...)}
+	if createsImage(commandName) {
+		// The generated image no longer derives from the file that was open,
+		// and starts a new layer stack.
+		s.clearLayers()
+		s.Path = ""
+		s.History = nil
+		s.nameBuffer(commandName)
+	}
+	s.record(step)
+	return nil
+}
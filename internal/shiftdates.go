@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shifting EXIF dates.
+//
+// A camera whose clock was never set, or still runs on home time after a
+// flight, stamps every photo wrong by the same amount, which scrambles the
+// order of shots from several cameras. shiftDates adds an offset to the
+// DateTime, DateTimeOriginal and DateTimeDigitized tags of JPEG files. The
+// new dates have the same length as the old ones, so they are written over
+// them in place: the image is not re-encoded and the file keeps its
+// modification time. GPS timestamps come from the satellites in UTC and are
+// left alone.
+
+// exifDateLayout is how EXIF stores dates.
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// shiftedDateTags are the EXIF tags shiftDates changes, in the order they are
+// reported.
+var shiftedDateTags = []string{"DateTimeOriginal", "DateTimeDigitized", "DateTime"}
+
+// parseDateOffset parses a time offset such as "+1h30m", "-45m", "-1d2h" or
+// "+365d": Go duration units plus d for days, with an optional sign.
+func parseDateOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	rest, neg := s, false
+	switch {
+	case strings.HasPrefix(rest, "-"):
+		rest, neg = rest[1:], true
+	case strings.HasPrefix(rest, "+"):
+		rest = rest[1:]
+	}
+	var d time.Duration
+	if days, after, ok := strings.Cut(rest, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid offset %q: bad day count", s)
+		}
+		d, rest = time.Duration(n)*24*time.Hour, after
+	}
+	if rest != "" {
+		t, err := time.ParseDuration(rest)
+		if err != nil || t < 0 {
+			return 0, fmt.Errorf("invalid offset %q: use e.g. +1h30m, -45m or -1d2h", s)
+		}
+		d += t
+	}
+	if d == 0 {
+		return 0, fmt.Errorf("invalid offset %q: it must not be zero", s)
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// dateField is an EXIF date in a file and where its text is stored.
+type dateField struct {
+	tag  string
+	pos  int
+	text string
+}
+
+// jpegDateFields returns the date tags of a JPEG file that hold a date, none
+// when it has no EXIF data.
+func jpegDateFields(data []byte) ([]dateField, error) {
+	pos, e, err := jpegExif(data)
+	if errors.Is(err, errNoExif) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fields []dateField
+	for _, name := range shiftedDateTags {
+		tag := exifTags[name]
+		offset, err := e.dirOffset(tag.ifd)
+		if err != nil {
+			return nil, err
+		}
+		if offset == 0 {
+			continue
+		}
+		dir, err := e.readDir(offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, en := range dir.entries {
+			// Dates are 19 characters plus the terminating NUL, so they are
+			// always stored out of line.
+			if en.tag != tag.id || en.typ != 2 || en.count < uint32(len(exifDateLayout)) {
+				continue
+			}
+			off := int(e.order.Uint32(en.value[:]))
+			if off+len(exifDateLayout) > len(e.tiff()) {
+				return nil, fmt.Errorf("%s is out of range", name)
+			}
+			at := pos + e.start + off
+			fields = append(fields, dateField{tag: name, pos: at, text: string(data[at : at+len(exifDateLayout)])})
+		}
+	}
+	return fields, nil
+}
+
+// shiftFileDates adds offset to the EXIF dates of the JPEG file at path and,
+// unless dryRun, writes them back. It prints each change as old -> new and
+// returns how many dates were shifted.
+func shiftFileDates(path string, offset time.Duration, dryRun bool) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, withExitCode(exitRead, err)
+	}
+	fields, err := jpegDateFields(data)
+	if err != nil {
+		return 0, withExitCode(exitRead, err)
+	}
+	shifted := 0
+	for _, f := range fields {
+		t, err := time.Parse(exifDateLayout, f.text)
+		if err != nil {
+			// Cameras without a set clock write blanks or zeros.
+			fmt.Printf("%s: %s %q is not a date, left unchanged\n", path, f.tag, f.text)
+			continue
+		}
+		to := t.Add(offset).Format(exifDateLayout)
+		fmt.Printf("%s: %s %s -> %s\n", path, f.tag, f.text, to)
+		copy(data[f.pos:], to)
+		shifted++
+	}
+	if shifted == 0 || dryRun {
+		return shifted, nil
+	}
+	if err := replaceFileKeepingTime(path, data); err != nil {
+		return 0, withExitCode(exitWrite, err)
+	}
+	return shifted, nil
+}
+
+// ShiftDates adds offset (see parseDateOffset) to the EXIF dates of files.
+// With dryRun the changes are only printed. Files other than JPEGs and
+// files without dates are reported and skipped.
+func ShiftDates(files []string, offset string, dryRun bool) error {
+	d, err := parseDateOffset(offset)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	var changed, skipped int
+	var problems []error
+	for _, f := range files {
+		if !isJPEGPath(f) {
+			fmt.Printf("%s: not a JPEG file, skipped\n", f)
+			skipped++
+			continue
+		}
+		n, err := shiftFileDates(f, d, dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", f, err)
+			problems = append(problems, err)
+			continue
+		}
+		if n == 0 {
+			fmt.Printf("%s: no EXIF dates, skipped\n", f)
+			skipped++
+			continue
+		}
+		changed++
+	}
+	summary := fmt.Sprintf("%d files: %d shifted by %s, %d skipped", len(files), changed, d, skipped)
+	if dryRun {
+		summary = fmt.Sprintf("%d files: %d would be shifted by %s (dry run, nothing written), %d skipped", len(files), changed, d, skipped)
+	}
+	if len(problems) > 0 {
+		summary += fmt.Sprintf(", %d failed", len(problems))
+	}
+	fmt.Println(summary)
+	if len(problems) > 0 {
+		return withExitCode(commonExitCode(problems), fmt.Errorf("%d of %d files failed", len(problems), len(files)))
+	}
+	return nil
+}
+
+// RunShiftDates implements
+// `termagick shift-dates --by <offset> [--dry-run] <files or directories...>`.
+func RunShiftDates(args []string) error {
+	fs := flag.NewFlagSet("shift-dates", flag.ContinueOnError)
+	by := fs.String("by", "", "time to add to the EXIF dates, e.g. +1h30m, -45m or -1d2h")
+	dryRun := fs.Bool("dry-run", false, "print the old and new dates without changing any file")
+	addQuietFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 || *by == "" {
+		return withExitCode(exitUsage, fmt.Errorf("usage: termagick shift-dates --by <offset> [--dry-run] <files or directories...>"))
+	}
+	files, err := expandFileList(strings.Join(positional, string(filepath.ListSeparator)))
+	if err != nil {
+		return err
+	}
+	return ShiftDates(files, *by, *dryRun)
+}
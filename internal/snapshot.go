@@ -0,0 +1,374 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Named snapshots.
+//
+// `snapshot save <name>` records the complete session state (image, layers,
+// history) under a name so it can be restored later, independently of any
+// linear undo. `snapshot compare <name>` previews it beside the current
+// image. Snapshots are kept as cloned wands while they fit in the memory
+// budget; beyond it the oldest ones are spilled to temporary MIFF files
+// (which preserve every frame and pixel exactly) and read back on restore.
+
+// snapshotActions are the values of the snapshot command's action parameter,
+// in EnumOptions order (the parameter normalizes to the option index).
+var snapshotActions = []string{"SAVE", "RESTORE", "LIST", "DELETE", "COMPARE"}
+
+// defaultSnapshotMemMB is the in-memory budget used when
+// TERMAGICK_SNAPSHOT_MEM_MB is not set.
+const defaultSnapshotMemMB = 512
+
+// snapshotImage is one stored wand, held either in memory or in a temp file.
+type snapshotImage struct {
+	wand *imagick.MagickWand
+	file string
+	size int64
+}
+
+// snapshotLayer stores a layer's image plus its properties.
+type snapshotLayer struct {
+	Layer
+	img *snapshotImage
+}
+
+// snapshot is a saved session state.
+type snapshot struct {
+	name        string
+	base        *snapshotImage
+	layers      []snapshotLayer
+	path        string
+	history     []RecipeStep
+	activeLayer int
+	frame       int
+}
+
+// snapshotMemLimit returns the in-memory budget in bytes.
+func snapshotMemLimit() int64 {
+	mb := int64(defaultSnapshotMemMB)
+	if v := os.Getenv("TERMAGICK_SNAPSHOT_MEM_MB"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			mb = n
+		}
+	}
+	return mb << 20
+}
+
+// estimateWandSize approximates the pixel memory held by a wand: every frame
+// at four channels of the library's quantum size.
+func estimateWandSize(wand *imagick.MagickWand) int64 {
+	_, depth := imagick.GetQuantumDepth()
+	bytesPerSample := int64(depth) / 8
+	if bytesPerSample == 0 {
+		bytesPerSample = 2
+	}
+	return int64(wand.GetNumberImages()) * int64(wand.GetImageWidth()) * int64(wand.GetImageHeight()) * 4 * bytesPerSample
+}
+
+func newSnapshotImage(wand *imagick.MagickWand) *snapshotImage {
+	return &snapshotImage{wand: wand.Clone(), size: estimateWandSize(wand)}
+}
+
+// spill writes the image to a temp file and frees the in-memory copy.
+func (si *snapshotImage) spill() error {
+	if si.wand == nil {
+		return nil
+	}
+	f, err := createSessionTemp("snapshot-*.miff")
+	if err != nil {
+		return fmt.Errorf("create snapshot file: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	if err := si.wand.WriteImages(name, true); err != nil {
+		os.Remove(name)
+		return fmt.Errorf("write snapshot file: %w", err)
+	}
+	si.wand.Destroy()
+	si.wand = nil
+	si.file = name
+	return nil
+}
+
+// load returns a new wand with the stored image; the caller owns it.
+func (si *snapshotImage) load() (*imagick.MagickWand, error) {
+	if si.wand != nil {
+		return si.wand.Clone(), nil
+	}
+	wand := imagick.NewMagickWand()
+	if err := wand.ReadImage(si.file); err != nil {
+		wand.Destroy()
+		return nil, fmt.Errorf("read snapshot file: %w", err)
+	}
+	return wand, nil
+}
+
+func (si *snapshotImage) release() {
+	if si.wand != nil {
+		si.wand.Destroy()
+		si.wand = nil
+	}
+	if si.file != "" {
+		os.Remove(si.file)
+		si.file = ""
+	}
+}
+
+// images returns every stored image of the snapshot.
+func (sn *snapshot) images() []*snapshotImage {
+	imgs := []*snapshotImage{sn.base}
+	for _, l := range sn.layers {
+		imgs = append(imgs, l.img)
+	}
+	return imgs
+}
+
+func (sn *snapshot) release() {
+	for _, img := range sn.images() {
+		img.release()
+	}
+}
+
+// inMemory reports whether any of the snapshot's images are held in memory.
+func (sn *snapshot) inMemory() bool {
+	for _, img := range sn.images() {
+		if img.wand != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// saveSnapshot stores the current state under name, replacing an existing
+// snapshot of the same name, then enforces the memory budget.
+func (s *Session) saveSnapshot(name string) error {
+	if s.Wand == nil {
+		return fmt.Errorf("no image loaded")
+	}
+	sn := &snapshot{
+		name:        name,
+		base:        newSnapshotImage(s.Wand),
+		path:        s.Path,
+		history:     append([]RecipeStep(nil), s.History...),
+		activeLayer: s.ActiveLayer,
+		frame:       int(s.Wand.GetIteratorIndex()),
+	}
+	for _, l := range s.Layers {
+		props := *l
+		props.Wand = nil
+		sn.layers = append(sn.layers, snapshotLayer{Layer: props, img: newSnapshotImage(l.Wand)})
+	}
+	s.deleteSnapshot(name)
+	s.snapshots = append(s.snapshots, sn)
+	return s.enforceSnapshotBudget()
+}
+
+// enforceSnapshotBudget spills the oldest in-memory snapshots to disk until
+// the in-memory total fits the budget. The newest snapshot stays in memory.
+func (s *Session) enforceSnapshotBudget() error {
+	limit := snapshotMemLimit()
+	var total int64
+	for _, sn := range s.snapshots {
+		for _, img := range sn.images() {
+			if img.wand != nil {
+				total += img.size
+			}
+		}
+	}
+	for i := 0; i < len(s.snapshots)-1 && total > limit; i++ {
+		sn := s.snapshots[i]
+		if !sn.inMemory() {
+			continue
+		}
+		for _, img := range sn.images() {
+			size := img.size
+			held := img.wand != nil
+			if err := img.spill(); err != nil {
+				return err
+			}
+			if held {
+				total -= size
+			}
+		}
+	}
+	return nil
+}
+
+// restoreSnapshot replaces the session state with the named snapshot.
+func (s *Session) restoreSnapshot(name string) error {
+	sn := s.findSnapshot(name)
+	if sn == nil {
+		return fmt.Errorf("no snapshot named %q", name)
+	}
+	base, err := sn.base.load()
+	if err != nil {
+		return err
+	}
+	var layers []*Layer
+	for _, sl := range sn.layers {
+		wand, err := sl.img.load()
+		if err != nil {
+			base.Destroy()
+			for _, l := range layers {
+				l.Wand.Destroy()
+			}
+			return err
+		}
+		l := sl.Layer
+		l.Wand = wand
+		layers = append(layers, &l)
+	}
+	s.replace(base, sn.path)
+	base.SetIteratorIndex(sn.frame)
+	s.Layers = layers
+	s.ActiveLayer = sn.activeLayer
+	s.History = append([]RecipeStep(nil), sn.history...)
+	s.modified = true
+	return nil
+}
+
+// compareSnapshot previews the named snapshot to the right of the current
+// image (each with its layers composited) without changing the session.
+func (s *Session) compareSnapshot(name string) error {
+	if s.Wand == nil {
+		return fmt.Errorf("no image loaded")
+	}
+	sn := s.findSnapshot(name)
+	if sn == nil {
+		return fmt.Errorf("no snapshot named %q", name)
+	}
+	base, err := sn.base.load()
+	if err != nil {
+		return err
+	}
+	base.SetIteratorIndex(sn.frame)
+	// A scratch session owns the snapshot's wands so Display can composite them.
+	saved := &Session{Wand: base}
+	defer saved.Close()
+	for _, sl := range sn.layers {
+		wand, err := sl.img.load()
+		if err != nil {
+			return err
+		}
+		l := sl.Layer
+		l.Wand = wand
+		saved.Layers = append(saved.Layers, &l)
+	}
+
+	pair := imagick.NewMagickWand()
+	defer pair.Destroy()
+	current := s.Display().GetImage()
+	defer current.Destroy()
+	then := saved.Display().GetImage()
+	defer then.Destroy()
+	if err := pair.AddImage(current); err != nil {
+		return fmt.Errorf("compare: %w", err)
+	}
+	if err := pair.AddImage(then); err != nil {
+		return fmt.Errorf("compare: %w", err)
+	}
+	sideBySide := pair.AppendImages(false)
+	if sideBySide == nil {
+		return fmt.Errorf("compare: failed to combine images")
+	}
+	defer sideBySide.Destroy()
+	fmt.Printf("Left: current image, right: snapshot %s\n", name)
+	showPreview(sideBySide)
+	return nil
+}
+
+func (s *Session) findSnapshot(name string) *snapshot {
+	for _, sn := range s.snapshots {
+		if sn.name == name {
+			return sn
+		}
+	}
+	return nil
+}
+
+// deleteSnapshot removes the named snapshot, reporting whether it existed.
+func (s *Session) deleteSnapshot(name string) bool {
+	for i, sn := range s.snapshots {
+		if sn.name == name {
+			sn.release()
+			s.snapshots = append(s.snapshots[:i], s.snapshots[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// releaseSnapshots frees every snapshot, including spilled temp files.
+func (s *Session) releaseSnapshots() {
+	for _, sn := range s.snapshots {
+		sn.release()
+	}
+	s.snapshots = nil
+}
+
+// listSnapshots prints the snapshots by name with size and storage location.
+func (s *Session) listSnapshots() {
+	if len(s.snapshots) == 0 {
+		fmt.Println("no snapshots")
+		return
+	}
+	sorted := append([]*snapshot(nil), s.snapshots...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	for _, sn := range sorted {
+		where := "memory"
+		if !sn.inMemory() {
+			where = "disk"
+		}
+		var size int64
+		for _, img := range sn.images() {
+			size += img.size
+		}
+		fmt.Printf("  %-20s %4d steps  %3d layers  %6.1f MB (%s)\n", sn.name, len(sn.history), len(sn.layers), float64(size)/(1<<20), where)
+	}
+}
+
+// applySnapshotCommand runs the snapshot command with normalized arguments.
+func (s *Session) applySnapshotCommand(args []string) error {
+	idx, err := strconv.Atoi(args[0])
+	if err != nil || idx < 0 || idx >= len(snapshotActions) {
+		return fmt.Errorf("invalid snapshot action %q", args[0])
+	}
+	action := snapshotActions[idx]
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	if name == "" && action != "LIST" {
+		return fmt.Errorf("snapshot %s requires a name", action)
+	}
+
+	switch action {
+	case "SAVE":
+		if err := s.saveSnapshot(name); err != nil {
+			return err
+		}
+		fmt.Printf("Saved snapshot %s\n", name)
+	case "RESTORE":
+		if err := s.restoreSnapshot(name); err != nil {
+			return err
+		}
+		fmt.Printf("Restored snapshot %s\n", name)
+	case "LIST":
+		s.listSnapshots()
+	case "DELETE":
+		if !s.deleteSnapshot(name) {
+			return fmt.Errorf("no snapshot named %q", name)
+		}
+		fmt.Printf("Deleted snapshot %s\n", name)
+	case "COMPARE":
+		return s.compareSnapshot(name)
+	}
+	return nil
+}
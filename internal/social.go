@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// socialTarget is an image size a platform asks for.
+type socialTarget struct {
+	Name          string
+	Width, Height uint
+	File          string
+}
+
+// socialTargets are the sizes the social command knows, in the order they
+// are written.
+var socialTargets = []socialTarget{
+	{"og", 1200, 630, "og-image.jpg"},
+	{"twitter", 1200, 675, "twitter-card.jpg"},
+	{"instagram-square", 1080, 1080, "instagram-square.jpg"},
+	{"instagram-portrait", 1080, 1350, "instagram-portrait.jpg"},
+	{"instagram-story", 1080, 1920, "instagram-story.jpg"},
+	{"youtube", 1280, 720, "youtube-thumbnail.jpg"},
+}
+
+// socialFits are the values of the social command's fit parameter, in
+// EnumOptions order.
+var socialFits = []string{"CROP", "PAD"}
+
+// socialQuality is the JPEG quality of the exported images; platforms
+// recompress uploads, so a high quality avoids compounding artifacts.
+const socialQuality = 90
+
+// selectSocialTargets resolves a comma-separated list of target names; an
+// empty list or "all" selects every target.
+func selectSocialTargets(list string) ([]socialTarget, error) {
+	if list == "" || strings.EqualFold(list, "all") {
+		return socialTargets, nil
+	}
+	var targets []socialTarget
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for _, t := range socialTargets {
+			if t.Name == name {
+				targets = append(targets, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(socialTargets))
+			for i, t := range socialTargets {
+				names[i] = t.Name
+			}
+			return nil, fmt.Errorf("unknown target %q (known: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return targets, nil
+}
+
+// ExportSocial writes the current image of wand to dir once per target, at
+// the target's size. With pad set the whole image is fitted inside and the
+// rest filled with background; otherwise it fills the frame and the overflow
+// is cropped evenly from both sides. Transparency is flattened onto
+// background, since the files are JPEGs.
+func ExportSocial(wand *imagick.MagickWand, dir string, targets []socialTarget, pad bool, background string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	if !bg.SetColor(background) {
+		return fmt.Errorf("invalid background color %q", background)
+	}
+
+	for _, t := range targets {
+		img := wand.GetImage()
+		err := fitSocial(img, t, pad, bg)
+		if err == nil {
+			img.StripImage()
+			img.SetImageCompressionQuality(socialQuality)
+			err = img.WriteImage("jpg:" + filepath.Join(dir, t.File))
+		}
+		img.Destroy()
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+		fmt.Printf("%-20s %4dx%-4d %s\n", t.Name, t.Width, t.Height, filepath.Join(dir, t.File))
+	}
+	return nil
+}
+
+// fitSocial resizes img in place to the size of t.
+func fitSocial(img *imagick.MagickWand, t socialTarget, pad bool, bg *imagick.PixelWand) error {
+	w, h := float64(img.GetImageWidth()), float64(img.GetImageHeight())
+	sx, sy := float64(t.Width)/w, float64(t.Height)/h
+	scale := max(sx, sy)
+	if pad {
+		scale = min(sx, sy)
+	}
+	rw, rh := max(1, uint(w*scale+0.5)), max(1, uint(h*scale+0.5))
+	if err := img.ResizeImage(rw, rh, imagick.FILTER_LANCZOS); err != nil {
+		return fmt.Errorf("failed to resize: %w", err)
+	}
+	if err := img.SetImageBackgroundColor(bg); err != nil {
+		return fmt.Errorf("failed to set background: %w", err)
+	}
+	// Extent centers the image on the target canvas, cropping or padding.
+	if err := img.ExtentImage(t.Width, t.Height, (int(rw)-int(t.Width))/2, (int(rh)-int(t.Height))/2); err != nil {
+		return fmt.Errorf("failed to fit to %dx%d: %w", t.Width, t.Height, err)
+	}
+	if img.GetImageAlphaChannel() {
+		if err := img.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE); err != nil {
+			return fmt.Errorf("failed to remove alpha: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Sprite sheets.
+//
+// SliceSheet cuts a sheet of equally sized sprites, optionally laid out with a
+// margin around the sheet and spacing between cells as many game tools export
+// them, into one PNG per tile. PackSheet does the reverse: it places a set of
+// images on a grid of equal cells, sized for the largest image, with padding
+// between and around the cells.
+
+// SliceSheet writes the width x height tiles of the current image of wand to
+// outDir as PNG files numbered in reading order, skipping the margin around
+// the sheet and the spacing between tiles. With skipEmpty, fully transparent
+// tiles are not written. It returns the number of files written and the
+// grid size.
+func SliceSheet(wand *imagick.MagickWand, outDir string, width, height, spacing, margin uint, skipEmpty bool) (written, cols, rows int, err error) {
+	if width == 0 || height == 0 {
+		return 0, 0, 0, fmt.Errorf("tile size must be positive")
+	}
+	sheetW, sheetH := wand.GetImageWidth(), wand.GetImageHeight()
+	if sheetW < 2*margin+width || sheetH < 2*margin+height {
+		return 0, 0, 0, fmt.Errorf("a %dx%d tile does not fit in the %dx%d sheet", width, height, sheetW, sheetH)
+	}
+	cols = int((sheetW - 2*margin + spacing) / (width + spacing))
+	rows = int((sheetH - 2*margin + spacing) / (height + spacing))
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return 0, 0, 0, fmt.Errorf("create %s: %w", outDir, err)
+	}
+
+	// Zero-pad the numbers so the files sort in order.
+	digits := len(fmt.Sprint(cols*rows - 1))
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			tile := wand.GetImage()
+			x := int(margin) + c*int(width+spacing)
+			y := int(margin) + r*int(height+spacing)
+			if err := tile.CropImage(width, height, x, y); err != nil {
+				tile.Destroy()
+				return written, cols, rows, fmt.Errorf("failed to crop tile at %d,%d: %w", x, y, err)
+			}
+			tile.ResetImagePage("")
+			if skipEmpty && isTransparent(tile) {
+				tile.Destroy()
+				continue
+			}
+			path := filepath.Join(outDir, fmt.Sprintf("tile_%0*d.png", digits, r*cols+c))
+			err := tile.WriteImage("png:" + path)
+			tile.Destroy()
+			if err != nil {
+				return written, cols, rows, fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			written++
+		}
+	}
+	return written, cols, rows, nil
+}
+
+// isTransparent reports whether every pixel of the current image of wand is
+// fully transparent.
+func isTransparent(wand *imagick.MagickWand) bool {
+	if !wand.GetImageAlphaChannel() {
+		return false
+	}
+	px, err := wand.ExportImagePixels(0, 0, wand.GetImageWidth(), wand.GetImageHeight(), "A", imagick.PIXEL_CHAR)
+	if err != nil {
+		return false
+	}
+	alpha, ok := px.([]byte)
+	if !ok {
+		return false
+	}
+	for _, a := range alpha {
+		if a != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// PackSheet places the first frame of each image in paths on a grid with
+// columns cells per row (0 chooses a near-square grid). Every cell is the size
+// of the largest image, which is centered in it; padding separates the cells
+// and surrounds the grid. It returns the sheet and its cell size.
+func PackSheet(paths []string, columns, padding uint, background string) (*imagick.MagickWand, uint, uint, error) {
+	if len(paths) == 0 {
+		return nil, 0, 0, fmt.Errorf("no input images")
+	}
+	tiles := make([]*imagick.MagickWand, 0, len(paths))
+	defer func() {
+		for _, t := range tiles {
+			t.Destroy()
+		}
+	}()
+	var cellW, cellH uint
+	for _, p := range paths {
+		frame := imagick.NewMagickWand()
+		if err := frame.ReadImage(p); err != nil {
+			frame.Destroy()
+			return nil, 0, 0, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		frame.SetFirstIterator()
+		tile := frame.GetImage()
+		frame.Destroy()
+		tiles = append(tiles, tile)
+		cellW = max(cellW, tile.GetImageWidth())
+		cellH = max(cellH, tile.GetImageHeight())
+	}
+
+	n := uint(len(tiles))
+	if columns == 0 {
+		columns = uint(math.Ceil(math.Sqrt(float64(n))))
+	}
+	columns = min(columns, n)
+	rows := (n + columns - 1) / columns
+
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	if !bg.SetColor(background) {
+		return nil, 0, 0, fmt.Errorf("invalid background color %q", background)
+	}
+	sheet := imagick.NewMagickWand()
+	sheetW := columns*cellW + (columns+1)*padding
+	sheetH := rows*cellH + (rows+1)*padding
+	if err := sheet.NewImage(sheetW, sheetH, bg); err != nil {
+		sheet.Destroy()
+		return nil, 0, 0, fmt.Errorf("failed to create sheet: %w", err)
+	}
+	for i, tile := range tiles {
+		c, r := uint(i)%columns, uint(i)/columns
+		x := int(padding+c*(cellW+padding)) + int(cellW-tile.GetImageWidth())/2
+		y := int(padding+r*(cellH+padding)) + int(cellH-tile.GetImageHeight())/2
+		if err := sheet.CompositeImage(tile, imagick.COMPOSITE_OP_OVER, true, x, y); err != nil {
+			sheet.Destroy()
+			return nil, 0, 0, fmt.Errorf("failed to place %s: %w", paths[i], err)
+		}
+	}
+	return sheet, cellW, cellH, nil
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Image stacking.
+//
+// stack combines several shots of the same scene pixel by pixel: the mean or
+// median of repeated exposures cancels sensor noise (the median also removes
+// people walking through), the maximum of a series of night sky exposures
+// draws star trails, and the minimum keeps the darkest value, for example to
+// drop passing lights. The shots must be aligned and of the same size, as
+// from a tripod. The reduction is done here rather than with the wand's
+// EvaluateImages, whose binding discards the result.
+
+// stackMethods are the values of the stack command's method parameter, in
+// EnumOptions order.
+var stackMethods = []string{"MEAN", "MEDIAN", "MIN", "MAX", "SUM"}
+
+// stackStripe is how many rows are combined at a time, to bound memory.
+const stackStripe = 64
+
+// StackImages reads the first frame of each file and returns a new wand with
+// one image, their pixel-wise reduction by method. SUM is clipped at white.
+func StackImages(paths []string, method string) (*imagick.MagickWand, error) {
+	if len(paths) < 2 {
+		return nil, fmt.Errorf("stack needs at least 2 images, got %d", len(paths))
+	}
+	if !slices.Contains(stackMethods, method) {
+		return nil, fmt.Errorf("unknown stack method %q", method)
+	}
+	var shots []*imagick.MagickWand
+	defer func() {
+		for _, s := range shots {
+			s.Destroy()
+		}
+	}()
+	alpha := false
+	for _, p := range paths {
+		s := imagick.NewMagickWand()
+		shots = append(shots, s)
+		if err := s.ReadImage(p); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		s.SetFirstIterator()
+		w, h := s.GetImageWidth(), s.GetImageHeight()
+		if fw, fh := shots[0].GetImageWidth(), shots[0].GetImageHeight(); w != fw || h != fh {
+			return nil, fmt.Errorf("%s is %dx%d but %s is %dx%d; stacked shots must be the same size", p, w, h, paths[0], fw, fh)
+		}
+		alpha = alpha || s.GetImageAlphaChannel()
+	}
+
+	out := shots[0].GetImage()
+	channels := "RGB"
+	if alpha {
+		channels = "RGBA"
+		if err := out.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET); err != nil {
+			out.Destroy()
+			return nil, fmt.Errorf("failed to add alpha: %w", err)
+		}
+	}
+	w, h := int(out.GetImageWidth()), int(out.GetImageHeight())
+	n := len(shots)
+	rows := make([][]float32, n)
+	values := make([]float32, n)
+	for y0 := 0; y0 < h; y0 += stackStripe {
+		sh := min(stackStripe, h-y0)
+		for i, s := range shots {
+			px, err := s.ExportImagePixels(0, y0, uint(w), uint(sh), channels, imagick.PIXEL_FLOAT)
+			if err != nil {
+				out.Destroy()
+				return nil, fmt.Errorf("ExportImagePixels failed: %w", err)
+			}
+			f, ok := px.([]float32)
+			if !ok {
+				out.Destroy()
+				return nil, fmt.Errorf("unsupported pixel data type: %T", px)
+			}
+			rows[i] = f
+		}
+		result := make([]float32, len(rows[0]))
+		for j := range result {
+			for i := range rows {
+				values[i] = rows[i][j]
+			}
+			switch method {
+			case "MEAN", "SUM":
+				var sum float64
+				for _, v := range values {
+					sum += float64(v)
+				}
+				if method == "MEAN" {
+					sum /= float64(n)
+				}
+				result[j] = float32(min(sum, 1))
+			case "MEDIAN":
+				// The middle value, or the upper of the two middle ones, as
+				// ImageMagick's median picks.
+				slices.Sort(values)
+				result[j] = values[n/2]
+			case "MIN":
+				result[j] = slices.Min(values)
+			case "MAX":
+				result[j] = slices.Max(values)
+			}
+		}
+		if err := out.ImportImagePixels(0, y0, uint(w), uint(sh), channels, imagick.PIXEL_FLOAT, result); err != nil {
+			out.Destroy()
+			return nil, fmt.Errorf("ImportImagePixels failed: %w", err)
+		}
+	}
+	return out, nil
+}
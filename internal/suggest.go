@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Save format suggestions.
+//
+// Before saving, the image is examined on a small sample: does it use
+// transparency, does it look like flat graphics (screenshots, diagrams, logos:
+// few distinct colors) or a photo, and is it animated. From that a format and
+// quality are suggested, and a chosen format that would lose something (alpha
+// in JPEG, crisp text in JPEG, colors in GIF, frames in a still format) is
+// flagged with a one-key switch to the suggestion.
+
+// suggestSampleSize bounds the longer side of the sample analyzed.
+const suggestSampleSize = 400
+
+// flatColorRatio is the share of distinct colors among the sampled pixels
+// below which an image counts as flat graphics rather than a photo.
+const flatColorRatio = 0.1
+
+// imageTraits is what suggestFormat learns about an image.
+type imageTraits struct {
+	Alpha    bool // some pixels are transparent
+	Flat     bool // few distinct colors: graphics, text, screenshots
+	Colors   int  // distinct colors in the sample
+	Animated bool
+}
+
+// formatSuggestion is the format suggested for saving an image.
+type formatSuggestion struct {
+	Ext     string // file extension, without the dot
+	Quality uint   // compression quality for lossy formats, 0 for lossless
+	Reason  string
+	traits  imageTraits
+}
+
+func (f formatSuggestion) String() string {
+	if f.Quality > 0 {
+		return fmt.Sprintf("%s (quality %d)", strings.ToUpper(f.Ext), f.Quality)
+	}
+	return strings.ToUpper(f.Ext)
+}
+
+// analyzeImage samples the current image of wand.
+func analyzeImage(wand *imagick.MagickWand) (imageTraits, error) {
+	traits := imageTraits{Animated: isMultiFrame(wand)}
+	img := wand.GetImage()
+	defer img.Destroy()
+	w, h := img.GetImageWidth(), img.GetImageHeight()
+	if w == 0 || h == 0 {
+		return traits, fmt.Errorf("image has zero dimensions")
+	}
+	// Point sampling keeps the original colors, so flat areas stay flat.
+	if long := max(w, h); long > suggestSampleSize {
+		scale := float64(suggestSampleSize) / float64(long)
+		w = max(1, uint(float64(w)*scale))
+		h = max(1, uint(float64(h)*scale))
+		if err := img.SampleImage(w, h); err != nil {
+			return traits, fmt.Errorf("failed to sample image: %w", err)
+		}
+	}
+	px, err := img.ExportImagePixels(0, 0, w, h, "RGBA", imagick.PIXEL_CHAR)
+	if err != nil {
+		return traits, fmt.Errorf("ExportImagePixels failed: %w", err)
+	}
+	rgba, ok := px.([]byte)
+	if !ok {
+		return traits, fmt.Errorf("unsupported pixel data type: %T", px)
+	}
+	colors := make(map[uint32]struct{})
+	for i := 0; i+3 < len(rgba); i += 4 {
+		if rgba[i+3] < 255 {
+			traits.Alpha = true
+		}
+		colors[uint32(rgba[i])<<16|uint32(rgba[i+1])<<8|uint32(rgba[i+2])] = struct{}{}
+	}
+	traits.Colors = len(colors)
+	traits.Flat = len(colors) <= 256 || float64(len(colors)) < flatColorRatio*float64(w*h)
+	return traits, nil
+}
+
+// suggestFormat suggests the format to save the image in wand as.
+func suggestFormat(wand *imagick.MagickWand) (formatSuggestion, error) {
+	t, err := analyzeImage(wand)
+	if err != nil {
+		return formatSuggestion{}, err
+	}
+	s := formatSuggestion{traits: t}
+	switch {
+	case t.Animated && t.Colors <= 256:
+		s.Ext, s.Reason = "gif", "animation with few colors"
+	case t.Animated:
+		s.Ext, s.Quality, s.Reason = "webp", 90, "animation with many colors; GIF would band them"
+	case t.Flat:
+		s.Ext, s.Reason = "png", "flat graphics or screenshot; lossless keeps edges and text sharp"
+	case t.Alpha:
+		s.Ext, s.Quality, s.Reason = "webp", 90, "photo with transparency; much smaller than PNG"
+	default:
+		s.Ext, s.Quality, s.Reason = "jpg", 90, "photo"
+	}
+	return s, nil
+}
+
+// formatProblem returns what saving the analyzed image as ext would lose, or
+// "" when ext suits it.
+func (f formatSuggestion) formatProblem(ext string) string {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	t := f.traits
+	switch {
+	case t.Animated && !containsString([]string{"gif", "webp", "png", "apng", "mng", "tif", "tiff", "avif"}, ext):
+		return fmt.Sprintf("%s cannot hold an animation; frames would be written to separate files", strings.ToUpper(ext))
+	case (ext == "jpg" || ext == "jpeg") && t.Alpha:
+		return "JPEG has no transparency; transparent areas would be filled"
+	case (ext == "jpg" || ext == "jpeg") && t.Flat:
+		return "JPEG blurs text and sharp edges of flat graphics and makes files larger than PNG"
+	case ext == "gif" && t.Colors > 256:
+		return "GIF holds at most 256 colors; gradients would band"
+	}
+	return ""
+}
+
+// withExt replaces the extension of path.
+func withExt(path, ext string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + ext
+}
+
+// setDefaultQuality sets quality on every frame of wand that has none, unless
+// the config file sets a save quality.
+func setDefaultQuality(wand *imagick.MagickWand, quality uint) {
+	if quality == 0 || config.SaveQuality != 0 {
+		return
+	}
+	current := wand.GetIteratorIndex()
+	wand.ResetIterator()
+	for wand.NextImage() {
+		if wand.GetImageCompressionQuality() == 0 {
+			wand.SetImageCompressionQuality(quality)
+		}
+	}
+	wand.SetIteratorIndex(int(current))
+}
+
+// confirmSaveFormat returns the path to save to once the format is settled. A
+// path without an extension takes the suggested one; a format that would lose
+// something is explained and a single y switches to the suggestion. When the
+// suggested format is used, its quality becomes the default for the save.
+func confirmSaveFormat(reader *bufio.Reader, wand *imagick.MagickWand, s formatSuggestion, path string) string {
+	ext := filepath.Ext(path)
+	switch {
+	case ext == "":
+		path = withExt(path, s.Ext)
+	case !sameFormat(ext, s.Ext):
+		problem := s.formatProblem(ext)
+		if problem == "" {
+			return path
+		}
+		suggested := withExt(path, s.Ext)
+		fmt.Printf("Warning: %s.\n", problem)
+		fmt.Printf("Press y to save as %s instead, any other key to keep %s: ", suggested, path)
+		if r, err := readKey(reader); err != nil || (r != 'y' && r != 'Y') {
+			return path
+		}
+		path = suggested
+	}
+	setDefaultQuality(wand, s.Quality)
+	return path
+}
+
+// sameFormat reports whether two file extensions name the same format.
+func sameFormat(a, b string) bool {
+	norm := func(ext string) string {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		switch ext {
+		case "jpeg":
+			return "jpg"
+		case "tiff":
+			return "tif"
+		}
+		return ext
+	}
+	return norm(a) == norm(b)
+}
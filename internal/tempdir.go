@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Temporary files.
+//
+// Everything termagick writes for its own use while running (snapshots,
+// lossless JPEG copies, files handed to iconutil or an external editor,
+// histogram fallbacks) goes in one directory per process, created on
+// first use and removed on exit, including exit by SIGTERM or SIGHUP. The
+// directory name carries the process ID, so directories left behind by a
+// crash are removed the next time termagick starts.
+
+// tempDirPrefix starts the name of every session temp directory, followed by
+// the process ID.
+const tempDirPrefix = "termagick-"
+
+// staleTempAge is how old a directory of a dead process must be before it is
+// swept, so a process that just started is never mistaken for a stale one.
+const staleTempAge = time.Minute
+
+var sessionTemp struct {
+	sync.Mutex
+	dir string
+}
+
+// sessionTempDir returns the session's temp directory, creating it if needed.
+func sessionTempDir() (string, error) {
+	sessionTemp.Lock()
+	defer sessionTemp.Unlock()
+	if sessionTemp.dir != "" {
+		return sessionTemp.dir, nil
+	}
+	dir, err := os.MkdirTemp("", fmt.Sprintf("%s%d-*", tempDirPrefix, os.Getpid()))
+	if err != nil {
+		return "", fmt.Errorf("create temp dir: %w", err)
+	}
+	sessionTemp.dir = dir
+	return dir, nil
+}
+
+// createSessionTemp creates a new file in the session's temp directory, as
+// os.CreateTemp does.
+func createSessionTemp(pattern string) (*os.File, error) {
+	dir, err := sessionTempDir()
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
+}
+
+// mkdirSessionTemp creates a new directory in the session's temp directory,
+// as os.MkdirTemp does.
+func mkdirSessionTemp(pattern string) (string, error) {
+	dir, err := sessionTempDir()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, pattern)
+}
+
+// removeSessionTempDir removes the session's temp directory and everything in
+// it. A later call to sessionTempDir creates a new one.
+func removeSessionTempDir() {
+	sessionTemp.Lock()
+	defer sessionTemp.Unlock()
+	if sessionTemp.dir == "" {
+		return
+	}
+	if err := os.RemoveAll(sessionTemp.dir); err != nil {
+		debugf("remove temp dir: %v", err)
+	}
+	sessionTemp.dir = ""
+}
+
+// handleTermination resets the terminal and removes the session's temp
+// directory when the process is terminated or its terminal goes away, then
+// exits. It returns a function that restores the default handling.
+func handleTermination() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		if sig, ok := <-signals; ok {
+			resetTerminal()
+			removeSessionTempDir()
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s)
+			}
+			os.Exit(code)
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
+
+// sweepStaleTempDirs removes the temp directories of termagick processes that
+// are no longer running.
+func sweepStaleTempDirs() {
+	entries, err := os.ReadDir(os.TempDir())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !strings.HasPrefix(name, tempDirPrefix) {
+			continue
+		}
+		pidPart, _, ok := strings.Cut(strings.TrimPrefix(name, tempDirPrefix), "-")
+		pid, err := strconv.Atoi(pidPart)
+		if !ok || err != nil || pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		path := filepath.Join(os.TempDir(), name)
+		if err := os.RemoveAll(path); err != nil {
+			debugf("remove stale temp dir %s: %v", path, err)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 
@@ -22,7 +21,7 @@ import (
 //	    // preview not available or failed
 //	}
 //
-// Behavior:
+// Behavior (the order is that of previewers in previewers.go):
 //   - If kitty is detected (KITTY_WINDOW_ID or TERM contains "kitty"), the PNG is sent using
 //     the kitty graphics protocol (chunked base64 inside ESC _G ... ESC \).
 //   - Else if iTerm2 is detected (TERM_PROGRAM == "iTerm.app" || ITERM_SESSION_ID present),
@@ -33,11 +32,12 @@ import (
 //     the PNG is piped to an external sixel renderer (img2sixel or chafa).
 //   - Else, if chafa is available on PATH, it will be invoked to render a terminal-friendly approximation
 //     even for terminals that don't implement the above protocols.
+//   - Else, if the --web-preview server is running, the PNG is only shown in the browser.
 //   - If none is available, PreviewWand returns an error indicating no supported terminal.
 //
 // Notes:
-//   - The function clones the provided wand to set the image format to PNG without mutating
-//     the caller's wand state.
+//   - The function copies the current image of the provided wand (the active frame for
+//     animations) to set the image format to PNG without mutating the caller's wand state.
 //   - Sending binary escape sequences to stdout is expected in this terminal-only preview mode.
 //
 // Debugging helper controlled by PREVIEW_DEBUG=1
@@ -82,12 +82,12 @@ func isKitty() bool {
 
 // Detects terminals that implement the generic "inline images" OSC protocol
 // (iTerm2 style) — many modern terminal emulators (WezTerm, Warp, Tabby, VSCode's terminal,
-// Rio, Hyper, Bobcat and others) implement that or compatible behavior.
+// Rio, Hyper, Bobcat, mintty and others) implement that or compatible behavior.
 // We use a heuristic based on TERM_PROGRAM and common TERM substrings.
 func isInlineImageCapable() bool {
 	debugf("checking inline-image capability via TERM_PROGRAM/TERM")
 	switch os.Getenv("TERM_PROGRAM") {
-	case "iTerm.app", "WezTerm", "Warp", "Hyper", "vscode", "VSCode", "Tabby", "Bobcat":
+	case "iTerm.app", "WezTerm", "Warp", "Hyper", "vscode", "VSCode", "Tabby", "Bobcat", "mintty":
 		debugf("TERM_PROGRAM indicates inline-capable: %s", os.Getenv("TERM_PROGRAM"))
 		return true
 	}
@@ -117,7 +117,7 @@ func isSixelCapable() bool {
 	if strings.Contains(term, "foot") || strings.Contains(term, "st") || strings.Contains(term, "linux") {
 		return true
 	}
-	if os.Getenv("WT_SESSION") != "" { // Windows Terminal newer versions support sixel
+	if os.Getenv("WT_SESSION") != "" { // Windows Terminal supports sixel since 1.22
 		return true
 	}
 	return false
@@ -127,13 +127,13 @@ func isSixelCapable() bool {
 // We treat chafa as a usable fallback for terminals that don't implement inline
 // or sixel protocols but can still display block/character graphics.
 func hasChafa() bool {
-	if os.Getenv("CHAFAPREVIEW") == "1" {
-		return true
+	if config.NoExec {
+		return false
 	}
-	if _, err := exec.LookPath("chafa"); err == nil {
+	if os.Getenv("CHAFAPREVIEW") == "1" {
 		return true
 	}
-	return false
+	return hasProgram("chafa")
 }
 
 // postImageNewlines returns a sane number of newline lines to emit after an image
@@ -178,28 +178,32 @@ func postImageNewlines(requestedRows int) int {
 // PreviewSupported returns true if the running environment likely supports a terminal inline preview.
 // We consider chafa availability as a valid fallback even if no inline/sixel protocol is detected.
 func PreviewSupported() bool {
-	supported := isKitty() || isInlineImageCapable() || isSixelCapable() || hasChafa()
-	debugf("PreviewSupported -> %v (kitty=%v inline=%v sixel=%v chafa=%v)", supported, isKitty(), isInlineImageCapable(), isSixelCapable(), hasChafa())
-	return supported
+	if previewDebug {
+		for _, p := range previewers {
+			debugf("previewer %s detected=%v", p.Name(), p.Detect())
+		}
+	}
+	return activePreviewer() != nil
 }
 
-// PreviewWand takes a MagickWand and tries to display it inline in the terminal.
-// It prefers kitty unicode/graphics placement, then the inline images OSC, then Sixel, then chafa.
-// Returns error if unsupported or on failure.
+// PreviewWand takes a MagickWand and tries to display it inline in the terminal
+// with the active previewer (see previewers.go), falling back to the other
+// detected ones when it fails. Returns error if unsupported or on failure.
 func PreviewWand(wand *imagick.MagickWand) error {
 	if wand == nil {
 		return fmt.Errorf("nil wand")
 	}
 
-	// Log entry and detection state when debugging is enabled
-	debugf("PreviewWand called (supported=%v, KITTY=%v, INLINE=%v, SIXEL=%v, CHAF A=%v)", PreviewSupported(), isKitty(), isInlineImageCapable(), isSixelCapable(), hasChafa())
-
-	if !PreviewSupported() {
+	p := activePreviewer()
+	if p == nil {
 		return fmt.Errorf("no supported terminal preview protocol detected")
 	}
+	debugf("PreviewWand called (previewer=%s)", p.Name())
 
-	// Clone the wand to avoid mutating the caller's wand (format, etc).
-	clone := wand.Clone()
+	// Copy the current image (the active frame of an animation) to avoid
+	// mutating the caller's wand (format, etc); large images are previewed
+	// from a downscaled proxy.
+	clone := previewImage(wand)
 	if clone == nil {
 		debugf("failed to clone wand")
 		return fmt.Errorf("failed to clone wand")
@@ -220,108 +224,39 @@ func PreviewWand(wand *imagick.MagickWand) error {
 		return fmt.Errorf("empty image blob")
 	}
 
-	// Prefer kitty if available (unicode placeholders / placement)
-	if isKitty() {
-		debugf("attempting kitty protocol")
-		if err := sendKittyPNG(blob); err != nil {
-			debugf("kitty protocol failed: %v", err)
-			// fallback attempt to inline images if available and kitty failed
-			if isInlineImageCapable() {
-				debugf("falling back to inline image OSC")
-				if err2 := sendInlineImagePNG(blob); err2 == nil {
-					debugf("inline image OSC succeeded after kitty failure")
-					return nil
-				} else {
-					debugf("inline image OSC also failed: %v", err2)
-				}
-			}
-			// fallback to Sixel if supported
-			if isSixelCapable() {
-				debugf("falling back to Sixel rendering")
-				if err3 := sendSixelPNG(blob); err3 == nil {
-					debugf("sixel succeeded after kitty failure")
-					return nil
-				} else {
-					debugf("sixel also failed: %v", err3)
-				}
-			}
-			// fallback to chafa if available
-			if hasChafa() {
-				debugf("falling back to chafa rendering")
-				if err4 := sendChafaPNG(blob); err4 == nil {
-					debugf("chafa succeeded after kitty failure")
-					return nil
-				} else {
-					debugf("chafa also failed: %v", err4)
-				}
-			}
-			return fmt.Errorf("kitty preview failed: %w", err)
-		}
-		debugf("kitty protocol succeeded")
-		return nil
+	err = p.Show(blob)
+	// A previewer chosen in the config file skips detection and fallbacks.
+	if err == nil || config.PreviewBackend != "auto" {
+		return err
 	}
-
-	// If terminal supports inline images OSC (iTerm2-style) prefer that.
-	if isInlineImageCapable() {
-		debugf("attempting inline image OSC protocol")
-		if err := sendInlineImagePNG(blob); err != nil {
-			debugf("inline image OSC failed: %v", err)
-			// fallback to Sixel if available
-			if isSixelCapable() {
-				debugf("falling back to Sixel rendering from inline image failure")
-				if err2 := sendSixelPNG(blob); err2 == nil {
-					debugf("sixel succeeded after inline image failure")
-					return nil
-				} else {
-					debugf("sixel also failed: %v", err2)
-				}
-			}
-			// fallback to chafa if available
-			if hasChafa() {
-				debugf("falling back to chafa rendering from inline image failure")
-				if err3 := sendChafaPNG(blob); err3 == nil {
-					debugf("chafa succeeded after inline image failure")
-					return nil
-				} else {
-					debugf("chafa also failed: %v", err3)
-				}
-			}
-			return fmt.Errorf("inline image preview failed: %w", err)
+	debugf("%s preview failed: %v", p.Name(), err)
+	for _, q := range fallbackPreviewers(p) {
+		debugf("falling back to %s", q.Name())
+		if err2 := q.Show(blob); err2 == nil {
+			debugf("%s succeeded after %s failure", q.Name(), p.Name())
+			return nil
+		} else {
+			debugf("%s also failed: %v", q.Name(), err2)
 		}
-		debugf("inline image OSC succeeded")
-		return nil
 	}
+	return fmt.Errorf("%s preview failed: %w", p.Name(), err)
+}
 
-	// Fallback: try Sixel-capable terminals
-	if isSixelCapable() {
-		if err := sendSixelPNG(blob); err != nil {
-			// If sixel rendering fails, but chafa is available, try chafa as a fallback.
-			if hasChafa() {
-				debugf("sixel failed; attempting chafa fallback")
-				if err2 := sendChafaPNG(blob); err2 == nil {
-					debugf("chafa fallback succeeded after sixel failure")
-					return nil
-				} else {
-					debugf("chafa fallback also failed: %v", err2)
-				}
-			}
-			return fmt.Errorf("sixel preview failed: %w", err)
+// kittyPreviewSize returns the kitty placement size in cells, from the
+// config file and environment.
+func kittyPreviewSize() (int, int) {
+	cols, rows := previewSize(60, 20)
+	if v := os.Getenv("KITTY_PREVIEW_COLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cols = n
 		}
-		return nil
 	}
-
-	// If chafa is available, try it even if no protocol heuristics matched.
-	if hasChafa() {
-		debugf("no protocol matched, but chafa detected; attempting chafa rendering")
-		if err := sendChafaPNG(blob); err == nil {
-			debugf("chafa rendering succeeded")
-			return nil
-		} else {
-			debugf("chafa rendering failed: %v", err)
+	if v := os.Getenv("KITTY_PREVIEW_ROWS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			rows = n
 		}
 	}
-
-	return fmt.Errorf("no preview protocol matched")
+	return cols, rows
 }
 
 // sendKittyPNG pushes PNG bytes to the terminal using the kitty graphics protocol.
@@ -332,7 +267,8 @@ func PreviewWand(wand *imagick.MagickWand) error {
 //
 //	KITTY_PREVIEW_COLS and KITTY_PREVIEW_ROWS
 //
-// If those are not present, sensible defaults are used.
+// If those are not present, preview.cols/preview.rows from the config file or
+// sensible defaults are used.
 //
 // Note: we still transmit PNG data (f=100) and a=T to transmit+display. The keys `c` and `r`
 // request the image be displayed over the specified number of columns and rows respectively.
@@ -347,20 +283,7 @@ func sendKittyPNG(data []byte) error {
 	enc := base64.StdEncoding.EncodeToString(data)
 	const chunkSize = 4096
 
-	// Determine preview placement size from environment (defaults).
-	cols := 60
-	rows := 20
-	if v := os.Getenv("KITTY_PREVIEW_COLS"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			cols = n
-		}
-	}
-	if v := os.Getenv("KITTY_PREVIEW_ROWS"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			rows = n
-		}
-	}
-
+	cols, rows := kittyPreviewSize()
 	debugf("kitty placement: cols=%d rows=%d (requested)", cols, rows)
 
 	stdout := os.Stdout
@@ -453,12 +376,14 @@ func sendSixelPNG(data []byte) error {
 	// Try to locate a suitable external sixel tool.
 	// Common tool: img2sixel (part of libsixel or some distributions).
 	// We call it with '-' to accept stdin.
-	cmd := exec.Command("img2sixel", "-")
-	cmd.Stdin = bytes.NewReader(data)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err == nil {
+	cmd, err := externalCommand("img2sixel", "-")
+	if err == nil {
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+	}
+	if err == nil {
 		debugf("img2sixel succeeded")
 		// Advance a small number of lines after the image so subsequent text
 		// appears just below it.
@@ -479,6 +404,17 @@ func sendSixelPNG(data []byte) error {
 		debugf("chafa failed: %v", err)
 	}
 
+	// Without either tool, let ImageMagick encode the sixels itself.
+	if err := sendMagickSixel(data); err == nil {
+		debugf("built-in sixel encoder succeeded")
+		for i := 0; i < postImageNewlines(0); i++ {
+			fmt.Println()
+		}
+		return nil
+	} else {
+		debugf("built-in sixel encoder failed: %v", err)
+	}
+
 	// As a last resort, write a small inline PNG with base64 to the terminal (rarely supported).
 	debugf("falling back to inline PNG base64 sequence as last resort")
 	enc := base64.StdEncoding.EncodeToString(data)
@@ -494,6 +430,25 @@ func sendSixelPNG(data []byte) error {
 	return err
 }
 
+// sendMagickSixel converts the provided PNG bytes to sixel with ImageMagick's
+// own SIXEL coder and writes them to stdout.
+func sendMagickSixel(data []byte) error {
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+	if err := mw.ReadImageBlob(data); err != nil {
+		return fmt.Errorf("read preview PNG: %w", err)
+	}
+	if err := mw.SetImageFormat("SIXEL"); err != nil {
+		return fmt.Errorf("SIXEL coder unavailable: %w", err)
+	}
+	sixel, err := mw.GetImageBlob()
+	if err != nil {
+		return fmt.Errorf("encode sixel: %w", err)
+	}
+	_, err = os.Stdout.Write(sixel)
+	return err
+}
+
 // sendChafaPNG invokes chafa to render the provided PNG bytes to stdout.
 // It attempts to choose reasonable flags to produce a block-symbol rendering that
 // works in many terminals. The function returns an error if chafa is not present or fails.
@@ -508,20 +463,22 @@ func sendChafaPNG(data []byte) error {
 	}
 
 	// Ensure chafa exists
-	if _, err := exec.LookPath("chafa"); err != nil {
-		return fmt.Errorf("chafa not found in PATH: %w", err)
+	if err := requireProgram("chafa", "chafa"); err != nil {
+		return err
 	}
 
 	debugf("sendChafaPNG invoking chafa for %d bytes", len(data))
 
 	// Determine chafa args. Use block fill and symbols for dense output.
-	// Default size is 80x40; user can override via CHAFA_SIZE.
-	args := []string{"--fill=block", "--symbols=block", "-s", "80x40", "-"}
-
+	// Default size is 80x40 or the config file's preview size; user can
+	// override via CHAFA_SIZE.
+	cols, rows := previewSize(80, 40)
+	size := fmt.Sprintf("%dx%d", cols, rows)
 	if v := os.Getenv("CHAFA_SIZE"); v != "" {
 		// If the user provides a size override, pass it through to -s.
-		args = []string{"--fill=block", "--symbols=block", "-s", v, "-"}
+		size = v
 	}
+	args := []string{"--fill=block", "--symbols=block", "-s", size, "-"}
 
 	// Allow custom fill/symbol selection via env (optional)
 	if f := os.Getenv("CHAFA_FILL"); f != "" {
@@ -540,7 +497,10 @@ func sendChafaPNG(data []byte) error {
 		}
 	}
 
-	cmd := exec.Command("chafa", args...)
+	cmd, err := externalCommand("chafa", args...)
+	if err != nil {
+		return err
+	}
 	cmd.Stdin = bytes.NewReader(data)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -553,6 +513,9 @@ func sendChafaPNG(data []byte) error {
 	// Use CHAFA_SIZE hint if provided; otherwise advance only a small number
 	// of lines so the prompt prints just under the rendered output.
 	sizeRows := 0
+	if config.PreviewRows > 0 {
+		sizeRows = rows
+	}
 	if v := os.Getenv("CHAFA_SIZE"); v != "" {
 		parts := strings.Split(v, "x")
 		if len(parts) == 2 {
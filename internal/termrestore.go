@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"os"
+	"sync"
+)
+
+// Terminal restoration.
+//
+// termagick changes the terminal while it runs: raw input for keys, mouse
+// reporting while picking points, and long graphics escape sequences for
+// previews. Normally each change is undone right after use, but a panic or a
+// termination signal in the middle of one would leave the shell garbled:
+// keys not echoed, mouse clicks printed as escape codes, or every byte
+// swallowed by an unfinished kitty or sixel image. resetTerminal puts the
+// terminal back into the mode it had when termagick started and closes
+// whatever sequence may be open.
+
+// terminalGuard holds the terminal mode saved by guardTerminal.
+var terminalGuard struct {
+	sync.Mutex
+	restoreMode func()
+}
+
+// terminalResetSequence ends an unfinished escape sequence (ST closes the
+// APC, DCS and OSC strings of kitty, sixel and iTerm2 images, and is ignored
+// elsewhere), turns mouse reporting off, resets colors and shows the cursor.
+const terminalResetSequence = "\x1b\\" + mouseOff + "\x1b[0m\x1b[?25h"
+
+// guardTerminal saves the current terminal mode for resetTerminal. It is
+// called once at startup, before the mode is changed.
+func guardTerminal() {
+	terminalGuard.Lock()
+	defer terminalGuard.Unlock()
+	terminalGuard.restoreMode = saveTerminalMode()
+}
+
+// resetTerminal restores the terminal after a crash or termination: it
+// closes open escape sequences, deletes kitty images whose transfer may have
+// been cut off, and restores the saved terminal mode.
+func resetTerminal() {
+	terminalGuard.Lock()
+	defer terminalGuard.Unlock()
+	if isTerminal(os.Stdout) {
+		seq := terminalResetSequence
+		if isKitty() {
+			seq += "\x1b_Ga=d\x1b\\"
+		}
+		os.Stdout.WriteString("\n" + seq)
+	}
+	if terminalGuard.restoreMode != nil {
+		terminalGuard.restoreMode()
+	}
+}
+
+// recoverTerminal resets the terminal when the calling function panics, then
+// lets the panic continue so its message and stack trace are printed to a
+// usable shell. It must be deferred directly.
+func recoverTerminal() {
+	if r := recover(); r != nil {
+		resetTerminal()
+		panic(r)
+	}
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Text rendering with Unicode fallback.
+//
+// ImageMagick's annotate draws with exactly one FreeType font and no shaping,
+// so emoji and CJK text come out as empty boxes with most default fonts. Before
+// drawing, the chosen font's coverage is checked with fontconfig. Missing
+// glyphs are handled by rendering through Pango when ImageMagick was built with
+// it (full shaping and per-glyph fallback), otherwise by switching to the first
+// configured fallback font that covers the text, with a warning either way.
+
+// defaultFontFallbacks is used when TERMAGICK_FONT_FALLBACK is not set.
+var defaultFontFallbacks = []string{"Noto Sans", "Noto Sans CJK SC", "Noto Color Emoji", "DejaVu Sans", "Symbola"}
+
+// fontFallbacks returns the fallback font list. TERMAGICK_FONT_FALLBACK takes a
+// comma-separated list of font families or font file paths.
+func fontFallbacks() []string {
+	v := os.Getenv("TERMAGICK_FONT_FALLBACK")
+	if v == "" {
+		return defaultFontFallbacks
+	}
+	var fonts []string
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fonts = append(fonts, f)
+		}
+	}
+	return fonts
+}
+
+// pangoAvailable reports whether ImageMagick can render text via the pango: coder.
+func pangoAvailable() bool {
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+	return len(mw.QueryFormats("PANGO")) > 0
+}
+
+// fontCharset resolves font (a family name or a font file; "" means the
+// default sans-serif) with fontconfig and returns the code point ranges it
+// covers.
+func fontCharset(font string) ([][2]rune, error) {
+	if font == "" {
+		font = "sans-serif"
+	}
+	file := font
+	if _, err := os.Stat(font); err != nil {
+		cmd, err := externalCommand("fc-match", "-f", "%{file}", font)
+		if err != nil {
+			return nil, err
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("fc-match %q: %w", font, err)
+		}
+		file = strings.TrimSpace(string(out))
+	}
+	cmd, err := externalCommand("fc-query", "-i", "0", "-f", "%{charset}", file)
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("fc-query %s: %w", file, err)
+	}
+	// The charset is printed as space-separated hex code points and ranges, e.g. "20-7e a0-17f".
+	var ranges [][2]rune
+	for _, field := range strings.Fields(string(out)) {
+		lo, hi, isRange := strings.Cut(field, "-")
+		if !isRange {
+			hi = lo
+		}
+		l, err1 := strconv.ParseUint(lo, 16, 32)
+		h, err2 := strconv.ParseUint(hi, 16, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ranges = append(ranges, [2]rune{rune(l), rune(h)})
+	}
+	return ranges, nil
+}
+
+// missingGlyphs returns the distinct characters of text that font cannot draw.
+// Whitespace and invisible joiners/variation selectors are ignored since no
+// font has visible glyphs for them.
+func missingGlyphs(font, text string) ([]rune, error) {
+	ranges, err := fontCharset(font)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[rune]bool{}
+	var missing []rune
+	for _, r := range text {
+		if seen[r] || unicode.IsSpace(r) || unicode.IsControl(r) || unicode.In(r, unicode.Cf, unicode.Mn, unicode.Variation_Selector) {
+			continue
+		}
+		seen[r] = true
+		covered := false
+		for _, rg := range ranges {
+			if r >= rg[0] && r <= rg[1] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, r)
+		}
+	}
+	return missing, nil
+}
+
+// DrawText draws text on the current image with its baseline origin at (x, y).
+// When markup is set the text is Pango markup (e.g. "<b>bold</b> text"), which
+// requires ImageMagick built with Pango.
+func DrawText(wand *imagick.MagickWand, text, font string, size, x, y float64, color string, markup bool) error {
+	usePango := markup
+	if markup && !pangoAvailable() {
+		return fmt.Errorf("markup requires ImageMagick built with Pango support")
+	}
+
+	if !markup {
+		missing, err := missingGlyphs(font, text)
+		// Without fontconfig the check is skipped and the font is used as given.
+		if err == nil && len(missing) > 0 {
+			fontName := font
+			if fontName == "" {
+				fontName = "default font"
+			}
+			switch {
+			case pangoAvailable():
+				usePango = true
+			default:
+				fallback := ""
+				for _, f := range fontFallbacks() {
+					if m, ferr := missingGlyphs(f, text); ferr == nil && len(m) == 0 {
+						fallback = f
+						break
+					}
+				}
+				if fallback == "" {
+					fmt.Fprintf(os.Stderr, "warning: %s lacks glyphs for %q and no fallback font covers them; they may render as boxes (set TERMAGICK_FONT_FALLBACK)\n", fontName, string(missing))
+				} else {
+					fmt.Fprintf(os.Stderr, "warning: %s lacks glyphs for %q; using %s\n", fontName, string(missing), fallback)
+					font = fallback
+				}
+			}
+		}
+	}
+
+	if usePango {
+		body := text
+		if !markup {
+			body = html.EscapeString(text)
+		}
+		return drawPangoText(wand, body, font, size, x, y, color)
+	}
+
+	dw := imagick.NewDrawingWand()
+	defer dw.Destroy()
+	if font != "" {
+		dw.SetFont(font)
+	}
+	dw.SetFontSize(size)
+	fill := imagick.NewPixelWand()
+	defer fill.Destroy()
+	fill.SetColor(color)
+	dw.SetFillColor(fill)
+
+	return wand.AnnotateImage(dw, x, y, 0.0, text)
+}
+
+// drawPangoText renders Pango markup on a transparent canvas and composites it
+// so that the first line's baseline lands approximately at (x, y).
+func drawPangoText(wand *imagick.MagickWand, markup, font string, size, x, y float64, color string) error {
+	attrs := fmt.Sprintf(`foreground="%s"`, html.EscapeString(color))
+	if font != "" {
+		attrs += fmt.Sprintf(` font_family="%s"`, html.EscapeString(font))
+	}
+
+	tw := imagick.NewMagickWand()
+	defer tw.Destroy()
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	bg.SetColor("none")
+	if err := tw.SetBackgroundColor(bg); err != nil {
+		return fmt.Errorf("failed to set text background: %w", err)
+	}
+	if err := tw.SetPointsize(size); err != nil {
+		return fmt.Errorf("failed to set text size: %w", err)
+	}
+	if err := tw.ReadImage("pango:<span " + attrs + ">" + markup + "</span>"); err != nil {
+		return fmt.Errorf("pango rendering failed: %w", err)
+	}
+	// Pango draws from the top of the line box; shift up by roughly one ascent.
+	return wand.CompositeImage(tw, imagick.COMPOSITE_OP_OVER, true, int(x), int(y-size))
+}
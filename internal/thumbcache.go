@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Thumbnail cache.
+//
+// The file selector previews each image as the cursor reaches it, which for
+// a directory of 40 MP photos means decoding every full-size file again on
+// every visit. Thumbnails are therefore cached on disk, in termagick's
+// directory under the user cache directory ($XDG_CACHE_HOME or ~/.cache on
+// Linux), keyed by the file's absolute path, size and modification time, so
+// an edited file gets a fresh thumbnail. The previewer runs
+// `termagick thumbnail <file>` to get the cached copy.
+
+// thumbnailSize bounds the longer side of cached thumbnails, in pixels.
+const thumbnailSize = 512
+
+// thumbnailMaxAge is how long an unused thumbnail is kept.
+const thumbnailMaxAge = 60 * 24 * time.Hour
+
+// thumbnailCacheDir returns the directory thumbnails are cached in.
+func thumbnailCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "termagick", "thumbnails"), nil
+}
+
+// thumbnailPath returns where the thumbnail of the file at path is cached.
+func thumbnailPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	dir, err := thumbnailCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", abs, info.Size(), info.ModTime().UnixNano())))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".png"), nil
+}
+
+// cachedThumbnail returns the path of a cached thumbnail of the image at
+// path, creating it first if needed.
+func cachedThumbnail(path string) (string, error) {
+	thumb, err := thumbnailPath(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(thumb); err == nil {
+		// Mark the thumbnail as used so pruning keeps it.
+		now := time.Now()
+		os.Chtimes(thumb, now, now)
+		return thumb, nil
+	}
+
+	wand := imagick.NewMagickWand()
+	defer wand.Destroy()
+	// Let the JPEG decoder scale down while decoding, much faster than
+	// reading the full image.
+	wand.SetOption("jpeg:size", fmt.Sprintf("%dx%d", thumbnailSize*2, thumbnailSize*2))
+	if err := wand.ReadImage(path + "[0]"); err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	wand.AutoOrientImage()
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	if long := max(w, h); long > thumbnailSize {
+		scale := float64(thumbnailSize) / float64(long)
+		if err := wand.ThumbnailImage(max(1, uint(float64(w)*scale)), max(1, uint(float64(h)*scale))); err != nil {
+			return "", fmt.Errorf("failed to scale %s: %w", path, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(thumb), 0755); err != nil {
+		return "", fmt.Errorf("create thumbnail cache: %w", err)
+	}
+	// Write under a temporary name and rename, so a previewer running at the
+	// same time never reads a partial file.
+	tmp := fmt.Sprintf("%s.%d.tmp", thumb, os.Getpid())
+	if err := wand.WriteImage("png:" + tmp); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("write thumbnail: %w", err)
+	}
+	if err := os.Rename(tmp, thumb); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("write thumbnail: %w", err)
+	}
+	return thumb, nil
+}
+
+// warmThumbnails prunes the cache, then creates the cached thumbnails of
+// files in order until ctx is done, so the previews of a directory seen for
+// the first time are ready by the time the cursor gets to them.
+func warmThumbnails(ctx context.Context, files []string) {
+	pruneThumbnailCache()
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := cachedThumbnail(f); err != nil {
+			debugf("thumbnail %s: %v", f, err)
+		}
+	}
+}
+
+// pruneThumbnailCache removes thumbnails unused for thumbnailMaxAge, and
+// temporary files left by interrupted writes.
+func pruneThumbnailCache() {
+	dir, err := thumbnailCacheDir()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		age := time.Since(info.ModTime())
+		switch filepath.Ext(e.Name()) {
+		case ".png":
+			if age < thumbnailMaxAge {
+				continue
+			}
+		case ".tmp":
+			// Recent ones may still be being written.
+			if age < time.Hour {
+				continue
+			}
+		}
+		os.Remove(filepath.Join(dir, e.Name()))
+	}
+}
+
+// RunThumbnail implements `termagick thumbnail <file>`, which prints the path
+// of the cached thumbnail of file, creating it if needed. The file selector's
+// previewer uses it.
+func RunThumbnail(args []string) error {
+	if len(args) != 1 {
+		return withExitCode(exitUsage, fmt.Errorf("usage: termagick thumbnail <file>"))
+	}
+	thumb, err := cachedThumbnail(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(thumb)
+	return nil
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Tiles.
+//
+// tiles cuts the whole image into a grid, for map tiles, print posters and
+// slicing artwork into pieces. Unlike sliceSheet, which cuts equal cells out
+// of a sprite sheet and drops what is left over, every pixel ends up in
+// exactly one tile: a GRID of M x N tiles spreads the remainder of an uneven
+// division over the tiles (they differ by at most a pixel), and with a tile
+// SIZE the last column and row hold the partial tiles.
+
+// tileModes are the values of the tiles command's mode parameter, in
+// EnumOptions order.
+var tileModes = []string{"GRID", "SIZE"}
+
+// tileEdges returns the n+1 boundaries that split length into n nearly equal
+// parts.
+func tileEdges(length, n uint) []int {
+	edges := make([]int, n+1)
+	for i := range edges {
+		edges[i] = int(uint(i) * length / n)
+	}
+	return edges
+}
+
+// sizeEdges returns the boundaries of size-pixel tiles over length, the last
+// tile holding the remainder.
+func sizeEdges(length, size uint) []int {
+	var edges []int
+	for p := uint(0); p < length; p += size {
+		edges = append(edges, int(p))
+	}
+	return append(edges, int(length))
+}
+
+// SplitTiles writes the tiles of the current image of wand to outDir as
+// tile_<row>_<column>.<format>, counting from 0. In GRID mode across and
+// down are the number of columns and rows, in SIZE mode the tile width and
+// height in pixels. It returns the grid size.
+func SplitTiles(wand *imagick.MagickWand, outDir, mode string, across, down uint, format string) (cols, rows int, err error) {
+	if across == 0 || down == 0 {
+		return 0, 0, fmt.Errorf("the grid must be at least 1x1")
+	}
+	w, h := wand.GetImageWidth(), wand.GetImageHeight()
+	var xs, ys []int
+	if mode == "SIZE" {
+		xs, ys = sizeEdges(w, across), sizeEdges(h, down)
+	} else {
+		if across > w || down > h {
+			return 0, 0, fmt.Errorf("a %dx%d grid does not fit in the %dx%d image", across, down, w, h)
+		}
+		xs, ys = tileEdges(w, across), tileEdges(h, down)
+	}
+	cols, rows = len(xs)-1, len(ys)-1
+	format = strings.TrimPrefix(strings.ToLower(format), ".")
+	if format == "" {
+		format = "png"
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return 0, 0, fmt.Errorf("create %s: %w", outDir, err)
+	}
+
+	// Zero-pad the numbers so the files sort in reading order.
+	rowDigits, colDigits := len(fmt.Sprint(rows-1)), len(fmt.Sprint(cols-1))
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			tile := wand.GetImage()
+			tw, th := uint(xs[c+1]-xs[c]), uint(ys[r+1]-ys[r])
+			if err := tile.CropImage(tw, th, xs[c], ys[r]); err != nil {
+				tile.Destroy()
+				return cols, rows, fmt.Errorf("failed to crop tile %d,%d: %w", r, c, err)
+			}
+			tile.ResetImagePage("")
+			path := filepath.Join(outDir, fmt.Sprintf("tile_%0*d_%0*d.%s", rowDigits, r, colDigits, c, format))
+			err := WriteWand(tile, path)
+			tile.Destroy()
+			if err != nil {
+				return cols, rows, fmt.Errorf("failed to write %s: %w", path, err)
+			}
+		}
+	}
+	return cols, rows, nil
+}
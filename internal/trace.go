@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Command tracing.
+//
+// With --trace every applied command is logged to stderr with its normalized
+// arguments, how long it took, and the image it left behind (size, frames and
+// ImageMagick's memory in use), in the interactive editor as well as in apply,
+// hotfolder, rpc and background exports. It is meant for finding the slow
+// steps of a recipe.
+
+// traceCommands is set by --trace.
+var traceCommands bool
+
+// addTraceFlag registers --trace on a subcommand's flag set.
+func addTraceFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&traceCommands, "trace", false, "log each applied command with its arguments, duration and resulting size to stderr")
+}
+
+// traceStep runs apply and, when tracing, logs step with its duration and the
+// state of the image returned by result afterwards.
+func traceStep(step RecipeStep, result func() *imagick.MagickWand, apply func() error) error {
+	if !traceCommands {
+		return apply()
+	}
+	start := time.Now()
+	err := apply()
+	elapsed := time.Since(start)
+
+	state := "no image"
+	if wand := result(); wand != nil && wand.GetNumberImages() > 0 {
+		state = fmt.Sprintf("%dx%d", wand.GetImageWidth(), wand.GetImageHeight())
+		if n := wand.GetNumberImages(); n > 1 {
+			state += fmt.Sprintf(" x%d frames", n)
+		}
+	}
+	mem := float64(imagick.GetResource(imagick.RESOURCE_MEMORY)) / (1 << 20)
+	status := ""
+	if err != nil {
+		status = "  FAILED: " + err.Error()
+	}
+	fmt.Fprintf(os.Stderr, "trace: %-40s %10s  %-18s mem %.1f MiB%s\n", step, elapsed.Round(time.Microsecond), state, mem, status)
+	return err
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Transparent colors.
+//
+// transparent knocks out every pixel close to a target color, the quick way
+// to drop a green screen or the white background of a product shot or a
+// scanned logo. Unlike floodfillPaint it matches pixels anywhere in the
+// image, not only those connected to a starting point, so a white background
+// also takes the white inside letters; fill those back or use a lower fuzz.
+// removeBackground is the connected variant for the common product photo:
+// it floods from each corner over the background color and stops at the
+// subject, so white parts of the subject stay. Either result keeps its
+// transparency only in formats with an alpha channel, such as PNG, WebP or
+// GIF.
+
+// MakeTransparent makes the pixels of wand within fuzz percent of color fully
+// transparent, or with invert every pixel that is not.
+func MakeTransparent(wand *imagick.MagickWand, color string, fuzz float64, invert bool) error {
+	if fuzz < 0 || fuzz > 100 {
+		return fmt.Errorf("fuzz must be between 0 and 100")
+	}
+	target := imagick.NewPixelWand()
+	defer target.Destroy()
+	if !target.SetColor(color) {
+		return fmt.Errorf("invalid color %q", color)
+	}
+	// Fuzz is a distance in quantum units; the command takes a percentage
+	// like magick's -fuzz.
+	_, quantumRange := imagick.GetQuantumRange()
+	if err := wand.TransparentPaintImage(target, 0, fuzz/100*float64(quantumRange), invert); err != nil {
+		return fmt.Errorf("failed to make %s transparent: %w", color, err)
+	}
+	return nil
+}
+
+// RemoveBackground makes the background of wand transparent: from each of
+// the four corners, the pixels connected to it within fuzz percent of its
+// color. A feather radius above 0 blurs the edge of the cut-out by that many
+// pixels, softening jagged outlines.
+func RemoveBackground(wand *imagick.MagickWand, fuzz, feather float64) error {
+	if fuzz < 0 || fuzz > 100 {
+		return fmt.Errorf("fuzz must be between 0 and 100")
+	}
+	if feather < 0 {
+		return fmt.Errorf("feather must not be negative")
+	}
+	// Flood fills paint the alpha channel only when there is one.
+	if err := wand.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET); err != nil {
+		return fmt.Errorf("failed to add alpha: %w", err)
+	}
+	none := imagick.NewPixelWand()
+	defer none.Destroy()
+	none.SetColor("none")
+	_, quantumRange := imagick.GetQuantumRange()
+	w, h := int(wand.GetImageWidth()), int(wand.GetImageHeight())
+	for _, c := range [][2]int{{0, 0}, {w - 1, 0}, {0, h - 1}, {w - 1, h - 1}} {
+		corner, err := wand.GetImagePixelColor(c[0], c[1])
+		if err != nil {
+			return fmt.Errorf("failed to read corner %d,%d: %w", c[0], c[1], err)
+		}
+		// A corner an earlier fill reached is already done. Otherwise the
+		// corner's color is the target the fill spreads over.
+		if corner.GetAlpha() > 0 {
+			err = wand.FloodfillPaintImage(none, fuzz/100*float64(quantumRange), corner, c[0], c[1], false)
+		}
+		corner.Destroy()
+		if err != nil {
+			return fmt.Errorf("failed to fill from corner %d,%d: %w", c[0], c[1], err)
+		}
+	}
+	if feather == 0 {
+		return nil
+	}
+	prev := wand.SetImageChannelMask(imagick.CHANNEL_ALPHA)
+	err := wand.BlurImage(0, feather)
+	wand.SetImageChannelMask(prev)
+	if err != nil {
+		return fmt.Errorf("failed to feather the edge: %w", err)
+	}
+	return nil
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"regexp"
 	"sort"
 	"strings"
@@ -126,8 +125,33 @@ func detectLatestFallback(repo string) (*selfupdate.Release, bool, error) {
 	return r, true, nil
 }
 
+// updateRepo is the GitHub repository releases are published to.
+const updateRepo = "Fepozopo/termagick"
+
+// NotifyUpdate prints a one-line notice when a newer release than the running
+// version exists. Unlike CheckForUpdates it never prompts or installs
+// anything, and failures are only reported in debug mode. It is run at
+// startup when the config file sets updates.check = "startup".
+func NotifyUpdate() {
+	latest, found, err := detectLatestFallback(updateRepo)
+	if err != nil || !found || latest == nil {
+		debugf("startup update check: found=%v err=%v", found, err)
+		return
+	}
+	current, err := semver.Parse(Version)
+	if err != nil || !latest.Version.GT(current) {
+		return
+	}
+	// This runs in its own goroutine, so the key binding is read like a job
+	// reads the settings.
+	userFiles.RLock()
+	key := keyName(boundKey("update"))
+	userFiles.RUnlock()
+	fmt.Printf("\ntermagick %s is available (running %s); press '%s' to update\n> ", latest.Version, Version, key)
+}
+
 func CheckForUpdates() error {
-	const repo = "Fepozopo/termagick"
+	repo := updateRepo
 
 	// Use the GitHub API fallback detector which is tolerant of tag naming.
 	latest, found, err := detectLatestFallback(repo)
@@ -189,11 +213,18 @@ func CheckForUpdates() error {
 		return fmt.Errorf("update failed: %w", err)
 	}
 
+	if config.NoExec {
+		fmt.Printf("Updated to version %s. Please restart the application.\n", latest.Version)
+		return nil
+	}
+
 	// Attempt to restart the process by replacing the current process image.
 	argv := append([]string{exe}, os.Args[1:]...)
+	// The new process gets the same ID and would never sweep this one's files.
+	removeSessionTempDir()
 	if err := syscall.Exec(exe, argv, os.Environ()); err != nil {
 		// Exec only returns on error. Try a fallback of starting the new binary as a child process.
-		cmd := exec.Command(exe, os.Args[1:]...)
+		cmd, _ := externalCommand(exe, os.Args[1:]...)
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
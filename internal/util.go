@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -89,8 +90,68 @@ func PromptLineWithFzf(prompt string) (string, error) {
 	return PromptLineOrFzf(prompt)
 }
 
-// GetImageInfo returns a string with basic info about the image in the wand
-func GetImageInfo(wand *imagick.MagickWand) (string, error) {
+// PromptLineWithFzfMulti works like PromptLineOrFzf but entering "/" opens fzf
+// in multi-select mode. The selected paths are joined with the OS path list
+// separator (':' on Unix, ';' on Windows) so they can travel as a single
+// argument; expandFileList splits them again.
+func PromptLineWithFzfMulti(prompt string) (string, error) {
+	input, err := PromptLine(prompt)
+	if err != nil {
+		return "", err
+	}
+	if input == "/" {
+		sel, selErr := SelectFilesWithFzf(".")
+		if selErr == nil && len(sel) > 0 {
+			fmt.Printf(" [fzf] %d files selected\n", len(sel))
+			return strings.Join(sel, string(os.PathListSeparator)), nil
+		}
+		return PromptLine(prompt)
+	}
+	return input, nil
+}
+
+// expandFileList turns a file-list argument into paths. The argument is split
+// on the OS path list separator; each entry containing glob metacharacters is
+// expanded (matches are sorted, as returned by filepath.Glob) and each
+// directory is replaced by the image files it contains, in name order.
+func expandFileList(arg string) ([]string, error) {
+	var paths []string
+	for _, entry := range filepath.SplitList(arg) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if info, err := os.Stat(entry); err == nil && info.IsDir() {
+			dirEntries, err := os.ReadDir(entry)
+			if err != nil {
+				return nil, fmt.Errorf("read directory %s: %w", entry, err)
+			}
+			for _, de := range dirEntries {
+				if !de.IsDir() && isImageFile(de.Name()) {
+					paths = append(paths, filepath.Join(entry, de.Name()))
+				}
+			}
+			continue
+		}
+		if !strings.ContainsAny(entry, "*?[") {
+			paths = append(paths, entry)
+			continue
+		}
+		matches, err := filepath.Glob(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", entry, err)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files match %q", arg)
+	}
+	return paths, nil
+}
+
+// imageSummary returns the format, size and compression of the image in the
+// wand, as printed under each preview. GetImageInfo has the full panel.
+func imageSummary(wand *imagick.MagickWand) (string, error) {
 	if wand == nil {
 		return "", fmt.Errorf("nil wand")
 	}
@@ -111,3 +172,36 @@ func GetImageInfo(wand *imagick.MagickWand) (string, error) {
 
 	return fmt.Sprintf("Format: %s, Width: %d, Height: %d\nCompression: %s, Compression Quality: %v", format, width, height, compressionName, compressionQuality), nil
 }
+
+// InspectPixel describes the color of the pixel at x, y of the current image:
+// hex, 8-bit RGB, alpha and HSL.
+func InspectPixel(wand *imagick.MagickWand, x, y int) (string, error) {
+	if x < 0 || y < 0 || x >= int(wand.GetImageWidth()) || y >= int(wand.GetImageHeight()) {
+		return "", fmt.Errorf("point %d,%d is outside the %dx%d image", x, y, wand.GetImageWidth(), wand.GetImageHeight())
+	}
+	px, err := wand.GetImagePixelColor(x, y)
+	if err != nil {
+		return "", fmt.Errorf("failed to read pixel: %w", err)
+	}
+	defer px.Destroy()
+	r := int(px.GetRed()*255 + 0.5)
+	g := int(px.GetGreen()*255 + 0.5)
+	b := int(px.GetBlue()*255 + 0.5)
+	h, s, l := px.GetHSL()
+	return fmt.Sprintf("Pixel %d,%d: #%02X%02X%02X  rgb(%d,%d,%d)  alpha %.2f  hsl(%.0f,%.0f%%,%.0f%%)", x, y, r, g, b, r, g, b, px.GetAlpha(), h*360, s*100, l*100), nil
+}
+
+// imageExtensions lists the file extensions termagick treats as images when
+// scanning directories (hotfolder, file selection, batch processing).
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".tif", ".tiff", ".webp", ".bmp"}
+
+// isImageFile reports whether path has one of the known image extensions.
+func isImageFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range imageExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
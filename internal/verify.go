@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// RunVerifyAgainst implements
+// `termagick verify-against <golden-dir> <output-dir> [--ssim n] [--psnr dB] [--diff dir]`.
+// It compares every image under golden-dir with the file at the same
+// relative path under output-dir and fails when any is missing, differs in
+// size or frame count, or scores below a threshold, so a pipeline's output
+// can be checked for visual regressions in CI.
+func RunVerifyAgainst(args []string) error {
+	fs := flag.NewFlagSet("verify-against", flag.ContinueOnError)
+	minSSIM := fs.Float64("ssim", 0.99, "minimum SSIM (0-1) a file must reach; 0 disables the check")
+	minPSNR := fs.Float64("psnr", 0, "minimum PSNR in dB a file must reach; 0 disables the check")
+	diffDir := fs.String("diff", "", "write a difference image for each regressed file to this directory")
+	var include, exclude stringsFlag
+	fs.Var(&include, "include", "only compare files whose name or relative path matches this glob (repeatable; default: all images)")
+	fs.Var(&exclude, "exclude", "skip files or directories whose name or relative path matches this glob (repeatable)")
+	addTraceFlag(fs)
+	addQuietFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return withExitCode(exitUsage, fmt.Errorf("usage: termagick verify-against <golden-dir> <output-dir> [--ssim n] [--psnr dB] [--diff dir]"))
+	}
+	if *minSSIM < 0 || *minSSIM > 1 {
+		return withExitCode(exitUsage, fmt.Errorf("--ssim must be between 0 and 1"))
+	}
+	for _, g := range append(append([]string(nil), include...), exclude...) {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return withExitCode(exitUsage, fmt.Errorf("invalid glob %q: %w", g, err))
+		}
+	}
+	golden, err := filepath.Abs(positional[0])
+	if err != nil {
+		return fmt.Errorf("resolve golden dir: %w", err)
+	}
+	output, err := filepath.Abs(positional[1])
+	if err != nil {
+		return fmt.Errorf("resolve output dir: %w", err)
+	}
+
+	files, err := batchFiles(golden, output, include, exclude)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return withExitCode(exitRead, fmt.Errorf("no files in %s match", golden))
+	}
+
+	var failed []string
+	var errs []error
+	for _, rel := range files {
+		m, err := verifyFile(filepath.Join(golden, rel), filepath.Join(output, rel), *diffDir, rel, *minSSIM, *minPSNR)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", rel, err)
+			failed = append(failed, rel)
+			// A missing, unreadable or different output is a regression.
+			errs = append(errs, withExitCode(exitValidation, err))
+			continue
+		}
+		fmt.Printf("ok   %s: %s\n", rel, m)
+	}
+
+	fmt.Printf("\nCompared %d files: %d passed, %d failed.\n", len(files), len(files)-len(failed), len(failed))
+	if len(failed) > 0 {
+		return withExitCode(commonExitCode(errs), fmt.Errorf("%d of %d files regressed", len(failed), len(files)))
+	}
+	return nil
+}
+
+// verifyFile compares the output file with its golden counterpart and returns
+// the metrics, or an error describing why the output does not pass. When
+// diffDir is set, a regressed file's difference image is written there.
+func verifyFile(goldenPath, outputPath, diffDir, rel string, minSSIM, minPSNR float64) (imageMetrics, error) {
+	if _, err := os.Stat(outputPath); err != nil {
+		return imageMetrics{}, fmt.Errorf("missing from the output")
+	}
+	ref, err := LoadImage(goldenPath)
+	if err != nil {
+		return imageMetrics{}, withExitCode(exitRead, fmt.Errorf("read golden file: %w", err))
+	}
+	defer ref.Destroy()
+	got, err := LoadImage(outputPath)
+	if err != nil {
+		return imageMetrics{}, fmt.Errorf("read output file: %w", err)
+	}
+	defer got.Destroy()
+
+	m, err := compareSequences(got, ref)
+	if err != nil {
+		return imageMetrics{}, err
+	}
+	var problems []string
+	if minSSIM > 0 && m.SSIM < minSSIM {
+		problems = append(problems, fmt.Sprintf("SSIM %.4f < %g", m.SSIM, minSSIM))
+	}
+	if minPSNR > 0 && m.PSNR < minPSNR {
+		problems = append(problems, fmt.Sprintf("PSNR %.2f dB < %g dB", m.PSNR, minPSNR))
+	}
+	if len(problems) == 0 {
+		return m, nil
+	}
+	if diffDir != "" {
+		if err := writeDifference(got, ref, filepath.Join(diffDir, rel)); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return m, fmt.Errorf("%s (%s)", strings.Join(problems, ", "), m)
+}
+
+// writeDifference saves the difference image of the first frames of got and
+// ref to path, as PNG.
+func writeDifference(got, ref *imagick.MagickWand, path string) error {
+	got.SetIteratorIndex(0)
+	ref.SetIteratorIndex(0)
+	diff, err := differenceImage(got, ref)
+	if err != nil {
+		return err
+	}
+	defer diff.Destroy()
+	path = strings.TrimSuffix(path, filepath.Ext(path)) + ".diff.png"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create diff dir: %w", err)
+	}
+	if err := diff.WriteImage("png:" + path); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
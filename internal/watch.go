@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// RunWatch implements `termagick watch <input> --recipe <file> --out <file>`.
+// It applies the recipe to input and writes the result to out once at start,
+// then again whenever the input or the recipe changes, so a processed copy of
+// a file being edited in another program is always up to date. It runs until
+// interrupted with Ctrl-C.
+func RunWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	outPath := fs.String("out", "", "file the result is written to (required)")
+	recipePath := fs.String("recipe", "", "recipe file to apply (required)")
+	preview := fs.Bool("preview", true, "preview the result in the terminal after each run")
+	addTraceFlag(fs)
+	addNoExecFlag(fs)
+	addQuietFlag(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return withExitCode(exitUsage, fmt.Errorf("usage: termagick watch <input> --recipe <file> --out <file>"))
+	}
+	if *outPath == "" || *recipePath == "" {
+		return withExitCode(exitUsage, fmt.Errorf("watch requires --recipe <file> and --out <file>"))
+	}
+
+	input, err := filepath.Abs(positional[0])
+	if err != nil {
+		return fmt.Errorf("resolve input: %w", err)
+	}
+	recipe, err := filepath.Abs(*recipePath)
+	if err != nil {
+		return fmt.Errorf("resolve recipe: %w", err)
+	}
+	out, err := filepath.Abs(*outPath)
+	if err != nil {
+		return fmt.Errorf("resolve output: %w", err)
+	}
+	// Writing over the input would re-trigger the watcher on our own output.
+	if out == input {
+		return fmt.Errorf("output must differ from the input")
+	}
+
+	steps, err := LoadRecipe(recipe)
+	if err != nil {
+		return err
+	}
+	store := NewMetaStore(Commands)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	defer watcher.Close()
+	// Watch the directories rather than the files: many programs save by
+	// writing a new file and renaming it over the old one, which ends a
+	// watch on the file itself.
+	for _, dir := range []string{filepath.Dir(input), filepath.Dir(recipe)} {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("watch %s: %w", dir, err)
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	run := func() {
+		start := time.Now()
+		if err := processHotfolderFile(store, steps, input, out, *preview); err != nil {
+			fmt.Fprintf(os.Stderr, "watch: %v\n", err)
+			return
+		}
+		fmt.Printf("[%s] %s -> %s (%d steps, %s)\n", time.Now().Format("15:04:05"), filepath.Base(input), out, len(steps), time.Since(start).Round(time.Millisecond))
+	}
+
+	fmt.Printf("Watching %s and %s. Press Ctrl-C to stop.\n", input, recipe)
+	run()
+
+	// changed is when the input or recipe last changed, zero when up to date.
+	var changed time.Time
+	inputChanged, recipeChanged := false, false
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			fmt.Println("Watch stopped.")
+			return nil
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+				continue
+			}
+			switch filepath.Clean(ev.Name) {
+			case input:
+				changed = time.Now()
+				inputChanged = true
+			case recipe:
+				changed = time.Now()
+				recipeChanged = true
+			}
+
+		case werr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "watch error: %v\n", werr)
+
+		case now := <-ticker.C:
+			// Wait until writes settle, as in hotfolder mode.
+			if changed.IsZero() || now.Sub(changed) < hotfolderSettle {
+				continue
+			}
+			changed = time.Time{}
+			rerun := inputChanged
+			inputChanged = false
+			if recipeChanged {
+				recipeChanged = false
+				// A broken recipe keeps the last good one in use, so input
+				// changes are still processed while it is being fixed.
+				if newSteps, err := LoadRecipe(recipe); err != nil {
+					fmt.Fprintf(os.Stderr, "watch: %v (keeping the previous recipe)\n", err)
+				} else {
+					steps = newSteps
+					rerun = true
+				}
+			}
+			if rerun {
+				run()
+			}
+		}
+	}
+}
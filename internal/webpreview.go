@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Companion web preview server.
+//
+// Started with `--web-preview :8787`, it serves a small page that shows the
+// current image at full quality and reloads it whenever the editor publishes a
+// new version. Updates are pushed with Server-Sent Events, so the page needs no
+// polling and no JavaScript dependencies. This gives users on terminals without
+// a graphics protocol a real preview in a browser next to the TUI.
+
+// webPreview is the running server, or nil when the web preview is disabled.
+var webPreview *WebPreviewServer
+
+// WebPreviewServer holds the most recently published PNG and the set of
+// connected event-stream clients.
+type WebPreviewServer struct {
+	mu      sync.Mutex
+	png     []byte
+	version int
+	clients map[chan int]struct{}
+}
+
+const webPreviewPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>termagick preview</title>
+<style>
+  body { margin: 0; background: #1e1e1e; color: #ccc; font-family: sans-serif; }
+  #status { padding: 4px 8px; font-size: 12px; }
+  img { display: block; max-width: 100vw; max-height: calc(100vh - 24px); margin: 0 auto;
+        background: repeating-conic-gradient(#444 0% 25%, #555 0% 50%) 50% / 20px 20px; }
+</style>
+</head>
+<body>
+<div id="status">waiting for image…</div>
+<img id="preview" alt="">
+<script>
+  const img = document.getElementById("preview");
+  const status = document.getElementById("status");
+  function load(v) {
+    img.src = "/image.png?v=" + v;
+    status.textContent = "version " + v;
+  }
+  const es = new EventSource("/events");
+  es.onmessage = (e) => load(e.data);
+  es.onerror = () => { status.textContent = "disconnected — retrying…"; };
+</script>
+</body>
+</html>
+`
+
+// StartWebPreview starts the preview server on addr (e.g. ":8787") in the
+// background and installs it as the package-level web preview.
+func StartWebPreview(addr string) (*WebPreviewServer, error) {
+	s := &WebPreviewServer{clients: make(map[chan int]struct{})}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handlePage)
+	mux.HandleFunc("/image.png", s.handleImage)
+	mux.HandleFunc("/events", s.handleEvents)
+
+	// Listen synchronously so address errors are reported to the caller.
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("web preview listen on %s: %w", addr, err)
+	}
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "web preview server stopped: %v\n", err)
+		}
+	}()
+	webPreview = s
+	fmt.Printf("Web preview available at http://%s/\n", displayAddr(ln.Addr()))
+	return s, nil
+}
+
+// displayAddr turns a listener address into something clickable, replacing
+// the unspecified host with localhost.
+func displayAddr(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Publish encodes the current image (the active frame for animations, the
+// proxy for large images) as PNG and notifies connected browsers.
+func (s *WebPreviewServer) Publish(wand *imagick.MagickWand) error {
+	if wand == nil {
+		return fmt.Errorf("nil wand")
+	}
+	frame := previewImage(wand)
+	if frame == nil {
+		return fmt.Errorf("failed to copy image")
+	}
+	defer frame.Destroy()
+	if err := frame.SetImageFormat("PNG"); err != nil {
+		return fmt.Errorf("failed to set PNG format: %w", err)
+	}
+	blob, err := frame.GetImageBlob()
+	if err != nil {
+		return fmt.Errorf("GetImageBlob failed: %w", err)
+	}
+	s.publishPNG(blob)
+	return nil
+}
+
+// publishPNG makes blob the current image and notifies connected browsers.
+func (s *WebPreviewServer) publishPNG(blob []byte) {
+	s.mu.Lock()
+	s.png = blob
+	s.version++
+	v := s.version
+	for ch := range s.clients {
+		// Never block the editor on a slow browser; a missed version is
+		// superseded by the next one anyway.
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *WebPreviewServer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, webPreviewPage)
+}
+
+func (s *WebPreviewServer) handleImage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	blob := s.png
+	s.mu.Unlock()
+	if blob == nil {
+		http.Error(w, "no image loaded", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(blob)
+}
+
+func (s *WebPreviewServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := make(chan int, 1)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	current := s.version
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	// Send the current version immediately so a freshly opened page shows the image.
+	if current > 0 {
+		fmt.Fprintf(w, "data: %s\n\n", strconv.Itoa(current))
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case v := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", strconv.Itoa(v))
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package termagick exposes termagick's editing engine to other Go programs:
+// the command set with its parameter metadata, argument normalization, and an
+// Editor that opens, edits, previews and saves images the way the interactive
+// editor does.
+//
+//	termagick.Initialize()
+//	defer termagick.Terminate()
+//
+//	ed := termagick.NewEditor()
+//	defer ed.Close()
+//	if err := ed.Open("photo.jpg"); err != nil {
+//		return err
+//	}
+//	if err := ed.Apply("resize", "1600", "1200"); err != nil {
+//		return err
+//	}
+//	return ed.Save("photo-small.jpg")
+//
+// Commands take the same arguments as recipes: strings in parameter order,
+// with enum values by name. Like ImageMagick itself, the package needs
+// Initialize before use and Terminate when done.
+package termagick
+
+import (
+	"github.com/Fepozopo/termagick/internal"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// CommandMeta describes a command and its parameters.
+type CommandMeta = internal.CommandMeta
+
+// ParamMeta describes one parameter of a command.
+type ParamMeta = internal.ParamMeta
+
+// Step is one applied command with its normalized arguments, as in a recipe.
+type Step = internal.RecipeStep
+
+// Initialize sets up ImageMagick; call it once before using the package.
+func Initialize() {
+	imagick.Initialize()
+}
+
+// Terminate releases ImageMagick; call it once when done with the package.
+func Terminate() {
+	imagick.Terminate()
+}
+
+// Commands returns the metadata of every available command.
+func Commands() []CommandMeta {
+	return internal.Commands
+}
+
+// Command returns the metadata of the named command, or nil.
+func Command(name string) *CommandMeta {
+	return internal.GetCommandMetaByName(internal.Commands, name)
+}
+
+// NormalizeArgs validates raw arguments for a command against its metadata
+// and returns them in the canonical form commands are applied with.
+func NormalizeArgs(command string, args []string) ([]string, error) {
+	return internal.NormalizeArgs(internal.NewMetaStore(internal.Commands), command, args)
+}
+
+// LoadRecipe reads a recipe file: one command per line.
+func LoadRecipe(path string) ([]Step, error) {
+	return internal.LoadRecipe(path)
+}
+
+// Editor is an editing session: the open images, their layers and history.
+// An Editor is not safe for concurrent use.
+type Editor struct {
+	sess *internal.Session
+}
+
+// NewEditor returns an Editor without an image. Generator commands (such as
+// generateNoise) can be applied before an image is opened.
+func NewEditor() *Editor {
+	return &Editor{sess: internal.NewSession(internal.NewMetaStore(internal.Commands))}
+}
+
+// Open loads path as the image being edited, in a new buffer when another
+// image is already open.
+func (e *Editor) Open(path string) error {
+	return e.sess.OpenBuffer(path)
+}
+
+// Apply normalizes args and applies the command to the current image,
+// recording it in the history.
+func (e *Editor) Apply(command string, args ...string) error {
+	normArgs, err := internal.NormalizeArgs(e.sess.Store, command, args)
+	if err != nil {
+		return err
+	}
+	return e.sess.Apply(command, normArgs)
+}
+
+// ApplySteps applies each step in order, stopping at the first error.
+func (e *Editor) ApplySteps(steps []Step) error {
+	for _, step := range steps {
+		if err := e.Apply(step.Command, step.Args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the current image, layers flattened, to path.
+func (e *Editor) Save(path string) error {
+	return e.sess.Save(path)
+}
+
+// Preview shows the current image inline in the terminal, when supported.
+func (e *Editor) Preview() error {
+	return internal.PreviewWand(e.sess.Display())
+}
+
+// Wand returns the displayed image (layers composited). It remains owned by
+// the Editor and is only valid until the next call that changes the image.
+func (e *Editor) Wand() *imagick.MagickWand {
+	return e.sess.Display()
+}
+
+// History returns the steps applied to the current image, oldest first.
+func (e *Editor) History() []Step {
+	return append([]Step(nil), e.sess.History...)
+}
+
+// Close waits for background jobs and releases every image.
+func (e *Editor) Close() {
+	e.sess.Close()
+}